@@ -23,20 +23,58 @@ type Config struct {
 		MusicDirectory string     `yaml:"music_directory"`
 		Conf           string     `yaml:"conf"`
 		BinaryLimit    BinarySize `yaml:"binarylimit"`
+		Password       string     `yaml:"password"`
+		PasswordFile   string     `yaml:"password_file"`
+		Partition      string     `yaml:"partition"`
+		PoolSize       int        `yaml:"pool_size"`
 	} `yaml:"mpd"`
 	Server struct {
 		Addr           string `yaml:"addr"`
 		CacheDirectory string `yaml:"cache_directory"`
 		Cover          struct {
+			Local         bool     `yaml:"local"`
+			LocalFiles    []string `yaml:"local_files"`
+			LocalFallback bool     `yaml:"local_fallback"`
+			Remote        bool     `yaml:"remote"`
+			Workers       int      `yaml:"workers"`
+			ArtistFiles   []string `yaml:"artist_files"`
+			ArtistImages  string   `yaml:"artist_images"`
+			Cache         struct {
+				MaxSize BinarySize    `yaml:"max_size"`
+				MaxAge  time.Duration `yaml:"max_age"`
+			} `yaml:"cache"`
+		} `yaml:"cover"`
+		Lyrics struct {
 			Local  bool `yaml:"local"`
 			Remote bool `yaml:"remote"`
-		} `yaml:"cover"`
+		} `yaml:"lyrics"`
 	} `yaml:"server"`
 	Playlist struct {
 		Tree      map[string]*ConfigListNode `yaml:"tree"`
 		TreeOrder []string                   `yaml:"tree_order"`
 	}
-	debug bool
+	Scrobble struct {
+		ListenBrainz struct {
+			URL       string `yaml:"url"`
+			Token     string `yaml:"token"`
+			TokenFile string `yaml:"token_file"`
+		} `yaml:"listenbrainz"`
+	} `yaml:"scrobble"`
+	Servers map[string]*ConfigServer `yaml:"servers"`
+	debug   bool
+}
+
+// ConfigServer represents additional mpd server served under /api/servers/<name>/.
+// name must consist of ASCII letters, digits, '-' and '_'.
+type ConfigServer struct {
+	Network        string     `yaml:"network"`
+	Addr           string     `yaml:"addr"`
+	MusicDirectory string     `yaml:"music_directory"`
+	BinaryLimit    BinarySize `yaml:"binarylimit"`
+	Password       string     `yaml:"password"`
+	PasswordFile   string     `yaml:"password_file"`
+	Partition      string     `yaml:"partition"`
+	PoolSize       int        `yaml:"pool_size"`
 }
 
 func DefaultConfig() *Config {
@@ -45,25 +83,51 @@ func DefaultConfig() *Config {
 	c.Server.Addr = ":8080"
 	c.Server.CacheDirectory = filepath.Join(os.TempDir(), "vv")
 	c.Server.Cover.Local = true
+	c.Server.Cover.LocalFiles = []string{"cover.jpg", "cover.jpeg", "cover.webp", "cover.png", "cover.gif", "cover.bmp"}
+	c.Server.Cover.Workers = 1
+	c.Server.Cover.ArtistFiles = []string{"artist.*"}
+	c.Server.Lyrics.Local = true
+	c.Server.Lyrics.Remote = true
 	return c
 }
 
 func fillConfig(c *Config) {
-	if c.MPD.Network == "" {
-		if strings.HasPrefix(c.MPD.Addr, "/") || strings.HasPrefix(c.MPD.Addr, "@") {
-			c.MPD.Network = "unix"
+	c.MPD.Network, c.MPD.Addr = fillMPDAddr(c.MPD.Network, c.MPD.Addr)
+	for _, s := range c.Servers {
+		if s != nil {
+			s.Network, s.Addr = fillMPDAddr(s.Network, s.Addr)
+		}
+	}
+}
+
+func fillMPDAddr(network, addr string) (string, string) {
+	if network == "" {
+		if strings.HasPrefix(addr, "/") || strings.HasPrefix(addr, "@") {
+			network = "unix"
 		} else {
-			c.MPD.Network = "tcp"
+			network = "tcp"
 		}
 	}
-	if c.MPD.Addr == "" {
-		switch c.MPD.Network {
+	if addr == "" {
+		switch network {
 		case "tcp", "tcp4", "tcp6":
-			c.MPD.Addr = "localhost:6600"
+			addr = "localhost:6600"
 		case "unix":
-			c.MPD.Addr = "/var/run/mpd/socket"
+			addr = "/var/run/mpd/socket"
 		}
 	}
+	return network, addr
+}
+
+func readPasswordFile(password, file string) (string, error) {
+	if len(password) != 0 || len(file) == 0 {
+		return password, nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
 }
 
 // ParseConfig parse yaml config and flags.
@@ -95,6 +159,10 @@ func ParseConfig(dir []string, name string, args []string) (*Config, time.Time,
 	mm := flagset.String("mpd.music_directory", "", "set music_directory in mpd.conf value to search album cover image")
 	mc := flagset.String("mpd.conf", "", "set mpd.conf path to get music_directory and http audio output")
 	mb := flagset.String("mpd.binarylimit", "", "set the maximum binary response size of mpd")
+	mp := flagset.String("mpd.password", "", "mpd server password")
+	mpf := flagset.String("mpd.password_file", "", "read mpd server password from file")
+	mpt := flagset.String("mpd.partition", "", "mpd partition to control")
+	mps := flagset.Int("mpd.pool_size", 0, "number of mpd connections to run commands concurrently")
 	sa := flagset.String("server.addr", "", "this app serving address")
 	si := flagset.Bool("server.cover.remote", false, "enable coverart via mpd api")
 	d := flagset.BoolP("debug", "d", false, "use local assets if exists")
@@ -118,6 +186,34 @@ func ParseConfig(dir []string, name string, args []string) (*Config, time.Time,
 		}
 		c.MPD.BinaryLimit = bl
 	}
+	if len(*mp) != 0 {
+		c.MPD.Password = *mp
+	}
+	if len(*mpf) != 0 {
+		c.MPD.PasswordFile = *mpf
+	}
+	if len(*mpt) != 0 {
+		c.MPD.Partition = *mpt
+	}
+	if *mps != 0 {
+		c.MPD.PoolSize = *mps
+	}
+	var err error
+	if c.MPD.Password, err = readPasswordFile(c.MPD.Password, c.MPD.PasswordFile); err != nil {
+		return nil, date, fmt.Errorf("mpd.password_file: %w", err)
+	}
+	lb := &c.Scrobble.ListenBrainz
+	if lb.Token, err = readPasswordFile(lb.Token, lb.TokenFile); err != nil {
+		return nil, date, fmt.Errorf("scrobble.listenbrainz.token_file: %w", err)
+	}
+	for name, s := range c.Servers {
+		if s == nil {
+			continue
+		}
+		if s.Password, err = readPasswordFile(s.Password, s.PasswordFile); err != nil {
+			return nil, date, fmt.Errorf("servers.%s.password_file: %w", name, err)
+		}
+	}
 	if len(*sa) != 0 {
 		c.Server.Addr = *sa
 	}
@@ -131,6 +227,23 @@ func ParseConfig(dir []string, name string, args []string) (*Config, time.Time,
 
 // Validate validates config data.
 func (c *Config) Validate() error {
+	for name, s := range c.Servers {
+		if name == defaultServerName || !isServerName(name) {
+			return fmt.Errorf("servers.%s: invalid server name", name)
+		}
+		if s == nil {
+			return fmt.Errorf("servers.%s: server config is empty", name)
+		}
+		if s.PoolSize < 0 {
+			return fmt.Errorf("servers.%s.pool_size: must not be negative: %d", name, s.PoolSize)
+		}
+	}
+	if c.MPD.PoolSize < 0 {
+		return fmt.Errorf("mpd.pool_size: must not be negative: %d", c.MPD.PoolSize)
+	}
+	if c.Server.Cover.Workers < 0 {
+		return fmt.Errorf("server.cover.workers: must not be negative: %d", c.Server.Cover.Workers)
+	}
 	set := make(map[string]struct{}, len(c.Playlist.TreeOrder))
 	for _, label := range c.Playlist.TreeOrder {
 		if _, ok := set[label]; ok {
@@ -233,7 +346,12 @@ func (b BinarySize) MarshalText() ([]byte, error) {
 		return buf.Bytes(), nil
 	}
 	m := k / 1024
-	fmt.Fprintf(buf, "%d MiB", m)
+	if m%1024 != 0 {
+		fmt.Fprintf(buf, "%d MiB", m)
+		return buf.Bytes(), nil
+	}
+	g := m / 1024
+	fmt.Fprintf(buf, "%d GiB", g)
 	return buf.Bytes(), nil
 }
 
@@ -260,6 +378,8 @@ func (b *BinarySize) UnmarshalText(text []byte) error {
 		*b = BinarySize(n) * 1024
 	} else if suffix == "m" || suffix == "M" || suffix == "MiB" || suffix == "MB" {
 		*b = BinarySize(n) * 1024 * 1024
+	} else if suffix == "g" || suffix == "G" || suffix == "GiB" || suffix == "GB" {
+		*b = BinarySize(n) * 1024 * 1024 * 1024
 	} else if suffix == "" || suffix == "B" {
 		*b = BinarySize(n)
 	} else {
@@ -276,3 +396,16 @@ func contains(list []string, item string) bool {
 	}
 	return false
 }
+
+// isServerName reports whether name is usable as a path segment of http.ServeMux pattern.
+func isServerName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for _, c := range name {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
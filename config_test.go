@@ -64,10 +64,18 @@ func TestParseConfigExample(t *testing.T) {
 	want.MPD.MusicDirectory = "/path/to/music/dir"
 	want.MPD.Conf = "/etc/mpd.conf"
 	want.MPD.BinaryLimit = 128 * 1024
+	want.MPD.PoolSize = 2
 	want.Server.Addr = ":8080"
 	want.Server.CacheDirectory = "/tmp/vv"
 	want.Server.Cover.Local = true
+	want.Server.Cover.LocalFiles = []string{"cover.*", "folder.*", "front.*", "AlbumArt*.jpg", "Scans/Front.*"}
+	want.Server.Cover.LocalFallback = true
 	want.Server.Cover.Remote = true
+	want.Server.Cover.Workers = 2
+	want.Server.Cover.ArtistFiles = []string{"artist.*", "folder.*"}
+	want.Server.Cover.ArtistImages = "/path/to/artist/images"
+	want.Server.Lyrics.Local = true
+	want.Server.Lyrics.Remote = true
 	want.Playlist.Tree = map[string]*ConfigListNode{
 		"AlbumArtist": {
 			Sort: []string{"AlbumArtist", "Date", "Album", "DiscNumber", "TrackNumber", "Title", "file"},
@@ -119,6 +127,11 @@ func TestParseConfigDefault(t *testing.T) {
 	want.Server.Addr = ":8080"
 	want.Server.CacheDirectory = "/tmp/vv"
 	want.Server.Cover.Local = true
+	want.Server.Cover.LocalFiles = []string{"cover.jpg", "cover.jpeg", "cover.webp", "cover.png", "cover.gif", "cover.bmp"}
+	want.Server.Cover.Workers = 1
+	want.Server.Cover.ArtistFiles = []string{"artist.*"}
+	want.Server.Lyrics.Local = true
+	want.Server.Lyrics.Remote = true
 	if !reflect.DeepEqual(config, want) {
 		t.Errorf("got %+v; want %+v", config, want)
 	}
@@ -132,6 +145,7 @@ func TestParseConfigOptions(t *testing.T) {
 		"--mpd.addr", "/var/run/mpd/socket",
 		"--mpd.music_directory", "/mnt/Music",
 		"--mpd.binarylimit", "32k",
+		"--mpd.pool_size", "4",
 		"--server.addr", ":80",
 		"--server.cover.remote",
 	})
@@ -148,10 +162,16 @@ func TestParseConfigOptions(t *testing.T) {
 	want.MPD.Conf = "/local/etc/mpd.conf"
 	want.MPD.MusicDirectory = "/mnt/Music"
 	want.MPD.BinaryLimit = 32768
+	want.MPD.PoolSize = 4
 	want.Server.Addr = ":80"
 	want.Server.CacheDirectory = "/tmp/vv"
 	want.Server.Cover.Local = true
+	want.Server.Cover.LocalFiles = []string{"cover.jpg", "cover.jpeg", "cover.webp", "cover.png", "cover.gif", "cover.bmp"}
+	want.Server.Cover.Workers = 1
+	want.Server.Cover.ArtistFiles = []string{"artist.*"}
 	want.Server.Cover.Remote = true
+	want.Server.Lyrics.Local = true
+	want.Server.Lyrics.Remote = true
 	if !reflect.DeepEqual(config, want) {
 		t.Errorf("got \n%+v; want \n%+v", config, want)
 	}
@@ -183,6 +203,15 @@ func TestValidateErrorText(t *testing.T) {
 		`{"playlist":{"tree_order":["foo"],"tree":{"foo":{"sort":["file"],"tree":[]}}}}`:                 "playlist.tree.foo: sort or tree must not be empty",
 		`{"playlist":{"tree_order":["foo"],"tree":{"foo":{"sort":["file"],"tree":[["title","song"]]}}}}`: "playlist.tree.foo: tree: index 0:0: tree tag must be defined in sort: title does not defined in sort: ",     // do not include []string printf representation
 		`{"playlist":{"tree_order":["foo"],"tree":{"foo":{"sort":["file"],"tree":[["file","foo"]]}}}}`:   "playlist.tree.foo: tree: index 0:1: unsupported tree view type: got foo; supported tree element views are ", // do not include []string printf representation
+
+		`{"servers":{"default":{"addr":"localhost:6600"}}}`:     "servers.default: invalid server name",
+		`{"servers":{"foo/bar":{"addr":"localhost:6600"}}}`:     "servers.foo/bar: invalid server name",
+		`{"servers":{"living room":{"addr":"localhost:6600"}}}`: "servers.living room: invalid server name",
+		`{"servers":{"{foo}":{"addr":"localhost:6600"}}}`:       "servers.{foo}: invalid server name",
+		`{"servers":{"foo":null}}`:                              "servers.foo: server config is empty",
+
+		`{"server":{"cover":{"workers":-1}}}`: "server.cover.workers: must not be negative",
+		`{"mpd":{"pool_size":-1}}`:            "mpd.pool_size: must not be negative",
 	} {
 		t.Run(errStr, func(t *testing.T) {
 			c := Config{}
@@ -212,6 +241,8 @@ func TestBinarySize(t *testing.T) {
 		"1M":      1024 * 1024,
 		"1MB":     1024 * 1024,
 		"1MiB":    1024 * 1024,
+		"2g":      2 * 1024 * 1024 * 1024,
+		"2 GiB":   2 * 1024 * 1024 * 1024,
 	} {
 		var b BinarySize
 		if err := b.UnmarshalText([]byte(str)); err != nil {
@@ -225,3 +256,90 @@ func TestBinarySize(t *testing.T) {
 	}
 
 }
+
+func TestParseConfigMPDPassword(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "password")
+	if err := os.WriteFile(path, []byte("bar\n"), 0600); err != nil {
+		t.Fatalf("failed to write password file: %v", err)
+	}
+	for label, tt := range map[string]struct {
+		args []string
+		want string
+	}{
+		"password":          {args: []string{"--mpd.password", "foo"}, want: "foo"},
+		"password_file":     {args: []string{"--mpd.password_file", path}, want: "bar"},
+		"password and file": {args: []string{"--mpd.password", "foo", "--mpd.password_file", path}, want: "foo"},
+		"no password":       {want: ""},
+	} {
+		t.Run(label, func(t *testing.T) {
+			config, _, err := ParseConfig(nil, "", append([]string{os.Args[0]}, tt.args...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.MPD.Password != tt.want {
+				t.Errorf("got %q; want %q", config.MPD.Password, tt.want)
+			}
+		})
+	}
+	if _, _, err := ParseConfig(nil, "", []string{os.Args[0], "--mpd.password_file", filepath.Join(dir, "notfound")}); err == nil {
+		t.Errorf("got nil error for missing password file; want error")
+	}
+}
+
+func TestParseConfigServers(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "password"), []byte("bar\n"), 0600); err != nil {
+		t.Fatalf("failed to write password file: %v", err)
+	}
+	conf := `servers:
+  living:
+    addr: "/run/mpd/socket"
+  kitchen:
+    addr: "kitchen:6600"
+    binarylimit: 128 KiB
+    partition: "kitchen"
+    pool_size: 2
+    password_file: "` + filepath.Join(dir, "password") + `"
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(conf), 0600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	config, _, err := ParseConfig([]string{dir}, "config.yaml", []string{os.Args[0]})
+	if err != nil {
+		t.Fatalf("got ParseConfig err %v; want <nil>", err)
+	}
+	want := map[string]*ConfigServer{
+		"living":  {Network: "unix", Addr: "/run/mpd/socket"},
+		"kitchen": {Network: "tcp", Addr: "kitchen:6600", BinaryLimit: 128 * 1024, Password: "bar", Partition: "kitchen", PoolSize: 2, PasswordFile: filepath.Join(dir, "password")},
+	}
+	if !reflect.DeepEqual(config.Servers, want) {
+		t.Errorf("got %+v; want %+v", config.Servers, want)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("got Validate() = %v; want <nil>", err)
+	}
+}
+
+func TestParseConfigScrobble(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("secret\n"), 0600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+	conf := `scrobble:
+  listenbrainz:
+    url: "http://localhost:8000/1/submit-listens"
+    token_file: "` + filepath.Join(dir, "token") + `"
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(conf), 0600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	config, _, err := ParseConfig([]string{dir}, "config.yaml", []string{os.Args[0]})
+	if err != nil {
+		t.Fatalf("got ParseConfig err %v; want <nil>", err)
+	}
+	lb := config.Scrobble.ListenBrainz
+	if lb.URL != "http://localhost:8000/1/submit-listens" || lb.Token != "secret" {
+		t.Errorf("got url %q, token %q; want %q, %q", lb.URL, lb.Token, "http://localhost:8000/1/submit-listens", "secret")
+	}
+}
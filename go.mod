@@ -3,6 +3,7 @@ module github.com/meiraka/vv
 go 1.25.0
 
 require (
+	github.com/HugoSmits86/nativewebp v0.9.3
 	github.com/gorilla/websocket v1.4.1
 	github.com/spf13/pflag v1.0.3
 	go.etcd.io/bbolt v1.3.5
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -20,6 +21,7 @@ const (
 // Client is a mpd client.
 type Client struct {
 	pool            *pool
+	binaryPool      *pool
 	stopHealthCheck func()
 	opts            *ClientOptions
 	commands        []string
@@ -32,7 +34,7 @@ func Dial(proto, addr string, opts *ClientOptions) (*Client, error) {
 		opts = &ClientOptions{}
 	}
 	c := &Client{opts: opts}
-	pool, err := newPool(proto, addr, opts.Timeout, opts.ReconnectionInterval, func(conn *conn) error {
+	hook := func(conn *conn) error {
 		if err := opts.connectHook(conn); err != nil {
 			return err
 		}
@@ -42,12 +44,22 @@ func Dial(proto, addr string, opts *ClientOptions) (*Client, error) {
 			}
 		}
 		return nil
-	})
+	}
+	pool, err := newPool(opts.PoolSize, proto, addr, opts.Timeout, opts.ReconnectionInterval, hook)
 	if err != nil {
 		return nil, err
 	}
+	binaryPool := pool
+	if opts.BinaryPoolSize > 0 {
+		binaryPool, err = newPool(opts.BinaryPoolSize, proto, addr, opts.Timeout, opts.ReconnectionInterval, opts.connectHook)
+		if err != nil {
+			pool.Close(context.Background())
+			return nil, err
+		}
+	}
 	hCtx, hStop := context.WithCancel(context.Background())
 	c.pool = pool
+	c.binaryPool = binaryPool
 	c.stopHealthCheck = hStop
 	go c.healthCheck(hCtx)
 	return c, nil
@@ -56,7 +68,13 @@ func Dial(proto, addr string, opts *ClientOptions) (*Client, error) {
 // Close closes mpd connection.
 func (c *Client) Close(ctx context.Context) error {
 	c.stopHealthCheck()
-	return c.pool.Close(ctx)
+	err := c.pool.Close(ctx)
+	if c.binaryPool != c.pool {
+		if berr := c.binaryPool.Close(ctx); berr != nil && err == nil {
+			err = berr
+		}
+	}
+	return err
 }
 
 // Version returns mpd server version.
@@ -172,22 +190,109 @@ func (c *Client) SeekCur(ctx context.Context, t float64) error {
 
 // The Queue
 
+// AddID adds a song to the playlist at position pos and returns the song id.
+// AddID appends a song to the end of the playlist if pos is negative.
+func (c *Client) AddID(ctx context.Context, uri string, pos int) (int, error) {
+	args := []interface{}{uri}
+	if pos >= 0 {
+		args = append(args, pos)
+	}
+	m, err := c.mapStr(ctx, "addid", args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.Atoi(m["Id"])
+	if err != nil {
+		return 0, addCommandInfo(err, "addid")
+	}
+	return id, nil
+}
+
+// DeleteID deletes the song id from the playlist.
+func (c *Client) DeleteID(ctx context.Context, id int) error {
+	return c.ok(ctx, "deleteid", id)
+}
+
+// MoveID moves the song id to the position to in the playlist.
+func (c *Client) MoveID(ctx context.Context, id, to int) error {
+	return c.ok(ctx, "moveid", id, to)
+}
+
 // PlaylistInfo displays a list of all songs in the playlist.
 func (c *Client) PlaylistInfo(ctx context.Context) ([]map[string][]string, error) {
-	ch := make(chan []map[string][]string, 1)
-	err := c.pool.Exec(ctx, func(conn *conn) error {
-		defer close(ch)
-		if err := request(conn, "playlistinfo"); err != nil {
-			return err
-		}
-		songs, err := parseSongs(conn, responseOK)
-		ch <- songs
-		return err
-	})
-	if err != nil {
-		return nil, addCommandInfo(err, "playlistinfo")
+	return c.listSongs(ctx, "playlistinfo")
+}
+
+// Prio sets the priority of the songs in the position range [start, end).
+func (c *Client) Prio(ctx context.Context, prio, start, end int) error {
+	return c.ok(ctx, "prio", prio, strconv.Itoa(start)+":"+strconv.Itoa(end))
+}
+
+// PrioID sets the priority of the songs ids.
+func (c *Client) PrioID(ctx context.Context, prio int, ids ...int) error {
+	args := make([]interface{}, len(ids)+1)
+	args[0] = prio
+	for i := range ids {
+		args[i+1] = ids[i]
 	}
-	return <-ch, nil
+	return c.ok(ctx, "prioid", args...)
+}
+
+// Shuffle shuffles the queue.
+func (c *Client) Shuffle(ctx context.Context) error {
+	return c.ok(ctx, "shuffle")
+}
+
+// SwapID swaps the positions of id1 and id2.
+func (c *Client) SwapID(ctx context.Context, id1, id2 int) error {
+	return c.ok(ctx, "swapid", id1, id2)
+}
+
+// Stored playlists
+
+// ListPlaylists prints a list of the playlist directory.
+func (c *Client) ListPlaylists(ctx context.Context) ([]map[string]string, error) {
+	return c.listMap(ctx, "playlist", "listplaylists")
+}
+
+// ListPlaylistInfo lists the songs with metadata in the playlist.
+func (c *Client) ListPlaylistInfo(ctx context.Context, name string) ([]map[string][]string, error) {
+	return c.listSongs(ctx, "listplaylistinfo", name)
+}
+
+// Load loads the playlist into the current queue.
+func (c *Client) Load(ctx context.Context, name string) error {
+	return c.ok(ctx, "load", name)
+}
+
+// PlaylistAdd adds uri to the playlist.
+func (c *Client) PlaylistAdd(ctx context.Context, name, uri string) error {
+	return c.ok(ctx, "playlistadd", name, uri)
+}
+
+// PlaylistDelete deletes pos from the playlist.
+func (c *Client) PlaylistDelete(ctx context.Context, name string, pos int) error {
+	return c.ok(ctx, "playlistdelete", name, pos)
+}
+
+// PlaylistMove moves the song at position from in the playlist to the position to.
+func (c *Client) PlaylistMove(ctx context.Context, name string, from, to int) error {
+	return c.ok(ctx, "playlistmove", name, from, to)
+}
+
+// Rename renames the playlist.
+func (c *Client) Rename(ctx context.Context, name, newName string) error {
+	return c.ok(ctx, "rename", name, newName)
+}
+
+// Rm removes the playlist from the playlist directory.
+func (c *Client) Rm(ctx context.Context, name string) error {
+	return c.ok(ctx, "rm", name)
+}
+
+// Save saves the queue to the playlist.
+func (c *Client) Save(ctx context.Context, name string) error {
+	return c.ok(ctx, "save", name)
 }
 
 // The music database
@@ -203,24 +308,87 @@ func (c *Client) ReadPicture(ctx context.Context, uri string) ([]byte, error) {
 	return c.binary(ctx, "readpicture", uri)
 }
 
+// ReadComments reads "comments" (i.e. key-value pairs) from the file specified by uri.
+// This call is not tag parsing, so returns raw file metadata like lyrics.
+// Repeated keys like several ARTIST comments have multiple values.
+func (c *Client) ReadComments(ctx context.Context, uri string) (map[string][]string, error) {
+	ch := make(chan map[string][]string, 1)
+	err := c.pool.Exec(ctx, func(conn *conn) error {
+		defer close(ch)
+		if err := request(conn, "readcomments", uri); err != nil {
+			return err
+		}
+		m, err := parseSong(conn, responseOK)
+		ch <- m
+		return err
+	})
+	if err != nil {
+		return nil, addCommandInfo(err, "readcomments")
+	}
+	return <-ch, nil
+}
+
+// GetFingerprint calculates chromaprint fingerprint of the song.
+// GetFingerprint uses connections for binary commands because decoding song
+// takes a long time.
+func (c *Client) GetFingerprint(ctx context.Context, uri string) (string, error) {
+	m, err := c.poolMapStr(ctx, c.binaryPool, "getfingerprint", uri)
+	if err != nil {
+		return "", err
+	}
+	return m["chromaprint"], nil
+}
+
+// Find searches the database for songs matching filter expression.
+func (c *Client) Find(ctx context.Context, filter string) ([]map[string][]string, error) {
+	return c.listSongs(ctx, "find", filter)
+}
+
+// FindAdd searches the database for songs matching filter expression and adds them to the queue.
+func (c *Client) FindAdd(ctx context.Context, filter string) error {
+	return c.ok(ctx, "findadd", filter)
+}
+
 // ListAllInfo lists all songs and directories in uri.
 func (c *Client) ListAllInfo(ctx context.Context, uri string) ([]map[string][]string, error) {
-	ch := make(chan []map[string][]string, 1)
+	return c.listSongs(ctx, "listallinfo", uri)
+}
+
+// ListAll lists all song files in uri.
+func (c *Client) ListAll(ctx context.Context, uri string) ([]string, error) {
+	ch := make(chan []string, 1)
 	err := c.pool.Exec(ctx, func(conn *conn) error {
 		defer close(ch)
-		if err := request(conn, "listallinfo", uri); err != nil {
+		if err := request(conn, "listall", uri); err != nil {
 			return err
 		}
-		songs, err := parseSongs(conn, responseOK)
-		ch <- songs
+		l, err := parseListOnly(conn, responseOK, "file")
+		ch <- l
 		return err
 	})
 	if err != nil {
-		return nil, addCommandInfo(err, "listallinfo")
+		return nil, addCommandInfo(err, "listall")
 	}
 	return <-ch, nil
 }
 
+// LsInfo lists songs in the directory uri. Sub directories and playlists in uri are skipped.
+func (c *Client) LsInfo(ctx context.Context, uri string) ([]map[string][]string, error) {
+	return c.listSongs(ctx, "lsinfo", uri)
+}
+
+// Search searches the database for songs matching filter expression.
+// Unlike Find, Search is not case sensitive.
+func (c *Client) Search(ctx context.Context, filter string) ([]map[string][]string, error) {
+	return c.listSongs(ctx, "search", filter)
+}
+
+// SearchAdd searches the database for songs matching filter expression and adds them to the queue.
+// Unlike FindAdd, SearchAdd is not case sensitive.
+func (c *Client) SearchAdd(ctx context.Context, filter string) error {
+	return c.ok(ctx, "searchadd", filter)
+}
+
 // Update updates the music database.
 func (c *Client) Update(ctx context.Context, uri string) (map[string]string, error) {
 	return c.mapStr(ctx, "update", uri)
@@ -248,6 +416,95 @@ func (c *Client) ListNeighbors(ctx context.Context) ([]map[string]string, error)
 	return c.listMap(ctx, "neighbor", "listneighbors")
 }
 
+// Partition commands
+
+// ListPartitions returns a list of partitions.
+func (c *Client) ListPartitions(ctx context.Context) ([]string, error) {
+	return c.list(ctx, "partition", "listpartitions")
+}
+
+// NewPartition creates a new partition.
+func (c *Client) NewPartition(ctx context.Context, name string) error {
+	return c.ok(ctx, "newpartition", name)
+}
+
+// DelPartition deletes a partition.
+// The partition must be empty (no connected clients and no outputs).
+func (c *Client) DelPartition(ctx context.Context, name string) error {
+	return c.ok(ctx, "delpartition", name)
+}
+
+// MoveOutput moves an output to the partition of this client.
+func (c *Client) MoveOutput(ctx context.Context, name string) error {
+	return c.ok(ctx, "moveoutput", name)
+}
+
+// Stickers
+
+// StickerGet reads a sticker value for the specified object.
+func (c *Client) StickerGet(ctx context.Context, typ, uri, name string) (string, error) {
+	l, err := c.list(ctx, "sticker", "sticker", "get", typ, uri, name)
+	if err != nil {
+		return "", err
+	}
+	for i := range l {
+		if k, v, ok := strings.Cut(l[i], "="); ok && k == name {
+			return v, nil
+		}
+	}
+	return "", nil
+}
+
+// StickerSet adds a sticker value to the specified object.
+// If a sticker item with that name already exists, it is replaced.
+func (c *Client) StickerSet(ctx context.Context, typ, uri, name, value string) error {
+	return c.ok(ctx, "sticker", "set", typ, uri, name, value)
+}
+
+// StickerDelete deletes a sticker value from the specified object.
+// If name is empty, all sticker values are deleted.
+func (c *Client) StickerDelete(ctx context.Context, typ, uri, name string) error {
+	if len(name) == 0 {
+		return c.ok(ctx, "sticker", "delete", typ, uri)
+	}
+	return c.ok(ctx, "sticker", "delete", typ, uri, name)
+}
+
+// StickerList lists the stickers for the specified object.
+func (c *Client) StickerList(ctx context.Context, typ, uri string) (map[string]string, error) {
+	l, err := c.list(ctx, "sticker", "sticker", "list", typ, uri)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]string, len(l))
+	for i := range l {
+		if k, v, ok := strings.Cut(l[i], "="); ok {
+			ret[k] = v
+		}
+	}
+	return ret, nil
+}
+
+// StickerFind searches the sticker database for stickers with the specified
+// name, below the specified directory (uri). It returns uri and sticker value pairs.
+func (c *Client) StickerFind(ctx context.Context, typ, uri, name string) (map[string]string, error) {
+	key := typ
+	if typ == "song" {
+		key = "file"
+	}
+	l, err := c.listMap(ctx, key, "sticker", "find", typ, uri, name)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]string, len(l))
+	for i := range l {
+		if k, v, ok := strings.Cut(l[i]["sticker"], "="); ok && k == name {
+			ret[l[i][key]] = v
+		}
+	}
+	return ret, nil
+}
+
 // Audio output devices
 
 // DisableOutput turns an output off.
@@ -287,6 +544,35 @@ func (c *Client) Outputs(ctx context.Context) ([]*Output, error) {
 	return <-ch, nil
 }
 
+// PartitionOutputs shows information about all outputs of the given partition.
+// The connection is switched back to the partition of the client after the command.
+func (c *Client) PartitionOutputs(ctx context.Context, partition string) ([]*Output, error) {
+	current := c.opts.Partition
+	if len(current) == 0 {
+		current = "default"
+	}
+	ch := make(chan []*Output, 1)
+	err := c.pool.Exec(ctx, func(conn *conn) error {
+		defer close(ch)
+		if err := execOK(conn, "partition", partition); err != nil {
+			return err
+		}
+		if err := request(conn, "outputs"); err != nil {
+			return err
+		}
+		outputs, err := parseOutputs(conn, responseOK)
+		if perr := execOK(conn, "partition", current); err == nil {
+			err = perr
+		}
+		ch <- outputs
+		return err
+	})
+	if err != nil {
+		return nil, addCommandInfo(err, "outputs")
+	}
+	return <-ch, nil
+}
+
 // OutputSet sets a runtime attribute.
 func (c *Client) OutputSet(ctx context.Context, id, name, value string) error {
 	return c.ok(ctx, "outputset", id, name, value)
@@ -305,7 +591,10 @@ func (c *Client) healthCheck(ctx context.Context) {
 				return
 			case <-ticker.C:
 				ctx, cancel := context.WithTimeout(ctx, c.opts.HealthCheckInterval)
-				c.Ping(ctx)
+				c.pool.ping(ctx)
+				if c.binaryPool != c.pool {
+					c.binaryPool.ping(ctx)
+				}
 				cancel()
 			}
 		}
@@ -371,7 +660,7 @@ func (c *Client) ok(ctx context.Context, cmd string, args ...interface{}) error
 
 func (c *Client) binaryPart(ctx context.Context, pos int, cmd string, args ...interface{}) (map[string]string, []byte, error) {
 	ch1, ch2 := make(chan map[string]string, 1), make(chan []byte, 1)
-	err := c.pool.Exec(ctx, func(conn *conn) error {
+	err := c.binaryPool.Exec(ctx, func(conn *conn) error {
 		defer close(ch1)
 		defer close(ch2)
 		if err := request(conn, cmd, append(args, pos)...); err != nil {
@@ -416,8 +705,12 @@ func (c *Client) binary(ctx context.Context, cmd string, args ...interface{}) ([
 }
 
 func (c *Client) mapStr(ctx context.Context, cmd string, args ...interface{}) (map[string]string, error) {
+	return c.poolMapStr(ctx, c.pool, cmd, args...)
+}
+
+func (c *Client) poolMapStr(ctx context.Context, p *pool, cmd string, args ...interface{}) (map[string]string, error) {
 	ch := make(chan map[string]string, 1)
-	err := c.pool.Exec(ctx, func(conn *conn) error {
+	err := p.Exec(ctx, func(conn *conn) error {
 		defer close(ch)
 		if err := request(conn, cmd, args...); err != nil {
 			return err
@@ -432,6 +725,40 @@ func (c *Client) mapStr(ctx context.Context, cmd string, args ...interface{}) (m
 	return <-ch, nil
 }
 
+func (c *Client) listSongs(ctx context.Context, cmd string, args ...interface{}) ([]map[string][]string, error) {
+	ch := make(chan []map[string][]string, 1)
+	err := c.pool.Exec(ctx, func(conn *conn) error {
+		defer close(ch)
+		if err := request(conn, cmd, args...); err != nil {
+			return err
+		}
+		songs, err := parseSongs(conn, responseOK)
+		ch <- songs
+		return err
+	})
+	if err != nil {
+		return nil, addCommandInfo(err, cmd)
+	}
+	return <-ch, nil
+}
+
+func (c *Client) list(ctx context.Context, label string, cmd string, args ...interface{}) ([]string, error) {
+	ch := make(chan []string, 1)
+	err := c.pool.Exec(ctx, func(conn *conn) error {
+		defer close(ch)
+		if err := request(conn, cmd, args...); err != nil {
+			return err
+		}
+		l, err := parseList(conn, responseOK, label)
+		ch <- l
+		return err
+	})
+	if err != nil {
+		return nil, addCommandInfo(err, cmd)
+	}
+	return <-ch, nil
+}
+
 func (c *Client) listMap(ctx context.Context, newKey string, cmd string, args ...interface{}) ([]map[string]string, error) {
 	ch := make(chan []map[string]string, 1)
 	err := c.pool.Exec(ctx, func(conn *conn) error {
@@ -460,6 +787,17 @@ type ClientOptions struct {
 	BinaryLimit int
 	// CacheCommandsResult caches mpd command "commands" result
 	CacheCommandsResult bool
+	// PoolSize sets the number of connections to run commands concurrently.
+	// Defaults to 1.
+	PoolSize int
+	// BinaryPoolSize sets the number of connections reserved for binary
+	// transfer commands like albumart and readpicture, so that other commands
+	// are not blocked by large binary responses.
+	// If 0, binary transfer commands share connections with other commands.
+	BinaryPoolSize int
+	// Partition switches connections to the given partition.
+	// If empty, connections use the default partition.
+	Partition string
 }
 
 func (c *ClientOptions) connectHook(conn *conn) error {
@@ -474,6 +812,11 @@ func (c *ClientOptions) connectHook(conn *conn) error {
 			return err
 		}
 	}
+	if len(c.Partition) > 0 {
+		if err := execOK(conn, "partition", c.Partition); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
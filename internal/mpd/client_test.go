@@ -56,6 +56,17 @@ func TestDial(t *testing.T) {
 			want: []*mpdtest.WR{{Read: "binarylimit 64\n", Write: `ACK [5@0] {} unknown command "binarylimit"` + "\n"}},
 			err:  false,
 		},
+		"partition": {
+			url:  ts.URL,
+			opts: &ClientOptions{Partition: "zone1"},
+			want: []*mpdtest.WR{{Read: "partition \"zone1\"\n", Write: "OK\n"}},
+		},
+		"partition(not found)": {
+			url:  ts.URL,
+			opts: &ClientOptions{Partition: "zone1"},
+			want: []*mpdtest.WR{{Read: "partition \"zone1\"\n", Write: "ACK [50@0] {partition} partition does not exist\n"}},
+			err:  true,
+		},
 		"cache commands result": {
 			url:  ts.URL,
 			opts: &ClientOptions{CacheCommandsResult: true},
@@ -212,11 +223,84 @@ func TestClient(t *testing.T) {
 			wr:   []*mpdtest.WR{{Read: "previous\n", Write: "OK\n"}},
 		},
 		// The Queue
+		"addid": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.AddID(ctx, "foo", -1) },
+			wr:   []*mpdtest.WR{{Read: "addid \"foo\"\n", Write: "Id: 1\nOK\n"}},
+			want: 1,
+		},
+		"addid(pos)": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.AddID(ctx, "foo", 0) },
+			wr:   []*mpdtest.WR{{Read: "addid \"foo\" 0\n", Write: "Id: 2\nOK\n"}},
+			want: 2,
+		},
+		"deleteid": {
+			cmd1: func(ctx context.Context) error { return c.DeleteID(ctx, 1) },
+			wr:   []*mpdtest.WR{{Read: "deleteid 1\n", Write: "OK\n"}},
+		},
+		"moveid": {
+			cmd1: func(ctx context.Context) error { return c.MoveID(ctx, 1, 2) },
+			wr:   []*mpdtest.WR{{Read: "moveid 1 2\n", Write: "OK\n"}},
+		},
+		"prio": {
+			cmd1: func(ctx context.Context) error { return c.Prio(ctx, 255, 0, 2) },
+			wr:   []*mpdtest.WR{{Read: "prio 255 \"0:2\"\n", Write: "OK\n"}},
+		},
+		"prioid": {
+			cmd1: func(ctx context.Context) error { return c.PrioID(ctx, 255, 1, 2) },
+			wr:   []*mpdtest.WR{{Read: "prioid 255 1 2\n", Write: "OK\n"}},
+		},
+		"shuffle": {
+			cmd1: c.Shuffle,
+			wr:   []*mpdtest.WR{{Read: "shuffle\n", Write: "OK\n"}},
+		},
+		"swapid": {
+			cmd1: func(ctx context.Context) error { return c.SwapID(ctx, 1, 2) },
+			wr:   []*mpdtest.WR{{Read: "swapid 1 2\n", Write: "OK\n"}},
+		},
 		"playlistinfo": {
 			cmd2: func(ctx context.Context) (interface{}, error) { return c.PlaylistInfo(ctx) },
 			wr:   []*mpdtest.WR{{Read: "playlistinfo\n", Write: "file: foo\nfile: bar\nOK\n"}},
 			want: []map[string][]string{{"file": {"foo"}}, {"file": {"bar"}}},
 		},
+		// Stored playlists
+		"listplaylists": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.ListPlaylists(ctx) },
+			wr:   []*mpdtest.WR{{Read: "listplaylists\n", Write: "playlist: foo\nLast-Modified: 2021-01-17T01:06:27Z\nplaylist: bar\nLast-Modified: 2021-01-18T01:06:27Z\nOK\n"}},
+			want: []map[string]string{{"playlist": "foo", "Last-Modified": "2021-01-17T01:06:27Z"}, {"playlist": "bar", "Last-Modified": "2021-01-18T01:06:27Z"}},
+		},
+		"listplaylistinfo": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.ListPlaylistInfo(ctx, "foo") },
+			wr:   []*mpdtest.WR{{Read: "listplaylistinfo \"foo\"\n", Write: "file: foo\nfile: bar\nOK\n"}},
+			want: []map[string][]string{{"file": {"foo"}}, {"file": {"bar"}}},
+		},
+		"load": {
+			cmd1: func(ctx context.Context) error { return c.Load(ctx, "foo") },
+			wr:   []*mpdtest.WR{{Read: "load \"foo\"\n", Write: "OK\n"}},
+		},
+		"playlistadd": {
+			cmd1: func(ctx context.Context) error { return c.PlaylistAdd(ctx, "foo", "bar/baz.flac") },
+			wr:   []*mpdtest.WR{{Read: "playlistadd \"foo\" \"bar/baz.flac\"\n", Write: "OK\n"}},
+		},
+		"playlistdelete": {
+			cmd1: func(ctx context.Context) error { return c.PlaylistDelete(ctx, "foo", 1) },
+			wr:   []*mpdtest.WR{{Read: "playlistdelete \"foo\" 1\n", Write: "OK\n"}},
+		},
+		"playlistmove": {
+			cmd1: func(ctx context.Context) error { return c.PlaylistMove(ctx, "foo", 1, 2) },
+			wr:   []*mpdtest.WR{{Read: "playlistmove \"foo\" 1 2\n", Write: "OK\n"}},
+		},
+		"rename": {
+			cmd1: func(ctx context.Context) error { return c.Rename(ctx, "foo", "bar") },
+			wr:   []*mpdtest.WR{{Read: "rename \"foo\" \"bar\"\n", Write: "OK\n"}},
+		},
+		"rm": {
+			cmd1: func(ctx context.Context) error { return c.Rm(ctx, "foo") },
+			wr:   []*mpdtest.WR{{Read: "rm \"foo\"\n", Write: "OK\n"}},
+		},
+		"save": {
+			cmd1: func(ctx context.Context) error { return c.Save(ctx, "foo") },
+			wr:   []*mpdtest.WR{{Read: "save \"foo\"\n", Write: "OK\n"}},
+		},
 		// The music database
 		"albumart": {
 			cmd2: func(ctx context.Context) (interface{}, error) { return c.AlbumArt(ctx, "foo/bar.flac") },
@@ -231,16 +315,50 @@ func TestClient(t *testing.T) {
 			},
 			want: img,
 		},
+		"find": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.Find(ctx, `(Album == "foo")`) },
+			wr:   []*mpdtest.WR{{Read: `find "(Album == \"foo\")"` + "\n", Write: "file: foo\nfile: bar\nOK\n"}},
+			want: []map[string][]string{{"file": {"foo"}}, {"file": {"bar"}}},
+		},
+		"findadd": {
+			cmd1: func(ctx context.Context) error { return c.FindAdd(ctx, `(Album == "foo")`) },
+			wr:   []*mpdtest.WR{{Read: `findadd "(Album == \"foo\")"` + "\n", Write: "OK\n"}},
+		},
 		"listallinfo /": {
 			cmd2: func(ctx context.Context) (interface{}, error) { return c.ListAllInfo(ctx, "/") },
 			wr:   []*mpdtest.WR{{Read: "listallinfo \"/\"\n", Write: "file: foo\nfile: bar\nfile: baz\nOK\n"}},
 			want: []map[string][]string{{"file": {"foo"}}, {"file": {"bar"}}, {"file": {"baz"}}},
 		},
+		"listallinfo / with directories and playlists": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.ListAllInfo(ctx, "/") },
+			wr:   []*mpdtest.WR{{Read: "listallinfo \"/\"\n", Write: "directory: foo\nLast-Modified: 2024-01-01T00:00:00Z\nfile: foo/bar\nplaylist: foo/baz.m3u\nLast-Modified: 2024-01-02T00:00:00Z\nfile: foo/qux\nOK\n"}},
+			want: []map[string][]string{{"file": {"foo/bar"}}, {"file": {"foo/qux"}}},
+		},
+		"listall /": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.ListAll(ctx, "/") },
+			wr:   []*mpdtest.WR{{Read: "listall \"/\"\n", Write: "directory: foo\nfile: foo/bar\nplaylist: foo/baz.m3u\nfile: foo/qux\nOK\n"}},
+			want: []string{"foo/bar", "foo/qux"},
+		},
+		"lsinfo foo": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.LsInfo(ctx, "foo") },
+			wr:   []*mpdtest.WR{{Read: "lsinfo \"foo\"\n", Write: "directory: foo/bar\nLast-Modified: 2024-01-01T00:00:00Z\nfile: foo/baz\nLast-Modified: 2024-01-02T00:00:00Z\nplaylist: foo/qux.m3u\nLast-Modified: 2024-01-03T00:00:00Z\nOK\n"}},
+			want: []map[string][]string{{"file": {"foo/baz"}, "Last-Modified": {"2024-01-02T00:00:00Z"}}},
+		},
 		"readpicture": {
 			cmd2: func(ctx context.Context) (interface{}, error) { return c.ReadPicture(ctx, "foo/bar.flac") },
 			wr:   []*mpdtest.WR{{Read: "readpicture \"foo/bar.flac\" 0\n", Write: fmt.Sprintf("size: %d\nbinary: %d\n%s\nOK\n", imgSize, imgSize, img)}},
 			want: img,
 		},
+		"readcomments": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.ReadComments(ctx, "foo/bar.flac") },
+			wr:   []*mpdtest.WR{{Read: "readcomments \"foo/bar.flac\"\n", Write: "LYRICS: foo\nARTIST: bar\nARTIST: baz\nOK\n"}},
+			want: map[string][]string{"LYRICS": {"foo"}, "ARTIST": {"bar", "baz"}},
+		},
+		"getfingerprint": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.GetFingerprint(ctx, "foo/bar.flac") },
+			wr:   []*mpdtest.WR{{Read: "getfingerprint \"foo/bar.flac\"\n", Write: "chromaprint: AQACcEmSREmWJJmkIT_6CCf64bsE\nOK\n"}},
+			want: "AQACcEmSREmWJJmkIT_6CCf64bsE",
+		},
 		"readpicture(part)": {
 			cmd2: func(ctx context.Context) (interface{}, error) { return c.ReadPicture(ctx, "foo/bar.flac") },
 			wr: []*mpdtest.WR{
@@ -249,6 +367,15 @@ func TestClient(t *testing.T) {
 			},
 			want: img,
 		},
+		"search": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.Search(ctx, `(Album contains "foo")`) },
+			wr:   []*mpdtest.WR{{Read: `search "(Album contains \"foo\")"` + "\n", Write: "file: foo\nfile: bar\nOK\n"}},
+			want: []map[string][]string{{"file": {"foo"}}, {"file": {"bar"}}},
+		},
+		"searchadd": {
+			cmd1: func(ctx context.Context) error { return c.SearchAdd(ctx, `(Album contains "foo")`) },
+			wr:   []*mpdtest.WR{{Read: `searchadd "(Album contains \"foo\")"` + "\n", Write: "OK\n"}},
+		},
 		"update /": {
 			cmd2: func(ctx context.Context) (interface{}, error) { return c.Update(ctx, "/") },
 			wr:   []*mpdtest.WR{{Read: "update \"/\"\n", Write: "updating_db: 1\nOK\n"}},
@@ -268,6 +395,52 @@ func TestClient(t *testing.T) {
 			wr:   []*mpdtest.WR{{Read: "listmounts\n", Write: "mount: \nstorage: /home/foo/music\nmount: foo\nstorage: nfs://192.168.1.4/export/mp3\nOK\n"}},
 			want: []map[string]string{{"mount": "", "storage": "/home/foo/music"}, {"mount": "foo", "storage": "nfs://192.168.1.4/export/mp3"}},
 		},
+		// Partition commands
+		"listpartitions": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.ListPartitions(ctx) },
+			wr:   []*mpdtest.WR{{Read: "listpartitions\n", Write: "partition: default\npartition: zone1\nOK\n"}},
+			want: []string{"default", "zone1"},
+		},
+		"newpartition": {
+			cmd1: func(ctx context.Context) error { return c.NewPartition(ctx, "zone1") },
+			wr:   []*mpdtest.WR{{Read: "newpartition \"zone1\"\n", Write: "OK\n"}},
+		},
+		"delpartition": {
+			cmd1: func(ctx context.Context) error { return c.DelPartition(ctx, "zone1") },
+			wr:   []*mpdtest.WR{{Read: "delpartition \"zone1\"\n", Write: "OK\n"}},
+		},
+		"moveoutput": {
+			cmd1: func(ctx context.Context) error { return c.MoveOutput(ctx, "My ALSA Device") },
+			wr:   []*mpdtest.WR{{Read: "moveoutput \"My ALSA Device\"\n", Write: "OK\n"}},
+		},
+		// Stickers
+		"sticker get": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.StickerGet(ctx, "song", "foo", "rating") },
+			wr:   []*mpdtest.WR{{Read: "sticker \"get\" \"song\" \"foo\" \"rating\"\n", Write: "sticker: rating=3\nOK\n"}},
+			want: "3",
+		},
+		"sticker set": {
+			cmd1: func(ctx context.Context) error { return c.StickerSet(ctx, "song", "foo", "rating", "3") },
+			wr:   []*mpdtest.WR{{Read: "sticker \"set\" \"song\" \"foo\" \"rating\" \"3\"\n", Write: "OK\n"}},
+		},
+		"sticker delete": {
+			cmd1: func(ctx context.Context) error { return c.StickerDelete(ctx, "song", "foo", "rating") },
+			wr:   []*mpdtest.WR{{Read: "sticker \"delete\" \"song\" \"foo\" \"rating\"\n", Write: "OK\n"}},
+		},
+		"sticker delete all": {
+			cmd1: func(ctx context.Context) error { return c.StickerDelete(ctx, "song", "foo", "") },
+			wr:   []*mpdtest.WR{{Read: "sticker \"delete\" \"song\" \"foo\"\n", Write: "OK\n"}},
+		},
+		"sticker list": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.StickerList(ctx, "song", "foo") },
+			wr:   []*mpdtest.WR{{Read: "sticker \"list\" \"song\" \"foo\"\n", Write: "sticker: rating=3\nsticker: playcount=10\nOK\n"}},
+			want: map[string]string{"rating": "3", "playcount": "10"},
+		},
+		"sticker find": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.StickerFind(ctx, "song", "", "rating") },
+			wr:   []*mpdtest.WR{{Read: "sticker \"find\" \"song\" \"\" \"rating\"\n", Write: "file: foo\nsticker: rating=3\nfile: bar\nsticker: rating=a=b\nOK\n"}},
+			want: map[string]string{"foo": "3", "bar": "a=b"},
+		},
 		// Audio output devices
 		"disableoutput": {
 			cmd1: func(ctx context.Context) error { return c.DisableOutput(ctx, "1") },
@@ -282,6 +455,15 @@ func TestClient(t *testing.T) {
 			wr:   []*mpdtest.WR{{Read: "outputs\n", Write: "outputid: 0\noutputname: My ALSA Device\nplugin: alsa\noutputenabled: 0\nattribute: dop=0\nOK\n"}},
 			want: []*Output{{ID: "0", Name: "My ALSA Device", Plugin: "alsa", Enabled: false, Attributes: map[string]string{"dop": "0"}}},
 		},
+		"partition zone1; outputs; partition default": {
+			cmd2: func(ctx context.Context) (interface{}, error) { return c.PartitionOutputs(ctx, "zone1") },
+			wr: []*mpdtest.WR{
+				{Read: "partition \"zone1\"\n", Write: "OK\n"},
+				{Read: "outputs\n", Write: "outputid: 0\noutputname: My ALSA Device\nplugin: alsa\noutputenabled: 1\nOK\n"},
+				{Read: "partition \"default\"\n", Write: "OK\n"},
+			},
+			want: []*Output{{ID: "0", Name: "My ALSA Device", Plugin: "alsa", Enabled: true}},
+		},
 		"outputset": {
 			cmd1: func(ctx context.Context) error { return c.OutputSet(ctx, "0", "dop", "1") },
 			wr:   []*mpdtest.WR{{Read: "outputset \"0\" \"dop\" \"1\"\n", Write: "OK\n"}},
@@ -393,6 +575,116 @@ func TestClient(t *testing.T) {
 	}
 }
 
+func TestClientPool(t *testing.T) {
+	for label, tt := range map[string]struct {
+		opts    *ClientOptions
+		blocked func(context.Context, *Client) error
+		read    string
+	}{
+		"binary pool": {
+			opts:    &ClientOptions{BinaryPoolSize: 1},
+			blocked: func(ctx context.Context, c *Client) error { _, err := c.AlbumArt(ctx, "foo"); return err },
+			read:    "albumart \"foo\" 0\n",
+		},
+		"binary pool/getfingerprint": {
+			opts:    &ClientOptions{BinaryPoolSize: 1},
+			blocked: func(ctx context.Context, c *Client) error { _, err := c.GetFingerprint(ctx, "foo"); return err },
+			read:    "getfingerprint \"foo\"\n",
+		},
+		"pool size": {
+			opts:    &ClientOptions{PoolSize: 2},
+			blocked: func(ctx context.Context, c *Client) error { _, err := c.Status(ctx); return err },
+			read:    "status\n",
+		},
+	} {
+		t.Run(label, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+			defer cancel()
+			ts := mpdtest.NewServer("OK MPD 0.19")
+			defer ts.Close()
+			tt.opts.Timeout = testTimeout
+			tt.opts.ReconnectionInterval = time.Millisecond
+			c, err := Dial("tcp", ts.URL, tt.opts)
+			if err != nil {
+				t.Fatalf("Dial got error %v; want nil", err)
+			}
+			blockedCtx, blockedCancel := context.WithCancel(ctx)
+			defer blockedCancel()
+			errs := make(chan error, 1)
+			go func() {
+				errs <- tt.blocked(blockedCtx, c)
+			}()
+			// mpd does not respond to blocked command
+			ts.Expect(ctx, &mpdtest.WR{Read: tt.read})
+			go func() {
+				ts.Expect(ctx, &mpdtest.WR{Read: "play 0\n", Write: "OK\n"})
+			}()
+			if err := c.Play(ctx, 0); err != nil {
+				t.Errorf("Play got error %v; want nil", err)
+			}
+			blockedCancel()
+			if err := <-errs; !errors.Is(err, context.Canceled) {
+				t.Errorf("blocked command got error %v; want %v", err, context.Canceled)
+			}
+			// wait for reconnection
+			go func() {
+				ts.Expect(ctx, &mpdtest.WR{Read: tt.read, Write: "ACK [50@0] {} test error\n"})
+			}()
+			if err := tt.blocked(ctx, c); !errors.Is(err, ErrNoExist) {
+				t.Errorf("blocked command got error %v; want %v", err, ErrNoExist)
+			}
+			if err := c.Close(ctx); err != nil {
+				t.Errorf("Close got error %v; want nil", err)
+			}
+		})
+	}
+}
+
+func TestPoolPing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ts := mpdtest.NewServer("OK MPD 0.19")
+	defer ts.Close()
+	p, err := newPool(2, "tcp", ts.URL, testTimeout, time.Millisecond, func(*conn) error { return nil })
+	if err != nil {
+		t.Fatalf("newPool got error %v; want nil", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		p.ping(ctx)
+		close(done)
+	}()
+	// all idle connections are pinged; broken one is reconnected
+	ts.Expect(ctx, &mpdtest.WR{Read: "ping\n", Write: "OK\n"})
+	ts.Expect(ctx, &mpdtest.WR{Read: "ping\n", Write: "broken\n"})
+	<-done
+	for i := 0; i < 2; i++ {
+		go func() {
+			ts.Expect(ctx, &mpdtest.WR{Read: "status\n", Write: "OK\n"})
+		}()
+	}
+	// both connections are available at once
+	var wg, held sync.WaitGroup
+	held.Add(2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.Exec(ctx, func(conn *conn) error {
+				held.Done()
+				held.Wait()
+				return execOK(conn, "status")
+			}); err != nil {
+				t.Errorf("Exec got error %v; want nil", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := p.Close(ctx); err != nil {
+		t.Errorf("Close got error %v; want nil", err)
+	}
+}
+
 func TestClientCloseNetworkError(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	defer cancel()
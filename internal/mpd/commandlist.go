@@ -35,29 +35,69 @@ const responseListOK = "list_OK"
 
 // Clear clears playlist
 func (cl *CommandList) Clear() {
-	req, _ := srequest("clear")
-	cl.requests = append(cl.requests, req)
-	cl.commands = append(cl.commands, "clear")
-	cl.parsers = append(cl.parsers, func(c *conn) error {
-		return parseEnd(c, responseListOK)
-	})
+	cl.ok("clear")
 }
 
 // Add adds uri to playlist.
 func (cl *CommandList) Add(uri string) {
-	req, _ := srequest("add", uri)
+	cl.ok("add", uri)
+}
+
+// AddID adds uri to playlist at position pos.
+// AddID appends uri to the end of the playlist if pos is negative.
+func (cl *CommandList) AddID(uri string, pos int) {
+	args := []interface{}{uri}
+	if pos >= 0 {
+		args = append(args, pos)
+	}
+	req, _ := srequest("addid", args...)
 	cl.requests = append(cl.requests, req)
-	cl.commands = append(cl.commands, "add")
+	cl.commands = append(cl.commands, "addid")
 	cl.parsers = append(cl.parsers, func(c *conn) error {
-		return parseEnd(c, responseListOK)
+		_, err := parseMap(c, responseListOK)
+		return err
 	})
 }
 
+// DeleteID deletes the song id from the playlist.
+func (cl *CommandList) DeleteID(id int) {
+	cl.ok("deleteid", id)
+}
+
+// MoveID moves the song id to the position to in the playlist.
+func (cl *CommandList) MoveID(id, to int) {
+	cl.ok("moveid", id, to)
+}
+
 // Play begins playing the playlist at song number pos.
 func (cl *CommandList) Play(pos int) {
-	req, _ := srequest("play", pos)
+	cl.ok("play", pos)
+}
+
+// PrioID sets the priority of the songs ids.
+func (cl *CommandList) PrioID(prio int, ids ...int) {
+	args := make([]interface{}, len(ids)+1)
+	args[0] = prio
+	for i := range ids {
+		args[i+1] = ids[i]
+	}
+	cl.ok("prioid", args...)
+}
+
+// Shuffle shuffles the queue.
+func (cl *CommandList) Shuffle() {
+	cl.ok("shuffle")
+}
+
+// SwapID swaps the positions of id1 and id2.
+func (cl *CommandList) SwapID(id1, id2 int) {
+	cl.ok("swapid", id1, id2)
+}
+
+func (cl *CommandList) ok(cmd string, args ...interface{}) {
+	req, _ := srequest(cmd, args...)
 	cl.requests = append(cl.requests, req)
-	cl.commands = append(cl.commands, "play")
+	cl.commands = append(cl.commands, cmd)
 	cl.parsers = append(cl.parsers, func(c *conn) error {
 		return parseEnd(c, responseListOK)
 	})
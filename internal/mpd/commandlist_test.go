@@ -104,3 +104,38 @@ func TestCommandListNetworkError(t *testing.T) {
 		t.Errorf("Close got error %v; want nil", err)
 	}
 }
+
+func TestCommandListQueue(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ts := mpdtest.NewServer("OK MPD 0.19")
+	defer ts.Close()
+	go func() {
+		ts.Expect(ctx, &mpdtest.WR{Read: "command_list_ok_begin\n"})
+		ts.Expect(ctx, &mpdtest.WR{Read: "addid \"/foo/bar\" 0\n"})
+		ts.Expect(ctx, &mpdtest.WR{Read: "deleteid 1\n"})
+		ts.Expect(ctx, &mpdtest.WR{Read: "moveid 2 0\n"})
+		ts.Expect(ctx, &mpdtest.WR{Read: "swapid 2 3\n"})
+		ts.Expect(ctx, &mpdtest.WR{Read: "prioid 255 2 3\n"})
+		ts.Expect(ctx, &mpdtest.WR{Read: "shuffle\n"})
+		ts.Expect(ctx, &mpdtest.WR{Read: "command_list_end\n", Write: "Id: 4\nlist_OK\nlist_OK\nlist_OK\nlist_OK\nlist_OK\nlist_OK\nOK\n"})
+	}()
+	c, err := Dial("tcp", ts.URL,
+		&ClientOptions{Timeout: testTimeout, ReconnectionInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Dial got error %v; want nil", err)
+	}
+	cl := &CommandList{}
+	cl.AddID("/foo/bar", 0)
+	cl.DeleteID(1)
+	cl.MoveID(2, 0)
+	cl.SwapID(2, 3)
+	cl.PrioID(255, 2, 3)
+	cl.Shuffle()
+	if err := c.ExecCommandList(ctx, cl); err != nil {
+		t.Errorf("CommandList got error %v; want nil", err)
+	}
+	if err := c.Close(ctx); err != nil {
+		t.Errorf("Close got error %v; want nil", err)
+	}
+}
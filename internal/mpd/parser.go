@@ -179,6 +179,27 @@ func parseList(conn connReader, end string, label string) ([]string, error) {
 
 }
 
+// parseListOnly parses values of label and skips lines of other keys.
+func parseListOnly(conn connReader, end string, label string) ([]string, error) {
+	prefix := label + ": "
+	ret := []string{}
+	for {
+		line, err := readln(conn)
+		if err != nil {
+			return nil, err
+		}
+		if ok, err := isEnd(line, end); ok {
+			if err != nil {
+				return nil, err
+			}
+			return ret, nil
+		}
+		if s := strings.TrimPrefix(line, prefix); s != line {
+			ret = append(ret, s)
+		}
+	}
+}
+
 func parseSong(conn connReader, end string) (map[string][]string, error) {
 	song := map[string][]string{}
 	for {
@@ -220,7 +241,7 @@ func parseSongs(conn connReader, end string) ([]map[string][]string, error) {
 			song = map[string][]string{}
 			songs = append(songs, song)
 			in = true
-		} else if strings.HasPrefix(line, "directory: ") { // skip listallinfo directory info
+		} else if strings.HasPrefix(line, "directory: ") || strings.HasPrefix(line, "playlist: ") { // skip directory and playlist info
 			in = false
 		}
 		if in {
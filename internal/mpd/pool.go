@@ -7,6 +7,7 @@ import (
 )
 
 type pool struct {
+	size                 int
 	proto                string
 	addr                 string
 	Timeout              time.Duration
@@ -19,20 +20,32 @@ type pool struct {
 	version              string
 }
 
-func newPool(proto string, addr string, timeout time.Duration, reconnectionInterval time.Duration, connHook func(*conn) error) (*pool, error) {
+// newPool creates size connections to mpd server.
+// Connections are not shared between concurrent Exec calls.
+func newPool(size int, proto string, addr string, timeout time.Duration, reconnectionInterval time.Duration, connHook func(*conn) error) (*pool, error) {
+	if size < 1 {
+		size = 1
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	p := &pool{
+		size:                 size,
 		proto:                proto,
 		addr:                 addr,
 		Timeout:              timeout,
 		ReconnectionInterval: reconnectionInterval,
 		connHook:             connHook,
-		connC:                make(chan *conn, 1),
+		connC:                make(chan *conn, size),
 		connCtx:              ctx,
 		connCancel:           cancel,
 	}
-	if err := p.connectOnce(); err != nil {
-		return nil, err
+	for i := 0; i < size; i++ {
+		if err := p.connectOnce(); err != nil {
+			cancel()
+			for j := 0; j < i; j++ {
+				(<-p.connC).Close()
+			}
+			return nil, err
+		}
 	}
 	return p, nil
 }
@@ -56,14 +69,71 @@ func (c *pool) Exec(ctx context.Context, f func(*conn) error) error {
 	return c.returnConn(conn, err)
 }
 
+// ping sends ping command to all idle connections concurrently to find
+// connections closed by server before other commands use them.
+// Connections used by other commands are not checked.
+func (c *pool) ping(ctx context.Context) {
+	var conns []*conn
+	nils := 0
+idle:
+	for len(conns)+nils < c.size {
+		select {
+		case conn, ok := <-c.connC:
+			if !ok {
+				return
+			}
+			if conn == nil {
+				nils++
+				continue
+			}
+			conns = append(conns, conn)
+		default:
+			break idle
+		}
+	}
+	for i := 0; i < nils; i++ {
+		// keep closed slot for Close
+		c.connC <- nil
+	}
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if d, ok := ctx.Deadline(); ok {
+				conn.SetDeadline(d)
+			} else {
+				conn.SetDeadline(time.Time{})
+			}
+			c.returnConn(conn, execOK(conn, "ping"))
+		}()
+	}
+	wg.Wait()
+}
+
 func (c *pool) Close(ctx context.Context) error {
 	c.connCancel()
-	conn, err := c.get(ctx)
-	if err != nil {
-		return err
+	var err error
+	for i := 0; i < c.size; i++ {
+		select {
+		case conn, ok := <-c.connC:
+			if !ok {
+				return ErrClosed
+			}
+			if conn == nil {
+				// connection was lost and reconnection is canceled
+				err = ErrClosed
+				continue
+			}
+			if cerr := conn.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 	close(c.connC)
-	return conn.Close()
+	return err
 }
 
 func (c *pool) Version() string {
@@ -78,6 +148,11 @@ func (c *pool) get(ctx context.Context) (*conn, error) {
 		if !ok {
 			return nil, ErrClosed
 		}
+		if conn == nil {
+			// keep closed slot for Close
+			c.connC <- nil
+			return nil, ErrClosed
+		}
 		if d, ok := ctx.Deadline(); ok {
 			conn.SetDeadline(d)
 		} else {
@@ -106,7 +181,8 @@ func (c *pool) connect() {
 		if err := c.connectOnce(); err != nil {
 			select {
 			case <-c.connCtx.Done():
-				close(c.connC)
+				// notify Close that this slot has no connection
+				c.connC <- nil
 				return
 			case <-time.After(c.ReconnectionInterval):
 			}
@@ -16,7 +16,7 @@ func NewWatcher(proto, addr string, opts *WatcherOptions) (*Watcher, error) {
 	for i := range opts.SubSystems {
 		args[i] = opts.SubSystems[i]
 	}
-	pool, err := newPool(proto, addr, opts.Timeout, opts.ReconnectionInterval, opts.connectHook)
+	pool, err := newPool(1, proto, addr, opts.Timeout, opts.ReconnectionInterval, opts.connectHook)
 	if err != nil {
 		return nil, err
 	}
@@ -130,6 +130,9 @@ type WatcherOptions struct {
 	ReconnectionInterval time.Duration
 	// SubSystems are list of recieve events. Watcher recieves all events if SubSystems are empty.
 	SubSystems []string
+	// Partition switches connection to the given partition to watch its events.
+	// If empty, connection uses the default partition.
+	Partition string
 }
 
 func (c *WatcherOptions) connectHook(conn *conn) error {
@@ -138,5 +141,10 @@ func (c *WatcherOptions) connectHook(conn *conn) error {
 			return err
 		}
 	}
+	if len(c.Partition) > 0 {
+		if err := execOK(conn, "partition", c.Partition); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,202 @@
+package songs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// knownTags is tag names stored in Song without map.
+var knownTags = []string{
+	"file",
+	"Artist", "ArtistSort",
+	"Album", "AlbumSort",
+	"AlbumArtist", "AlbumArtistSort",
+	"Title", "Name", "Track", "Disc",
+	"Genre", "Date", "OriginalDate",
+	"Composer", "Performer", "Conductor",
+	"Work", "Grouping", "Comment", "Label",
+	"Time", "duration", "Format", "Last-Modified",
+	"Pos", "Id", "Prio",
+	// vv tags, see AddTags
+	"TrackNumber", "DiscNumber", "Length", "LastModifiedDate",
+}
+
+var knownTagIndex = func() map[string]int {
+	m := make(map[string]int, len(knownTags))
+	for i := range knownTags {
+		m[knownTags[i]] = i
+	}
+	return m
+}()
+
+// Song represents mpd song with parsed numeric fields.
+// Song is used for sorting songs repeatedly like library and playlist sort;
+// other api handlers use mpd song map as is.
+type Song struct {
+	// Track is parsed Track tag; 0 if not available.
+	Track int
+	// Disc is parsed Disc tag; 1 if not available.
+	Disc int
+	// Time is song duration in seconds; 0 if not available.
+	Time int
+	// LastModified is parsed Last-Modified tag; zero time if not available.
+	LastModified time.Time
+	tags         []songTag
+	// Extras contains tags not in known tag set.
+	Extras map[string][]string
+}
+
+// songTag is a value of known tag.
+type songTag struct {
+	key   int // index of knownTags
+	value []string
+}
+
+// NewSong creates Song from mpd song map.
+// NewSong adds tags for vv same as AddTags without modifying m.
+func NewSong(m map[string][]string) *Song {
+	// m and vv tags
+	s := &Song{tags: make([]songTag, 0, len(m)+4)}
+	for k, v := range m {
+		s.set(k, v)
+	}
+	s.Track = getIntTag(m, "Track", 0)
+	s.Disc = getIntTag(m, "Disc", 1)
+	s.Time = getIntTag(m, "Time", 0)
+	s.set("TrackNumber", []string{fmt.Sprintf("%04d", s.Track)})
+	s.set("DiscNumber", []string{fmt.Sprintf("%04d", s.Disc)})
+	s.set("Length", []string{fmt.Sprintf("%02d:%02d", s.Time/60, s.Time%60)})
+	if l := s.Get("Last-Modified"); len(l) == 1 {
+		if lt, err := time.Parse(time.RFC3339, l[0]); err == nil {
+			s.LastModified = lt
+			s.set("LastModifiedDate", []string{lt.Format("2006.01.02")})
+		}
+	}
+	return s
+}
+
+// NewSongs creates Song list from mpd song maps.
+func NewSongs(m []map[string][]string) []*Song {
+	s := make([]*Song, len(m))
+	for i := range m {
+		s[i] = NewSong(m[i])
+	}
+	return s
+}
+
+// File returns song file path.
+func (s *Song) File() string {
+	if f := s.Get("file"); len(f) != 0 {
+		return f[0]
+	}
+	return ""
+}
+
+// Get returns raw tag values in song.
+// returns nil if not found.
+func (s *Song) Get(key string) []string {
+	if i, ok := knownTagIndex[key]; ok {
+		for j := range s.tags {
+			if s.tags[j].key == i {
+				return s.tags[j].value
+			}
+		}
+		return nil
+	}
+	return s.Extras[key]
+}
+
+func (s *Song) set(key string, v []string) {
+	if i, ok := knownTagIndex[key]; ok {
+		for j := range s.tags {
+			if s.tags[j].key == i {
+				s.tags[j].value = v
+				return
+			}
+		}
+		s.tags = append(s.tags, songTag{key: i, value: v})
+		return
+	}
+	if s.Extras == nil {
+		s.Extras = map[string][]string{}
+	}
+	s.Extras[key] = v
+}
+
+// number returns parsed numeric value of tag for sorting.
+func (s *Song) number(key string) (int, bool) {
+	switch key {
+	case "TrackNumber":
+		return s.Track, true
+	case "DiscNumber":
+		return s.Disc, true
+	case "Length":
+		return s.Time, true
+	case "Track":
+		return s.Track, s.Get(key) != nil
+	case "Disc":
+		return s.Disc, s.Get(key) != nil
+	case "Time":
+		return s.Time, s.Get(key) != nil
+	}
+	return 0, false
+}
+
+// Tag returns tag values in song.
+// returns nil if not found.
+func (s *Song) Tag(key string) []string {
+	return tag(s.Get, key)
+}
+
+// Tags returns "-" separated tags values in song.
+// returns nil if not found.
+func (s *Song) Tags(tags string) []string {
+	return joinTags(s.Get, tags)
+}
+
+// Map returns song as mpd song map.
+func (s *Song) Map() map[string][]string {
+	m := make(map[string][]string, len(s.tags)+len(s.Extras))
+	for i := range s.tags {
+		m[knownTags[s.tags[i].key]] = s.tags[i].value
+	}
+	for k, v := range s.Extras {
+		m[k] = v
+	}
+	return m
+}
+
+// MarshalJSON returns song as json object same as Map().
+func (s *Song) MarshalJSON() ([]byte, error) {
+	keys := make([]string, 0, len(s.tags)+len(s.Extras))
+	for i := range s.tags {
+		keys = append(keys, knownTags[s.tags[i].key])
+	}
+	for k := range s.Extras {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i := range keys {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(keys[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		v, err := json.Marshal(s.Get(keys[i]))
+		if err != nil {
+			return nil, err
+		}
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,120 @@
+package songs
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewSong(t *testing.T) {
+	for _, tt := range []struct {
+		in        map[string][]string
+		track     int
+		disc      int
+		time      int
+		modified  time.Time
+		wantExtra map[string][]string
+	}{
+		{
+			in:    map[string][]string{"file": {"hoge"}},
+			track: 0, disc: 1, time: 0,
+		},
+		{
+			in:    map[string][]string{"file": {"appendix/hoge"}, "Track": {"1"}, "Disc": {"2"}, "Time": {"121"}, "Last-Modified": {"2008-09-28T20:04:57Z"}, "MUSICBRAINZ_TRACKID": {"foo"}},
+			track: 1, disc: 2, time: 121,
+			modified:  time.Date(2008, 9, 28, 20, 4, 57, 0, time.UTC),
+			wantExtra: map[string][]string{"MUSICBRAINZ_TRACKID": {"foo"}},
+		},
+	} {
+		s := NewSong(tt.in)
+		if s.Track != tt.track || s.Disc != tt.disc || s.Time != tt.time || !s.LastModified.Equal(tt.modified) {
+			t.Errorf("got NewSong(%v) = {%d %d %d %v}; want {%d %d %d %v}", tt.in, s.Track, s.Disc, s.Time, s.LastModified, tt.track, tt.disc, tt.time, tt.modified)
+		}
+		if !reflect.DeepEqual(s.Extras, tt.wantExtra) {
+			t.Errorf("got NewSong(%v).Extras = %v; want %v", tt.in, s.Extras, tt.wantExtra)
+		}
+		if got, want := s.File(), tt.in["file"][0]; got != want {
+			t.Errorf("got NewSong(%v).File() = %s; want %s", tt.in, got, want)
+		}
+		want := addTagsAll([]map[string][]string{tt.in})[0]
+		if got := s.Map(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got NewSong(%v).Map() = %v; want %v", tt.in, got, want)
+		}
+		wantJSON, _ := json.Marshal(want)
+		if got, err := json.Marshal(s); string(got) != string(wantJSON) || err != nil {
+			t.Errorf("got json.Marshal(NewSong(%v)) = %s, %v; want %s, <nil>", tt.in, got, err, wantJSON)
+		}
+	}
+}
+
+func TestSongTagMethods(t *testing.T) {
+	s := NewSong(map[string][]string{"Artist": {"baz", "qux"}, "Album": {"foo", "bar"}, "Date": {"2021"}})
+	for _, tt := range []struct {
+		input string
+		want  []string
+	}{
+		{input: "AlbumArtist", want: []string{"baz", "qux"}},
+		{input: "OriginalDate", want: []string{"2021"}},
+		{input: "Genre", want: nil},
+		{input: "Artist-Album", want: []string{"baz-foo", "baz-bar", "qux-foo", "qux-bar"}},
+	} {
+		if got := s.Tags(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("got Tags(%v) = %v; want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestWeakFilterSortSongs(t *testing.T) {
+	m := []map[string][]string{
+		{"file": {"c"}, "Artist": {"hoge", "fuga"}, "Album": {"piyo"}},
+		{"file": {"b"}, "Artist": {"bar"}, "Track": {"2"}, "Album": {"baz"}},
+		{"file": {"a"}, "Artist": {"foo", "bar"}, "Track": {"1"}, "Album": {"baz"}},
+	}
+	s := NewSongs(m)
+	for _, keys := range [][]string{{"Album", "TrackNumber"}, {"Artist", "Album"}} {
+		want, _, wantPos := WeakFilterSort(addTagsAll(m), keys, [][2]*string{{strPtr("Album"), strPtr("baz")}}, 0, 2, 1)
+		got, _, pos := WeakFilterSortSongs(s, keys, [][2]*string{{strPtr("Album"), strPtr("baz")}}, 0, 2, 1)
+		if !SortEqualSongs(want, got) || pos != wantPos {
+			t.Errorf("got WeakFilterSortSongs(_, %v, ...) = %v, _, %d; want %v, _, %d", keys, got, pos, want, wantPos)
+		}
+	}
+}
+
+func TestWeakFilterSortSongsNumeric(t *testing.T) {
+	s := NewSongs([]map[string][]string{
+		{"file": {"c"}, "Album": {"foo"}, "Track": {"10"}},
+		{"file": {"b"}, "Album": {"foo"}, "Track": {"9"}},
+		{"file": {"d"}, "Album": {"foo"}},
+		{"file": {"a"}, "Album": {"foo"}, "Track": {"1"}},
+	})
+	for _, tt := range []struct {
+		keys []string
+		want []string
+	}{
+		{keys: []string{"Album", "Track"}, want: []string{"d", "a", "b", "c"}},
+		{keys: []string{"Album", "TrackNumber"}, want: []string{"d", "a", "b", "c"}},
+	} {
+		got, _, _ := WeakFilterSortSongs(s, tt.keys, nil, 0, 100, -1)
+		files := make([]string, len(got))
+		for i := range got {
+			files[i] = got[i].File()
+		}
+		if !reflect.DeepEqual(files, tt.want) {
+			t.Errorf("got WeakFilterSortSongs(_, %v, ...) = %v; want %v", tt.keys, files, tt.want)
+		}
+	}
+}
+
+// addTagsAll applies AddTags to copy of songs.
+func addTagsAll(s []map[string][]string) []map[string][]string {
+	n := make([]map[string][]string, len(s))
+	for i := range s {
+		c := make(map[string][]string, len(s[i]))
+		for k, v := range s[i] {
+			c[k] = v
+		}
+		n[i] = AddTags(c)
+	}
+	return n
+}
@@ -17,45 +17,88 @@ func SortEqual(o, n []map[string][]string) bool {
 	return true
 }
 
-type sorter struct {
-	song    map[string][]string
-	keys    map[string]*string
-	sortkey string
-	target  bool
+// SortEqualSongs compares song filepath is equal
+func SortEqualSongs(o []map[string][]string, n []*Song) bool {
+	if len(o) != len(n) {
+		return false
+	}
+	for i := range n {
+		if o[i]["file"][0] != n[i].File() {
+			return false
+		}
+	}
+	return true
+}
+
+type sorter[S any] struct {
+	song   S
+	keys   map[string]*string
+	values []sortValue
+	target bool
+}
+
+// sortValue is a value of sort key; num is used instead of str if both
+// values have numeric value.
+type sortValue struct {
+	str   string
+	num   int
+	isNum bool
+}
+
+func (s *sorter[S]) less(o *sorter[S]) bool {
+	for i := range s.values {
+		a, b := s.values[i], o.values[i]
+		if a.isNum && b.isNum {
+			if a.num != b.num {
+				return a.num < b.num
+			}
+		} else if a.str != b.str {
+			return a.str < b.str
+		}
+	}
+	return false
 }
 
-func sortKeys(s map[string][]string, keys []string) []*sorter {
-	sp := []*sorter{{song: s, keys: make(map[string]*string, len(keys))}}
+func sortKeys[S any](s S, tags func(S, string) []string, num func(S, string) (int, bool), keys []string) []*sorter[S] {
+	sp := []*sorter[S]{{song: s, keys: make(map[string]*string, len(keys)), values: make([]sortValue, 0, len(keys))}}
 	for _, key := range keys {
-		sp = addAllKeys(sp, key, Tags(s, key))
+		var v sortValue
+		var ok bool
+		if num != nil {
+			v.num, ok = num(s, key)
+		}
+		sp = addAllKeys(sp, key, tags(s, key), v.num, ok)
 	}
 	return sp
 }
 
-func addAllKeys(sp []*sorter, key string, add []string) []*sorter {
+// addAllKeys adds key values to sorters; num is used as numeric value of key
+// if isNum is true and song has single value.
+func addAllKeys[S any](sp []*sorter[S], key string, add []string, num int, isNum bool) []*sorter[S] {
 	if len(add) == 0 {
 		for i := range sp {
-			sp[i].sortkey = sp[i].sortkey + " "
+			sp[i].values = append(sp[i].values, sortValue{str: " "})
 			sp[i].keys[key] = nil
 		}
 		return sp
 	}
 	if len(add) == 1 {
 		for i := range sp {
-			sp[i].sortkey = sp[i].sortkey + add[0]
+			sp[i].values = append(sp[i].values, sortValue{str: add[0], num: num, isNum: isNum})
 			sp[i].keys[key] = &add[0]
 		}
 		return sp
 	}
-	newsp := make([]*sorter, len(sp)*len(add))
+	newsp := make([]*sorter[S], len(sp)*len(add))
 	index := 0
 	for i := range sp {
 		for j := range add {
-			s := &sorter{song: sp[i].song, keys: make(map[string]*string, len(sp[i].keys))}
+			s := &sorter[S]{song: sp[i].song, keys: make(map[string]*string, len(sp[i].keys)), values: make([]sortValue, len(sp[i].values), cap(sp[i].values))}
 			for k := range sp[i].keys {
 				s.keys[k] = sp[i].keys[k]
 			}
-			s.sortkey = s.sortkey + add[j]
+			copy(s.values, sp[i].values)
+			s.values = append(s.values, sortValue{str: add[j]})
 			s.keys[key] = &add[j]
 			newsp[index] = s
 			index++
@@ -66,15 +109,25 @@ func addAllKeys(sp []*sorter, key string, add []string) []*sorter {
 
 // WeakFilterSort sorts songs by song tag list.
 func WeakFilterSort(s []map[string][]string, keys []string, filters [][2]*string, must, max, pos int) ([]map[string][]string, [][2]*string, int) {
-	flatten := flat(s, keys)
+	return weakFilterSort(s, Tags, nil, keys, filters, must, max, pos)
+}
+
+// WeakFilterSortSongs sorts songs by song tag list.
+// Track, Disc and Time tags and its vv tags are sorted by parsed numeric value.
+func WeakFilterSortSongs(s []*Song, keys []string, filters [][2]*string, must, max, pos int) ([]*Song, [][2]*string, int) {
+	return weakFilterSort(s, (*Song).Tags, (*Song).number, keys, filters, must, max, pos)
+}
+
+func weakFilterSort[S any](s []S, tags func(S, string) []string, num func(S, string) (int, bool), keys []string, filters [][2]*string, must, max, pos int) ([]S, [][2]*string, int) {
+	flatten := flat(s, tags, num, keys)
 	sort.Slice(flatten, func(i, j int) bool {
-		return flatten[i].sortkey < flatten[j].sortkey
+		return flatten[i].less(flatten[j])
 	})
 	if pos < len(flatten) && pos >= 0 {
 		flatten[pos].target = true
 	}
 	flatten, used := weakFilterSongs(flatten, filters, must, max)
-	ret := make([]map[string][]string, len(flatten))
+	ret := make([]S, len(flatten))
 	newpos := -1
 	for i, sorter := range flatten {
 		ret[i] = sorter.song
@@ -85,17 +138,17 @@ func WeakFilterSort(s []map[string][]string, keys []string, filters [][2]*string
 	return ret, used, newpos
 }
 
-func flat(s []map[string][]string, keys []string) []*sorter {
-	flatten := make([]*sorter, 0, len(s))
+func flat[S any](s []S, tags func(S, string) []string, num func(S, string) (int, bool), keys []string) []*sorter[S] {
+	flatten := make([]*sorter[S], 0, len(s))
 	for _, song := range s {
-		flatten = append(flatten, sortKeys(song, keys)...)
+		flatten = append(flatten, sortKeys(song, tags, num, keys)...)
 	}
 	return flatten
 }
 
 // weakFilterSongs removes songs if not matched by filters until len(songs) over max.
 // filters example: [][]string{[]string{"Artist", "foo"}}
-func weakFilterSongs(s []*sorter, filters [][2]*string, must, max int) ([]*sorter, [][2]*string) {
+func weakFilterSongs[S any](s []*sorter[S], filters [][2]*string, must, max int) ([]*sorter[S], [][2]*string) {
 	used := [][2]*string{}
 	if len(s) <= max && must == 0 {
 		return s, used
@@ -106,7 +159,7 @@ func weakFilterSongs(s []*sorter, filters [][2]*string, must, max int) ([]*sorte
 			break
 		}
 		used = append(used, filter)
-		nc := make([]*sorter, 0, len(n))
+		nc := make([]*sorter[S], 0, len(n))
 		for _, sorter := range n {
 			key, want := filter[0], filter[1]
 			if key == nil {
@@ -118,7 +171,7 @@ func weakFilterSongs(s []*sorter, filters [][2]*string, must, max int) ([]*sorte
 		n = nc
 	}
 	if len(n) > max {
-		nc := make([]*sorter, max)
+		nc := make([]*sorter[S], max)
 		for i := range n {
 			if i < max {
 				nc[i] = n[i]
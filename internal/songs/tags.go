@@ -39,10 +39,30 @@ func getIntTag(m map[string][]string, k string, e int) int {
 // Tags returns "-" separated tags values in song.
 // returns nil if not found.
 func Tags(s map[string][]string, tags string) []string {
+	return joinTags(mapGetter(s), tags)
+}
+
+// Tag returns tag values in song.
+// returns nil if not found.
+func Tag(s map[string][]string, key string) []string {
+	return tag(mapGetter(s), key)
+}
+
+// TagSearch searches tags in song.
+// returns nil if not found.
+func TagSearch(s map[string][]string, keys []string) []string {
+	return tagSearch(mapGetter(s), keys)
+}
+
+func mapGetter(s map[string][]string) func(string) []string {
+	return func(key string) []string { return s[key] }
+}
+
+func joinTags(get func(string) []string, tags string) []string {
 	keys := strings.Split(tags, "-")
 	var ret []string
 	for _, key := range keys {
-		t := Tag(s, key)
+		t := tag(get, key)
 		if ret == nil {
 			ret = t
 		} else if t != nil {
@@ -58,38 +78,33 @@ func Tags(s map[string][]string, tags string) []string {
 	return ret
 }
 
-// Tag returns tag values in song.
-// returns nil if not found.
-func Tag(s map[string][]string, key string) []string {
-	if v, found := s[key]; found {
+func tag(get func(string) []string, key string) []string {
+	if v := get(key); v != nil {
 		return v
 	} else if key == "AlbumArtist" {
-		return Tag(s, "Artist")
+		return tag(get, "Artist")
 	} else if key == "AlbumSort" {
-		return Tag(s, "Album")
+		return tag(get, "Album")
 	} else if key == "ArtistSort" {
-		return Tag(s, "Artist")
+		return tag(get, "Artist")
 	} else if key == "AlbumArtistSort" {
-		return TagSearch(s, []string{"AlbumArtist", "Artist"})
+		return tagSearch(get, []string{"AlbumArtist", "Artist"})
 	} else if key == "Date" {
-		if v, found := s["OriginalDate"]; found {
+		if v := get("OriginalDate"); v != nil {
 			return v
 		}
 	} else if key == "OriginalDate" {
-		if v, found := s["Date"]; found {
+		if v := get("Date"); v != nil {
 			return v
 		}
 	}
 	return nil
 }
 
-// TagSearch searches tags in song.
-// returns nil if not found.
-func TagSearch(s map[string][]string, keys []string) []string {
+func tagSearch(get func(string) []string, keys []string) []string {
 	for i := range keys {
-		key := keys[i]
-		if _, ok := s[key]; ok {
-			return s[key]
+		if v := get(keys[i]); v != nil {
+			return v
 		}
 	}
 	return nil
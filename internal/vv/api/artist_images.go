@@ -0,0 +1,31 @@
+package api
+
+// ArtistImageProvider represents http artist image url api.
+type ArtistImageProvider interface {
+	// GetArtistURLs returns artist image urls for AlbumArtist of song.
+	GetArtistURLs(map[string][]string) []string
+}
+
+// artistImages adds artist image urls to songs.
+type artistImages struct {
+	apis []ArtistImageProvider
+}
+
+// ConvSong adds artist_image urls of the first provider which has images to song.
+func (a *artistImages) ConvSong(s map[string][]string) map[string][]string {
+	delete(s, "artist_image")
+	for _, api := range a.apis {
+		if urls := api.GetArtistURLs(s); len(urls) != 0 {
+			s["artist_image"] = urls
+			break
+		}
+	}
+	return s
+}
+
+func (a *artistImages) ConvSongs(s []map[string][]string) []map[string][]string {
+	for i := range s {
+		s[i] = a.ConvSong(s[i])
+	}
+	return s
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArtistImages(t *testing.T) {
+	a := &artistImages{apis: []ArtistImageProvider{
+		artistImageFunc(func(s map[string][]string) []string {
+			if s["AlbumArtist"][0] == "foo" {
+				return []string{"/foo.jpg"}
+			}
+			return []string{}
+		}),
+		artistImageFunc(func(s map[string][]string) []string { return []string{"/fallback.jpg"} }),
+	}}
+	for _, tt := range []struct {
+		in   map[string][]string
+		want map[string][]string
+	}{{
+		in:   map[string][]string{"AlbumArtist": {"foo"}, "artist_image": {"/old.jpg"}},
+		want: map[string][]string{"AlbumArtist": {"foo"}, "artist_image": {"/foo.jpg"}},
+	}, {
+		in:   map[string][]string{"AlbumArtist": {"bar"}},
+		want: map[string][]string{"AlbumArtist": {"bar"}, "artist_image": {"/fallback.jpg"}},
+	}} {
+		if got := a.ConvSongs([]map[string][]string{tt.in}); !reflect.DeepEqual(got, []map[string][]string{tt.want}) {
+			t.Errorf("got ConvSongs() = %v; want %v", got, []map[string][]string{tt.want})
+		}
+	}
+	empty := &artistImages{}
+	if got, want := empty.ConvSong(map[string][]string{"artist_image": {"/old.jpg"}}), map[string][]string{}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got ConvSong() = %v; want %v", got, want)
+	}
+}
+
+type artistImageFunc func(map[string][]string) []string
+
+func (f artistImageFunc) GetArtistURLs(s map[string][]string) []string { return f(s) }
@@ -3,7 +3,9 @@ package api
 import (
 	"context"
 	"errors"
+	"path"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,11 +26,28 @@ type ImageProvider interface {
 	GetURLs(map[string][]string) ([]string, bool)
 }
 
+// ImagePalette represents image provider which extracts colors from images.
+type ImagePalette interface {
+	// Palette returns dominant, accent and text colors as "#rrggbb" for image url.
+	Palette(string) ([]string, bool)
+}
+
+// ImageBlurhash represents image provider which generates blurhash placeholders.
+type ImageBlurhash interface {
+	// Blurhash returns blurhash string for image url.
+	Blurhash(string) (string, bool)
+}
+
 // imgBatch provides background updater for cover image api.
 type imgBatch struct {
-	apis []ImageProvider
-	sem  chan struct{}
-	e    chan bool
+	apis    []ImageProvider
+	workers int
+	sem     chan struct{}
+	e       chan bool
+
+	progressMu   sync.Mutex
+	progress     imgBatchProgress
+	progressTime time.Time
 
 	shutdownMu sync.Mutex
 	shutdownCh chan struct{}
@@ -36,10 +55,27 @@ type imgBatch struct {
 	logger     Logger
 }
 
-// newImgBatch creates Batch from some cover image api.
-func newImgBatch(apis []ImageProvider, logger Logger) *imgBatch {
+// imgBatchProgress is progress of the last image update.
+type imgBatchProgress struct {
+	Total   int    // number of albums to update
+	Done    int    // number of updated albums
+	Errors  int    // number of albums failed to update
+	Current string // last started album
+}
+
+// imgBatchProgressInterval is the minimum interval of progress events.
+const imgBatchProgressInterval = time.Second
+
+// newImgBatch creates Batch from some cover image api. Batch updates workers
+// albums concurrently; workers should not exceed the number of mpd
+// connections for binary commands to avoid blocking other requests.
+func newImgBatch(apis []ImageProvider, workers int, logger Logger) *imgBatch {
+	if workers < 1 {
+		workers = 1
+	}
 	ret := &imgBatch{
 		apis:       apis,
+		workers:    workers,
 		sem:        make(chan struct{}, 1),
 		e:          make(chan bool, 2), // 2: first updating/updated event
 		shutdownCh: make(chan struct{}),
@@ -49,6 +85,13 @@ func newImgBatch(apis []ImageProvider, logger Logger) *imgBatch {
 	return ret
 }
 
+// Progress returns progress of the running or the last update.
+func (b *imgBatch) Progress() imgBatchProgress {
+	b.progressMu.Lock()
+	defer b.progressMu.Unlock()
+	return b.progress
+}
+
 // Event returns event chan which returns bool updating or not.
 func (b *imgBatch) Event() <-chan bool {
 	return b.e
@@ -69,7 +112,34 @@ func (b *imgBatch) GetURLs(song map[string][]string) (urls []string, updated boo
 	return urls, allUpdated
 }
 
-var songsTag = songs.Tag
+// Palette returns image colors for image url.
+func (b *imgBatch) Palette(url string) ([]string, bool) {
+	for _, api := range b.apis {
+		if p, ok := api.(ImagePalette); ok {
+			if colors, ok := p.Palette(url); ok {
+				return colors, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Blurhash returns image placeholder for image url.
+func (b *imgBatch) Blurhash(url string) (string, bool) {
+	for _, api := range b.apis {
+		if p, ok := api.(ImageBlurhash); ok {
+			if hash, ok := p.Blurhash(url); ok {
+				return hash, true
+			}
+		}
+	}
+	return "", false
+}
+
+var (
+	songsTag  = songs.Tag
+	songsTags = songs.Tags
+)
 
 // Update updates image url database.
 func (b *imgBatch) Update(songs []map[string][]string) error {
@@ -91,6 +161,14 @@ func (b *imgBatch) update(songs []map[string][]string, force bool) error {
 	default:
 		return errAlreadyUpdating
 	}
+	albums := imgBatchAlbums(songs)
+	b.progressMu.Lock()
+	b.progress = imgBatchProgress{Total: len(albums)}
+	if len(albums) != 0 {
+		b.progress.Current = albums[0].name
+	}
+	b.progressTime = time.Now()
+	b.progressMu.Unlock()
 	select {
 	case b.e <- true:
 	default:
@@ -106,35 +184,127 @@ func (b *imgBatch) update(songs []map[string][]string, force bool) error {
 				cancel()
 			}
 		}()
-		for _, song := range songs {
-			for _, c := range b.apis {
-				if force {
-					if err := c.Rescan(ctx, song, reqID); err != nil {
-						b.logger.Printf("vv/api: batch: rescan: %v: %v", songsTag(song, "file"), err)
-						// use previous rescanned result
+		jobs := make(chan *imgBatchAlbum)
+		var wg sync.WaitGroup
+		for i := 0; i < b.workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for album := range jobs {
+					b.updateProgress(album.name, 0, 0)
+					ok := b.updateAlbum(ctx, album.song, force, reqID)
+					if ok {
+						b.updateProgress("", 1, 0)
+					} else {
+						b.updateProgress("", 1, 1)
 					}
-				} else {
-					if err := c.Update(ctx, song); err != nil {
-						b.logger.Printf("vv/api: batch: update: %v: %v", songsTag(song, "file"), err)
-						// use previous rescanned result
-					}
-				}
-				urls, _ := c.GetURLs(song)
-				if len(urls) > 0 {
-					break
 				}
+			}()
+		}
+	L:
+		for _, album := range albums {
+			select {
+			case jobs <- album:
+			case <-ctx.Done():
+				break L
 			}
 		}
+		close(jobs)
+		wg.Wait()
+		b.progressMu.Lock()
+		b.progress.Current = ""
+		b.progressMu.Unlock()
 		select {
 		case <-ctx.Done():
 		case b.e <- false:
-		default:
-			b.logger.Println("vv/api: batch: fixme: event buffer is too small")
 		}
 	}()
 	return nil
 }
 
+// updateAlbum updates images by providers in order until image is found.
+// updateAlbum returns false if any provider failed.
+func (b *imgBatch) updateAlbum(ctx context.Context, song map[string][]string, force bool, reqID string) bool {
+	ok := true
+	for _, c := range b.apis {
+		if force {
+			if err := c.Rescan(ctx, song, reqID); err != nil {
+				b.logger.Printf("vv/api: batch: rescan: %v: %v", songsTag(song, "file"), err)
+				ok = false
+				// use previous rescanned result
+			}
+		} else {
+			if err := c.Update(ctx, song); err != nil {
+				b.logger.Printf("vv/api: batch: update: %v: %v", songsTag(song, "file"), err)
+				ok = false
+				// use previous rescanned result
+			}
+		}
+		urls, _ := c.GetURLs(song)
+		if len(urls) > 0 {
+			break
+		}
+	}
+	return ok
+}
+
+// updateProgress adds done and errors count and sends progress event at
+// most once per imgBatchProgressInterval.
+func (b *imgBatch) updateProgress(current string, done, errors int) {
+	b.progressMu.Lock()
+	if len(current) != 0 {
+		b.progress.Current = current
+	}
+	b.progress.Done += done
+	b.progress.Errors += errors
+	now := time.Now()
+	notify := now.Sub(b.progressTime) >= imgBatchProgressInterval
+	if notify {
+		b.progressTime = now
+	}
+	b.progressMu.Unlock()
+	if notify {
+		select {
+		case b.e <- true:
+		default:
+		}
+	}
+}
+
+// imgBatchAlbum is a representative song of songs which share cover images.
+type imgBatchAlbum struct {
+	name string
+	song map[string][]string
+}
+
+// imgBatchAlbums groups songs by directory and album tags to call providers
+// once per album.
+func imgBatchAlbums(songs []map[string][]string) []*imgBatchAlbum {
+	keys := map[string]struct{}{}
+	ret := []*imgBatchAlbum{}
+	for _, song := range songs {
+		var dir string
+		if f := song["file"]; len(f) == 1 {
+			dir = path.Dir(f[0])
+		}
+		tags := songsTags(song, "AlbumArtist-Album-Date-Label")
+		key := dir + "\n" + strings.Join(tags, ",")
+		if _, ok := keys[key]; ok {
+			continue
+		}
+		keys[key] = struct{}{}
+		name := dir
+		if album := songsTag(song, "Album"); len(album) != 0 {
+			name = strings.Join(album, ",")
+			if artist := songsTag(song, "AlbumArtist"); len(artist) != 0 {
+				name = strings.Join(artist, ",") + " - " + name
+			}
+		}
+		ret = append(ret, &imgBatchAlbum{name: name, song: song})
+	}
+	return ret
+}
+
 // Shutdown gracefully shuts down cover image updater.
 func (b *imgBatch) Shutdown(ctx context.Context) error {
 	b.shutdownMu.Lock()
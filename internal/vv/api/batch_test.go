@@ -3,6 +3,9 @@ package api
 import (
 	"context"
 	"errors"
+	"reflect"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -26,7 +29,7 @@ func TestImgBatch(t *testing.T) {
 				return nil
 			}, func(context.Context, map[string][]string, string) error { return errors.New("must not be called") })
 
-		batch := newImgBatch([]ImageProvider{cov1, cov2}, log.NewTestLogger(t))
+		batch := newImgBatch([]ImageProvider{cov1, cov2}, 1, log.NewTestLogger(t))
 		if err := batch.Update([]map[string][]string{{"file": {"/foo/bar"}}}); err != nil {
 			t.Errorf("batch.Update() = %v; want %v", err, nil)
 		}
@@ -75,7 +78,7 @@ func TestImgBatch(t *testing.T) {
 				return nil
 			})
 
-		batch := newImgBatch([]ImageProvider{cov1, cov2}, log.NewTestLogger(t))
+		batch := newImgBatch([]ImageProvider{cov1, cov2}, 1, log.NewTestLogger(t))
 		if err := batch.Rescan([]map[string][]string{{"file": {"/foo/bar"}}}); err != nil {
 			t.Errorf("batch.Rescan() = %v; want %v", err, nil)
 		}
@@ -115,7 +118,7 @@ func TestImgBatch(t *testing.T) {
 				<-ctx.Done()
 				return nil
 			}, func(context.Context, map[string][]string, string) error { return errors.New("must not be called") })
-		batch := newImgBatch([]ImageProvider{cov}, log.NewTestLogger(t))
+		batch := newImgBatch([]ImageProvider{cov}, 1, log.NewTestLogger(t))
 		if err := batch.Update([]map[string][]string{{"file": {"/foo/bar"}}}); err != nil {
 			t.Errorf("batch.Update() = %v; want nil", err)
 		}
@@ -128,16 +131,23 @@ func TestImgBatch(t *testing.T) {
 	t.Run("shutdown timeout", func(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 		defer cancel()
+		called := make(chan struct{})
 		cov := newCoverFunc(func(map[string][]string) ([]string, bool) { return []string{""}, true },
 			func(context.Context, map[string][]string) error {
+				close(called)
 				<-ctx.Done()
 				return nil
 			}, func(context.Context, map[string][]string, string) error { return errors.New("must not be called") })
-		batch := newImgBatch([]ImageProvider{cov}, log.NewTestLogger(t))
+		batch := newImgBatch([]ImageProvider{cov}, 1, log.NewTestLogger(t))
 		if err := batch.Update([]map[string][]string{{"file": {"/foo/bar"}}}); err != nil {
 			t.Errorf("batch.Update() = err; want nil")
 		}
 		testEvent(ctx, t, batch.Event(), true, true)
+		select {
+		case <-called:
+		case <-ctx.Done():
+			t.Fatal("cover update is not called")
+		}
 		sctx, scancel := context.WithTimeout(ctx, time.Millisecond)
 		defer scancel()
 		if err := batch.Shutdown(sctx); err != context.DeadlineExceeded {
@@ -147,7 +157,7 @@ func TestImgBatch(t *testing.T) {
 	t.Run("empty", func(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 		defer cancel()
-		batch := newImgBatch([]ImageProvider{}, log.NewTestLogger(t))
+		batch := newImgBatch([]ImageProvider{}, 1, log.NewTestLogger(t))
 		if err := batch.Update([]map[string][]string{{"file": {"/foo/bar"}}}); err != nil {
 			t.Errorf("batch.Update() = %v; want nil", err)
 		}
@@ -163,6 +173,73 @@ func TestImgBatch(t *testing.T) {
 	})
 }
 
+func TestImgBatchParallel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	var mu sync.Mutex
+	called := []string{}
+	running := make(chan struct{}, 2)
+	cov := newCoverFunc(func(map[string][]string) ([]string, bool) { return []string{""}, true },
+		func(ctx context.Context, song map[string][]string) error {
+			mu.Lock()
+			called = append(called, song["file"][0])
+			mu.Unlock()
+			// waits for the other worker to check concurrency
+			running <- struct{}{}
+			for len(running) != 2 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Millisecond):
+				}
+			}
+			if song["Album"][0] == "baz" {
+				return errors.New("test error")
+			}
+			return nil
+		}, func(context.Context, map[string][]string, string) error { return errors.New("must not be called") })
+	batch := newImgBatch([]ImageProvider{cov}, 2, log.NewTestLogger(t))
+	if err := batch.Update([]map[string][]string{
+		{"file": {"foo/1.flac"}, "Album": {"foo"}},
+		{"file": {"foo/2.flac"}, "Album": {"foo"}},
+		{"file": {"bar/1.flac"}, "Album": {"baz"}, "AlbumArtist": {"qux"}},
+	}); err != nil {
+		t.Fatalf("batch.Update() = %v; want nil", err)
+	}
+	testEvent(ctx, t, batch.Event(), true, true)
+	testEvent(ctx, t, batch.Event(), false, true)
+	sort.Strings(called)
+	if want := []string{"bar/1.flac", "foo/1.flac"}; !reflect.DeepEqual(called, want) {
+		t.Errorf("called Update for %v; want %v", called, want)
+	}
+	if got, want := batch.Progress(), (imgBatchProgress{Total: 2, Done: 2, Errors: 1}); got != want {
+		t.Errorf("got Progress() = %+v; want %+v", got, want)
+	}
+	if err := batch.Shutdown(ctx); err != nil {
+		t.Errorf("got batch.Shutdown() = %v; want nil", err)
+	}
+}
+
+func TestImgBatchAlbums(t *testing.T) {
+	songs := []map[string][]string{
+		{"file": {"foo/1.flac"}, "Album": {"foo"}, "AlbumArtist": {"bar"}},
+		{"file": {"foo/2.flac"}, "Album": {"foo"}, "AlbumArtist": {"bar"}},
+		{"file": {"foo/3.flac"}, "Album": {"baz"}},
+		{"file": {"qux/1.flac"}, "Album": {"foo"}, "AlbumArtist": {"bar"}},
+		{"file": {"quux/1.flac"}},
+	}
+	got := imgBatchAlbums(songs)
+	want := []*imgBatchAlbum{
+		{name: "bar - foo", song: songs[0]},
+		{name: "baz", song: songs[2]},
+		{name: "bar - foo", song: songs[3]},
+		{name: "quux", song: songs[4]},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
 func testEvent(ctx context.Context, t *testing.T, e <-chan bool, want bool, ok bool) {
 	t.Helper()
 	select {
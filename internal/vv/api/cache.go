@@ -71,7 +71,7 @@ func (c *cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	c.mu.RLock()
 	b, gz, date := c.json, c.gzjson, c.date
 	c.mu.RUnlock()
-	etag := fmt.Sprintf(`"%d.%d"`, date.Unix(), date.Nanosecond())
+	etag := cacheETag(date)
 	if request.NoneMatch(r, etag) {
 		w.WriteHeader(http.StatusNotModified)
 		return
@@ -101,6 +101,10 @@ func (c *cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Write(b)
 }
 
+func cacheETag(date time.Time) string {
+	return fmt.Sprintf(`"%d.%d"`, date.Unix(), date.Nanosecond())
+}
+
 func (c *cache) set(i interface{}, force bool) (bool, error) {
 	n, gz, err := cacheBinary(i)
 	if err != nil {
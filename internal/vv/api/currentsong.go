@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"net/http"
+	"sync"
 )
 
 type MPDCurrentSong interface {
@@ -13,6 +14,9 @@ type CurrentSongHandler struct {
 	mpd      MPDCurrentSong
 	cache    *cache
 	songHook func(map[string][]string) map[string][]string
+	data     map[string][]string
+	raw      map[string][]string
+	mu       sync.RWMutex
 }
 
 func NewCurrentSongHandler(mpd MPDCurrentSong, songHook func(map[string][]string) map[string][]string) (*CurrentSongHandler, error) {
@@ -32,10 +36,33 @@ func (a *CurrentSongHandler) Update(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	_, err = a.cache.SetIfModified(a.songHook(l))
+	raw := make(map[string][]string, len(l))
+	for k, v := range l {
+		raw[k] = v
+	}
+	v := a.songHook(l)
+	a.mu.Lock()
+	a.data = v
+	a.raw = raw
+	a.mu.Unlock()
+	_, err = a.cache.SetIfModified(v)
 	return err
 }
 
+// Cache returns current song.
+func (a *CurrentSongHandler) Cache() map[string][]string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.data
+}
+
+// Raw returns current song tags from mpd without songHook.
+func (a *CurrentSongHandler) Raw() map[string][]string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.raw
+}
+
 func (a *CurrentSongHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	a.cache.ServeHTTP(w, r)
 }
@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -22,6 +23,7 @@ func TestCurrentSongHandlerGET(t *testing.T) {
 		err         error
 		want        string
 		cache       map[string][]string
+		raw         map[string][]string
 		changed     bool
 	}{
 		"ok": {{
@@ -29,18 +31,21 @@ func TestCurrentSongHandlerGET(t *testing.T) {
 			currentSong: func() (map[string][]string, error) { return map[string][]string{}, nil },
 			want:        fmt.Sprintf(`{"%s":["%s"]}`, randValue, randValue),
 			cache:       map[string][]string{randValue: {randValue}},
+			raw:         map[string][]string{},
 			changed:     true,
 		}, {
 			label:       "some data",
 			currentSong: func() (map[string][]string, error) { return map[string][]string{"file": {"/foo/bar.mp3"}}, nil },
 			want:        fmt.Sprintf(`{"%s":["%s"],"file":["/foo/bar.mp3"]}`, randValue, randValue),
 			cache:       map[string][]string{"file": {"/foo/bar.mp3"}, randValue: {randValue}},
+			raw:         map[string][]string{"file": {"/foo/bar.mp3"}},
 			changed:     true,
 		}, {
 			label:       "remove",
 			currentSong: func() (map[string][]string, error) { return map[string][]string{}, nil },
 			want:        fmt.Sprintf(`{"%s":["%s"]}`, randValue, randValue),
 			cache:       map[string][]string{randValue: {randValue}},
+			raw:         map[string][]string{},
 			changed:     true,
 		}},
 		"error": {{
@@ -48,6 +53,7 @@ func TestCurrentSongHandlerGET(t *testing.T) {
 			currentSong: func() (map[string][]string, error) { return map[string][]string{"file": {"/foo/bar.mp3"}}, nil },
 			want:        fmt.Sprintf(`{"%s":["%s"],"file":["/foo/bar.mp3"]}`, randValue, randValue),
 			cache:       map[string][]string{"file": {"/foo/bar.mp3"}, randValue: {randValue}},
+			raw:         map[string][]string{"file": {"/foo/bar.mp3"}},
 			changed:     true,
 		}, {
 			label:       "error",
@@ -55,6 +61,7 @@ func TestCurrentSongHandlerGET(t *testing.T) {
 			err:         errTest,
 			want:        fmt.Sprintf(`{"%s":["%s"],"file":["/foo/bar.mp3"]}`, randValue, randValue),
 			cache:       map[string][]string{"file": {"/foo/bar.mp3"}, randValue: {randValue}},
+			raw:         map[string][]string{"file": {"/foo/bar.mp3"}},
 		}},
 	} {
 		t.Run(label, func(t *testing.T) {
@@ -76,6 +83,9 @@ func TestCurrentSongHandlerGET(t *testing.T) {
 					if status, got := w.Result().StatusCode, w.Body.String(); status != http.StatusOK || got != tt[i].want {
 						t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", status, got, http.StatusOK, tt[i].want)
 					}
+					if got := h.Raw(); !reflect.DeepEqual(got, tt[i].raw) {
+						t.Errorf("Raw() = %v; want %v", got, tt[i].raw)
+					}
 					if changed := recieveMsg(h.Changed()); changed != tt[i].changed {
 						t.Errorf("changed = %v; want %v", changed, tt[i].changed)
 					}
@@ -3,9 +3,13 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,15 +20,25 @@ import (
 
 const (
 	pathAPIMusicStatus               = "/api/music"
+	pathAPIMusicHistory              = "/api/music/history"
 	pathAPIMusicImages               = "/api/music/images"
+	pathAPIMusicImagesCache          = "/api/music/images/cache"
+	pathAPIMusicImagesOverride       = "/api/music/images/override"
+	pathAPIMusicLyrics               = "/api/music/lyrics"
 	pathAPIMusicLibrary              = "/api/music/library"
+	pathAPIMusicLibrarySearch        = "/api/music/library/search"
 	pathAPIMusicLibrarySongs         = "/api/music/library/songs"
+	pathAPIMusicLibrarySongsComments = "/api/music/library/songs/comments"
 	pathAPIMusicOutputs              = "/api/music/outputs"
 	pathAPIMusicOutputsStream        = "/api/music/outputs/stream"
+	pathAPIMusicPartitions           = "/api/music/partitions"
 	pathAPIMusicPlaylist             = "/api/music/playlist"
 	pathAPIMusicPlaylistSongs        = "/api/music/playlist/songs"
 	pathAPIMusicPlaylistSongsCurrent = "/api/music/playlist/songs/current"
+	pathAPIMusicPlaylists            = "/api/music/playlists"
+	pathAPIMusicPlaylistsSongs       = "/api/music/playlists/songs"
 	pathAPIMusicStats                = "/api/music/stats"
+	pathAPIMusicStickers             = "/api/music/stickers"
 	pathAPIMusicStorage              = "/api/music/storage"
 	pathAPIMusicStorageNeighbors     = "/api/music/storage/neighbors"
 	pathAPIVersion                   = "/api/version"
@@ -37,21 +51,41 @@ type Config struct {
 	AudioProxy        map[string]string // audio device - mpd http server addr pair to proxy
 	skipInit          bool              // do not initialize mpd cache(for test)
 	ImageProviders    []ImageProvider
+	ImageWorkers      int // number of albums to update cover images concurrently; defaults to 1
+	ArtistImages      []ArtistImageProvider
+	LyricsProviders   []LyricsProvider
+	Scrobblers        []Scrobbler // submits listened songs
 	Logger            Logger
+	CacheDirectory    string // directory to persist library songs and play history; disabled if empty
+	ServerName        string // serves api under /api/servers/<ServerName>/ if not empty
+	Partition         string // mpd partition name of mpd connections; "default" if empty
+	// DialPartition connects to other mpd partition selected by partition
+	// query; partition query is ignored if nil.
+	DialPartition func(ctx context.Context, partition string) (*mpd.Client, *mpd.Watcher, error)
 }
 
 // Handler implements http.Handler for vv json api.
 type Handler struct {
 	apiMusic                     *StatusHandler
+	apiMusicHistory              *HistoryHandler
 	apiMusicImages               *ImagesHandler
+	apiMusicImagesCache          *ImagesCacheHandler
+	apiMusicImagesOverride       *ImagesOverrideHandler
 	apiMusicLibrary              *LibraryHandler
+	apiMusicLyrics               *LyricsHandler
+	apiMusicLibrarySearch        *LibrarySearchHandler
 	apiMusicLibrarySongs         *LibrarySongsHandler
+	apiMusicLibrarySongsComments *LibrarySongsCommentsHandler
 	apiMusicOutputs              *OutputsHandler
 	apiMusicOutputsStream        *OutputsStreamHandler
+	apiMusicPartitions           *PartitionsHandler
 	apiMusicPlaylist             *PlaylistHandler
 	apiMusicPlaylistSongs        *PlaylistSongsHandler
 	apiMusicPlaylistSongsCurrent *CurrentSongHandler
+	apiMusicPlaylists            *StoredPlaylistsHandler
+	apiMusicPlaylistsSongs       *StoredPlaylistSongsHandler
 	apiMusicStats                *StatsHandler
+	apiMusicStickers             *StickersHandler
 	apiMusicStorage              *StorageHandler
 	apiMusicStorageNeighbors     *NeighborsHandler
 	apiVersion                   *VersionHandler
@@ -60,10 +94,17 @@ type Handler struct {
 	closable                     []interface{ Close() }
 	stoppable                    []interface{ Stop() }
 	shutdownable                 []interface{ Shutdown(context.Context) error }
+	pathPrefix                   string
+	config                       *Config
+	partition                    string
+	parent                       *Handler         // handler which owns shared apis; nil if h owns them
+	router                       *PartitionRouter // handlers of other partitions; nil if disabled
+	children                     map[*Handler]struct{}
+	mu                           sync.Mutex
 }
 
 // NewHandler creates Handler and initialize mpd cache data.
-func NewHandler(ctx context.Context, cl *mpd.Client, w *mpd.Watcher, c *Config) (*Handler, error) {
+func NewHandler(ctx context.Context, cl *mpd.Client, w *mpd.Watcher, c *Config) (_ *Handler, err error) {
 	if c == nil {
 		c = &Config{}
 	}
@@ -73,14 +114,32 @@ func NewHandler(ctx context.Context, cl *mpd.Client, w *mpd.Watcher, c *Config)
 	if c.Logger == nil {
 		c.Logger = log.New(io.Discard)
 	}
-	h := &Handler{}
-	var err error
-	if h.apiMusic, err = NewStatusHandler(cl); err != nil {
+	h := &Handler{config: c, children: map[*Handler]struct{}{}}
+	if len(c.ServerName) != 0 {
+		h.pathPrefix = ServerPath(c.ServerName)
+	}
+	hooked := false
+	defer func() {
+		if err == nil {
+			return
+		}
+		if hooked {
+			// other handlers are closed by event goroutine on watcher close;
+			// release cache db locks now.
+			h.apiMusicHistory.Close()
+			h.apiMusicLibrarySongs.Close()
+			return
+		}
+		for i := range h.closable {
+			h.closable[i].Close()
+		}
+	}()
+	if h.apiMusicStickers, err = NewStickersHandler(cl, c.Logger); err != nil {
 		return nil, err
 	}
-	h.closable = append(h.closable, h.apiMusic)
+	h.closable = append(h.closable, h.apiMusicStickers)
 
-	if h.apiMusicImages, err = NewImagesHandler(c.ImageProviders, c.Logger); err != nil {
+	if h.apiMusicImages, err = NewImagesHandler(c.ImageProviders, c.ImageWorkers, c.Logger); err != nil {
 		return nil, err
 	}
 	h.songHooks = append(h.songHooks, func(s map[string][]string) map[string][]string { s, _ = h.apiMusicImages.ConvSong(s); return s })
@@ -88,41 +147,66 @@ func NewHandler(ctx context.Context, cl *mpd.Client, w *mpd.Watcher, c *Config)
 	h.closable = append(h.closable, h.apiMusicImages)
 	h.shutdownable = append(h.shutdownable, h.apiMusicImages)
 
-	if h.apiMusicLibrary, err = NewLibraryHandler(cl); err != nil {
+	if len(c.ArtistImages) != 0 {
+		a := &artistImages{apis: c.ArtistImages}
+		h.songHooks = append(h.songHooks, a.ConvSong)
+		h.songsHooks = append(h.songsHooks, a.ConvSongs)
+	}
+
+	var imageCaches []ImageCache
+	for _, p := range c.ImageProviders {
+		if ic, ok := p.(ImageCache); ok {
+			imageCaches = append(imageCaches, ic)
+		}
+	}
+	for _, p := range c.ArtistImages {
+		if ic, ok := p.(ImageCache); ok {
+			imageCaches = append(imageCaches, ic)
+		}
+	}
+	if h.apiMusicImagesCache, err = NewImagesCacheHandler(imageCaches, c.Logger); err != nil {
 		return nil, err
 	}
-	h.closable = append(h.closable, h.apiMusicLibrary)
+	h.closable = append(h.closable, h.apiMusicImagesCache)
+	h.shutdownable = append(h.shutdownable, h.apiMusicImagesCache)
 
-	if h.apiMusicLibrarySongs, err = NewLibrarySongsHandler(cl, h.songsHook); err != nil {
+	var imageOverride ImageOverride
+	for _, p := range c.ImageProviders {
+		if o, ok := p.(ImageOverride); ok {
+			imageOverride = o
+			break
+		}
+	}
+	if h.apiMusicImagesOverride, err = NewImagesOverrideHandler(imageOverride); err != nil {
 		return nil, err
 	}
-	h.closable = append(h.closable, h.apiMusicLibrarySongs)
+	h.closable = append(h.closable, h.apiMusicImagesOverride)
+
+	h.songHooks = append(h.songHooks, h.apiMusicStickers.ConvSong)
+	h.songsHooks = append(h.songsHooks, h.apiMusicStickers.ConvSongs)
 
-	if h.apiMusicOutputs, err = NewOutputsHandler(cl, c.AudioProxy); err != nil {
+	if h.apiMusicLibrary, err = NewLibraryHandler(cl); err != nil {
 		return nil, err
 	}
-	h.closable = append(h.closable, h.apiMusicOutputs)
+	h.closable = append(h.closable, h.apiMusicLibrary)
 
-	if h.apiMusicOutputsStream, err = NewOutputsStreamHandler(c.AudioProxy, c.Logger); err != nil {
+	if h.apiMusicLibrarySearch, err = NewLibrarySearchHandler(cl, h.songsHook); err != nil {
 		return nil, err
 	}
-	h.stoppable = append(h.stoppable, h.apiMusicOutputsStream)
 
-	if h.apiMusicPlaylist, err = NewPlaylistHandler(cl, c); err != nil {
+	if h.apiMusicLibrarySongs, err = NewLibrarySongsHandler(cl, h.songsHook, c.CacheDirectory); err != nil {
 		return nil, err
 	}
-	h.closable = append(h.closable, h.apiMusicPlaylist)
-	h.shutdownable = append(h.shutdownable, h.apiMusicPlaylist)
+	h.closable = append(h.closable, h.apiMusicLibrarySongs)
 
-	if h.apiMusicPlaylistSongs, err = NewPlaylistSongsHandler(cl, h.songsHook); err != nil {
+	if h.apiMusicLibrarySongsComments, err = NewLibrarySongsCommentsHandler(cl); err != nil {
 		return nil, err
 	}
-	h.closable = append(h.closable, h.apiMusicPlaylistSongs)
 
-	if h.apiMusicPlaylistSongsCurrent, err = NewCurrentSongHandler(cl, h.songHook); err != nil {
+	if h.apiMusicOutputsStream, err = NewOutputsStreamHandler(c.AudioProxy, c.Logger); err != nil {
 		return nil, err
 	}
-	h.closable = append(h.closable, h.apiMusicPlaylistSongsCurrent)
+	h.stoppable = append(h.stoppable, h.apiMusicOutputsStream)
 
 	if h.apiMusicStats, err = NewStatsHandler(cl); err != nil {
 		return nil, err
@@ -147,14 +231,269 @@ func NewHandler(ctx context.Context, cl *mpd.Client, w *mpd.Watcher, c *Config)
 	}
 	// remove changed event for test stability
 	clearChan(h.apiVersion.Changed())
+
+	if err := h.newPartitionAPIs(cl, c); err != nil {
+		return nil, err
+	}
+	if c.DialPartition != nil {
+		h.router = NewPartitionRouter(cl, h.partition, http.HandlerFunc(h.serveHTTP), h.newPartitionHandler)
+	}
+	hooked = true
 	if err := h.hookEvent(ctx, w, c); err != nil {
 		return nil, err
 	}
 	return h, nil
 }
 
+// newPartitionAPIs creates apis which depend on mpd partition of cl.
+func (h *Handler) newPartitionAPIs(cl *mpd.Client, c *Config) (err error) {
+	h.partition = c.Partition
+	if len(h.partition) == 0 {
+		h.partition = defaultPartition
+	}
+	if h.apiMusic, err = NewStatusHandler(cl); err != nil {
+		return err
+	}
+	h.closable = append(h.closable, h.apiMusic)
+
+	// counts listened songs as play count stickers
+	scrobblers := append(append([]Scrobbler{}, c.Scrobblers...), h.apiMusicStickers)
+	if h.apiMusicHistory, err = NewHistoryHandler(c.CacheDirectory, scrobblers, c.Logger); err != nil {
+		return err
+	}
+	// closes history first to scrobble current song before stickers are closed
+	h.closable = append([]interface{ Close() }{h.apiMusicHistory}, h.closable...)
+
+	if h.apiMusicLyrics, err = NewLyricsHandler(c.LyricsProviders); err != nil {
+		return err
+	}
+	h.closable = append(h.closable, h.apiMusicLyrics)
+
+	if h.apiMusicOutputs, err = NewOutputsHandler(cl, c.AudioProxy, c.Partition); err != nil {
+		return err
+	}
+	h.closable = append(h.closable, h.apiMusicOutputs)
+
+	if h.apiMusicPartitions, err = NewPartitionsHandler(&partitionReleaser{Client: cl, release: h.releasePartition}, c.Partition, c.Logger); err != nil {
+		return err
+	}
+	h.closable = append(h.closable, h.apiMusicPartitions)
+
+	if h.apiMusicPlaylist, err = NewPlaylistHandler(cl, c); err != nil {
+		return err
+	}
+	h.closable = append(h.closable, h.apiMusicPlaylist)
+	h.shutdownable = append(h.shutdownable, h.apiMusicPlaylist)
+
+	if h.apiMusicPlaylistSongs, err = NewPlaylistSongsHandler(cl, h.songsHook); err != nil {
+		return err
+	}
+	h.closable = append(h.closable, h.apiMusicPlaylistSongs)
+
+	if h.apiMusicPlaylistSongsCurrent, err = NewCurrentSongHandler(cl, h.songHook); err != nil {
+		return err
+	}
+	h.closable = append(h.closable, h.apiMusicPlaylistSongsCurrent)
+
+	if h.apiMusicPlaylists, err = NewStoredPlaylistsHandler(cl, c.Logger); err != nil {
+		return err
+	}
+	h.closable = append(h.closable, h.apiMusicPlaylists)
+	if h.apiMusicPlaylistsSongs, err = NewStoredPlaylistSongsHandler(cl, h.songsHook); err != nil {
+		return err
+	}
+	return nil
+}
+
+// newPartitionHandler connects to mpd partition name and creates its api handler.
+func (h *Handler) newPartitionHandler(name string) (http.Handler, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.BackgroundTimeout)
+	defer cancel()
+	cl, w, err := h.config.DialPartition(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	p, err := h.newPartition(ctx, cl, w, name)
+	if err != nil {
+		cl.Close(ctx)
+		w.Close(ctx)
+		return nil, err
+	}
+	return &partitionHandler{Handler: p, client: cl, watcher: w}, nil
+}
+
+// newPartition creates Handler for mpd partition name which shares database
+// apis like library, images and stickers with h.
+func (h *Handler) newPartition(ctx context.Context, cl *mpd.Client, w *mpd.Watcher, name string) (_ *Handler, err error) {
+	c := *h.config
+	c.Partition = name
+	if len(c.CacheDirectory) != 0 {
+		c.CacheDirectory = filepath.Join(c.CacheDirectory, "partitions", url.PathEscape(name))
+	}
+	p := &Handler{
+		apiMusicImages:               h.apiMusicImages,
+		apiMusicImagesCache:          h.apiMusicImagesCache,
+		apiMusicImagesOverride:       h.apiMusicImagesOverride,
+		apiMusicLibrary:              h.apiMusicLibrary,
+		apiMusicLibrarySearch:        h.apiMusicLibrarySearch,
+		apiMusicLibrarySongs:         h.apiMusicLibrarySongs,
+		apiMusicLibrarySongsComments: h.apiMusicLibrarySongsComments,
+		apiMusicOutputsStream:        h.apiMusicOutputsStream,
+		apiMusicStats:                h.apiMusicStats,
+		apiMusicStickers:             h.apiMusicStickers,
+		apiMusicStorage:              h.apiMusicStorage,
+		apiMusicStorageNeighbors:     h.apiMusicStorageNeighbors,
+		apiVersion:                   h.apiVersion,
+		songHooks:                    h.songHooks,
+		songsHooks:                   h.songsHooks,
+		pathPrefix:                   h.pathPrefix,
+		config:                       &c,
+		parent:                       h,
+	}
+	hooked := false
+	defer func() {
+		if err == nil {
+			return
+		}
+		if hooked {
+			p.apiMusicHistory.Close()
+			return
+		}
+		for i := range p.closable {
+			p.closable[i].Close()
+		}
+	}()
+	if err := p.newPartitionAPIs(cl, &c); err != nil {
+		return nil, err
+	}
+	p.apiMusicPlaylist.UpdateLibrarySongs(h.apiMusicLibrarySongs.Songs())
+	h.mu.Lock()
+	h.children[p] = struct{}{}
+	h.mu.Unlock()
+	hooked = true
+	if err := p.hookEvent(ctx, w, &c); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// partitionHandler is api handler of mpd partition with its mpd connections.
+type partitionHandler struct {
+	*Handler
+	client  *mpd.Client
+	watcher *mpd.Watcher
+}
+
+// Close closes mpd connections and background tasks of the partition.
+func (p *partitionHandler) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.BackgroundTimeout)
+	defer cancel()
+	p.Stop()
+	if err := p.client.Close(ctx); err != nil {
+		p.config.Logger.Printf("vv/api: partition %s: %v", p.partition, err)
+	}
+	if err := p.watcher.Close(ctx); err != nil {
+		p.config.Logger.Printf("vv/api: partition %s: %v", p.partition, err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		p.config.Logger.Printf("vv/api: partition %s: %v", p.partition, err)
+	}
+}
+
+// partitionReleaser closes connections to mpd partition before removing it;
+// mpd can not remove partition which has clients.
+type partitionReleaser struct {
+	*mpd.Client
+	release func(string)
+}
+
+func (p *partitionReleaser) DelPartition(ctx context.Context, name string) error {
+	p.release(name)
+	return p.Client.DelPartition(ctx, name)
+}
+
+// releasePartition closes handler of mpd partition name.
+func (h *Handler) releasePartition(name string) {
+	if h.parent != nil {
+		h = h.parent
+	}
+	if h.router != nil {
+		h.router.Remove(name)
+	}
+}
+
+// partitions returns h and handlers of other partitions.
+func (h *Handler) partitions() []*Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ret := make([]*Handler, 0, len(h.children)+1)
+	ret = append(ret, h)
+	for p := range h.children {
+		ret = append(ret, p)
+	}
+	return ret
+}
+
+// ServerPath returns api path prefix for named mpd server.
+func ServerPath(name string) string {
+	return "/api/servers/" + name + "/"
+}
+
+// broadcast sends api path to websocket clients.
+func (h *Handler) broadcast(path string) {
+	if len(h.pathPrefix) != 0 {
+		path = h.pathPrefix + strings.TrimPrefix(path, "/api/")
+	}
+	h.apiMusic.BroadCast(path)
+}
+
+// rehookImages applies updated image urls to library songs and current songs
+// of all partitions without listing library songs again.
+func (h *Handler) rehookImages(c *Config) {
+	if err := h.apiMusicLibrarySongs.Rehook(); err != nil {
+		c.Logger.Printf("vv/api: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.BackgroundTimeout)
+	defer cancel()
+	for _, p := range h.partitions() {
+		if err := p.apiMusicPlaylistSongsCurrent.Update(ctx); err != nil {
+			c.Logger.Printf("vv/api: %v", err)
+		}
+	}
+}
+
+// broadcastAll sends api path to websocket clients of all partitions.
+func (h *Handler) broadcastAll(path string) {
+	for _, p := range h.partitions() {
+		p.broadcast(path)
+	}
+}
+
 // ServeHTTP serves vv json api.
+// ServeHTTP serves api of other mpd partition given by partition query if
+// Config.DialPartition is set.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.router != nil {
+		h.router.ServeHTTP(w, r)
+		return
+	}
+	h.serveHTTP(w, r)
+}
+
+func (h *Handler) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(h.pathPrefix) != 0 {
+		if !strings.HasPrefix(r.URL.Path, h.pathPrefix) {
+			http.NotFound(w, r)
+			return
+		}
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = "/api/" + strings.TrimPrefix(r.URL.Path, h.pathPrefix)
+		r2.URL.RawPath = ""
+		r = r2
+	}
 	switch r.URL.Path {
 	case pathAPIVersion:
 		h.apiVersion.ServeHTTP(w, r)
@@ -162,22 +501,42 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.apiMusic.ServeHTTP(w, r)
 	case pathAPIMusicStats:
 		h.apiMusicStats.ServeHTTP(w, r)
+	case pathAPIMusicStickers:
+		h.apiMusicStickers.ServeHTTP(w, r)
 	case pathAPIMusicPlaylist:
 		h.apiMusicPlaylist.ServeHTTP(w, r)
 	case pathAPIMusicPlaylistSongs:
 		h.apiMusicPlaylistSongs.ServeHTTP(w, r)
 	case pathAPIMusicPlaylistSongsCurrent:
 		h.apiMusicPlaylistSongsCurrent.ServeHTTP(w, r)
+	case pathAPIMusicPlaylists:
+		h.apiMusicPlaylists.ServeHTTP(w, r)
+	case pathAPIMusicPlaylistsSongs:
+		h.apiMusicPlaylistsSongs.ServeHTTP(w, r)
 	case pathAPIMusicLibrary:
 		h.apiMusicLibrary.ServeHTTP(w, r)
+	case pathAPIMusicLibrarySearch:
+		h.apiMusicLibrarySearch.ServeHTTP(w, r)
 	case pathAPIMusicLibrarySongs:
 		h.apiMusicLibrarySongs.ServeHTTP(w, r)
+	case pathAPIMusicLibrarySongsComments:
+		h.apiMusicLibrarySongsComments.ServeHTTP(w, r)
 	case pathAPIMusicOutputs:
 		h.apiMusicOutputs.ServeHTTP(w, r)
 	case pathAPIMusicOutputsStream:
 		h.apiMusicOutputsStream.ServeHTTP(w, r)
+	case pathAPIMusicPartitions:
+		h.apiMusicPartitions.ServeHTTP(w, r)
 	case pathAPIMusicImages:
 		h.apiMusicImages.ServeHTTP(w, r)
+	case pathAPIMusicImagesCache:
+		h.apiMusicImagesCache.ServeHTTP(w, r)
+	case pathAPIMusicImagesOverride:
+		h.apiMusicImagesOverride.ServeHTTP(w, r)
+	case pathAPIMusicHistory:
+		h.apiMusicHistory.ServeHTTP(w, r)
+	case pathAPIMusicLyrics:
+		h.apiMusicLyrics.ServeHTTP(w, r)
 	case pathAPIMusicStorage:
 		h.apiMusicStorage.ServeHTTP(w, r)
 	case pathAPIMusicStorageNeighbors:
@@ -194,8 +553,11 @@ func (h *Handler) Stop() {
 	}
 }
 
-// Shutdown stops background api.
+// Shutdown stops background api and closes handlers of other partitions.
 func (h *Handler) Shutdown(ctx context.Context) error {
+	if h.router != nil {
+		h.router.Close()
+	}
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	errs := make(chan error, len(h.shutdownable))
@@ -226,9 +588,11 @@ func clearChan(c <-chan struct{}) {
 }
 
 func (h *Handler) hookEvent(ctx context.Context, w *mpd.Watcher, c *Config) error {
+	// partition handlers leave apis shared with parent to parent
+	shared := h.parent == nil
 	go func() {
 		for range h.apiMusic.Changed() {
-			h.apiMusic.BroadCast(pathAPIMusicStatus)
+			h.broadcast(pathAPIMusicStatus)
 			if err := h.apiMusicLibrary.UpdateStatus(h.apiMusic.Cache().Updating); err != nil {
 				c.Logger.Printf("vv/api: %v", err)
 			}
@@ -237,114 +601,135 @@ func (h *Handler) hookEvent(ctx context.Context, w *mpd.Watcher, c *Config) erro
 					c.Logger.Printf("vv/api: %v", err)
 				}
 			}
+			h.observeHistory(c)
 		}
 	}()
 	go func() {
-		for updating := range h.apiMusicImages.Changed() {
-			h.apiMusic.BroadCast(pathAPIMusicImages)
-			if !updating {
-				ctx, cancel := context.WithTimeout(context.Background(), c.BackgroundTimeout)
-				if err := h.apiMusicPlaylistSongsCurrent.Update(ctx); err != nil {
-					c.Logger.Printf("vv/api: %v", err)
-				}
-				if err := h.apiMusicLibrarySongs.Update(ctx); err != nil {
-					c.Logger.Printf("vv/api: %v", err)
-				}
-				cancel()
-			}
+		for range h.apiMusicLyrics.Changed() {
+			h.broadcast(pathAPIMusicLyrics)
 		}
 	}()
 	go func() {
-		for range h.apiMusicLibrary.Changed() {
-			h.apiMusic.BroadCast(pathAPIMusicLibrary)
+		for range h.apiMusicHistory.Changed() {
+			h.broadcast(pathAPIMusicHistory)
 		}
 	}()
+	if shared {
+		h.hookSharedEvent(c)
+	}
 	go func() {
-		for range h.apiMusicLibrarySongs.Changed() {
-			h.apiMusic.BroadCast(pathAPIMusicLibrarySongs)
-			h.apiMusicPlaylist.UpdateLibrarySongs(h.apiMusicLibrarySongs.Cache())
+		for range h.apiMusicOutputs.Changed() {
+			h.broadcast(pathAPIMusicOutputs)
 		}
 	}()
 	go func() {
-		for range h.apiMusicOutputs.Changed() {
-			h.apiMusic.BroadCast(pathAPIMusicOutputs)
+		for range h.apiMusicPartitions.Changed() {
+			h.broadcast(pathAPIMusicPartitions)
+			if h.router != nil {
+				ctx, cancel := context.WithTimeout(context.Background(), c.BackgroundTimeout)
+				if err := h.router.Update(ctx); err != nil {
+					c.Logger.Printf("vv/api: %v", err)
+				}
+				cancel()
+			}
 		}
 	}()
 	go func() {
 		for range h.apiMusicPlaylist.Changed() {
-			h.apiMusic.BroadCast(pathAPIMusicPlaylist)
+			h.broadcast(pathAPIMusicPlaylist)
 		}
 	}()
 	go func() {
 		for range h.apiMusicPlaylistSongs.Changed() {
-			h.apiMusic.BroadCast(pathAPIMusicPlaylistSongs)
+			h.broadcast(pathAPIMusicPlaylistSongs)
 			h.apiMusicPlaylist.UpdatePlaylistSongs(h.apiMusicPlaylistSongs.Cache())
 		}
 	}()
 	go func() {
 		for range h.apiMusicPlaylistSongsCurrent.Changed() {
-			h.apiMusic.BroadCast(pathAPIMusicPlaylistSongsCurrent)
-		}
-	}()
-	go func() {
-		for range h.apiMusicStats.Changed() {
-			h.apiMusic.BroadCast(pathAPIMusicStats)
-		}
-	}()
-	go func() {
-		for range h.apiMusicStorage.Changed() {
-			h.apiMusic.BroadCast(pathAPIMusicStorage)
-		}
-	}()
-	go func() {
-		for range h.apiMusicStorageNeighbors.Changed() {
-			h.apiMusic.BroadCast(pathAPIMusicStorageNeighbors)
+			h.broadcast(pathAPIMusicPlaylistSongsCurrent)
+			h.observeHistory(c)
+			song := h.apiMusicPlaylistSongsCurrent.Cache()
+			ctx, cancel := context.WithTimeout(context.Background(), c.BackgroundTimeout)
+			if err := h.apiMusicLyrics.Update(ctx, song); err != nil {
+				c.Logger.Printf("vv/api: lyrics: %v", err)
+			}
+			cancel()
 		}
 	}()
 	go func() {
-		for range h.apiVersion.Changed() {
-			h.apiMusic.BroadCast(pathAPIVersion)
+		for range h.apiMusicPlaylists.Changed() {
+			h.broadcast(pathAPIMusicPlaylists)
 		}
 	}()
 
-	all := []func(context.Context) error{
-		h.apiMusicLibrarySongs.Update,
+	var all []func(context.Context) error
+	librarySongsUpdate := h.apiMusicLibrarySongs.Update
+	if h.apiMusicLibrarySongs.HasSnapshot() {
+		librarySongsUpdate = h.apiMusicLibrarySongs.Revalidate
+	}
+	if shared {
+		// stickers are required by library songs hook
+		all = append(all, h.apiMusicStickers.Update, librarySongsUpdate)
+	}
+	others := []func(context.Context) error{
 		h.apiMusicPlaylistSongs.Update,
 		h.apiMusic.UpdateOptions,
 		h.apiMusicPlaylistSongsCurrent.Update,
 		h.apiMusicOutputs.Update,
-		h.apiMusicStats.Update,
-		h.apiMusicStorage.Update,
-		h.apiMusicStorageNeighbors.Update,
 	}
+	if shared {
+		others = append(others,
+			h.apiMusicStats.Update,
+			h.apiMusicStorage.Update,
+			h.apiMusicStorageNeighbors.Update,
+		)
+	}
+	others = append(others, h.apiMusicPlaylists.Update, h.apiMusicPartitions.Update)
+	all = append(all, others...)
 	go func() {
 		for e := range w.Event() {
 			ctx, cancel := context.WithTimeout(context.Background(), c.BackgroundTimeout)
 			switch e {
 			case "reconnecting":
+				if !shared {
+					break
+				}
 				if err := h.apiVersion.UpdateNoMPD(); err != nil {
 					c.Logger.Printf("vv/api: %v", err)
 				}
 			case "reconnect":
-				if err := h.apiVersion.Update(); err != nil {
-					c.Logger.Printf("vv/api: %v", err)
+				if shared {
+					if err := h.apiVersion.Update(); err != nil {
+						c.Logger.Printf("vv/api: %v", err)
+					}
 				}
 				for _, v := range all {
 					if err := v(ctx); err != nil {
 						c.Logger.Printf("vv/api: %v", err)
+						if !shared {
+							continue
+						}
+						if err := h.apiVersion.UpdateError(err); err != nil {
+							c.Logger.Printf("vv/api: %v", err)
+						}
 					}
 				}
 			case "database":
-				if err := h.apiMusicLibrarySongs.Update(ctx); err != nil {
-					c.Logger.Printf("vv/api: %v", err)
+				if shared {
+					if err := h.apiMusicLibrarySongs.Update(ctx); err != nil {
+						c.Logger.Printf("vv/api: %v", err)
+					}
 				}
 				if err := h.apiMusic.Update(ctx); err != nil {
 					c.Logger.Printf("vv/api: %v", err)
 				}
 				// h.apiMusicPlaylistSongsCurrent.Update(ctx) // "currentsong" metadata did not updated until song changes
 				// h.apiMusicPlaylistSongs.Update(ctx) // client does not use this api
-				if err := h.apiMusicStats.Update(ctx); err != nil {
-					c.Logger.Printf("vv/api: %v", err)
+				if shared {
+					if err := h.apiMusicStats.Update(ctx); err != nil {
+						c.Logger.Printf("vv/api: %v", err)
+					}
 				}
 			case "playlist":
 				if err := h.apiMusicPlaylistSongs.Update(ctx); err != nil {
@@ -357,7 +742,13 @@ func (h *Handler) hookEvent(ctx context.Context, w *mpd.Watcher, c *Config) erro
 				if err := h.apiMusicPlaylistSongsCurrent.Update(ctx); err != nil {
 					c.Logger.Printf("vv/api: %v", err)
 				}
-				if err := h.apiMusicStats.Update(ctx); err != nil {
+				if shared {
+					if err := h.apiMusicStats.Update(ctx); err != nil {
+						c.Logger.Printf("vv/api: %v", err)
+					}
+				}
+			case "stored_playlist":
+				if err := h.apiMusicPlaylists.Update(ctx); err != nil {
 					c.Logger.Printf("vv/api: %v", err)
 				}
 			case "mixer":
@@ -376,11 +767,28 @@ func (h *Handler) hookEvent(ctx context.Context, w *mpd.Watcher, c *Config) erro
 				if err := h.apiMusicOutputs.Update(ctx); err != nil {
 					c.Logger.Printf("vv/api: %v", err)
 				}
+			case "partition":
+				if err := h.apiMusicPartitions.Update(ctx); err != nil {
+					c.Logger.Printf("vv/api: %v", err)
+				}
+			case "sticker":
+				if !shared {
+					break
+				}
+				if err := h.apiMusicStickers.UpdateExternal(ctx); err != nil {
+					c.Logger.Printf("vv/api: %v", err)
+				}
 			case "mount":
+				if !shared {
+					break
+				}
 				if err := h.apiMusicStorage.Update(ctx); err != nil {
 					c.Logger.Printf("vv/api: %v", err)
 				}
 			case "neighbor":
+				if !shared {
+					break
+				}
 				if err := h.apiMusicStorageNeighbors.Update(ctx); err != nil {
 					c.Logger.Printf("vv/api: %v", err)
 				}
@@ -391,12 +799,29 @@ func (h *Handler) hookEvent(ctx context.Context, w *mpd.Watcher, c *Config) erro
 		for i := range h.closable {
 			h.closable[i].Close()
 		}
+		if !shared {
+			h.parent.mu.Lock()
+			delete(h.parent.children, h)
+			h.parent.mu.Unlock()
+		}
 	}()
 	if c.skipInit {
 		return nil
 	}
-	for i := range all {
-		if err := all[i](ctx); err != nil {
+	initAll := all
+	if shared && h.apiMusicLibrarySongs.HasSnapshot() {
+		// serve persisted library songs while revalidating
+		initAll = append([]func(context.Context) error{h.apiMusicStickers.Update}, others...)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), c.BackgroundTimeout)
+			defer cancel()
+			if err := librarySongsUpdate(ctx); err != nil {
+				c.Logger.Printf("vv/api: %v", err)
+			}
+		}()
+	}
+	for i := range initAll {
+		if err := initAll[i](ctx); err != nil {
 			return err
 		}
 	}
@@ -411,6 +836,136 @@ func (h *Handler) hookEvent(ctx context.Context, w *mpd.Watcher, c *Config) erro
 	return nil
 }
 
+// hookSharedEvent broadcasts changes of apis shared by partitions and
+// updates partition apis which depend on them.
+func (h *Handler) hookSharedEvent(c *Config) {
+	go func() {
+		for updating := range h.apiMusicImages.Changed() {
+			h.broadcastAll(pathAPIMusicImages)
+			if !updating {
+				h.rehookImages(c)
+			}
+		}
+	}()
+	go func() {
+		for range h.apiMusicImages.URLChanged() {
+			h.rehookImages(c)
+		}
+	}()
+	go func() {
+		for range h.apiMusicImagesCache.Changed() {
+			h.broadcastAll(pathAPIMusicImagesCache)
+		}
+	}()
+	go func() {
+		for range h.apiMusicImagesOverride.Changed() {
+			h.broadcastAll(pathAPIMusicImagesOverride)
+		}
+	}()
+	go func() {
+		for range h.apiMusicLibrary.Changed() {
+			h.broadcastAll(pathAPIMusicLibrary)
+		}
+	}()
+	go func() {
+		var collected string // db_update of library songs collected by images cache gc
+		for range h.apiMusicLibrarySongs.Changed() {
+			h.broadcastAll(pathAPIMusicLibrarySongs)
+			typed := h.apiMusicLibrarySongs.Songs()
+			for _, p := range h.partitions() {
+				p.apiMusicPlaylist.UpdateLibrarySongs(typed)
+			}
+			library := h.apiMusicLibrarySongs.Cache()
+			h.apiMusicImages.UpdateLibrarySongs(library)
+			h.apiMusicImagesCache.UpdateLibrarySongs(library)
+			// songs are removed from library by mpd database update only
+			dbUpdate := h.apiMusicLibrarySongs.DBUpdate()
+			if dbUpdate == collected {
+				continue
+			}
+			if err := h.apiMusicImagesCache.GC(); err == nil {
+				collected = dbUpdate
+			} else if !errors.Is(err, errAlreadyCollecting) && !errors.Is(err, errEmptyLibrary) && !errors.Is(err, ErrAlreadyShutdown) {
+				c.Logger.Printf("vv/api: images cache: %v", err)
+			}
+		}
+	}()
+	go func() {
+		for range h.apiMusicStickers.Changed() {
+			h.broadcastAll(pathAPIMusicStickers)
+			ctx, cancel := context.WithTimeout(context.Background(), c.BackgroundTimeout)
+			for _, p := range h.partitions() {
+				if err := p.apiMusicPlaylistSongsCurrent.Update(ctx); err != nil {
+					c.Logger.Printf("vv/api: %v", err)
+				}
+				if err := p.apiMusicPlaylistSongs.Update(ctx); err != nil {
+					c.Logger.Printf("vv/api: %v", err)
+				}
+			}
+			if err := h.apiMusicLibrarySongs.Rehook(); err != nil {
+				c.Logger.Printf("vv/api: %v", err)
+			}
+			cancel()
+		}
+	}()
+	go func() {
+		for file := range h.apiMusicStickers.SongChanged() {
+			h.broadcastAll(pathAPIMusicStickers)
+			for _, p := range h.partitions() {
+				if f := p.apiMusicPlaylistSongsCurrent.Raw()["file"]; len(f) != 0 && f[0] == file {
+					ctx, cancel := context.WithTimeout(context.Background(), c.BackgroundTimeout)
+					if err := p.apiMusicPlaylistSongsCurrent.Update(ctx); err != nil {
+						c.Logger.Printf("vv/api: %v", err)
+					}
+					cancel()
+				}
+			}
+			if err := h.apiMusicLibrarySongs.RehookSong(file); err != nil {
+				c.Logger.Printf("vv/api: %v", err)
+			}
+		}
+	}()
+	go func() {
+		for range h.apiMusicStats.Changed() {
+			h.broadcastAll(pathAPIMusicStats)
+		}
+	}()
+	go func() {
+		for range h.apiMusicStorage.Changed() {
+			h.broadcastAll(pathAPIMusicStorage)
+		}
+	}()
+	go func() {
+		for range h.apiMusicStorageNeighbors.Changed() {
+			h.broadcastAll(pathAPIMusicStorageNeighbors)
+		}
+	}()
+	go func() {
+		for range h.apiVersion.Changed() {
+			h.broadcastAll(pathAPIVersion)
+		}
+	}()
+}
+
+// observeHistory records play history from current song and status cache.
+func (h *Handler) observeHistory(c *Config) {
+	status := h.apiMusic.Cache()
+	if status == nil {
+		return
+	}
+	var state string
+	if status.State != nil {
+		state = *status.State
+	}
+	var elapsed float64
+	if status.SongElapsed != nil {
+		elapsed = *status.SongElapsed
+	}
+	if err := h.apiMusicHistory.Observe(h.apiMusicPlaylistSongsCurrent.Raw(), status.Song, state, elapsed); err != nil {
+		c.Logger.Printf("vv/api: %v", err)
+	}
+}
+
 func (h *Handler) songHook(s map[string][]string) map[string][]string {
 	s = songs.AddTags(s)
 	for i := range h.songHooks {
@@ -438,6 +993,19 @@ func writeHTTPError(w http.ResponseWriter, status int, err error) {
 	w.Write(b)
 }
 
+func writeHTTPJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Add("Cache-Control", "max-age=0")
+	w.Header().Add("Content-Type", "application/json; charset=utf-8")
+	w.Header().Add("Content-Length", strconv.Itoa(len(b)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
 func boolPtr(b bool) *bool                { return &b }
 func stringPtr(s string) *string          { return &s }
 func stringSlicePtr(s []string) *[]string { return &s }
@@ -48,6 +48,8 @@ func TestHandler(t *testing.T) {
 		"init": {
 			config: Config{BackgroundTimeout: time.Second, AudioProxy: map[string]string{"My HTTP Stream": "http://foo/bar"}},
 			initFunc: func(ctx context.Context, main *mpdtest.Server) {
+				main.Expect(ctx, &mpdtest.WR{Read: "sticker \"find\" \"song\" \"\" \"rating\"\n", Write: "ACK [5@0] {sticker} sticker database is disabled\n"})
+				main.Expect(ctx, &mpdtest.WR{Read: "stats\n", Write: "uptime: 667505\nplaytime: 0\nartists: 835\nalbums: 528\nsongs: 5715\ndb_playtime: 1475220\ndb_update: 1560656023\nOK\n"})
 				main.Expect(ctx, &mpdtest.WR{Read: "listallinfo \"/\"\n", Write: "file: foo\nfile: bar\nfile: baz\nOK\n"})
 				main.Expect(ctx, &mpdtest.WR{Read: "playlistinfo\n", Write: "file: foo\nfile: bar\nOK\n"})
 				main.Expect(ctx, &mpdtest.WR{Read: "replay_gain_status\n", Write: "replay_gain_mode: off\nOK\n"})
@@ -57,6 +59,8 @@ func TestHandler(t *testing.T) {
 				main.Expect(ctx, &mpdtest.WR{Read: "stats\n", Write: "uptime: 667505\nplaytime: 0\nartists: 835\nalbums: 528\nsongs: 5715\ndb_playtime: 1475220\ndb_update: 1560656023\nOK\n"})
 				main.Expect(ctx, &mpdtest.WR{Read: "listmounts\n", Write: "mount: \nstorage: /home/foo/music\nmount: foo\nstorage: nfs://192.168.1.4/export/mp3\nOK\n"})
 				main.Expect(ctx, &mpdtest.WR{Read: "listneighbors\n", Write: "neighbor: smb://FOO\nname: FOO (Samba 4.1.11-Debian)\nOK\n"})
+				main.Expect(ctx, &mpdtest.WR{Read: "listplaylists\n", Write: "playlist: foo\nLast-Modified: 2021-01-17T01:06:27Z\nOK\n"})
+				main.Expect(ctx, &mpdtest.WR{Read: "listpartitions\n", Write: "partition: default\nOK\n"})
 			},
 			tests: []*testRequest{
 				{
@@ -85,7 +89,11 @@ func TestHandler(t *testing.T) {
 				},
 				{
 					method: http.MethodGet, path: "/api/music/outputs",
-					want: map[int]string{http.StatusOK: `{"0":{"name":"My HTTP Stream","enabled":false,"stream":"/api/music/outputs/stream?name=My+HTTP+Stream"}}`},
+					want: map[int]string{http.StatusOK: `{"0":{"name":"My HTTP Stream","enabled":false,"partition":"default","stream":"/api/music/outputs/stream?name=My+HTTP+Stream"}}`},
+				},
+				{
+					method: http.MethodGet, path: "/api/music/partitions",
+					want: map[int]string{http.StatusOK: `{"default":{"current":true}}`},
 				},
 				{
 					method: http.MethodGet, path: "/api/music/stats",
@@ -95,6 +103,10 @@ func TestHandler(t *testing.T) {
 					method: http.MethodGet, path: "/api/music/storage",
 					want: map[int]string{http.StatusOK: `{"":{"uri":"/home/foo/music"},"foo":{"uri":"nfs://192.168.1.4/export/mp3"}}`},
 				},
+				{
+					method: http.MethodGet, path: "/api/music/playlists",
+					want: map[int]string{http.StatusOK: `{"foo":{"last_modified":"2021-01-17T01:06:27Z"}}`},
+				},
 			},
 		},
 		"reconnect": {
@@ -141,6 +153,8 @@ func TestHandler(t *testing.T) {
 					initFunc: func(ctx context.Context, main *mpdtest.Server, sub *mpdtest.Server) {
 						sub.Expect(ctx, &mpdtest.WR{Read: "idle\n"})
 						sub.Disconnect(ctx)
+						main.Expect(ctx, &mpdtest.WR{Read: "sticker \"find\" \"song\" \"\" \"rating\"\n", Write: "ACK [5@0] {sticker} sticker database is disabled\n"})
+						main.Expect(ctx, &mpdtest.WR{Read: "stats\n", Write: "uptime: 667505\nplaytime: 0\nartists: 835\nalbums: 528\nsongs: 5715\ndb_playtime: 1475220\ndb_update: 1560656023\nOK\n"})
 						main.Expect(ctx, &mpdtest.WR{Read: "listallinfo \"/\"\n", Write: "file: foo\nfile: bar\nfile: baz\nOK\n"})
 						main.Expect(ctx, &mpdtest.WR{Read: "playlistinfo\n", Write: "file: foo\nfile: bar\nOK\n"})
 						main.Expect(ctx, &mpdtest.WR{Read: "replay_gain_status\n", Write: "replay_gain_mode: off\nOK\n"})
@@ -150,10 +164,12 @@ func TestHandler(t *testing.T) {
 						main.Expect(ctx, &mpdtest.WR{Read: "stats\n", Write: "uptime: 667505\nplaytime: 0\nartists: 835\nalbums: 528\nsongs: 5715\ndb_playtime: 1475220\ndb_update: 1560656023\nOK\n"})
 						main.Expect(ctx, &mpdtest.WR{Read: "listmounts\n", Write: "mount: \nstorage: /home/foo/music\nmount: foo\nstorage: nfs://192.168.1.4/export/mp3\nOK\n"})
 						main.Expect(ctx, &mpdtest.WR{Read: "listneighbors\n", Write: "neighbor: smb://FOO\nname: FOO (Samba 4.1.11-Debian)\nOK\n"})
+						main.Expect(ctx, &mpdtest.WR{Read: "listplaylists\n", Write: "playlist: foo\nLast-Modified: 2021-01-17T01:06:27Z\nOK\n"})
+						main.Expect(ctx, &mpdtest.WR{Read: "listpartitions\n", Write: "partition: default\nOK\n"})
 						sub.Expect(ctx, &mpdtest.WR{Read: "idle\n"})
 					},
 					// preWebSocket: []string{"/api/version", "/api/version", "/api/music/library/songs", "/api/music/playlist", "/api/music/playlist/songs", "/api/music", "/api/music/playlist", "/api/music/library", "/api/music/playlist/songs/current", "/api/music/outputs", "/api/music/stats", "/api/music/storage"},
-					preWebSocket: []string{"/api/version", "/api/version", "/api/music/library/songs", "/api/music/playlist/songs", "/api/music", "/api/music/playlist/songs/current", "/api/music/outputs", "/api/music/playlist", "/api/music/stats", "/api/music/storage", "/api/music/storage/neighbors"},
+					preWebSocket: []string{"/api/version", "/api/version", "/api/music/library/songs", "/api/music/playlist/songs", "/api/music", "/api/music/playlist/songs/current", "/api/music/lyrics", "/api/music/outputs", "/api/music/playlist", "/api/music/stats", "/api/music/storage", "/api/music/storage/neighbors", "/api/music/playlists"},
 					method:       http.MethodGet, path: "/api/music",
 					want: map[int]string{http.StatusOK: `{"repeat":false,"random":false,"single":false,"oneshot":false,"consume":false,"state":"pause","song_elapsed":1.1,"replay_gain":"off","crossfade":0}`},
 				},
@@ -183,7 +199,7 @@ func TestHandler(t *testing.T) {
 				},
 				{
 					method: http.MethodGet, path: "/api/music/outputs",
-					want: map[int]string{http.StatusOK: `{"0":{"name":"My ALSA Device","enabled":false}}`},
+					want: map[int]string{http.StatusOK: `{"0":{"name":"My ALSA Device","enabled":false,"partition":"default"}}`},
 				},
 				{
 					method: http.MethodGet, path: "/api/music/stats",
@@ -193,6 +209,10 @@ func TestHandler(t *testing.T) {
 					method: http.MethodGet, path: "/api/music/storage",
 					want: map[int]string{http.StatusOK: `{"":{"uri":"/home/foo/music"},"foo":{"uri":"nfs://192.168.1.4/export/mp3"}}`},
 				},
+				{
+					method: http.MethodGet, path: "/api/music/playlists",
+					want: map[int]string{http.StatusOK: `{"foo":{"last_modified":"2021-01-17T01:06:27Z"}}`},
+				},
 			},
 		},
 		`POST /api/music {"repeat":true}`: {
@@ -337,7 +357,7 @@ func TestHandler(t *testing.T) {
 						main.Expect(ctx, &mpdtest.WR{Read: "currentsong\n", Write: "file: bar\nPos: 1\nOK\n"})
 						main.Expect(ctx, &mpdtest.WR{Read: "stats\n", Write: "uptime: 667505\nplaytime: 0\nartists: 835\nalbums: 528\nsongs: 5715\ndb_playtime: 1475220\ndb_update: 1560656023\nOK\n"})
 					},
-					postWebSocket: []string{"/api/music", "/api/music/playlist", "/api/music/playlist/songs/current", "/api/music/stats"},
+					postWebSocket: []string{"/api/music", "/api/music/playlist", "/api/music/playlist/songs/current", "/api/music/lyrics", "/api/music/stats"},
 				},
 				{
 					method: http.MethodGet, path: "/api/music",
@@ -360,7 +380,7 @@ func TestHandler(t *testing.T) {
 						main.Expect(ctx, &mpdtest.WR{Read: "currentsong\n", Write: "file: baz\nPos: 2\nOK\n"})
 						main.Expect(ctx, &mpdtest.WR{Read: "stats\n", Write: "uptime: 667505\nplaytime: 0\nartists: 835\nalbums: 528\nsongs: 5715\ndb_playtime: 1475220\ndb_update: 1560656023\nOK\n"})
 					},
-					postWebSocket: []string{"/api/music", "/api/music/playlist", "/api/music/playlist/songs/current", "/api/music/stats"},
+					postWebSocket: []string{"/api/music", "/api/music/playlist", "/api/music/playlist/songs/current", "/api/music/lyrics", "/api/music/stats"},
 				},
 				{
 					method: http.MethodGet, path: "/api/music",
@@ -383,7 +403,7 @@ func TestHandler(t *testing.T) {
 						main.Expect(ctx, &mpdtest.WR{Read: "currentsong\n", Write: "file: bar\nPos: 1\nOK\n"})
 						main.Expect(ctx, &mpdtest.WR{Read: "stats\n", Write: "uptime: 667505\nplaytime: 0\nartists: 835\nalbums: 528\nsongs: 5715\ndb_playtime: 1475220\ndb_update: 1560656023\nOK\n"})
 					},
-					postWebSocket: []string{"/api/music", "/api/music/playlist", "/api/music/playlist/songs/current", "/api/music/stats"},
+					postWebSocket: []string{"/api/music", "/api/music/playlist", "/api/music/playlist/songs/current", "/api/music/lyrics", "/api/music/stats"},
 				},
 				{
 					method: http.MethodGet, path: "/api/music",
@@ -406,7 +426,7 @@ func TestHandler(t *testing.T) {
 						main.Expect(ctx, &mpdtest.WR{Read: "currentsong\n", Write: "file: bar\nPos: 1\nOK\n"})
 						main.Expect(ctx, &mpdtest.WR{Read: "stats\n", Write: "uptime: 667505\nplaytime: 0\nartists: 835\nalbums: 528\nsongs: 5715\ndb_playtime: 1475220\ndb_update: 1560656023\nOK\n"})
 					},
-					postWebSocket: []string{"/api/music", "/api/music/playlist", "/api/music/playlist/songs/current", "/api/music/stats"},
+					postWebSocket: []string{"/api/music", "/api/music/playlist", "/api/music/playlist/songs/current", "/api/music/lyrics", "/api/music/stats"},
 				},
 				{
 					method: http.MethodGet, path: "/api/music",
@@ -506,7 +526,7 @@ func TestHandler(t *testing.T) {
 			tests: []*testRequest{
 				{
 					method: http.MethodGet, path: "/api/music/images",
-					want: map[int]string{http.StatusOK: `{"updating":false}`},
+					want: map[int]string{http.StatusOK: `{"updating":false,"total":0,"done":0,"errors":0}`},
 				},
 			},
 		},
@@ -597,7 +617,7 @@ func TestHandler(t *testing.T) {
 					},
 					preWebSocket: []string{"/api/music/outputs"},
 					method:       http.MethodGet, path: "/api/music/outputs",
-					want: map[int]string{http.StatusOK: `{"1":{"name":"My ALSA Device","enabled":true}}`},
+					want: map[int]string{http.StatusOK: `{"1":{"name":"My ALSA Device","enabled":true,"partition":"default"}}`},
 				},
 			},
 		},
@@ -611,7 +631,7 @@ func TestHandler(t *testing.T) {
 					},
 					preWebSocket: []string{"/api/music/outputs"},
 					method:       http.MethodGet, path: "/api/music/outputs",
-					want: map[int]string{http.StatusOK: `{"1":{"name":"My ALSA Device","enabled":true,"attributes":{"dop":false,"allowed_formats":[]}}}`},
+					want: map[int]string{http.StatusOK: `{"1":{"name":"My ALSA Device","enabled":true,"partition":"default","attributes":{"dop":false,"allowed_formats":[]}}}`},
 				},
 			},
 		},
@@ -625,7 +645,7 @@ func TestHandler(t *testing.T) {
 					},
 					preWebSocket: []string{"/api/music/outputs"},
 					method:       http.MethodGet, path: "/api/music/outputs",
-					want: map[int]string{http.StatusOK: `{"1":{"name":"My HTTP Stream","enabled":true,"stream":"/api/music/outputs/stream?name=My+HTTP+Stream"}}`},
+					want: map[int]string{http.StatusOK: `{"1":{"name":"My HTTP Stream","enabled":true,"partition":"default","stream":"/api/music/outputs/stream?name=My+HTTP+Stream"}}`},
 				},
 			},
 		},
@@ -644,7 +664,7 @@ func TestHandler(t *testing.T) {
 					method: http.MethodPost, path: "/api/music/outputs", body: strings.NewReader(`{"0":{"enabled":true}}`),
 					want: map[int]string{
 						http.StatusAccepted: "{}",
-						http.StatusOK:       `{"0":{"name":"My ALSA Device","enabled":true}}`,
+						http.StatusOK:       `{"0":{"name":"My ALSA Device","enabled":true,"partition":"default"}}`,
 					},
 					initFunc: func(ctx context.Context, main *mpdtest.Server, sub *mpdtest.Server) {
 						main.Expect(ctx, &mpdtest.WR{Read: "enableoutput \"0\"\n", Write: "OK\n"})
@@ -655,7 +675,7 @@ func TestHandler(t *testing.T) {
 				},
 				{
 					method: http.MethodGet, path: "/api/music/outputs",
-					want: map[int]string{http.StatusOK: `{"0":{"name":"My ALSA Device","enabled":true}}`},
+					want: map[int]string{http.StatusOK: `{"0":{"name":"My ALSA Device","enabled":true,"partition":"default"}}`},
 				},
 			}},
 		`POST /api/music/outputs {"0":{"enabled":false}}`: {
@@ -665,7 +685,7 @@ func TestHandler(t *testing.T) {
 					method: http.MethodPost, path: "/api/music/outputs", body: strings.NewReader(`{"0":{"enabled":false}}`),
 					want: map[int]string{
 						http.StatusAccepted: "{}",
-						http.StatusOK:       `{"0":{"name":"My ALSA Device","enabled":false}}`,
+						http.StatusOK:       `{"0":{"name":"My ALSA Device","enabled":false,"partition":"default"}}`,
 					},
 					initFunc: func(ctx context.Context, main *mpdtest.Server, sub *mpdtest.Server) {
 						main.Expect(ctx, &mpdtest.WR{Read: "disableoutput \"0\"\n", Write: "OK\n"})
@@ -676,7 +696,7 @@ func TestHandler(t *testing.T) {
 				},
 				{
 					method: http.MethodGet, path: "/api/music/outputs",
-					want: map[int]string{http.StatusOK: `{"0":{"name":"My ALSA Device","enabled":false}}`},
+					want: map[int]string{http.StatusOK: `{"0":{"name":"My ALSA Device","enabled":false,"partition":"default"}}`},
 				},
 			}},
 		`POST /api/music/outputs {"0":{"attributes":{"dop":true}}}`: {
@@ -686,7 +706,7 @@ func TestHandler(t *testing.T) {
 					method: http.MethodPost, path: "/api/music/outputs", body: strings.NewReader(`{"0":{"attributes":{"dop":true}}}`),
 					want: map[int]string{
 						http.StatusAccepted: "{}",
-						http.StatusOK:       `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":false,"attributes":{"dop":true}}}`,
+						http.StatusOK:       `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":false,"partition":"default","attributes":{"dop":true}}}`,
 					},
 					initFunc: func(ctx context.Context, main *mpdtest.Server, sub *mpdtest.Server) {
 						main.Expect(ctx, &mpdtest.WR{Read: "outputset \"0\" \"dop\" \"1\"\n", Write: "OK\n"})
@@ -697,7 +717,7 @@ func TestHandler(t *testing.T) {
 				},
 				{
 					method: http.MethodGet, path: "/api/music/outputs",
-					want: map[int]string{http.StatusOK: `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":false,"attributes":{"dop":true}}}`},
+					want: map[int]string{http.StatusOK: `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":false,"partition":"default","attributes":{"dop":true}}}`},
 				},
 			}},
 		`POST /api/music/outputs {"0":{"attributes":{"allowed_formats":[]}}}`: {
@@ -707,7 +727,7 @@ func TestHandler(t *testing.T) {
 					method: http.MethodPost, path: "/api/music/outputs", body: strings.NewReader(`{"0":{"attributes":{"allowed_formats":[]}}}`),
 					want: map[int]string{
 						http.StatusAccepted: "{}",
-						http.StatusOK:       `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":false,"attributes":{"allowed_formats":[]}}}`,
+						http.StatusOK:       `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":false,"partition":"default","attributes":{"allowed_formats":[]}}}`,
 					},
 					initFunc: func(ctx context.Context, main *mpdtest.Server, sub *mpdtest.Server) {
 						main.Expect(ctx, &mpdtest.WR{Read: "outputset \"0\" \"allowed_formats\" \"\"\n", Write: "OK\n"})
@@ -718,7 +738,7 @@ func TestHandler(t *testing.T) {
 				},
 				{
 					method: http.MethodGet, path: "/api/music/outputs",
-					want: map[int]string{http.StatusOK: `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":false,"attributes":{"allowed_formats":[]}}}`},
+					want: map[int]string{http.StatusOK: `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":false,"partition":"default","attributes":{"allowed_formats":[]}}}`},
 				},
 			}},
 		`POST /api/music/outputs {"0":{"attributes":{"allowed_formats":["96000:16:*","192000:24:*","dsd32:*=dop"]}}}`: {
@@ -728,7 +748,7 @@ func TestHandler(t *testing.T) {
 					method: http.MethodPost, path: "/api/music/outputs", body: strings.NewReader(`{"0":{"attributes":{"allowed_formats":["96000:16:*","192000:24:*","dsd32:*=dop"]}}}`),
 					want: map[int]string{
 						http.StatusAccepted: "{}",
-						http.StatusOK:       `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":false,"attributes":{"allowed_formats":["96000:16:*","192000:24:*","dsd32:*=dop"]}}}`,
+						http.StatusOK:       `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":false,"partition":"default","attributes":{"allowed_formats":["96000:16:*","192000:24:*","dsd32:*=dop"]}}}`,
 					},
 					initFunc: func(ctx context.Context, main *mpdtest.Server, sub *mpdtest.Server) {
 						main.Expect(ctx, &mpdtest.WR{Read: "outputset \"0\" \"allowed_formats\" \"96000:16:* 192000:24:* dsd32:*=dop\"\n", Write: "OK\n"})
@@ -739,7 +759,7 @@ func TestHandler(t *testing.T) {
 				},
 				{
 					method: http.MethodGet, path: "/api/music/outputs",
-					want: map[int]string{http.StatusOK: `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":false,"attributes":{"allowed_formats":["96000:16:*","192000:24:*","dsd32:*=dop"]}}}`},
+					want: map[int]string{http.StatusOK: `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":false,"partition":"default","attributes":{"allowed_formats":["96000:16:*","192000:24:*","dsd32:*=dop"]}}}`},
 				},
 			}},
 		`POST /api/music/playlist {invalid json}`: {
@@ -761,6 +781,8 @@ func TestHandler(t *testing.T) {
 		`POST /api/music/playlist {"current":0,"sort":["file"],"filters":[]}`: {
 			config: Config{BackgroundTimeout: time.Second},
 			initFunc: func(ctx context.Context, main *mpdtest.Server) {
+				main.Expect(ctx, &mpdtest.WR{Read: "sticker \"find\" \"song\" \"\" \"rating\"\n", Write: "ACK [5@0] {sticker} sticker database is disabled\n"})
+				main.Expect(ctx, &mpdtest.WR{Read: "stats\n", Write: "uptime: 667505\nplaytime: 0\nartists: 835\nalbums: 528\nsongs: 5715\ndb_playtime: 1475220\ndb_update: 1560656023\nOK\n"})
 				main.Expect(ctx, &mpdtest.WR{Read: "listallinfo \"/\"\n", Write: "file: foo\nfile: bar\nfile: baz\nOK\n"})
 				main.Expect(ctx, &mpdtest.WR{Read: "playlistinfo\n", Write: "file: foo\nfile: bar\nOK\n"})
 				main.Expect(ctx, &mpdtest.WR{Read: "replay_gain_status\n", Write: "replay_gain_mode: off\nOK\n"})
@@ -770,6 +792,8 @@ func TestHandler(t *testing.T) {
 				main.Expect(ctx, &mpdtest.WR{Read: "stats\n", Write: "uptime: 667505\nplaytime: 0\nartists: 835\nalbums: 528\nsongs: 5715\ndb_playtime: 1475220\ndb_update: 1560656023\nOK\n"})
 				main.Expect(ctx, &mpdtest.WR{Read: "listmounts\n", Write: "mount: \nstorage: /home/foo/music\nmount: foo\nstorage: nfs://192.168.1.4/export/mp3\nOK\n"})
 				main.Expect(ctx, &mpdtest.WR{Read: "listneighbors\n", Write: "neighbor: smb://FOO\nname: FOO (Samba 4.1.11-Debian)\nOK\n"})
+				main.Expect(ctx, &mpdtest.WR{Read: "listplaylists\n", Write: "playlist: foo\nLast-Modified: 2021-01-17T01:06:27Z\nOK\n"})
+				main.Expect(ctx, &mpdtest.WR{Read: "listpartitions\n", Write: "partition: default\nOK\n"})
 			},
 			tests: []*testRequest{
 				{ // update playlist and current song
@@ -885,6 +909,7 @@ func TestHandler(t *testing.T) {
 						sub.Expect(ctx, &mpdtest.WR{Read: "idle\n", Write: "changed: update\nOK\n"})
 						main.Expect(ctx, &mpdtest.WR{Read: "status\n", Write: "volume: -1\nsong: 1\nelapsed: 1.1\nrepeat: 0\nrandom: 0\nsingle: 0\nconsume: 0\nstate: pause\nOK\n"})
 						sub.Expect(ctx, &mpdtest.WR{Read: "idle\n", Write: "changed: database\nOK\n"})
+						main.Expect(ctx, &mpdtest.WR{Read: "stats\n", Write: "uptime: 667505\nplaytime: 0\nartists: 835\nalbums: 528\nsongs: 5715\ndb_playtime: 1475220\ndb_update: 1560656023\nOK\n"})
 						main.Expect(ctx, &mpdtest.WR{Read: "listallinfo \"/\"\n", Write: "file: foo\nfile: bar\nfile: baz\nOK\n"})
 						main.Expect(ctx, &mpdtest.WR{Read: "status\n", Write: "volume: -1\nsong: 1\nelapsed: 1.1\nrepeat: 0\nrandom: 0\nsingle: 0\nconsume: 0\nstate: pause\nOK\n"})
 						main.Expect(ctx, &mpdtest.WR{Read: "stats\n", Write: "uptime: 667505\nplaytime: 0\nartists: 835\nalbums: 528\nsongs: 5715\ndb_playtime: 1475220\ndb_update: 1560656023\nOK\n"})
@@ -1162,3 +1187,208 @@ func sortUniq(s []string) []string {
 	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
 	return s
 }
+
+func TestHandlerServerName(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	main := mpdtest.NewServer("OK MPD 0.19")
+	defer main.Close()
+	sub := mpdtest.NewServer("OK MPD 0.19")
+	defer sub.Close()
+	c, err := mpd.Dial("tcp", main.URL,
+		&mpd.ClientOptions{Timeout: testTimeout, ReconnectionInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Dial got error %v; want nil", err)
+	}
+	defer c.Close(ctx)
+	wl, err := mpd.NewWatcher("tcp", sub.URL,
+		&mpd.WatcherOptions{Timeout: testTimeout, ReconnectionInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Dial got error %v; want nil", err)
+	}
+	defer wl.Close(ctx)
+	h, err := NewHandler(ctx, c, wl, &Config{AppVersion: "0.0.0", BackgroundTimeout: time.Second, ServerName: "living", skipInit: true})
+	if err != nil {
+		t.Fatalf("NewHTTPHandler got error = %v; want <nil>", err)
+	}
+	defer h.Stop()
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	for path, status := range map[string]int{
+		"/api/servers/living/version":  http.StatusOK,
+		"/api/version":                 http.StatusNotFound,
+		"/api/servers/kitchen/version": http.StatusNotFound,
+	} {
+		resp, err := testHTTPClient.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("failed to request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("GET %s = %d; want %d", path, resp.StatusCode, status)
+		}
+	}
+
+	ws, _, err := websocket.DefaultDialer.Dial(strings.Replace(ts.URL, "http://", "ws://", 1)+"/api/servers/living/music", nil)
+	if err != nil {
+		t.Fatalf("failed to connect websocket: %v", err)
+	}
+	defer ws.Close()
+	timeout, _ := ctx.Deadline()
+	ws.SetReadDeadline(timeout)
+	if _, msg, err := ws.ReadMessage(); string(msg) != "ok" || err != nil {
+		t.Fatalf("got message: %s, %v, want: ok <nil>", msg, err)
+	}
+	go func() {
+		sub.Expect(ctx, &mpdtest.WR{Read: "idle\n", Write: "changed: stored_playlist\nOK\n"})
+		main.Expect(ctx, &mpdtest.WR{Read: "listplaylists\n", Write: "playlist: foo\nOK\n"})
+	}()
+	if _, msg, err := ws.ReadMessage(); string(msg) != "/api/servers/living/music/playlists" || err != nil {
+		t.Errorf("got message: %s, %v, want: /api/servers/living/music/playlists <nil>", msg, err)
+	}
+	if err := h.Shutdown(ctx); err != nil {
+		t.Errorf("Handler.Shutdown got err %v; want nil", err)
+	}
+	go func() {
+		sub.Expect(ctx, &mpdtest.WR{Read: "idle\n", Write: ""})
+		sub.Expect(ctx, &mpdtest.WR{Read: "noidle\n", Write: "OK\n"})
+	}()
+}
+
+func TestHandlerPartition(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	main := mpdtest.NewServer("OK MPD 0.22")
+	defer main.Close()
+	sub := mpdtest.NewServer("OK MPD 0.22")
+	defer sub.Close()
+	zoneMain := mpdtest.NewServer("OK MPD 0.22")
+	defer zoneMain.Close()
+	zoneSub := mpdtest.NewServer("OK MPD 0.22")
+	defer zoneSub.Close()
+	c, err := mpd.Dial("tcp", main.URL,
+		&mpd.ClientOptions{Timeout: testTimeout, ReconnectionInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Dial got error %v; want nil", err)
+	}
+	defer c.Close(ctx)
+	wl, err := mpd.NewWatcher("tcp", sub.URL,
+		&mpd.WatcherOptions{Timeout: testTimeout, ReconnectionInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Dial got error %v; want nil", err)
+	}
+	defer wl.Close(ctx)
+	dialed := []string{}
+	h, err := NewHandler(ctx, c, wl, &Config{AppVersion: "0.0.0", BackgroundTimeout: time.Second, skipInit: true,
+		DialPartition: func(ctx context.Context, partition string) (*mpd.Client, *mpd.Watcher, error) {
+			dialed = append(dialed, partition)
+			c, err := mpd.Dial("tcp", zoneMain.URL, &mpd.ClientOptions{Timeout: testTimeout, ReconnectionInterval: time.Millisecond})
+			if err != nil {
+				return nil, nil, err
+			}
+			wl, err := mpd.NewWatcher("tcp", zoneSub.URL, &mpd.WatcherOptions{Timeout: testTimeout, ReconnectionInterval: time.Millisecond})
+			if err != nil {
+				c.Close(ctx)
+				return nil, nil, err
+			}
+			return c, wl, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPHandler got error = %v; want <nil>", err)
+	}
+	defer h.Stop()
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	go main.Expect(ctx, &mpdtest.WR{Read: "listpartitions\n", Write: "partition: default\npartition: zone1\nOK\n"})
+	wsURL := strings.Replace(ts.URL, "http://", "ws://", 1) + "/api/music"
+	zone, _, err := websocket.DefaultDialer.Dial(wsURL+"?partition=zone1", nil)
+	if err != nil {
+		t.Fatalf("failed to connect websocket: %v", err)
+	}
+	defer zone.Close()
+	def, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to connect websocket: %v", err)
+	}
+	defer def.Close()
+	timeout, _ := ctx.Deadline()
+	for _, ws := range []*websocket.Conn{zone, def} {
+		ws.SetReadDeadline(timeout)
+		if _, msg, err := ws.ReadMessage(); string(msg) != "ok" || err != nil {
+			t.Fatalf("got message: %s, %v, want: ok <nil>", msg, err)
+		}
+	}
+	if want := []string{"zone1"}; !reflect.DeepEqual(dialed, want) {
+		t.Errorf("dialed partitions %v; want %v", dialed, want)
+	}
+
+	// partition apis are updated by the partition connections
+	go func() {
+		zoneSub.Expect(ctx, &mpdtest.WR{Read: "idle\n", Write: "changed: stored_playlist\nOK\n"})
+		zoneMain.Expect(ctx, &mpdtest.WR{Read: "listplaylists\n", Write: "playlist: foo\nOK\n"})
+	}()
+	if _, msg, err := zone.ReadMessage(); string(msg) != "/api/music/playlists" || err != nil {
+		t.Errorf("got message: %s, %v, want: /api/music/playlists <nil>", msg, err)
+	}
+	// shared apis are updated once and broadcasted to all partitions
+	go func() {
+		sub.Expect(ctx, &mpdtest.WR{Read: "idle\n", Write: "changed: mount\nOK\n"})
+		main.Expect(ctx, &mpdtest.WR{Read: "listmounts\n", Write: "mount: \nstorage: /home/foo/music\nOK\n"})
+	}()
+	for _, ws := range []*websocket.Conn{zone, def} {
+		if _, msg, err := ws.ReadMessage(); string(msg) != "/api/music/storage" || err != nil {
+			t.Errorf("got message: %s, %v, want: /api/music/storage <nil>", msg, err)
+		}
+	}
+	for path, want := range map[string]string{
+		"/api/music/playlists?partition=zone1": `{"foo":{}}`,
+		"/api/music/playlists":                 `{}`,
+		"/api/music/storage?partition=zone1":   `{"":{"uri":"/home/foo/music"}}`,
+	} {
+		resp, err := testHTTPClient.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("failed to request: %v", err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if got := string(b); got != want {
+			t.Errorf("GET %s = %s; want %s", path, got, want)
+		}
+	}
+
+	// connections to removed partition are closed
+	go func() {
+		sub.Expect(ctx, &mpdtest.WR{Read: "idle\n", Write: "changed: partition\nOK\n"})
+		main.Expect(ctx, &mpdtest.WR{Read: "listpartitions\n", Write: "partition: default\nOK\n"})
+		main.Expect(ctx, &mpdtest.WR{Read: "listpartitions\n", Write: "partition: default\nOK\n"})
+		zoneSub.Expect(ctx, &mpdtest.WR{Read: "idle\n", Write: ""})
+		zoneSub.Expect(ctx, &mpdtest.WR{Read: "noidle\n", Write: "OK\n"})
+	}()
+	if _, msg, err := def.ReadMessage(); string(msg) != "/api/music/partitions" || err != nil {
+		t.Errorf("got message: %s, %v, want: /api/music/partitions <nil>", msg, err)
+	}
+	for {
+		h.mu.Lock()
+		n := len(h.children)
+		h.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("partition handler is not closed")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	if err := h.Shutdown(ctx); err != nil {
+		t.Errorf("Handler.Shutdown got err %v; want nil", err)
+	}
+	go func() {
+		sub.Expect(ctx, &mpdtest.WR{Read: "idle\n", Write: ""})
+		sub.Expect(ctx, &mpdtest.WR{Read: "noidle\n", Write: "OK\n"})
+	}()
+}
@@ -0,0 +1,398 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var bucketHistory = []byte("history")
+
+// history event types.
+const (
+	historyStart  = "start"
+	historyFinish = "finish"
+	historySkip   = "skip"
+)
+
+const (
+	// historyListenedMax is the maximum played time required to count as listened.
+	historyListenedMax = 4 * time.Minute
+	// historyFinishMargin is the tolerance of played time to treat song as finished.
+	historyFinishMargin = 2 * time.Second
+	// historyLimit is the default number of history entries in a response.
+	historyLimit = 100
+	// historyRetention is the period to keep history entries.
+	historyRetention = 365 * 24 * time.Hour
+	// historyScrobbleTimeout is the timeout of each Scrobble call.
+	historyScrobbleTimeout = 30 * time.Second
+	// historyScrobbleQueue is the number of listened songs waiting for scrobblers.
+	historyScrobbleQueue = 64
+)
+
+// Scrobbler represents listened songs submission api.
+// Scrobble is called from a background goroutine one by one.
+type Scrobbler interface {
+	Scrobble(ctx context.Context, listenedAt time.Time, song map[string][]string) error
+}
+
+type httpHistory struct {
+	Type     string              `json:"type"`
+	Time     time.Time           `json:"time"`
+	Song     map[string][]string `json:"song"`
+	Elapsed  *float64            `json:"elapsed,omitempty"`
+	Listened *bool               `json:"listened,omitempty"`
+}
+
+// historyPlaying is the song observed as current song.
+type historyPlaying struct {
+	song    map[string][]string
+	file    string
+	id      string
+	started bool
+	startAt time.Time
+	state   string
+	elapsed float64
+	at      time.Time
+	reached float64 // max elapsed time to keep played time after stop
+}
+
+type historyListen struct {
+	listenedAt time.Time
+	song       map[string][]string
+}
+
+// HistoryHandler records song start, finish and skip events and provides
+// play history api. Listened songs are submitted to scrobblers.
+type HistoryHandler struct {
+	db         *bolt.DB
+	scrobblers []Scrobbler
+	listens    chan *historyListen
+	scrobbled  chan struct{}
+	changed    chan struct{}
+	playing    *historyPlaying
+	now        func() time.Time
+	logger     Logger
+	closed     bool
+	mu         sync.Mutex
+}
+
+// NewHistoryHandler initilize play history db in cacheDir.
+// If cacheDir is empty, history is not recorded.
+func NewHistoryHandler(cacheDir string, scrobblers []Scrobbler, logger Logger) (*HistoryHandler, error) {
+	a := &HistoryHandler{
+		scrobblers: scrobblers,
+		listens:    make(chan *historyListen, historyScrobbleQueue),
+		scrobbled:  make(chan struct{}),
+		changed:    make(chan struct{}, 1),
+		now:        time.Now,
+		logger:     logger,
+	}
+	if len(cacheDir) == 0 {
+		go a.scrobble()
+		return a, nil
+	}
+	if err := os.MkdirAll(cacheDir, 0766); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(cacheDir, "history.db"), 0666, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			return nil, fmt.Errorf("obtain history lock: %w", err)
+		}
+		return nil, err
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(bucketHistory); err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	a.db = db
+	go a.scrobble()
+	return a, nil
+}
+
+// Observe records history events from current song and player status.
+// song should be raw mpd currentsong tags to store.
+// pos is the song position in status; Observe ignores inconsistent song and
+// status while one of them is not updated yet.
+// Observe treats the song as played again if its song id is changed or its
+// elapsed time is reset to the beginning like repeat single mode.
+func (a *HistoryHandler) Observe(song map[string][]string, pos *int, state string, elapsed float64) error {
+	if a.db == nil && len(a.scrobblers) == 0 {
+		return nil
+	}
+	var file, id string
+	if f := song["file"]; len(f) != 0 {
+		file = f[0]
+	}
+	if i := song["Id"]; len(i) != 0 {
+		id = i[0]
+	}
+	if len(file) != 0 {
+		p := song["Pos"]
+		if pos == nil || len(p) == 0 || p[0] != strconv.Itoa(*pos) {
+			return nil
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return nil
+	}
+	now := a.now()
+	var events []*httpHistory
+	if prev := a.playing; prev != nil && (prev.file != file || prev.id != id || prev.restarted(now, state, elapsed)) {
+		events = append(events, a.finish(now)...)
+	}
+	if len(file) == 0 {
+		return a.put(events)
+	}
+	if a.playing == nil {
+		a.playing = &historyPlaying{song: song, file: file, id: id}
+	}
+	p := a.playing
+	p.reached = math.Max(p.reached, p.played(now))
+	p.state, p.elapsed, p.at = state, elapsed, now
+	if !p.started && state == "play" {
+		p.started = true
+		p.startAt = now.Add(-time.Duration(elapsed * float64(time.Second)))
+		events = append(events, &httpHistory{
+			Type: historyStart,
+			Time: p.startAt,
+			Song: song,
+		})
+	}
+	return a.put(events)
+}
+
+// finish ends current song and queues it to scrobblers if listened.
+func (a *HistoryHandler) finish(now time.Time) []*httpHistory {
+	p := a.playing
+	a.playing = nil
+	if p == nil || !p.started {
+		return nil
+	}
+	e := p.end(now)
+	if *e.Listened && len(a.scrobblers) != 0 {
+		select {
+		case a.listens <- &historyListen{listenedAt: p.startAt, song: p.song}:
+		default:
+			a.logger.Printf("vv/api: scrobble: queue is full; dropped %s", p.file)
+		}
+	}
+	return []*httpHistory{e}
+}
+
+// scrobble submits listened songs to scrobblers until listens is closed.
+func (a *HistoryHandler) scrobble() {
+	defer close(a.scrobbled)
+	for l := range a.listens {
+		for _, s := range a.scrobblers {
+			ctx, cancel := context.WithTimeout(context.Background(), historyScrobbleTimeout)
+			if err := s.Scrobble(ctx, l.listenedAt, l.song); err != nil {
+				a.logger.Printf("vv/api: scrobble: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// played returns estimated elapsed time of the song at now.
+func (p *historyPlaying) played(now time.Time) float64 {
+	if p.state == "play" {
+		return p.elapsed + now.Sub(p.at).Seconds()
+	}
+	return p.elapsed
+}
+
+// restarted returns true if the song is played again from the beginning.
+func (p *historyPlaying) restarted(now time.Time, state string, elapsed float64) bool {
+	if state == "stop" || elapsed > historyFinishMargin.Seconds() {
+		return false
+	}
+	return math.Max(p.reached, p.played(now)) > elapsed+historyFinishMargin.Seconds()
+}
+
+// end returns finish or skip event of the song.
+func (p *historyPlaying) end(now time.Time) *httpHistory {
+	played := math.Max(p.reached, p.played(now))
+	var duration float64
+	if d := p.song["duration"]; len(d) != 0 {
+		duration, _ = strconv.ParseFloat(d[0], 64)
+	} else if d := p.song["Time"]; len(d) != 0 {
+		duration, _ = strconv.ParseFloat(d[0], 64)
+	}
+	typ := historySkip
+	if duration > 0 && played >= duration-historyFinishMargin.Seconds() {
+		typ = historyFinish
+		played = duration
+	}
+	return &httpHistory{
+		Type:     typ,
+		Time:     now,
+		Song:     p.song,
+		Elapsed:  &played,
+		Listened: boolPtr(isListened(played, duration)),
+	}
+}
+
+// isListened applies "counts as listened" rule; song was played for at least
+// half its duration, or for 4 minutes.
+func isListened(played, duration float64) bool {
+	required := historyListenedMax.Seconds()
+	if duration > 0 && duration/2 < required {
+		required = duration / 2
+	}
+	return played >= required
+}
+
+func (a *HistoryHandler) put(events []*httpHistory) error {
+	if len(events) == 0 || a.db == nil {
+		return nil
+	}
+	expired := historyKey(a.now().Add(-historyRetention).UnixNano())
+	if err := a.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketHistory)
+		var old [][]byte
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, expired) < 0; k, _ = c.Next() {
+			old = append(old, k)
+		}
+		for _, k := range old {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		for _, e := range events {
+			v, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			t := e.Time.UnixNano()
+			// avoid overwriting events at same time
+			for b.Get(historyKey(t)) != nil {
+				t++
+			}
+			if err := b.Put(historyKey(t), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	select {
+	case a.changed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func historyKey(t int64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(t))
+	return k
+}
+
+// ServeHTTP responses play history as json format, newest first.
+// ServeHTTP supports from, to(RFC3339 time range), limit and listened queries.
+func (a *HistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.db == nil {
+		writeHTTPError(w, http.StatusNotFound, errors.New("history is disabled"))
+		return
+	}
+	q := r.URL.Query()
+	from, err := queryTime(q.Get("from"), time.Unix(0, 0))
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, fmt.Errorf("invalid from: %w", err))
+		return
+	}
+	to, err := queryTime(q.Get("to"), time.Unix(0, math.MaxInt64))
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, fmt.Errorf("invalid to: %w", err))
+		return
+	}
+	limit, err := queryInt(q, "limit", historyLimit)
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	listened := q.Get("listened") == "true"
+	ret := []*httpHistory{}
+	if err := a.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketHistory).Cursor()
+		min := historyKey(from.UnixNano())
+		// to is exclusive
+		k, v := c.Seek(historyKey(to.UnixNano()))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		for ; k != nil && string(k) >= string(min) && len(ret) < limit; k, v = c.Prev() {
+			var e httpHistory
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if listened && (e.Listened == nil || !*e.Listened) {
+				continue
+			}
+			ret = append(ret, &e)
+		}
+		return nil
+	}); err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeHTTPJSON(w, ret)
+}
+
+func queryTime(v string, e time.Time) (time.Time, error) {
+	if len(v) == 0 {
+		return e, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// Changed returns history update event chan.
+func (a *HistoryHandler) Changed() <-chan struct{} {
+	return a.changed
+}
+
+// Close records the end of current song, closes update event chan and
+// history db. Close waits for queued songs to be scrobbled.
+func (a *HistoryHandler) Close() {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return
+	}
+	if err := a.put(a.finish(a.now())); err != nil {
+		a.logger.Printf("vv/api: %v", err)
+	}
+	a.closed = true
+	close(a.listens)
+	close(a.changed)
+	if a.db != nil {
+		a.db.Close()
+	}
+	a.mu.Unlock()
+	<-a.scrobbled
+}
@@ -0,0 +1,327 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/meiraka/vv/internal/log"
+)
+
+func TestHistoryHandler(t *testing.T) {
+	base := time.Date(2021, 1, 17, 0, 0, 0, 0, time.UTC)
+	foo := map[string][]string{"file": {"foo"}, "Pos": {"0"}, "duration": {"300"}}
+	bar := map[string][]string{"file": {"bar"}, "Pos": {"1"}, "duration": {"60"}}
+	baz := map[string][]string{"file": {"baz"}, "Pos": {"2"}, "duration": {"600"}}
+	type observe struct {
+		sec     int
+		song    map[string][]string
+		pos     *int
+		state   string
+		elapsed float64
+	}
+	for label, tt := range map[string]struct {
+		observes []observe
+		want     []*httpHistory
+	}{
+		"skip": {
+			observes: []observe{
+				{sec: 0, song: foo, pos: intPtr(0), state: "play"},
+				{sec: 100, song: bar, pos: intPtr(1), state: "play"},
+			},
+			want: []*httpHistory{
+				{Type: historyStart, Time: base.Add(100 * time.Second), Song: bar},
+				{Type: historySkip, Time: base.Add(100 * time.Second), Song: foo, Elapsed: float64Ptr(100), Listened: boolPtr(false)},
+				{Type: historyStart, Time: base, Song: foo},
+			},
+		},
+		"finish": {
+			observes: []observe{
+				{sec: 0, song: bar, pos: intPtr(1), state: "play", elapsed: 10},
+				{sec: 49, song: foo, pos: intPtr(0), state: "play"},
+			},
+			want: []*httpHistory{
+				{Type: historyStart, Time: base.Add(49 * time.Second), Song: foo},
+				{Type: historyFinish, Time: base.Add(49 * time.Second), Song: bar, Elapsed: float64Ptr(60), Listened: boolPtr(true)},
+				{Type: historyStart, Time: base.Add(-10 * time.Second), Song: bar},
+			},
+		},
+		"listened 4 minutes": {
+			observes: []observe{
+				{sec: 0, song: baz, pos: intPtr(2), state: "play"},
+				{sec: 240, song: nil, state: "stop"},
+			},
+			want: []*httpHistory{
+				{Type: historySkip, Time: base.Add(240 * time.Second), Song: baz, Elapsed: float64Ptr(240), Listened: boolPtr(true)},
+				{Type: historyStart, Time: base, Song: baz},
+			},
+		},
+		"ignore pos mismatch": {
+			observes: []observe{
+				{sec: 0, song: foo, pos: intPtr(0), state: "play"},
+				{sec: 10, song: foo, pos: intPtr(1), state: "play"},
+				{sec: 10, song: bar, pos: intPtr(0), state: "play"},
+			},
+			want: []*httpHistory{
+				{Type: historyStart, Time: base, Song: foo},
+			},
+		},
+		"keep played time after stop": {
+			observes: []observe{
+				{sec: 0, song: foo, pos: intPtr(0), state: "play"},
+				{sec: 200, song: foo, pos: intPtr(0), state: "stop"},
+				{sec: 300, song: bar, pos: intPtr(1), state: "stop"},
+			},
+			want: []*httpHistory{
+				{Type: historySkip, Time: base.Add(300 * time.Second), Song: foo, Elapsed: float64Ptr(200), Listened: boolPtr(true)},
+				{Type: historyStart, Time: base, Song: foo},
+			},
+		},
+		"same file with other song id": {
+			observes: []observe{
+				{sec: 0, song: map[string][]string{"file": {"bar"}, "Id": {"1"}, "Pos": {"1"}, "duration": {"60"}}, pos: intPtr(1), state: "play"},
+				{sec: 60, song: map[string][]string{"file": {"bar"}, "Id": {"2"}, "Pos": {"2"}, "duration": {"60"}}, pos: intPtr(2), state: "play"},
+			},
+			want: []*httpHistory{
+				{Type: historyStart, Time: base.Add(60 * time.Second), Song: map[string][]string{"file": {"bar"}, "Id": {"2"}, "Pos": {"2"}, "duration": {"60"}}},
+				{Type: historyFinish, Time: base.Add(60 * time.Second), Song: map[string][]string{"file": {"bar"}, "Id": {"1"}, "Pos": {"1"}, "duration": {"60"}}, Elapsed: float64Ptr(60), Listened: boolPtr(true)},
+				{Type: historyStart, Time: base, Song: map[string][]string{"file": {"bar"}, "Id": {"1"}, "Pos": {"1"}, "duration": {"60"}}},
+			},
+		},
+		"elapsed reset": {
+			observes: []observe{
+				{sec: 0, song: bar, pos: intPtr(1), state: "play"},
+				{sec: 30, song: bar, pos: intPtr(1), state: "play", elapsed: 30},
+				{sec: 61, song: bar, pos: intPtr(1), state: "play", elapsed: 1},
+				{sec: 70, song: bar, pos: intPtr(1), state: "play", elapsed: 9},
+			},
+			want: []*httpHistory{
+				{Type: historyFinish, Time: base.Add(61 * time.Second), Song: bar, Elapsed: float64Ptr(60), Listened: boolPtr(true)},
+				{Type: historyStart, Time: base.Add(60 * time.Second), Song: bar},
+				{Type: historyStart, Time: base, Song: bar},
+			},
+		},
+		"not started while paused": {
+			observes: []observe{
+				{sec: 0, song: foo, pos: intPtr(0), state: "pause", elapsed: 30},
+				{sec: 10, song: bar, pos: intPtr(1), state: "pause"},
+			},
+			want: []*httpHistory{},
+		},
+	} {
+		t.Run(label, func(t *testing.T) {
+			h, err := NewHistoryHandler(t.TempDir(), nil, log.New(io.Discard))
+			if err != nil {
+				t.Fatalf("NewHistoryHandler() = %v", err)
+			}
+			defer h.Close()
+			for _, o := range tt.observes {
+				h.now = func() time.Time { return base.Add(time.Duration(o.sec) * time.Second) }
+				if err := h.Observe(o.song, o.pos, o.state, o.elapsed); err != nil {
+					t.Fatalf("Observe(%v, %v, %q, %v) = %v", o.song, o.pos, o.state, o.elapsed, err)
+				}
+			}
+			if got := getHistory(t, h, ""); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got history\n%s; want\n%s", jsonString(got), jsonString(tt.want))
+			}
+		})
+	}
+}
+
+type testScrobbler struct {
+	listens []string
+}
+
+func (s *testScrobbler) Scrobble(ctx context.Context, listenedAt time.Time, song map[string][]string) error {
+	s.listens = append(s.listens, listenedAt.Format(time.RFC3339)+" "+song["file"][0])
+	return nil
+}
+
+func TestHistoryHandlerScrobble(t *testing.T) {
+	base := time.Date(2021, 1, 17, 0, 0, 0, 0, time.UTC)
+	for label, dir := range map[string]string{"with history": t.TempDir(), "without history": ""} {
+		t.Run(label, func(t *testing.T) {
+			s := &testScrobbler{}
+			h, err := NewHistoryHandler(dir, []Scrobbler{s}, log.New(io.Discard))
+			if err != nil {
+				t.Fatalf("NewHistoryHandler() = %v", err)
+			}
+			defer h.Close()
+			for i, o := range []struct {
+				sec  int
+				song map[string][]string
+				pos  int
+			}{
+				{sec: 0, song: map[string][]string{"file": {"foo"}, "Pos": {"0"}, "duration": {"100"}}, pos: 0},
+				{sec: 10, song: map[string][]string{"file": {"bar"}, "Pos": {"1"}, "duration": {"100"}}, pos: 1},
+				{sec: 70, song: map[string][]string{"file": {"baz"}, "Pos": {"2"}, "duration": {"100"}}, pos: 2},
+			} {
+				h.now = func() time.Time { return base.Add(time.Duration(o.sec) * time.Second) }
+				if err := h.Observe(o.song, &o.pos, "play", 0); err != nil {
+					t.Fatalf("[%d] Observe() = %v", i, err)
+				}
+			}
+			h.Close() // waits for scrobblers
+			if want := []string{"2021-01-17T00:00:10Z bar"}; !reflect.DeepEqual(s.listens, want) {
+				t.Errorf("got scrobbled %v; want %v", s.listens, want)
+			}
+		})
+	}
+}
+
+func TestHistoryHandlerClose(t *testing.T) {
+	base := time.Date(2021, 1, 17, 0, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	s := &testScrobbler{}
+	h, err := NewHistoryHandler(dir, []Scrobbler{s}, log.New(io.Discard))
+	if err != nil {
+		t.Fatalf("NewHistoryHandler() = %v", err)
+	}
+	song := map[string][]string{"file": {"foo"}, "Pos": {"0"}, "duration": {"100"}}
+	h.now = func() time.Time { return base }
+	if err := h.Observe(song, intPtr(0), "play", 0); err != nil {
+		t.Fatalf("Observe() = %v", err)
+	}
+	h.now = func() time.Time { return base.Add(60 * time.Second) }
+	h.Close()
+	if want := []string{"2021-01-17T00:00:00Z foo"}; !reflect.DeepEqual(s.listens, want) {
+		t.Errorf("got scrobbled %v; want %v", s.listens, want)
+	}
+	h, err = NewHistoryHandler(dir, nil, log.New(io.Discard))
+	if err != nil {
+		t.Fatalf("NewHistoryHandler() = %v", err)
+	}
+	defer h.Close()
+	want := []*httpHistory{
+		{Type: historySkip, Time: base.Add(60 * time.Second), Song: song, Elapsed: float64Ptr(60), Listened: boolPtr(true)},
+		{Type: historyStart, Time: base, Song: song},
+	}
+	if got := getHistory(t, h, ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("got history\n%s; want\n%s", jsonString(got), jsonString(want))
+	}
+}
+
+func TestHistoryHandlerRetention(t *testing.T) {
+	base := time.Date(2021, 1, 17, 0, 0, 0, 0, time.UTC)
+	h, err := NewHistoryHandler(t.TempDir(), nil, log.New(io.Discard))
+	if err != nil {
+		t.Fatalf("NewHistoryHandler() = %v", err)
+	}
+	defer h.Close()
+	foo := map[string][]string{"file": {"foo"}, "Pos": {"0"}}
+	bar := map[string][]string{"file": {"bar"}, "Pos": {"1"}}
+	h.now = func() time.Time { return base }
+	if err := h.Observe(foo, intPtr(0), "play", 0); err != nil {
+		t.Fatalf("Observe(foo) = %v", err)
+	}
+	h.now = func() time.Time { return base.Add(historyRetention + time.Second) }
+	if err := h.Observe(bar, intPtr(1), "play", 0); err != nil {
+		t.Fatalf("Observe(bar) = %v", err)
+	}
+	got := getHistory(t, h, "")
+	events := make([]string, len(got))
+	for i := range got {
+		events[i] = got[i].Type + " " + got[i].Song["file"][0]
+	}
+	if want := []string{"start bar", "skip foo"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("got %v; want %v", events, want)
+	}
+}
+
+func TestHistoryHandlerQuery(t *testing.T) {
+	base := time.Date(2021, 1, 17, 0, 0, 0, 0, time.UTC)
+	h, err := NewHistoryHandler(t.TempDir(), nil, log.New(io.Discard))
+	if err != nil {
+		t.Fatalf("NewHistoryHandler() = %v", err)
+	}
+	defer h.Close()
+	songs := []map[string][]string{
+		{"file": {"foo"}, "Pos": {"0"}, "duration": {"100"}},
+		{"file": {"bar"}, "Pos": {"1"}, "duration": {"100"}},
+		{"file": {"baz"}, "Pos": {"2"}, "duration": {"100"}},
+	}
+	for i, s := range songs {
+		h.now = func() time.Time { return base.Add(time.Duration(i) * time.Minute) }
+		if err := h.Observe(s, intPtr(i), "play", 0); err != nil {
+			t.Fatalf("Observe(%v) = %v", s, err)
+		}
+	}
+	for _, tt := range []struct {
+		query  string
+		status int
+		want   []string
+	}{
+		{query: "", status: http.StatusOK, want: []string{"start baz", "skip bar", "start bar", "skip foo", "start foo"}},
+		{query: "?limit=2", status: http.StatusOK, want: []string{"start baz", "skip bar"}},
+		{query: "?listened=true", status: http.StatusOK, want: []string{"skip bar", "skip foo"}},
+		{query: "?from=2021-01-17T00:01:00Z&to=2021-01-17T00:02:00Z", status: http.StatusOK, want: []string{"start bar", "skip foo"}},
+		{query: "?from=invalid", status: http.StatusBadRequest},
+		{query: "?to=invalid", status: http.StatusBadRequest},
+		{query: "?limit=invalid", status: http.StatusBadRequest},
+	} {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Fatalf("got status %d; want %d", w.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var got []*httpHistory
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			events := make([]string, len(got))
+			for i := range got {
+				events[i] = got[i].Type + " " + got[i].Song["file"][0]
+			}
+			if !reflect.DeepEqual(events, tt.want) {
+				t.Errorf("got %v; want %v", events, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryHandlerDisabled(t *testing.T) {
+	h, err := NewHistoryHandler("", nil, log.New(io.Discard))
+	if err != nil {
+		t.Fatalf("NewHistoryHandler() = %v", err)
+	}
+	defer h.Close()
+	if err := h.Observe(map[string][]string{"file": {"foo"}, "Pos": {"0"}}, intPtr(0), "play", 0); err != nil {
+		t.Errorf("Observe() = %v; want nil", err)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("got status %d; want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func getHistory(t *testing.T, h *HistoryHandler, query string) []*httpHistory {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d; want %d", w.Code, http.StatusOK)
+	}
+	var got []*httpHistory
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return got
+}
+
+func jsonString(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func intPtr(i int) *int             { return &i }
+func float64Ptr(f float64) *float64 { return &f }
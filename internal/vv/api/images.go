@@ -9,8 +9,18 @@ import (
 	"time"
 )
 
+// ImageWatcher represents image provider which updates image urls by itself.
+type ImageWatcher interface {
+	// Changed returns image urls changes event chan.
+	Changed() <-chan struct{}
+}
+
 type httpImages struct {
-	Updating bool `json:"updating"`
+	Updating bool   `json:"updating"`
+	Total    int    `json:"total"`
+	Done     int    `json:"done"`
+	Errors   int    `json:"errors"`
+	Current  string `json:"current,omitempty"`
 }
 
 type ImagesHandler struct {
@@ -19,35 +29,77 @@ type ImagesHandler struct {
 	mu       sync.RWMutex
 	library  []map[string][]string
 	changed  chan bool
+	watched  chan struct{}
 	logger   Logger
 }
 
-func NewImagesHandler(img []ImageProvider, logger Logger) (*ImagesHandler, error) {
+// NewImagesHandler creates ImagesHandler. workers is the number of albums to
+// update images concurrently.
+func NewImagesHandler(img []ImageProvider, workers int, logger Logger) (*ImagesHandler, error) {
 	c, err := newCache(&httpImages{})
 	if err != nil {
 		return nil, err
 	}
 	ret := &ImagesHandler{
 		cache:    c,
-		imgBatch: newImgBatch(img, logger),
+		imgBatch: newImgBatch(img, workers, logger),
 		changed:  make(chan bool, 10),
+		watched:  make(chan struct{}, 1),
 		logger:   logger,
 	}
+	watched := make(chan struct{}, 1)
+	for _, p := range img {
+		if w, ok := p.(ImageWatcher); ok {
+			go func() {
+				for range w.Changed() {
+					select {
+					case watched <- struct{}{}:
+					default:
+					}
+				}
+			}()
+		}
+	}
 	go func() {
-		for e := range ret.imgBatch.Event() {
-			ret.cache.SetIfModified(&httpImages{Updating: e})
-			ret.changed <- e
+		events := ret.imgBatch.Event()
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					close(ret.changed)
+					close(ret.watched)
+					return
+				}
+				p := ret.imgBatch.Progress()
+				ret.cache.SetIfModified(&httpImages{Updating: e, Total: p.Total, Done: p.Done, Errors: p.Errors, Current: p.Current})
+				ret.changed <- e
+			case <-watched:
+				select {
+				case ret.watched <- struct{}{}:
+				default:
+				}
+			}
 		}
-		close(ret.changed)
 	}()
 	return ret, nil
 }
 
+// ConvSong adds cover image urls to song. cover_palette has dominant, accent
+// and text colors and cover_blurhash has placeholder of the first cover if
+// provider supports it.
 func (a *ImagesHandler) ConvSong(s map[string][]string) (map[string][]string, bool) {
 	delete(s, "cover")
+	delete(s, "cover_palette")
+	delete(s, "cover_blurhash")
 	cover, updated := a.imgBatch.GetURLs(s)
 	if len(cover) != 0 {
 		s["cover"] = cover
+		if colors, ok := a.imgBatch.Palette(cover[0]); ok {
+			s["cover_palette"] = colors
+		}
+		if hash, ok := a.imgBatch.Blurhash(cover[0]); ok {
+			s["cover_blurhash"] = []string{hash}
+		}
 	}
 	return s, updated
 }
@@ -108,6 +160,12 @@ func (a *ImagesHandler) Changed() <-chan bool {
 	return a.changed
 }
 
+// URLChanged returns event chan of image urls updated by ImageWatcher
+// providers. Response body of ImagesHandler is not changed by the event.
+func (a *ImagesHandler) URLChanged() <-chan struct{} {
+	return a.watched
+}
+
 // Close closes update event chan.
 func (a *ImagesHandler) Close() {
 	a.cache.Close()
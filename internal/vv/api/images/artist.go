@@ -0,0 +1,198 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meiraka/vv/internal/songs"
+)
+
+// Artist provides http server artist images from local filesystem.
+// Artist image is searched by AlbumArtist name in artist images directory
+// like "artists/Foo.jpg", then by file patterns in the parent directory of
+// the song directory like "Foo/artist.jpg" for "Foo/Album/song.flac".
+type Artist struct {
+	httpPrefix     string
+	musicDirectory string // empty if parent directory search is disabled
+	files          []string
+	artistDir      string // empty if artist images directory is disabled
+	thumbnails     *thumbnailCache
+	opts           *CacheOptions
+	mu             sync.RWMutex
+	names          map[string]string   // lower cased file name without ext to path in artistDir; nil if not read
+	byName         map[string][]string // artist name to urls
+	byDir          map[string][]string // parent directory to urls
+	url2img        map[string]string
+}
+
+// NewArtist creates Artist. files are slash separated glob patterns relative to
+// the parent directory of the song directory like "artist.*", matched
+// case-insensitively in priority order. artistDir is a directory which has
+// images named by artist like "Foo.jpg". musicDir or artistDir may be empty to
+// disable the search. Resized images are cached in cacheDir; opts limits the
+// cache size, nil means unlimited.
+func NewArtist(httpPrefix string, musicDir string, files []string, artistDir string, cacheDir string, opts *CacheOptions) (*Artist, error) {
+	var err error
+	if len(musicDir) != 0 {
+		if musicDir, err = filepath.Abs(musicDir); err != nil {
+			return nil, err
+		}
+	}
+	if len(artistDir) != 0 {
+		if artistDir, err = filepath.Abs(artistDir); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range files {
+		if _, err := path.Match(f, ""); err != nil {
+			return nil, fmt.Errorf("invalid artist image file pattern %q: %w", f, err)
+		}
+	}
+	thumbnails, err := newThumbnailCache(filepath.Join(cacheDir, "thumbnails"))
+	if err != nil {
+		return nil, err
+	}
+	return &Artist{
+		httpPrefix:     httpPrefix,
+		musicDirectory: musicDir,
+		files:          files,
+		artistDir:      artistDir,
+		thumbnails:     thumbnails,
+		opts:           opts,
+		byName:         map[string][]string{},
+		byDir:          map[string][]string{},
+		url2img:        map[string]string{},
+	}, nil
+}
+
+// ServeHTTP serves artist image with httpPrefix
+func (a *Artist) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	path, ok := a.url2img[r.URL.Path]
+	a.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	serveImage(path, a.thumbnails, w, r)
+}
+
+// GetArtistURLs returns artist image urls for AlbumArtist of song.
+func (a *Artist) GetArtistURLs(song map[string][]string) []string {
+	if a == nil {
+		return nil
+	}
+	if name := songs.Tag(song, "AlbumArtist"); len(name) != 0 && len(a.artistDir) != 0 {
+		a.mu.RLock()
+		v, ok := a.byName[name[0]]
+		a.mu.RUnlock()
+		if !ok {
+			v = a.updateName(name[0])
+		}
+		if len(v) != 0 {
+			return v
+		}
+	}
+	if file := song["file"]; len(file) == 1 && len(a.musicDirectory) != 0 && len(a.files) != 0 {
+		dir := path.Dir(path.Dir(file[0]))
+		if dir == "." || dir == "/" {
+			// songs in music directory root or top level directories have no artist directory
+			return nil
+		}
+		a.mu.RLock()
+		v, ok := a.byDir[dir]
+		a.mu.RUnlock()
+		if !ok {
+			v = a.updateDir(dir)
+		}
+		return v
+	}
+	return nil
+}
+
+func (a *Artist) updateName(name string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.names == nil {
+		a.names = map[string]string{}
+		l, _ := os.ReadDir(a.artistDir)
+		for _, e := range l {
+			if e.IsDir() || !isImageFile(e.Name()) {
+				continue
+			}
+			key := strings.ToLower(strings.TrimSuffix(e.Name(), filepath.Ext(e.Name())))
+			if _, ok := a.names[key]; !ok {
+				a.names[key] = filepath.Join(a.artistDir, e.Name())
+			}
+		}
+	}
+	ret := []string{}
+	if rpath, ok := a.names[strings.ToLower(strings.ReplaceAll(name, "/", "_"))]; ok {
+		ret = a.addURL(ret, path.Join(a.httpPrefix, "artists", filepath.Base(rpath)), rpath)
+	}
+	a.byName[name] = ret
+	return ret
+}
+
+func (a *Artist) updateDir(dir string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ret := []string{}
+	localDir := filepath.Join(a.musicDirectory, filepath.FromSlash(dir))
+	dirs := map[string][]os.DirEntry{}
+	found := map[string]struct{}{}
+	for _, pattern := range a.files {
+		for _, rpath := range matchFiles(localDir, strings.Split(strings.ToLower(pattern), "/"), dirs) {
+			if _, ok := found[rpath]; ok {
+				continue
+			}
+			found[rpath] = struct{}{}
+			rel := strings.TrimPrefix(strings.TrimPrefix(filepath.ToSlash(rpath), filepath.ToSlash(a.musicDirectory)), "/")
+			ret = a.addURL(ret, path.Join(a.httpPrefix, "library", rel), rpath)
+		}
+	}
+	a.byDir[dir] = ret
+	return ret
+}
+
+// addURL appends image url with modified date query to urls.
+func (a *Artist) addURL(urls []string, u, rpath string) []string {
+	s, err := os.Stat(rpath)
+	if err != nil {
+		return urls
+	}
+	a.url2img[u] = rpath
+	// artist names may have url special characters like '?'
+	return append(urls, (&url.URL{Path: u}).EscapedPath()+"?"+url.Values{"d": {strconv.FormatInt(s.ModTime().Unix(), 10)}}.Encode())
+}
+
+// CacheName returns cache name for cache usage report.
+func (a *Artist) CacheName() string {
+	return path.Base(a.httpPrefix)
+}
+
+// CacheUsage returns number of files and total bytes of cached thumbnails.
+func (a *Artist) CacheUsage() (int, int64, error) {
+	return cacheUsage(a.thumbnails)
+}
+
+// GC forgets searched artist images to find added, replaced or removed images
+// by next search, and evicts thumbnails over the cache limits. Image urls
+// already responded are still served.
+func (a *Artist) GC(ctx context.Context, library []map[string][]string) error {
+	a.mu.Lock()
+	a.names = nil
+	a.byName = map[string][]string{}
+	a.byDir = map[string][]string{}
+	a.mu.Unlock()
+	return evict(a.opts, time.Now(), a.thumbnails)
+}
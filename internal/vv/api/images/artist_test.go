@@ -0,0 +1,83 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestArtist(t *testing.T) {
+	dir := t.TempDir()
+	music, artists := filepath.Join(dir, "music"), filepath.Join(dir, "artists")
+	png := readFile(t, filepath.Join("testdata", "app.png"))
+	for _, f := range []string{
+		"music/Foo/Artist.PNG", "music/Foo/Album/song.flac",
+		"music/Bar/Album/song.flac",
+		"music/Album/song.flac",
+		"artists/Bar.png", "artists/AC_DC.png", "artists/Baz?.png", "artists/Qux.txt",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(p, png, 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+	d := func(f string) string {
+		return "?d=" + strconv.FormatInt(stat(t, filepath.Join(dir, filepath.FromSlash(f))).ModTime().Unix(), 10)
+	}
+	api, err := NewArtist("/api/images/artist/", music, []string{"artist.*"}, artists, filepath.Join(dir, "cache"), nil)
+	if err != nil {
+		t.Fatalf("failed to initialize images.Artist: %v", err)
+	}
+	for _, tt := range []struct {
+		in   map[string][]string
+		want []string
+	}{
+		{in: map[string][]string{"file": {"Foo/Album/song.flac"}, "AlbumArtist": {"Foo"}}, want: []string{"/api/images/artist/library/Foo/Artist.PNG" + d("music/Foo/Artist.PNG")}},
+		{in: map[string][]string{"file": {"Bar/Album/song.flac"}, "Artist": {"bar"}}, want: []string{"/api/images/artist/artists/Bar.png" + d("artists/Bar.png")}},
+		{in: map[string][]string{"file": {"Bar/Album/song.flac"}, "AlbumArtist": {"AC/DC"}}, want: []string{"/api/images/artist/artists/AC_DC.png" + d("artists/AC_DC.png")}},
+		{in: map[string][]string{"file": {"Bar/Album/song.flac"}, "AlbumArtist": {"Baz?"}}, want: []string{"/api/images/artist/artists/Baz%3F.png" + d("artists/Baz?.png")}},
+		{in: map[string][]string{"file": {"Bar/Album/song.flac"}, "AlbumArtist": {"Qux"}}, want: []string{}},
+		{in: map[string][]string{"file": {"Album/song.flac"}, "AlbumArtist": {"Foo"}}},
+	} {
+		t.Run(fmt.Sprint(tt.in), func(t *testing.T) {
+			got := api.GetArtistURLs(tt.in)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got GetArtistURLs() = %v; want %v", got, tt.want)
+			}
+			if len(got) == 0 {
+				return
+			}
+			u, _ := url.Parse(got[0])
+			w := httptest.NewRecorder()
+			api.ServeHTTP(w, httptest.NewRequest("GET", u.String(), nil))
+			if b, _ := io.ReadAll(w.Result().Body); w.Code != 200 || !reflect.DeepEqual(b, png) {
+				t.Errorf("got status %d and invalid binary response; want 200 and image", w.Code)
+			}
+		})
+	}
+
+	// GC forgets searched images
+	if err := os.Remove(filepath.Join(artists, "Bar.png")); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	song := map[string][]string{"file": {"Bar/Album/song.flac"}, "Artist": {"bar"}}
+	if got := api.GetArtistURLs(song); len(got) != 1 {
+		t.Errorf("got GetArtistURLs() = %v before GC; want cached url", got)
+	}
+	if err := api.GC(context.Background(), nil); err != nil {
+		t.Errorf("GC() = %v; want <nil>", err)
+	}
+	if got := api.GetArtistURLs(song); len(got) != 0 {
+		t.Errorf("got GetArtistURLs() = %v after GC; want []", got)
+	}
+}
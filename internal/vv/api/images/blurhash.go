@@ -0,0 +1,107 @@
+package images
+
+import (
+	"image"
+	"math"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// blurhashComponentsX and blurhashComponentsY are the number of blurhash
+	// components; 4x3 is enough for album covers.
+	blurhashComponentsX = 4
+	blurhashComponentsY = 3
+	// blurhashSize is the width and height of image to calculate blurhash.
+	blurhashSize = 32
+	base83Chars  = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+)
+
+// blurhash returns blurhash string of img.
+// See https://github.com/woltapp/blurhash/blob/master/Algorithm.md
+func blurhash(img image.Image) string {
+	rect := image.Rect(0, 0, blurhashSize, blurhashSize)
+	small := image.NewNRGBA(rect)
+	draw.ApproxBiLinear.Scale(small, rect, img, img.Bounds(), draw.Src, nil)
+	var linear [blurhashSize * blurhashSize][3]float64
+	for y := 0; y < blurhashSize; y++ {
+		for x := 0; x < blurhashSize; x++ {
+			c := small.NRGBAAt(x, y)
+			linear[y*blurhashSize+x] = [3]float64{srgbToLinear(c.R), srgbToLinear(c.G), srgbToLinear(c.B)}
+		}
+	}
+	factors := make([][3]float64, 0, blurhashComponentsX*blurhashComponentsY)
+	for j := 0; j < blurhashComponentsY; j++ {
+		for i := 0; i < blurhashComponentsX; i++ {
+			norm := 2.0
+			if i == 0 && j == 0 {
+				norm = 1
+			}
+			var f [3]float64
+			for y := 0; y < blurhashSize; y++ {
+				for x := 0; x < blurhashSize; x++ {
+					basis := norm * math.Cos(math.Pi*float64(i*x)/blurhashSize) * math.Cos(math.Pi*float64(j*y)/blurhashSize)
+					for k := range f {
+						f[k] += basis * linear[y*blurhashSize+x][k]
+					}
+				}
+			}
+			for k := range f {
+				f[k] /= blurhashSize * blurhashSize
+			}
+			factors = append(factors, f)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(encode83((blurhashComponentsX-1)+(blurhashComponentsY-1)*9, 1))
+	maxAC := 0.0
+	for _, f := range factors[1:] {
+		for _, v := range f {
+			maxAC = math.Max(maxAC, math.Abs(v))
+		}
+	}
+	quantizedMax := int(math.Max(0, math.Min(82, math.Floor(maxAC*166-0.5))))
+	maxValue := float64(quantizedMax+1) / 166
+	b.WriteString(encode83(quantizedMax, 1))
+	dc := factors[0]
+	b.WriteString(encode83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+	for _, f := range factors[1:] {
+		var q [3]int
+		for k, v := range f {
+			q[k] = int(math.Max(0, math.Min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+		}
+		b.WriteString(encode83(q[0]*19*19+q[1]*19+q[2], 2))
+	}
+	return b.String()
+}
+
+func encode83(v, length int) string {
+	b := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		b[i] = base83Chars[v%83]
+		v /= 83
+	}
+	return string(b)
+}
+
+func srgbToLinear(c uint8) float64 {
+	v := float64(c) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
@@ -0,0 +1,41 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestBlurhash(t *testing.T) {
+	fill := func(c color.Color) image.Image {
+		img := image.NewNRGBA(image.Rect(0, 0, 50, 40))
+		for y := 0; y < 40; y++ {
+			for x := 0; x < 50; x++ {
+				img.Set(x, y, c)
+			}
+		}
+		return img
+	}
+	gradient := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			gradient.Set(x, y, color.NRGBA{uint8(x * 8), uint8(y * 8), 0x80, 0xff})
+		}
+	}
+	for label, tt := range map[string]struct {
+		img  image.Image
+		want string
+	}{
+		// 4x3 components, max ac, dc and 11 ac components
+		"white":    {img: fill(color.White), want: "L9TSUA~qfQ~q~qoffQoffQfQfQfQ"},
+		"black":    {img: fill(color.Black), want: "L00000" + strings.Repeat("fQ", 11)},
+		"gradient": {img: gradient, want: "LxH2cX2swxX8l}WDjte;gJfjfQfj"},
+	} {
+		t.Run(label, func(t *testing.T) {
+			if got := blurhash(tt.img); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
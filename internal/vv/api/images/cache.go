@@ -4,31 +4,42 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
 
 var (
-	bucketKeyToURL   = []byte("key2url")
-	bucketURLToLocal = []byte("url2local")
-	bucketKeyToReqID = []byte("key2req")
+	bucketKeyToURL      = []byte("key2url")
+	bucketURLToLocal    = []byte("url2local")
+	bucketKeyToReqID    = []byte("key2req")
+	bucketLocalToAccess = []byte("local2access")
+	bucketLocalToColor  = []byte("local2color")
+	bucketLocalToHash   = []byte("local2blurhash")
 )
 
+// cacheDBName is the cache db file name in cacheDir.
+const cacheDBName = "db4"
+
 type cache struct {
 	cacheDir string
 	db       *bolt.DB
+	mu       sync.Mutex // serializes writing image files and removing them
+	accessMu sync.Mutex
+	accessed map[string]time.Time
 }
 
 func newCache(cacheDir string) (*cache, error) {
 	if err := os.MkdirAll(cacheDir, 0766); err != nil {
 		return nil, err
 	}
-	db, err := bolt.Open(filepath.Join(cacheDir, "db4"), 0666, &bolt.Options{Timeout: time.Second})
+	db, err := bolt.Open(filepath.Join(cacheDir, cacheDBName), 0666, &bolt.Options{Timeout: time.Second})
 	if err != nil {
 		if errors.Is(err, bolt.ErrTimeout) {
 			return nil, fmt.Errorf("obtain cache lock: %w", err)
@@ -37,7 +48,7 @@ func newCache(cacheDir string) (*cache, error) {
 	}
 
 	if err := db.Update(func(tx *bolt.Tx) error {
-		for _, s := range [][]byte{bucketKeyToURL, bucketURLToLocal, bucketKeyToReqID} {
+		for _, s := range [][]byte{bucketKeyToURL, bucketURLToLocal, bucketKeyToReqID, bucketLocalToAccess, bucketLocalToColor, bucketLocalToHash} {
 			_, err := tx.CreateBucketIfNotExists(s)
 			if err != nil {
 				return fmt.Errorf("create bucket: %s", err)
@@ -50,6 +61,7 @@ func newCache(cacheDir string) (*cache, error) {
 	return &cache{
 		cacheDir: cacheDir,
 		db:       db,
+		accessed: map[string]time.Time{},
 	}, nil
 }
 
@@ -65,6 +77,7 @@ func (c *cache) GetLocalPath(r string) (string, bool) {
 	if localName == nil {
 		return "", false
 	}
+	c.touch(string(localName))
 	return filepath.Join(c.cacheDir, string(localName)), true
 }
 
@@ -86,6 +99,34 @@ func (c *cache) GetURL(key string) (string, bool) {
 	return string(url), true
 }
 
+// GetPalette returns image palette from url path.
+func (c *cache) GetPalette(r string) ([]string, bool) {
+	b := c.getSummary(bucketLocalToColor, r)
+	if len(b) == 0 {
+		return nil, false
+	}
+	return strings.Split(string(b), ","), true
+}
+
+// GetBlurhash returns image blurhash from url path.
+func (c *cache) GetBlurhash(r string) (string, bool) {
+	b := c.getSummary(bucketLocalToHash, r)
+	return string(b), len(b) != 0
+}
+
+func (c *cache) getSummary(bucket []byte, r string) []byte {
+	var b []byte
+	c.db.View(func(tx *bolt.Tx) error {
+		if localName := tx.Bucket(bucketURLToLocal).Get([]byte(localName([]byte(r)))); localName != nil {
+			if v := tx.Bucket(bucket).Get(localName); v != nil {
+				b = append([]byte{}, v...)
+			}
+		}
+		return nil
+	})
+	return b
+}
+
 func (c *cache) GetLastRequestID(key string) (string, bool) {
 	var b []byte
 	if err := c.db.View(func(tx *bolt.Tx) error {
@@ -100,8 +141,25 @@ func (c *cache) GetLastRequestID(key string) (string, bool) {
 	return string(b), true
 }
 
+// touch records access time of local file for eviction.
+func (c *cache) touch(localName string) {
+	now := time.Now()
+	c.accessMu.Lock()
+	if now.Sub(c.accessed[localName]) < accessInterval {
+		c.accessMu.Unlock()
+		return
+	}
+	c.accessed[localName] = now
+	c.accessMu.Unlock()
+	c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketLocalToAccess).Put([]byte(localName), []byte(strconv.FormatInt(now.Unix(), 10)))
+	})
+}
+
 // Set updates image cache and reqid by key.
 func (c *cache) Set(key, reqid string, b []byte) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	bkey := []byte(key)
 	ext, err := ext(b)
 	if err != nil {
@@ -117,9 +175,10 @@ func (c *cache) Set(key, reqid string, b []byte) (err error) {
 		return err
 	}
 
-	var filename string
+	var filename, oldFilename string
 	var version int64
 	if len(url) != 0 {
+		oldFilename = localName(url)
 		// get old version
 		url := string(url)
 		i := strings.LastIndex(url, "=")
@@ -155,6 +214,12 @@ func (c *cache) Set(key, reqid string, b []byte) (err error) {
 					// same binary
 					return c.db.Update(func(tx *bolt.Tx) error {
 						tx.Bucket(bucketKeyToReqID).Put(bkey, []byte(reqid))
+						// fills summary of images cached by previous version
+						if tx.Bucket(bucketLocalToHash).Get([]byte(filename)) == nil {
+							colors, hash := byteSummary(b)
+							tx.Bucket(bucketLocalToColor).Put([]byte(filename), colors)
+							tx.Bucket(bucketLocalToHash).Put([]byte(filename), hash)
+						}
 						return nil
 					})
 				}
@@ -172,16 +237,203 @@ func (c *cache) Set(key, reqid string, b []byte) (err error) {
 
 	// stores filename to db
 	value := filepath.Base(f.Name())
-	return c.db.Update(func(tx *bolt.Tx) error {
+	colors, hash := byteSummary(b)
+	if err := c.db.Update(func(tx *bolt.Tx) error {
 		tx.Bucket(bucketKeyToURL).Put(bkey, []byte(value+"?v="+strconv.FormatInt(version, 10)))
 		tx.Bucket(bucketURLToLocal).Put([]byte(value), []byte(value))
 		tx.Bucket(bucketKeyToReqID).Put(bkey, []byte(reqid))
+		tx.Bucket(bucketLocalToAccess).Put([]byte(value), []byte(strconv.FormatInt(time.Now().Unix(), 10)))
+		tx.Bucket(bucketLocalToColor).Put([]byte(value), colors)
+		tx.Bucket(bucketLocalToHash).Put([]byte(value), hash)
 		return nil
+	}); err != nil {
+		return err
+	}
+	if len(oldFilename) != 0 && oldFilename != value {
+		// image format is changed
+		return c.deleteFiles([]string{oldFilename})
+	}
+	return nil
+}
+
+// byteSummary returns comma separated palette colors and blurhash of image binary.
+// byteSummary returns empty values if image is not decodable.
+func byteSummary(b []byte) ([]byte, []byte) {
+	sum, err := summarizeImage(bytes.NewReader(b))
+	if err != nil {
+		return []byte{}, []byte{}
+	}
+	return []byte(strings.Join(sum.palette, ",")), []byte(sum.blurhash)
+}
+
+// localName returns local file name from key2url value.
+func localName(url []byte) string {
+	s := string(url)
+	if i := strings.LastIndex(s, "?"); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// entries returns cached image files.
+func (c *cache) entries() ([]*cacheEntry, error) {
+	var ret []*cacheEntry
+	err := c.db.View(func(tx *bolt.Tx) error {
+		access := tx.Bucket(bucketLocalToAccess)
+		return tx.Bucket(bucketURLToLocal).ForEach(func(k, v []byte) error {
+			s, err := os.Stat(filepath.Join(c.cacheDir, string(v)))
+			if err != nil {
+				return nil
+			}
+			e := &cacheEntry{name: string(v), size: s.Size(), access: s.ModTime()}
+			if a := access.Get(v); a != nil {
+				if i, err := strconv.ParseInt(string(a), 10, 64); err == nil {
+					e.access = time.Unix(i, 0)
+				}
+			}
+			ret = append(ret, e)
+			return nil
+		})
 	})
+	return ret, err
+}
+
+// deleteFiles removes cached image files and keys which refer them.
+// Removed keys are fetched again by next Update.
+func (c *cache) deleteFiles(names []string) error {
+	rm := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		rm[n] = struct{}{}
+	}
+	if err := c.db.Update(func(tx *bolt.Tx) error {
+		k2u, k2r := tx.Bucket(bucketKeyToURL), tx.Bucket(bucketKeyToReqID)
+		var keys [][]byte
+		if err := k2u.ForEach(func(k, v []byte) error {
+			if _, ok := rm[localName(v)]; ok {
+				keys = append(keys, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, k := range keys {
+			k2u.Delete(k)
+			k2r.Delete(k)
+		}
+		for n := range rm {
+			for _, b := range [][]byte{bucketURLToLocal, bucketLocalToAccess, bucketLocalToColor, bucketLocalToHash} {
+				tx.Bucket(b).Delete([]byte(n))
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	c.accessMu.Lock()
+	for n := range rm {
+		delete(c.accessed, n)
+	}
+	c.accessMu.Unlock()
+	for n := range rm {
+		if err := os.Remove(filepath.Join(c.cacheDir, n)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// gc removes keys not in given keys, and image files not referred by any keys.
+func (c *cache) gc(keys map[string]struct{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	used := map[string]struct{}{}
+	var unused []string
+	if err := c.db.Update(func(tx *bolt.Tx) error {
+		for _, b := range []*bolt.Bucket{tx.Bucket(bucketKeyToURL), tx.Bucket(bucketKeyToReqID)} {
+			var del [][]byte
+			if err := b.ForEach(func(k, v []byte) error {
+				if _, ok := keys[string(k)]; !ok {
+					del = append(del, k)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			for _, k := range del {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Bucket(bucketKeyToURL).ForEach(func(k, v []byte) error {
+			if len(v) != 0 {
+				used[localName(v)] = struct{}{}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketURLToLocal).ForEach(func(k, v []byte) error {
+			if _, ok := used[string(v)]; !ok {
+				unused = append(unused, string(v))
+			}
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+	if err := c.deleteFiles(unused); err != nil {
+		return err
+	}
+	// removes files not recorded in db like previous version leftovers
+	l, err := os.ReadDir(c.cacheDir)
+	if err != nil {
+		return err
+	}
+	for _, f := range l {
+		if f.IsDir() || !isCacheFile(f.Name()) {
+			continue
+		}
+		if _, ok := used[f.Name()]; ok {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.cacheDir, f.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheFileExts are image file extensions written by cache.Set.
+var cacheFileExts = map[string]struct{}{"jpeg": {}, "png": {}, "gif": {}, "bmp": {}, "webp": {}}
+
+// isCacheFile reports whether name is image file name written by cache.Set,
+// which is random digits and image format like "123456.png".
+func isCacheFile(name string) bool {
+	base, ext, ok := strings.Cut(name, ".")
+	if !ok || len(base) == 0 {
+		return false
+	}
+	for _, r := range base {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	_, ok = cacheFileExts[ext]
+	return ok
+}
+
+// remove removes cached image files and keys which refer them.
+func (c *cache) remove(names []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deleteFiles(names)
 }
 
 // Set updates image cache and reqid by key.
 func (c *cache) SetEmpty(key, reqid string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.db.Update(func(tx *bolt.Tx) error {
 		bkey := []byte(key)
 		tx.Bucket(bucketKeyToURL).Put(bkey, []byte(""))
@@ -190,6 +442,42 @@ func (c *cache) SetEmpty(key, reqid string) error {
 	})
 }
 
+// Delete removes cached image of key. Delete returns fs.ErrNotExist if key is not cached.
+func (c *cache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var url []byte
+	if err := c.db.Update(func(tx *bolt.Tx) error {
+		bkey := []byte(key)
+		url = tx.Bucket(bucketKeyToURL).Get(bkey)
+		if url == nil {
+			return fs.ErrNotExist
+		}
+		url = append([]byte{}, url...)
+		tx.Bucket(bucketKeyToURL).Delete(bkey)
+		tx.Bucket(bucketKeyToReqID).Delete(bkey)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(url) == 0 {
+		return nil
+	}
+	return c.deleteFiles([]string{localName(url)})
+}
+
+// urls returns all cached url by key.
+func (c *cache) urls() (map[string]string, error) {
+	ret := map[string]string{}
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketKeyToURL).ForEach(func(k, v []byte) error {
+			ret[string(k)] = string(v)
+			return nil
+		})
+	})
+	return ret, err
+}
+
 // Close finalizes cache db, coroutines.
 func (c *cache) Close() error {
 	return c.db.Close()
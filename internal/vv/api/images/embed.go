@@ -4,7 +4,9 @@ import (
 	"context"
 	"net/http"
 	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/meiraka/vv/internal/mpd"
 	"github.com/meiraka/vv/internal/songs"
@@ -14,18 +16,27 @@ import (
 type Embed struct {
 	httpPrefix string
 	cache      *cache
+	thumbnails *thumbnailCache
+	opts       *CacheOptions
 	client     *mpd.Client
 }
 
-// NewEmbed initializes Embed Cover Art provider with cacheDir.
-func NewEmbed(httpPrefix string, client *mpd.Client, cacheDir string) (*Embed, error) {
+// NewEmbed initializes Embed Cover Art provider with cacheDir. opts limits the cache size; nil means unlimited.
+func NewEmbed(httpPrefix string, client *mpd.Client, cacheDir string, opts *CacheOptions) (*Embed, error) {
 	cache, err := newCache(cacheDir)
 	if err != nil {
 		return nil, err
 	}
+	thumbnails, err := newThumbnailCache(filepath.Join(cacheDir, "thumbnails"))
+	if err != nil {
+		cache.Close()
+		return nil, err
+	}
 	s := &Embed{
 		httpPrefix: httpPrefix,
 		cache:      cache,
+		thumbnails: thumbnails,
+		opts:       opts,
 		client:     client,
 	}
 	return s, nil
@@ -99,7 +110,56 @@ func (s *Embed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	serveImage(path, w, r)
+	serveImage(path, s.thumbnails, w, r)
+}
+
+// Palette returns dominant, accent and text colors of cover url.
+func (s *Embed) Palette(url string) ([]string, bool) {
+	p := s.httpPrefix
+	if p[len(p)-1] != '/' {
+		p += "/"
+	}
+	if !strings.HasPrefix(url, p) {
+		return nil, false
+	}
+	return s.cache.GetPalette(url[len(p):])
+}
+
+// Blurhash returns blurhash placeholder of cover url.
+func (s *Embed) Blurhash(url string) (string, bool) {
+	p := s.httpPrefix
+	if p[len(p)-1] != '/' {
+		p += "/"
+	}
+	if !strings.HasPrefix(url, p) {
+		return "", false
+	}
+	return s.cache.GetBlurhash(url[len(p):])
+}
+
+// CacheName returns cache name for cache usage report.
+func (s *Embed) CacheName() string {
+	return path.Base(s.httpPrefix)
+}
+
+// CacheUsage returns number of files and total bytes of cached images and thumbnails.
+func (s *Embed) CacheUsage() (int, int64, error) {
+	return cacheUsage(s.cache, s.thumbnails)
+}
+
+// GC removes cached images for songs not in library, and evicts images over
+// the cache limits.
+func (s *Embed) GC(ctx context.Context, library []map[string][]string) error {
+	keys := map[string]struct{}{}
+	for _, song := range library {
+		if k, _, ok := s.key(song); ok {
+			keys[k] = struct{}{}
+		}
+	}
+	if err := s.cache.gc(keys); err != nil {
+		return err
+	}
+	return evict(s.opts, time.Now(), s.cache, s.thumbnails)
 }
 
 // GetURLs returns cover path for song
@@ -31,7 +31,7 @@ func TestEmbed(t *testing.T) {
 	}
 	defer os.RemoveAll(testDir)
 
-	api, err := NewEmbed("/api/images", c, testDir)
+	api, err := NewEmbed("/api/images", c, testDir, nil)
 	if err != nil {
 		t.Fatalf("failed to initialize cover.Embed: %v", err)
 	}
@@ -136,7 +136,7 @@ func TestEmbedUpdate(t *testing.T) {
 					}
 				}
 			}()
-			api, err := NewEmbed("/api/images", c, testDir)
+			api, err := NewEmbed("/api/images", c, testDir, nil)
 			if err != nil {
 				t.Fatalf("failed to initialize cover.Embed: %v", err)
 			}
@@ -301,7 +301,7 @@ func TestEmbedRescan(t *testing.T) {
 					}
 				}
 			}()
-			api, err := NewEmbed("/api/images", c, testDir)
+			api, err := NewEmbed("/api/images", c, testDir, nil)
 			if err != nil {
 				t.Fatalf("failed to initialize cover.Embed: %v", err)
 			}
@@ -0,0 +1,90 @@
+package images
+
+import (
+	"sort"
+	"time"
+)
+
+// CacheOptions contains cover image cache limits.
+// Zero values mean unlimited.
+type CacheOptions struct {
+	MaxSize int64         // maximum total bytes of cached images and thumbnails
+	MaxAge  time.Duration // removes images not accessed for MaxAge
+}
+
+// accessInterval is the minimum interval to record image access time.
+const accessInterval = time.Hour
+
+// cacheEntry represents a cached file.
+type cacheEntry struct {
+	name   string
+	size   int64
+	access time.Time
+}
+
+// cacheStore represents evictable file cache.
+type cacheStore interface {
+	entries() ([]*cacheEntry, error)
+	remove([]string) error
+}
+
+// cacheUsage returns number of files and total bytes of stores.
+func cacheUsage(stores ...cacheStore) (int, int64, error) {
+	var files int
+	var bytes int64
+	for _, s := range stores {
+		l, err := s.entries()
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, e := range l {
+			files++
+			bytes += e.size
+		}
+	}
+	return files, bytes, nil
+}
+
+// evict removes files not accessed for opts.MaxAge and least recently
+// accessed files over opts.MaxSize.
+func evict(opts *CacheOptions, now time.Time, stores ...cacheStore) error {
+	if opts == nil || (opts.MaxSize <= 0 && opts.MaxAge <= 0) {
+		return nil
+	}
+	type entry struct {
+		*cacheEntry
+		store int
+	}
+	var all []entry
+	var total int64
+	for i, s := range stores {
+		l, err := s.entries()
+		if err != nil {
+			return err
+		}
+		for _, e := range l {
+			all = append(all, entry{cacheEntry: e, store: i})
+			total += e.size
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].access.Before(all[j].access) })
+	removes := make([][]string, len(stores))
+	for _, e := range all {
+		expired := opts.MaxAge > 0 && now.Sub(e.access) > opts.MaxAge
+		overflow := opts.MaxSize > 0 && total > opts.MaxSize
+		if !expired && !overflow {
+			break
+		}
+		removes[e.store] = append(removes[e.store], e.name)
+		total -= e.size
+	}
+	for i, names := range removes {
+		if len(names) == 0 {
+			continue
+		}
+		if err := stores[i].remove(names); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package images
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type testStore struct {
+	files   []*cacheEntry
+	removed []string
+}
+
+func (s *testStore) entries() ([]*cacheEntry, error) { return s.files, nil }
+
+func (s *testStore) remove(names []string) error {
+	s.removed = append(s.removed, names...)
+	return nil
+}
+
+func TestEvict(t *testing.T) {
+	now := time.Date(2021, 1, 17, 0, 0, 0, 0, time.UTC)
+	newStores := func() (*testStore, *testStore) {
+		return &testStore{files: []*cacheEntry{
+			{name: "a", size: 10, access: now.Add(-4 * time.Hour)},
+			{name: "b", size: 10, access: now.Add(-1 * time.Hour)},
+		}}, &testStore{files: []*cacheEntry{
+			{name: "c", size: 10, access: now.Add(-3 * time.Hour)},
+			{name: "d", size: 10, access: now.Add(-2 * time.Hour)},
+		}}
+	}
+	for _, tt := range []struct {
+		label string
+		opts  *CacheOptions
+		want1 []string
+		want2 []string
+	}{
+		{label: "nil", opts: nil},
+		{label: "unlimited", opts: &CacheOptions{}},
+		{label: "size", opts: &CacheOptions{MaxSize: 25}, want1: []string{"a"}, want2: []string{"c"}},
+		{label: "size/fit", opts: &CacheOptions{MaxSize: 40}},
+		{label: "age", opts: &CacheOptions{MaxAge: 150 * time.Minute}, want1: []string{"a"}, want2: []string{"c"}},
+		{label: "size and age", opts: &CacheOptions{MaxSize: 15, MaxAge: 210 * time.Minute}, want1: []string{"a"}, want2: []string{"c", "d"}},
+	} {
+		t.Run(tt.label, func(t *testing.T) {
+			s1, s2 := newStores()
+			if err := evict(tt.opts, now, s1, s2); err != nil {
+				t.Fatalf("evict() = %v", err)
+			}
+			if !reflect.DeepEqual(s1.removed, tt.want1) || !reflect.DeepEqual(s2.removed, tt.want2) {
+				t.Errorf("got removed %v, %v; want %v, %v", s1.removed, s2.removed, tt.want1, tt.want2)
+			}
+		})
+	}
+}
+
+func TestCacheGC(t *testing.T) {
+	dir := t.TempDir()
+	c, err := newCache(dir)
+	if err != nil {
+		t.Fatalf("newCache() = %v", err)
+	}
+	defer c.Close()
+	png := readFile(t, filepath.Join("testdata", "app.png"))
+	jpg := readFile(t, filepath.Join("testdata", "app.jpg"))
+	for _, kv := range []struct {
+		key string
+		b   []byte
+	}{{"foo", png}, {"bar", png}, {"bar", jpg}, {"baz", png}} {
+		if err := c.Set(kv.key, "req", kv.b); err != nil {
+			t.Fatalf("Set(%q) = %v", kv.key, err)
+		}
+	}
+	if err := c.SetEmpty("qux", "req"); err != nil {
+		t.Fatalf("SetEmpty() = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "123.png"), png, 0666); err != nil {
+		t.Fatalf("failed to write orphan file: %v", err)
+	}
+	// not a cache file; placed by user in misconfigured cache directory
+	if err := os.WriteFile(filepath.Join(dir, "cover.png"), png, 0666); err != nil {
+		t.Fatalf("failed to write user file: %v", err)
+	}
+	if got := cacheFiles(t, dir); len(got) != 4 {
+		t.Errorf("got files %v before gc; want 3 images and orphan file", got)
+	}
+	if err := c.gc(map[string]struct{}{"foo": {}, "bar": {}, "qux": {}}); err != nil {
+		t.Fatalf("gc() = %v", err)
+	}
+	for key, want := range map[string]bool{"foo": true, "bar": true, "baz": false, "qux": true} {
+		if _, ok := c.GetURL(key); ok != want {
+			t.Errorf("GetURL(%q) = _, %v; want %v", key, ok, want)
+		}
+	}
+	l, err := c.entries()
+	if err != nil {
+		t.Fatalf("entries() = %v", err)
+	}
+	names := make([]string, len(l))
+	for i := range l {
+		names[i] = l[i].name
+	}
+	sort.Strings(names)
+	if got := cacheFiles(t, dir); !reflect.DeepEqual(got, names) || len(got) != 2 {
+		t.Errorf("got files %v; want 2 files same as entries %v", got, names)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cover.png")); err != nil {
+		t.Errorf("user file is removed by gc: %v", err)
+	}
+
+	// evicted keys are fetched again
+	if err := c.remove(names[:1]); err != nil {
+		t.Fatalf("remove() = %v", err)
+	}
+	if got := cacheFiles(t, dir); len(got) != 1 {
+		t.Errorf("got files %v after remove; want 1 file", got)
+	}
+	removed := 0
+	for _, key := range []string{"foo", "bar"} {
+		_, urlOK := c.GetURL(key)
+		_, reqOK := c.GetLastRequestID(key)
+		if !urlOK && !reqOK {
+			removed++
+		}
+	}
+	if removed != 1 {
+		t.Errorf("got %d removed keys; want 1", removed)
+	}
+}
+
+// cacheFiles returns image file names in cache dir.
+func cacheFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	l, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	ret := []string{}
+	for _, f := range l {
+		if !f.IsDir() && isCacheFile(f.Name()) {
+			ret = append(ret, f.Name())
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -2,12 +2,14 @@ package images
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"image"
 	"time"
 
-	_ "image/gif" // support gif cover load
-	"image/jpeg"
-	_ "image/png" // support png cover load
+	_ "image/gif"  // support gif cover load
+	_ "image/jpeg" // support jpeg cover load
+	_ "image/png"  // support png cover load
 	"io"
 	"math"
 	"mime"
@@ -22,15 +24,40 @@ import (
 	"golang.org/x/image/draw"
 )
 
-func resizeImage(data io.ReadSeeker, width, height int) ([]byte, error) {
-	info, _, err := image.DecodeConfig(data)
+// MaxPixels is the maximum number of pixels of image to decode.
+// Decoding image allocates memory in proportion to its pixels, not to its file size.
+const MaxPixels = 8192 * 8192
+
+// ErrTooManyPixels is returned if image has more than MaxPixels pixels.
+var ErrTooManyPixels = errors.New("images: too many pixels")
+
+// DecodeConfig decodes image config and returns ErrTooManyPixels if image is too large to decode.
+func DecodeConfig(r io.Reader) (image.Config, string, error) {
+	info, format, err := image.DecodeConfig(r)
 	if err != nil {
-		return nil, err
+		return info, format, err
+	}
+	if int64(info.Width)*int64(info.Height) > MaxPixels {
+		return info, format, fmt.Errorf("%w: %dx%d", ErrTooManyPixels, info.Width, info.Height)
+	}
+	return info, format, nil
+}
+
+// decode decodes image if its size is acceptable.
+func decode(data io.ReadSeeker) (image.Image, image.Config, error) {
+	info, _, err := DecodeConfig(data)
+	if err != nil {
+		return nil, info, err
 	}
 	if _, err := data.Seek(0, io.SeekStart); err != nil {
-		return nil, err
+		return nil, info, err
 	}
 	img, _, err := image.Decode(data)
+	return img, info, err
+}
+
+func resizeImage(data io.ReadSeeker, width, height int) (image.Image, error) {
+	img, info, err := decode(data)
 	if err != nil {
 		return nil, err
 	}
@@ -44,19 +71,26 @@ func resizeImage(data io.ReadSeeker, width, height int) ([]byte, error) {
 	rect := image.Rect(0, 0, width, height)
 	out := image.NewRGBA(rect)
 	draw.CatmullRom.Scale(out, rect, img, img.Bounds(), draw.Over, nil)
-	outwriter := new(bytes.Buffer)
-	opt := jpeg.Options{Quality: 100}
-	jpeg.Encode(outwriter, out, &opt)
-	return outwriter.Bytes(), nil
+	return out, nil
 }
 
-func serveImage(rpath string, w http.ResponseWriter, r *http.Request) {
+// serveImage serves image file. If width and height queries are given,
+// serveImage serves resized image from thumbnails in the format negotiated
+// by Accept header.
+func serveImage(rpath string, thumbnails *thumbnailCache, w http.ResponseWriter, r *http.Request) {
 	i, err := os.Stat(rpath)
 	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
 	l := i.ModTime().UTC()
+	q := r.URL.Query()
+	ws, hs := q.Get("width"), q.Get("height")
+	resize := len(ws) != 0 && len(hs) != 0
+	if resize {
+		// response differs by Accept header even if not modified
+		w.Header().Add("Vary", "Accept")
+	}
 	if !modifiedSince(r, l) {
 		w.WriteHeader(http.StatusNotModified)
 		return
@@ -67,14 +101,12 @@ func serveImage(rpath string, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer f.Close()
-	q := r.URL.Query()
-	ws, hs := q.Get("width"), q.Get("height")
-	if len(ws) == 0 || len(hs) == 0 {
-		if r.URL.Query().Get("d") != "" || q.Get("v") != "" {
-			w.Header().Add("Cache-Control", "max-age=31536000")
-		} else {
-			w.Header().Add("Cache-Control", "max-age=86400")
-		}
+	if q.Get("d") != "" || q.Get("v") != "" {
+		w.Header().Add("Cache-Control", "max-age=31536000")
+	} else {
+		w.Header().Add("Cache-Control", "max-age=86400")
+	}
+	if !resize {
 		w.Header().Add("Content-Length", strconv.FormatInt(i.Size(), 10))
 		w.Header().Add("Content-Type", mime.TypeByExtension(path.Ext(rpath)))
 		w.Header().Add("Last-Modified", l.Format(http.TimeFormat))
@@ -82,31 +114,51 @@ func serveImage(rpath string, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	wi, err := strconv.Atoi(ws)
-	if err != nil {
+	if err != nil || wi <= 0 {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 	hi, err := strconv.Atoi(hs)
-	if err != nil {
+	if err != nil || hi <= 0 {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	b, err := resizeImage(f, wi, hi)
+	format := negotiateThumbnailFormat(r.Header.Get("Accept"))
+	b, err := thumbnails.get(rpath, l, f, thumbnailSize(wi), thumbnailSize(hi), format)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	if q := r.URL.Query(); q.Get("d") != "" || q.Get("v") != "" {
-		w.Header().Add("Cache-Control", "max-age=31536000")
-	} else {
-		w.Header().Add("Cache-Control", "max-age=86400")
-	}
 	w.Header().Add("Content-Length", strconv.Itoa(len(b)))
-	w.Header().Add("Content-Type", mime.TypeByExtension(path.Ext(rpath)))
+	w.Header().Add("Content-Type", format.mime)
 	w.Header().Add("Last-Modified", l.Format(http.TimeFormat))
 	w.Write(b)
 }
 
+// imageSummary is colors and placeholder of image to show before loading image.
+type imageSummary struct {
+	palette  []string // nil if image has no opaque pixels
+	blurhash string
+}
+
+// summarizeImage decodes image and returns its palette and blurhash.
+func summarizeImage(r io.ReadSeeker) (*imageSummary, error) {
+	img, _, err := decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return &imageSummary{palette: palette(img), blurhash: blurhash(img)}, nil
+}
+
+func summarizeFile(rpath string) (*imageSummary, error) {
+	f, err := os.Open(rpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return summarizeImage(f)
+}
+
 // ext guesses image extention by binary.
 func ext(b []byte) (string, error) {
 	_, format, err := image.DecodeConfig(bytes.NewReader(b))
@@ -1,6 +1,11 @@
 package images
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
 	"os"
 	"path/filepath"
 	"testing"
@@ -23,5 +28,30 @@ func TestResizeImage(t *testing.T) {
 			}
 		})
 	}
+}
+
+// largePNGHeader returns png binary which declares width x height pixels without pixel data.
+func largePNGHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], width)
+	binary.BigEndian.PutUint32(ihdr[8:], height)
+	ihdr[12] = 8 // bit depth
+	ihdr[13] = 6 // RGBA
+	b := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d")
+	b = append(b, ihdr...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(ihdr))
+}
 
+func TestResizeImageTooManyPixels(t *testing.T) {
+	b := largePNGHeader(50000, 50000)
+	if _, _, err := image.DecodeConfig(bytes.NewReader(b)); err != nil {
+		t.Fatalf("image.DecodeConfig() = %v; want <nil>", err)
+	}
+	if _, err := resizeImage(bytes.NewReader(b), 8, 8); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("resizeImage() = %v; want %v", err, ErrTooManyPixels)
+	}
+	if _, err := summarizeImage(bytes.NewReader(b)); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("summarizeImage() = %v; want %v", err, ErrTooManyPixels)
+	}
 }
@@ -2,14 +2,18 @@ package images
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Local provides http server song conver art from local filesystem.
@@ -17,28 +21,125 @@ type Local struct {
 	httpPrefix     string
 	musicDirectory string
 	files          []string
+	fallback       bool
+	thumbnails     *thumbnailCache
+	opts           *CacheOptions
 	cache          map[string][]string
 	url2img        map[string]string
 	img2req        map[string]string
+	watcher        *dirWatcher       // nil if not watching
+	watchDirs      map[string]string // watched dir to song dir
+	changed        chan struct{}
+	closed         bool
+	summaries      map[string]*imageSummary // cover url to summary; nil if failed
+	summaryPending map[string]string        // cover url to image path
+	summaryRunning bool
+	summaryWG      sync.WaitGroup
 	mu             sync.RWMutex
 }
 
-// NewLocal creates Local.
-func NewLocal(httpPrefix string, dir string, files []string) (*Local, error) {
+// NewLocal creates Local. files are slash separated glob patterns relative to
+// the song directory like "cover.*" or "Scans/Front.jpg", matched case-insensitively
+// in priority order. If fallback is true, the only image file in the song
+// directory is used when no pattern matches. Resized images are cached in
+// cacheDir; opts limits the cache size, nil means unlimited.
+func NewLocal(httpPrefix string, dir string, files []string, fallback bool, cacheDir string, opts *CacheOptions) (*Local, error) {
 	dir, err := filepath.Abs(dir)
 	if err != nil {
 		return nil, err
 	}
+	for _, f := range files {
+		if _, err := path.Match(f, ""); err != nil {
+			return nil, fmt.Errorf("invalid cover file pattern %q: %w", f, err)
+		}
+	}
+	thumbnails, err := newThumbnailCache(filepath.Join(cacheDir, "thumbnails"))
+	if err != nil {
+		return nil, err
+	}
 	return &Local{
 		httpPrefix:     httpPrefix,
 		musicDirectory: dir,
 		files:          files,
+		fallback:       fallback,
+		thumbnails:     thumbnails,
+		opts:           opts,
 		cache:          map[string][]string{},
 		url2img:        map[string]string{},
 		img2req:        map[string]string{},
+		watchDirs:      map[string]string{},
+		changed:        make(chan struct{}, 1),
+		summaries:      map[string]*imageSummary{},
+		summaryPending: map[string]string{},
 	}, nil
 }
 
+// Watch starts watching song directories to update cover urls if cover
+// files are added, replaced or removed. Directories which os can not watch
+// are polled periodically.
+func (l *Local) Watch() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.watcher != nil || l.closed {
+		return
+	}
+	l.watcher = newDirWatcher(l.dirsChanged, true, pollInterval)
+	for k := range l.cache {
+		l.watchDirs[k] = k
+		l.watcher.add(k)
+	}
+}
+
+func (l *Local) dirsChanged(dirs []string) {
+	songDirs := map[string]struct{}{}
+	l.mu.RLock()
+	for _, dir := range dirs {
+		if k, ok := l.watchDirs[dir]; ok {
+			songDirs[k] = struct{}{}
+		}
+	}
+	l.mu.RUnlock()
+	changed := false
+	for k := range songDirs {
+		l.mu.RLock()
+		old := l.cache[k]
+		l.mu.RUnlock()
+		if !reflect.DeepEqual(old, l.updateCache(k)) {
+			changed = true
+		}
+	}
+	if changed {
+		select {
+		case l.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Changed returns cover urls changes event chan by watching song directories.
+func (l *Local) Changed() <-chan struct{} {
+	return l.changed
+}
+
+// Close stops watching song directories.
+func (l *Local) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	w := l.watcher
+	l.mu.Unlock()
+	var err error
+	if w != nil {
+		err = w.close()
+	}
+	l.summaryWG.Wait()
+	close(l.changed)
+	return err
+}
+
 // ServeHTTP serves cover art with httpPrefix
 func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	l.mu.RLock()
@@ -48,7 +149,7 @@ func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	serveImage(path, w, r)
+	serveImage(path, l.thumbnails, w, r)
 }
 
 // Update rescans all songs images.
@@ -103,20 +204,211 @@ func (l *Local) updateCache(songDirPath string) []string {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	ret := []string{}
-	for _, n := range l.files {
-		rpath := filepath.Join(songDirPath, n)
+	images, dirs := l.findImages(songDirPath)
+	if l.watcher != nil {
+		for _, dir := range dirs {
+			l.watchDirs[dir] = songDirPath
+			l.watcher.add(dir)
+		}
+	}
+	for _, rpath := range images {
 		s, err := os.Stat(rpath)
 		if err == nil {
 			cover := path.Join(l.httpPrefix, strings.TrimPrefix(strings.TrimPrefix(filepath.ToSlash(rpath), filepath.ToSlash(l.musicDirectory)), "/"))
-
-			ret = append(ret, cover+"?"+url.Values{"d": {strconv.FormatInt(s.ModTime().Unix(), 10)}}.Encode())
+			u := cover + "?" + url.Values{"d": {strconv.FormatInt(s.ModTime().Unix(), 10)}}.Encode()
+			ret = append(ret, u)
 			l.url2img[cover] = rpath
+			if _, ok := l.summaries[u]; !ok {
+				l.summaryPending[u] = rpath
+			}
+		}
+	}
+	for _, u := range l.cache[songDirPath] {
+		if !slices.Contains(ret, u) {
+			delete(l.summaries, u)
+			delete(l.summaryPending, u)
 		}
 	}
 	l.cache[songDirPath] = ret
+	if len(l.summaryPending) != 0 && !l.summaryRunning && !l.closed {
+		l.summaryRunning = true
+		l.summaryWG.Add(1)
+		go l.updateSummaries()
+	}
+	return ret
+}
+
+// updateSummaries extracts palettes and blurhashes of pending images in
+// background to avoid decoding images while responding cover urls.
+func (l *Local) updateSummaries() {
+	defer l.summaryWG.Done()
+	updated := false
+	for {
+		l.mu.Lock()
+		if len(l.summaryPending) == 0 || l.closed {
+			l.summaryRunning = false
+			l.mu.Unlock()
+			break
+		}
+		var u, rpath string
+		for u, rpath = range l.summaryPending {
+			break
+		}
+		delete(l.summaryPending, u)
+		l.mu.Unlock()
+		sum, _ := summarizeFile(rpath)
+		l.mu.Lock()
+		l.summaries[u] = sum
+		l.mu.Unlock()
+		if sum != nil {
+			updated = true
+		}
+	}
+	if updated {
+		select {
+		case l.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Palette returns dominant, accent and text colors of cover url.
+func (l *Local) Palette(url string) ([]string, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if sum := l.summaries[url]; sum != nil && sum.palette != nil {
+		return sum.palette, true
+	}
+	return nil, false
+}
+
+// Blurhash returns blurhash placeholder of cover url.
+func (l *Local) Blurhash(url string) (string, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if sum := l.summaries[url]; sum != nil {
+		return sum.blurhash, true
+	}
+	return "", false
+}
+
+// findImages returns image file paths in songDirPath matched to file patterns
+// and directories read to find images.
+func (l *Local) findImages(songDirPath string) ([]string, []string) {
+	ret := []string{}
+	found := map[string]struct{}{}
+	dirs := map[string][]os.DirEntry{}
+	for _, pattern := range l.files {
+		for _, p := range matchFiles(songDirPath, strings.Split(strings.ToLower(pattern), "/"), dirs) {
+			if _, ok := found[p]; !ok {
+				found[p] = struct{}{}
+				ret = append(ret, p)
+			}
+		}
+	}
+	if len(ret) == 0 && l.fallback {
+		var images []string
+		for _, e := range readDir(songDirPath, dirs) {
+			if !e.IsDir() && isImageFile(e.Name()) {
+				images = append(images, filepath.Join(songDirPath, e.Name()))
+			}
+		}
+		if len(images) == 1 {
+			ret = images
+		}
+	}
+	read := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		read = append(read, dir)
+	}
+	return ret, read
+}
+
+// matchFiles returns file paths in dir matched to lower cased pattern elements.
+func matchFiles(dir string, elems []string, dirs map[string][]os.DirEntry) []string {
+	var ret []string
+	last := len(elems) == 1
+	for _, e := range readDir(dir, dirs) {
+		if last && e.IsDir() || !last && !e.IsDir() && e.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		if ok, _ := path.Match(elems[0], strings.ToLower(e.Name())); !ok {
+			continue
+		}
+		p := filepath.Join(dir, e.Name())
+		if last {
+			ret = append(ret, p)
+		} else {
+			ret = append(ret, matchFiles(p, elems[1:], dirs)...)
+		}
+	}
 	return ret
 }
 
+// readDir returns sorted directory entries; results are memoized in dirs.
+func readDir(dir string, dirs map[string][]os.DirEntry) []os.DirEntry {
+	if l, ok := dirs[dir]; ok {
+		return l
+	}
+	l, _ := os.ReadDir(dir)
+	dirs[dir] = l
+	return l
+}
+
+var imageExts = map[string]struct{}{".jpg": {}, ".jpeg": {}, ".png": {}, ".gif": {}, ".webp": {}, ".bmp": {}}
+
+func isImageFile(name string) bool {
+	_, ok := imageExts[strings.ToLower(filepath.Ext(name))]
+	return ok
+}
+
+// CacheName returns cache name for cache usage report.
+func (l *Local) CacheName() string {
+	return path.Base(l.httpPrefix)
+}
+
+// CacheUsage returns number of files and total bytes of cached thumbnails.
+func (l *Local) CacheUsage() (int, int64, error) {
+	return cacheUsage(l.thumbnails)
+}
+
+// GC removes image paths for directories not in library, and evicts
+// thumbnails over the cache limits.
+func (l *Local) GC(ctx context.Context, library []map[string][]string) error {
+	dirs := map[string]struct{}{}
+	for _, song := range library {
+		if k, ok := l.songDirPath(song); ok {
+			dirs[k] = struct{}{}
+		}
+	}
+	l.mu.Lock()
+	for k, urls := range l.cache {
+		if _, ok := dirs[k]; ok {
+			continue
+		}
+		delete(l.cache, k)
+		delete(l.img2req, k)
+		for _, u := range urls {
+			delete(l.summaries, u)
+			delete(l.summaryPending, u)
+			if i := strings.LastIndex(u, "?"); i >= 0 {
+				u = u[:i]
+			}
+			delete(l.url2img, u)
+		}
+	}
+	for dir, k := range l.watchDirs {
+		if _, ok := dirs[k]; !ok {
+			delete(l.watchDirs, dir)
+			if l.watcher != nil {
+				l.watcher.remove(dir)
+			}
+		}
+	}
+	l.mu.Unlock()
+	return evict(l.opts, time.Now(), l.thumbnails)
+}
+
 // GetURLs returns cover path for m
 func (l *Local) GetURLs(m map[string][]string) ([]string, bool) {
 	if l == nil {
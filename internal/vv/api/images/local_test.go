@@ -1,6 +1,7 @@
 package images
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,11 +10,13 @@ import (
 	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLocalCover(t *testing.T) {
-	api, err := NewLocal("/foo", ".", []string{"app.png"})
+	api, err := NewLocal("/foo", ".", []string{"app.png"}, false, t.TempDir(), nil)
 	if err != nil {
 		t.Fatalf("failed to initialize cover.Local: %v", err)
 	}
@@ -64,6 +67,54 @@ func TestLocalCover(t *testing.T) {
 	}
 }
 
+func TestLocalFindImages(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{
+		"a/Folder.JPG", "a/cover.png", "a/AlbumArt_1.jpg", "a/AlbumArt_2.jpg", "a/song.flac",
+		"b/Scans/Front.jpg", "b/Scans/Back.jpg", "b/song.flac",
+		"c/scan.jpg", "c/song.flac",
+		"d/1.jpg", "d/2.png", "d/song.flac",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte{}, 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+	files := []string{"cover.*", "folder.jpg", "AlbumArt*.jpg", "scans/front.*"}
+	for _, tt := range []struct {
+		file     string
+		fallback bool
+		want     []string
+	}{
+		{file: "a/song.flac", want: []string{"/foo/a/cover.png", "/foo/a/Folder.JPG", "/foo/a/AlbumArt_1.jpg", "/foo/a/AlbumArt_2.jpg"}},
+		{file: "b/song.flac", want: []string{"/foo/b/Scans/Front.jpg"}},
+		{file: "c/song.flac", want: []string{}},
+		{file: "c/song.flac", fallback: true, want: []string{"/foo/c/scan.jpg"}},
+		{file: "d/song.flac", fallback: true, want: []string{}},
+	} {
+		t.Run(fmt.Sprintf("%s/fallback=%v", tt.file, tt.fallback), func(t *testing.T) {
+			api, err := NewLocal("/foo", dir, files, tt.fallback, t.TempDir(), nil)
+			if err != nil {
+				t.Fatalf("failed to initialize cover.Local: %v", err)
+			}
+			covers, _ := api.GetURLs(map[string][]string{"file": {tt.file}})
+			got := make([]string, len(covers))
+			for i := range covers {
+				got[i] = strings.Split(covers[i], "?")[0]
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got GetURLs=%v; want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := NewLocal("/foo", dir, []string{"["}, false, t.TempDir(), nil); err == nil {
+		t.Errorf("got NewLocal with invalid pattern = <nil>; want error")
+	}
+}
+
 func readFile(t *testing.T, path string) []byte {
 	t.Helper()
 	b, err := os.ReadFile(path)
@@ -80,3 +131,69 @@ func stat(t *testing.T, path string) os.FileInfo {
 	}
 	return s
 }
+
+func TestLocalWatch(t *testing.T) {
+	for _, tt := range []struct {
+		label string
+		sys   bool
+	}{
+		{label: "sys", sys: true},
+		{label: "poll", sys: false},
+	} {
+		t.Run(tt.label, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(dir, "a", "Scans"), 0755); err != nil {
+				t.Fatalf("failed to create dir: %v", err)
+			}
+			api, err := NewLocal("/foo", dir, []string{"cover.*", "scans/front.*"}, false, t.TempDir(), nil)
+			if err != nil {
+				t.Fatalf("failed to initialize cover.Local: %v", err)
+			}
+			defer api.Close()
+			api.mu.Lock()
+			api.watcher = newDirWatcher(api.dirsChanged, tt.sys, 10*time.Millisecond)
+			api.mu.Unlock()
+			song := map[string][]string{"file": {"a/song.flac"}}
+			if got, _ := api.GetURLs(song); len(got) != 0 {
+				t.Fatalf("got GetURLs=%v; want []", got)
+			}
+			for _, f := range []string{"cover.png", filepath.Join("Scans", "Front.jpg")} {
+				if err := os.WriteFile(filepath.Join(dir, "a", f), []byte{}, 0644); err != nil {
+					t.Fatalf("failed to create file: %v", err)
+				}
+				select {
+				case <-api.Changed():
+				case <-time.After(3 * time.Second):
+					t.Fatalf("no changed event in 3sec after %s is created", f)
+				}
+			}
+			covers, _ := api.GetURLs(song)
+			got := make([]string, len(covers))
+			for i := range covers {
+				got[i] = strings.Split(covers[i], "?")[0]
+			}
+			if want := []string{"/foo/a/cover.png", "/foo/a/Scans/Front.jpg"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got GetURLs=%v; want %v", got, want)
+			}
+
+			// stops watching directories not in library
+			if err := api.GC(context.Background(), []map[string][]string{{"file": {"b/song.flac"}}}); err != nil {
+				t.Fatalf("GC() = %v; want <nil>", err)
+			}
+			api.watcher.mu.Lock()
+			watched, polled := len(api.watcher.watched), len(api.watcher.polled)
+			api.watcher.mu.Unlock()
+			if watched != 0 || polled != 0 {
+				t.Errorf("got %d watched and %d polled directories after GC; want 0", watched, polled)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "a", "cover.jpg"), []byte{}, 0644); err != nil {
+				t.Fatalf("failed to create file: %v", err)
+			}
+			select {
+			case <-api.Changed():
+				t.Errorf("got changed event for removed directory")
+			case <-time.After(watchDelay + 100*time.Millisecond):
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Override provides http album art server for images uploaded by users.
+// Override image is set to song file or directory, and directory image is
+// used for all songs in the directory and its sub directories.
+type Override struct {
+	httpPrefix string
+	cache      *cache
+	thumbnails *thumbnailCache
+	opts       *CacheOptions
+	mu         sync.RWMutex
+	urls       map[string]string // song file or directory to image url
+	changed    chan struct{}
+	closed     bool
+}
+
+// NewOverride initializes Override Cover Art provider with cacheDir.
+// Override images are not evicted by cache limits; opts limits the size of
+// resized images only, nil means unlimited.
+func NewOverride(httpPrefix string, cacheDir string, opts *CacheOptions) (*Override, error) {
+	cache, err := newCache(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	urls, err := cache.urls()
+	if err != nil {
+		cache.Close()
+		return nil, err
+	}
+	thumbnails, err := newThumbnailCache(filepath.Join(cacheDir, "thumbnails"))
+	if err != nil {
+		cache.Close()
+		return nil, err
+	}
+	return &Override{
+		httpPrefix: httpPrefix,
+		cache:      cache,
+		thumbnails: thumbnails,
+		opts:       opts,
+		urls:       urls,
+		changed:    make(chan struct{}, 1),
+	}, nil
+}
+
+// Update does nothing; override images are set by SetImage.
+func (o *Override) Update(context.Context, map[string][]string) error {
+	return nil
+}
+
+// Rescan does nothing; override images are set by SetImage.
+func (o *Override) Rescan(context.Context, map[string][]string, string) error {
+	return nil
+}
+
+// SetImage overrides images of song file or directory by image binary.
+func (o *Override) SetImage(file string, b []byte) error {
+	key, err := overrideKey(file)
+	if err != nil {
+		return err
+	}
+	if err := o.cache.Set(key, "", b); err != nil {
+		return err
+	}
+	url, ok := o.cache.GetURL(key)
+	if !ok {
+		return errors.New("images: override: image is not saved")
+	}
+	o.mu.Lock()
+	o.urls[key] = url
+	o.mu.Unlock()
+	o.notify()
+	return nil
+}
+
+// DeleteImage removes override image of song file or directory.
+// DeleteImage returns fs.ErrNotExist if image is not overridden.
+func (o *Override) DeleteImage(file string) error {
+	key, err := overrideKey(file)
+	if err != nil {
+		return err
+	}
+	if err := o.cache.Delete(key); err != nil {
+		return err
+	}
+	o.mu.Lock()
+	delete(o.urls, key)
+	o.mu.Unlock()
+	o.notify()
+	return nil
+}
+
+// Images returns override image urls by song file or directory.
+func (o *Override) Images() map[string]string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	ret := make(map[string]string, len(o.urls))
+	for k, v := range o.urls {
+		ret[k] = path.Join(o.httpPrefix, v)
+	}
+	return ret
+}
+
+func (o *Override) notify() {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if o.closed {
+		return
+	}
+	select {
+	case o.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Changed returns image urls changes event chan.
+func (o *Override) Changed() <-chan struct{} {
+	return o.changed
+}
+
+// GetURLs returns override image url for song file or its nearest parent directory.
+func (o *Override) GetURLs(song map[string][]string) ([]string, bool) {
+	file, ok := song["file"]
+	if !ok || len(file) != 1 {
+		return nil, true
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if len(o.urls) == 0 {
+		return nil, true
+	}
+	for key := file[0]; key != "." && key != "/"; key = path.Dir(key) {
+		if url, ok := o.urls[key]; ok {
+			return []string{path.Join(o.httpPrefix, url)}, true
+		}
+	}
+	return nil, true
+}
+
+// ServeHTTP serves override image with httpPrefix
+func (o *Override) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// strip httpPrefix
+	p := o.httpPrefix
+	if p[len(p)-1] != '/' {
+		p += "/"
+	}
+	if !strings.HasPrefix(r.URL.Path, p) {
+		http.NotFound(w, r)
+		return
+	}
+	path, ok := o.cache.GetLocalPath(r.URL.Path[len(p):])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	serveImage(path, o.thumbnails, w, r)
+}
+
+// Palette returns dominant, accent and text colors of cover url.
+func (o *Override) Palette(url string) ([]string, bool) {
+	p := o.httpPrefix
+	if p[len(p)-1] != '/' {
+		p += "/"
+	}
+	if !strings.HasPrefix(url, p) {
+		return nil, false
+	}
+	return o.cache.GetPalette(url[len(p):])
+}
+
+// Blurhash returns blurhash placeholder of cover url.
+func (o *Override) Blurhash(url string) (string, bool) {
+	p := o.httpPrefix
+	if p[len(p)-1] != '/' {
+		p += "/"
+	}
+	if !strings.HasPrefix(url, p) {
+		return "", false
+	}
+	return o.cache.GetBlurhash(url[len(p):])
+}
+
+// CacheName returns cache name for cache usage report.
+func (o *Override) CacheName() string {
+	return path.Base(o.httpPrefix)
+}
+
+// CacheUsage returns number of files and total bytes of override images and thumbnails.
+func (o *Override) CacheUsage() (int, int64, error) {
+	return cacheUsage(o.cache, o.thumbnails)
+}
+
+// GC evicts thumbnails over the cache limits. Override images are kept even
+// if songs are not in library.
+func (o *Override) GC(ctx context.Context, library []map[string][]string) error {
+	return evict(o.opts, time.Now(), o.thumbnails)
+}
+
+// Close finalizes cache db and closes changes event chan.
+func (o *Override) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+	close(o.changed)
+	return o.cache.Close()
+}
+
+// overrideKey returns clean relative path of song file or directory.
+func overrideKey(file string) (string, error) {
+	key := path.Clean(strings.TrimSuffix(file, "/"))
+	if file == "" || key == "." || key == "/" || path.IsAbs(key) || key == ".." || strings.HasPrefix(key, "../") {
+		return "", errors.New("images: override: invalid file path")
+	}
+	return key, nil
+}
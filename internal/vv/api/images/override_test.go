@@ -0,0 +1,129 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOverride(t *testing.T) {
+	dir := t.TempDir()
+	api, err := NewOverride("/api/images/override/", dir, nil)
+	if err != nil {
+		t.Fatalf("failed to initialize cover.Override: %v", err)
+	}
+	png := readFile(t, filepath.Join("testdata", "app.png"))
+	if err := api.SetImage("foo", png); err != nil {
+		t.Fatalf("SetImage(foo) = %v; want <nil>", err)
+	}
+	testOverrideChanged(t, api)
+	jpg := readFile(t, filepath.Join("testdata", "app.jpg"))
+	if err := api.SetImage("foo/CD2/song.flac", jpg); err != nil {
+		t.Fatalf("SetImage(foo/CD2/song.flac) = %v; want <nil>", err)
+	}
+	testOverrideChanged(t, api)
+	for _, file := range []string{"", "/foo", "../foo", "."} {
+		if err := api.SetImage(file, png); err == nil {
+			t.Errorf("SetImage(%q) = <nil>; want error", file)
+		}
+	}
+	if err := api.SetImage("bar", []byte("not image")); err == nil {
+		t.Errorf("SetImage(bar, invalid) = <nil>; want error")
+	}
+	dirURL := api.Images()["foo"]
+	fileURL := api.Images()["foo/CD2/song.flac"]
+	if !strings.HasPrefix(dirURL, "/api/images/override/") || !strings.HasPrefix(fileURL, "/api/images/override/") || dirURL == fileURL {
+		t.Fatalf("got Images() = %v; want different urls for foo and foo/CD2/song.flac", api.Images())
+	}
+	for _, tt := range []struct {
+		file string
+		want []string
+	}{
+		{file: "foo/song.flac", want: []string{dirURL}},
+		{file: "foo/CD1/song.flac", want: []string{dirURL}},
+		{file: "foo/CD2/song.flac", want: []string{fileURL}},
+		{file: "foobar/song.flac"},
+		{file: "song.flac"},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			got, updated := api.GetURLs(map[string][]string{"file": {tt.file}})
+			if !reflect.DeepEqual(got, tt.want) || !updated {
+				t.Errorf("got GetURLs() = %v, %v; want %v, true", got, updated, tt.want)
+			}
+		})
+	}
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest("GET", dirURL, nil))
+	if got, _ := io.ReadAll(w.Result().Body); w.Code != 200 || !reflect.DeepEqual(got, png) {
+		t.Errorf("got status %d and invalid binary response; want 200 and uploaded image", w.Code)
+	}
+	if _, ok := api.Palette(dirURL); !ok {
+		t.Errorf("got Palette(%q) = _, false; want true", dirURL)
+	}
+	if _, ok := api.Blurhash(dirURL); !ok {
+		t.Errorf("got Blurhash(%q) = _, false; want true", dirURL)
+	}
+
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest("GET", dirURL+"&width=8&height=8", nil))
+	if w.Code != 200 {
+		t.Errorf("got status %d for thumbnail; want 200", w.Code)
+	}
+	if files, _, err := api.CacheUsage(); err != nil || files != 3 {
+		t.Errorf("got CacheUsage() = %d, _, %v; want 3 files(2 images, 1 thumbnail), _, <nil>", files, err)
+	}
+	if err := api.GC(context.Background(), nil); err != nil {
+		t.Errorf("GC() = %v; want <nil>", err)
+	}
+	if got, _ := api.GetURLs(map[string][]string{"file": {"foo/song.flac"}}); len(got) != 1 {
+		t.Errorf("got GetURLs() = %v after GC; want override image", got)
+	}
+
+	if err := api.DeleteImage("foo"); err != nil {
+		t.Errorf("DeleteImage(foo) = %v; want <nil>", err)
+	}
+	testOverrideChanged(t, api)
+	if err := api.DeleteImage("foo"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("second DeleteImage(foo) = %v; want %v", err, fs.ErrNotExist)
+	}
+	if got, _ := api.GetURLs(map[string][]string{"file": {"foo/song.flac"}}); len(got) != 0 {
+		t.Errorf("got GetURLs() = %v after DeleteImage; want nil", got)
+	}
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest("GET", dirURL, nil))
+	if w.Code != 404 {
+		t.Errorf("got status %d for deleted image; want 404", w.Code)
+	}
+	if err := api.Close(); err != nil {
+		t.Errorf("Close() = %v; want <nil>", err)
+	}
+
+	// reload
+	api, err = NewOverride("/api/images/override/", dir, nil)
+	if err != nil {
+		t.Fatalf("failed to reload cover.Override: %v", err)
+	}
+	defer api.Close()
+	if got := api.Images(); !reflect.DeepEqual(got, map[string]string{"foo/CD2/song.flac": fileURL}) {
+		t.Errorf("got reloaded Images() = %v; want %v", got, map[string]string{"foo/CD2/song.flac": fileURL})
+	}
+	if err := api.Update(context.Background(), map[string][]string{"file": {"foo/song.flac"}}); err != nil {
+		t.Errorf("Update() = %v; want <nil>", err)
+	}
+}
+
+func testOverrideChanged(t *testing.T, api *Override) {
+	t.Helper()
+	select {
+	case <-api.Changed():
+	case <-time.After(time.Second):
+		t.Errorf("Changed() is not notified")
+	}
+}
@@ -0,0 +1,103 @@
+package images
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+const (
+	// paletteSamples is the maximum number of sampled pixels in each axis.
+	paletteSamples = 64
+	// paletteAccentDistance is the minimum rgb distance of accent color from dominant color.
+	paletteAccentDistance = 80
+)
+
+// colorBucket is sum of pixel colors quantized to the same 4bit rgb.
+type colorBucket struct {
+	r, g, b, n int
+}
+
+func (c *colorBucket) rgb() (int, int, int) {
+	return c.r / c.n, c.g / c.n, c.b / c.n
+}
+
+// palette returns dominant, accent and text colors of img as "#rrggbb".
+// Text color is black or white which has higher contrast to dominant color.
+// palette returns nil if img has no opaque pixels.
+func palette(img image.Image) []string {
+	bounds := img.Bounds()
+	stepX, stepY := max(1, bounds.Dx()/paletteSamples), max(1, bounds.Dy()/paletteSamples)
+	buckets := make([]colorBucket, 4096)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stepY {
+		for x := bounds.Min.X; x < bounds.Max.X; x += stepX {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			// un-premultiply alpha
+			r, g, b = r*0xffff/a>>8, g*0xffff/a>>8, b*0xffff/a>>8
+			c := &buckets[r>>4<<8|g>>4<<4|b>>4]
+			c.r += int(r)
+			c.g += int(g)
+			c.b += int(b)
+			c.n++
+		}
+	}
+	var dominant *colorBucket
+	for i := range buckets {
+		if buckets[i].n != 0 && (dominant == nil || buckets[i].n > dominant.n) {
+			dominant = &buckets[i]
+		}
+	}
+	if dominant == nil {
+		return nil
+	}
+	dr, dg, db := dominant.rgb()
+	accent, score := dominant, 0.0
+	for i := range buckets {
+		c := &buckets[i]
+		if c.n == 0 {
+			continue
+		}
+		r, g, b := c.rgb()
+		if math.Sqrt(float64((r-dr)*(r-dr)+(g-dg)*(g-dg)+(b-db)*(b-db))) < paletteAccentDistance {
+			continue
+		}
+		// prefer vivid colors to frequent gray colors
+		if s := float64(c.n) * (0.1 + saturation(r, g, b)); s > score {
+			accent, score = c, s
+		}
+	}
+	ar, ag, ab := accent.rgb()
+	text := "#000000"
+	if l := luminance(dr, dg, db); 1.05/(l+0.05) > (l+0.05)/0.05 {
+		text = "#ffffff"
+	}
+	return []string{hexColor(dr, dg, db), hexColor(ar, ag, ab), text}
+}
+
+// saturation returns hsv saturation of 8bit rgb color.
+func saturation(r, g, b int) float64 {
+	mx, mn := max(r, g, b), min(r, g, b)
+	if mx == 0 {
+		return 0
+	}
+	return float64(mx-mn) / float64(mx)
+}
+
+// luminance returns relative luminance of 8bit rgb color defined in WCAG 2.
+func luminance(r, g, b int) float64 {
+	f := func(c int) float64 {
+		v := float64(c) / 255
+		if v <= 0.03928 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return 0.2126*f(r) + 0.7152*f(g) + 0.0722*f(b)
+}
+
+func hexColor(r, g, b int) string {
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
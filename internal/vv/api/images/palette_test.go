@@ -0,0 +1,104 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPalette(t *testing.T) {
+	fill := func(colors ...color.Color) image.Image {
+		// fills rows by colors; the first color fills the upper half of image
+		img := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+		for y := 0; y < 100; y++ {
+			c := colors[0]
+			if rest := colors[1:]; y >= 50 && len(rest) != 0 {
+				c = rest[(y-50)*len(rest)/50]
+			}
+			for x := 0; x < 100; x++ {
+				img.Set(x, y, c)
+			}
+		}
+		return img
+	}
+	for label, tt := range map[string]struct {
+		img  image.Image
+		want []string
+	}{
+		"dark": {
+			img:  fill(color.NRGBA{0x10, 0x20, 0x30, 0xff}, color.NRGBA{0x18, 0x18, 0x18, 0xff}, color.NRGBA{0xff, 0xe0, 0x00, 0xff}),
+			want: []string{"#102030", "#ffe000", "#ffffff"},
+		},
+		"light/no accent": {
+			img:  fill(color.NRGBA{0xf0, 0xf0, 0xf0, 0xff}, color.NRGBA{0xe0, 0xe0, 0xe0, 0xff}, color.NRGBA{0xd0, 0xd0, 0xd0, 0xff}),
+			want: []string{"#f0f0f0", "#f0f0f0", "#000000"},
+		},
+		"transparent": {
+			img: fill(color.NRGBA{0xff, 0xff, 0xff, 0x00}),
+		},
+	} {
+		t.Run(label, func(t *testing.T) {
+			if got := palette(tt.img); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheSummary(t *testing.T) {
+	c, err := newCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("newCache() = %v", err)
+	}
+	defer c.Close()
+	want, err := summarizeFile(filepath.Join("testdata", "app.png"))
+	if err != nil {
+		t.Fatalf("summarizeFile() = %v", err)
+	}
+	if err := c.Set("foo", "req", readFile(t, filepath.Join("testdata", "app.png"))); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+	url, _ := c.GetURL("foo")
+	if got, ok := c.GetPalette(url); !ok || !reflect.DeepEqual(got, want.palette) {
+		t.Errorf("GetPalette(%q) = %v, %v; want %v, true", url, got, ok, want.palette)
+	}
+	if got, ok := c.GetBlurhash(url); !ok || got != want.blurhash {
+		t.Errorf("GetBlurhash(%q) = %q, %v; want %q, true", url, got, ok, want.blurhash)
+	}
+	if got, ok := c.GetPalette("notfound.png?v=0"); ok {
+		t.Errorf("GetPalette(notfound) = %v, %v; want nil, false", got, ok)
+	}
+	if got, ok := c.GetBlurhash("notfound.png?v=0"); ok {
+		t.Errorf("GetBlurhash(notfound) = %q, %v; want \"\", false", got, ok)
+	}
+}
+
+func TestLocalSummary(t *testing.T) {
+	api, err := NewLocal("/foo", ".", []string{"app.png"}, false, t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("failed to initialize cover.Local: %v", err)
+	}
+	defer api.Close()
+	want, err := summarizeFile(filepath.Join("testdata", "app.png"))
+	if err != nil {
+		t.Fatalf("summarizeFile() = %v", err)
+	}
+	covers, _ := api.GetURLs(map[string][]string{"file": {"testdata/test.flac"}})
+	if len(covers) != 1 {
+		t.Fatalf("got GetURLs=%v; want 1 cover", covers)
+	}
+	select {
+	case <-api.Changed():
+	case <-time.After(3 * time.Second):
+		t.Fatalf("no changed event in 3sec")
+	}
+	if got, ok := api.Palette(covers[0]); !ok || !reflect.DeepEqual(got, want.palette) {
+		t.Errorf("Palette(%q) = %v, %v; want %v, true", covers[0], got, ok, want.palette)
+	}
+	if got, ok := api.Blurhash(covers[0]); !ok || got != want.blurhash {
+		t.Errorf("Blurhash(%q) = %q, %v; want %q, true", covers[0], got, ok, want.blurhash)
+	}
+}
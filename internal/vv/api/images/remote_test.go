@@ -34,7 +34,7 @@ func TestRemote(t *testing.T) {
 	}
 	defer os.RemoveAll(testDir)
 
-	api, err := NewRemote("/api/images", c, testDir)
+	api, err := NewRemote("/api/images", c, testDir, nil)
 	if err != nil {
 		t.Fatalf("failed to initialize cover.Remote: %v", err)
 	}
@@ -135,7 +135,7 @@ func TestRemoteUpdate(t *testing.T) {
 					}
 				}
 			}()
-			api, err := NewRemote("/api/images", c, testDir)
+			api, err := NewRemote("/api/images", c, testDir, nil)
 			if err != nil {
 				t.Fatalf("failed to initialize cover.Remote: %v", err)
 			}
@@ -295,7 +295,7 @@ func TestRemoteRescan(t *testing.T) {
 					}
 				}
 			}()
-			api, err := NewRemote("/api/images", c, testDir)
+			api, err := NewRemote("/api/images", c, testDir, nil)
 			if err != nil {
 				t.Fatalf("failed to initialize cover.Remote: %v", err)
 			}
@@ -0,0 +1,224 @@
+package images
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// thumbnailSizes are allowed thumbnail width and height in ascending order.
+// Requested sizes are rounded up to them to bound the number of cached images.
+var thumbnailSizes = []int{64, 96, 128, 192, 256, 384, 512, 768, 1024}
+
+// thumbnailFormat represents thumbnail image encoding.
+type thumbnailFormat struct {
+	mime   string
+	ext    string
+	encode func(io.Writer, image.Image) error
+}
+
+// thumbnailFormats are thumbnail formats in server preference order.
+// webp thumbnails are encoded as lossless webp.
+var thumbnailFormats = []*thumbnailFormat{
+	{mime: "image/jpeg", ext: ".jpg", encode: func(w io.Writer, img image.Image) error {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+	}},
+	{mime: "image/webp", ext: ".webp", encode: func(w io.Writer, img image.Image) error {
+		return nativewebp.Encode(w, img, nil)
+	}},
+	{mime: "image/png", ext: ".png", encode: png.Encode},
+}
+
+// thumbnailSize rounds up n to the allowed thumbnail size.
+func thumbnailSize(n int) int {
+	i := sort.SearchInts(thumbnailSizes, n)
+	if i == len(thumbnailSizes) {
+		return thumbnailSizes[len(thumbnailSizes)-1]
+	}
+	return thumbnailSizes[i]
+}
+
+// negotiateThumbnailFormat selects thumbnail format by Accept header value.
+// Formats listed explicitly take precedence over wildcards with the same
+// quality like "image/webp,*/*".
+// It returns the first format if no format is acceptable.
+func negotiateThumbnailFormat(accept string) *thumbnailFormat {
+	if len(strings.TrimSpace(accept)) == 0 {
+		return thumbnailFormats[0]
+	}
+	var ret *thumbnailFormat
+	var retQ float64
+	retSpecificity := -1
+	for _, f := range thumbnailFormats {
+		q, specificity := 0.0, -1
+		for _, r := range strings.Split(accept, ",") {
+			params := strings.Split(r, ";")
+			mime := strings.ToLower(strings.TrimSpace(params[0]))
+			s := -1
+			switch {
+			case mime == f.mime:
+				s = 2
+			case mime == "image/*":
+				s = 1
+			case mime == "*/*":
+				s = 0
+			}
+			if s <= specificity {
+				continue
+			}
+			specificity, q = s, 1
+			for _, p := range params[1:] {
+				if k, v, ok := strings.Cut(strings.TrimSpace(p), "="); ok && k == "q" {
+					if n, err := strconv.ParseFloat(v, 64); err == nil {
+						q = n
+					}
+				}
+			}
+		}
+		if q > retQ || (q > 0 && q == retQ && specificity > retSpecificity) {
+			ret, retQ, retSpecificity = f, q, specificity
+		}
+	}
+	if ret == nil {
+		return thumbnailFormats[0]
+	}
+	return ret
+}
+
+// thumbnailCache is a persistent resized image cache.
+type thumbnailCache struct {
+	dir string
+}
+
+func newThumbnailCache(dir string) (*thumbnailCache, error) {
+	if err := os.MkdirAll(dir, 0766); err != nil {
+		return nil, err
+	}
+	return &thumbnailCache{dir: dir}, nil
+}
+
+// path returns cache file path for resized image of src.
+func (c *thumbnailCache) path(src string, modTime time.Time, width, height int, f *thumbnailFormat) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%dx%d\x00%s", src, modTime.UnixNano(), width, height, f.mime)))
+	k := hex.EncodeToString(h[:])
+	return filepath.Join(c.dir, k[:2], k+f.ext)
+}
+
+// get returns resized image from cache or resizes data and stores it.
+func (c *thumbnailCache) get(src string, modTime time.Time, data io.ReadSeeker, width, height int, f *thumbnailFormat) ([]byte, error) {
+	if c == nil {
+		return encodeThumbnail(data, width, height, f)
+	}
+	p := c.path(src, modTime, width, height, f)
+	b, err := os.ReadFile(p)
+	if err == nil {
+		// records access time for eviction
+		if s, err := os.Stat(p); err == nil && time.Since(s.ModTime()) > accessInterval {
+			now := time.Now()
+			os.Chtimes(p, now, now)
+		}
+		return b, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	b, err = encodeThumbnail(data, width, height, f)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(p, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// entries returns cached thumbnail files. Modification time of the file is
+// used as access time.
+func (c *thumbnailCache) entries() ([]*cacheEntry, error) {
+	var ret []*cacheEntry
+	err := filepath.WalkDir(c.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		s, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		name, err := filepath.Rel(c.dir, p)
+		if err != nil {
+			return err
+		}
+		ret = append(ret, &cacheEntry{name: name, size: s.Size(), access: s.ModTime()})
+		return nil
+	})
+	return ret, err
+}
+
+// remove removes cached thumbnail files.
+func (c *thumbnailCache) remove(names []string) error {
+	for _, n := range names {
+		if err := os.Remove(filepath.Join(c.dir, n)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeThumbnail(data io.ReadSeeker, width, height int, f *thumbnailFormat) ([]byte, error) {
+	img, err := resizeImage(data, width, height)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := f.encode(&b, img); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeFileAtomic writes data to temporary file and renames to name to avoid
+// serving partially written file.
+func writeFileAtomic(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0766); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
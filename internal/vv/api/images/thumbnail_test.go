@@ -0,0 +1,122 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestThumbnailSize(t *testing.T) {
+	for in, want := range map[int]int{
+		1:     64,
+		64:    64,
+		65:    96,
+		140:   192,
+		300:   384,
+		1024:  1024,
+		10000: 1024,
+	} {
+		if got := thumbnailSize(in); got != want {
+			t.Errorf("thumbnailSize(%d) = %d; want %d", in, got, want)
+		}
+	}
+}
+
+func TestNegotiateThumbnailFormat(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                                 "image/jpeg",
+		"*/*":                              "image/jpeg",
+		"image/avif,image/webp,*/*":        "image/webp",
+		"image/png":                        "image/png",
+		"image/png,image/*;q=0.8":          "image/png",
+		"image/jpeg;q=0.5, image/png":      "image/png",
+		"image/*;q=0.8, image/jpeg;q=0":    "image/webp",
+		"image/png,image/webp":             "image/webp",
+		"image/webp":                       "image/webp",
+		"text/html,application/xhtml+xml":  "image/jpeg",
+		"IMAGE/PNG;q=0.9, image/jpeg;q=.5": "image/png",
+	} {
+		if got := negotiateThumbnailFormat(accept).mime; got != want {
+			t.Errorf("negotiateThumbnailFormat(%q) = %s; want %s", accept, got, want)
+		}
+	}
+}
+
+func TestServeImageThumbnail(t *testing.T) {
+	dir := t.TempDir()
+	thumbnails, err := newThumbnailCache(dir)
+	if err != nil {
+		t.Fatalf("newThumbnailCache() = %v", err)
+	}
+	src := filepath.Join("testdata", "app.png")
+	for _, tt := range []struct {
+		query      string
+		accept     string
+		status     int
+		wantType   string
+		wantFormat string
+		wantSize   int
+		wantCached int
+	}{
+		{query: "?width=100&height=100", status: http.StatusOK, wantType: "image/jpeg", wantFormat: "jpeg", wantSize: 128, wantCached: 1},
+		{query: "?width=128&height=128", status: http.StatusOK, wantType: "image/jpeg", wantFormat: "jpeg", wantSize: 128, wantCached: 1},
+		{query: "?width=100&height=100", accept: "image/png", status: http.StatusOK, wantType: "image/png", wantFormat: "png", wantSize: 128, wantCached: 2},
+		{query: "?width=100&height=100", accept: "image/webp,*/*", status: http.StatusOK, wantType: "image/webp", wantFormat: "webp", wantSize: 128, wantCached: 3},
+		{query: "?width=5000&height=5000", status: http.StatusOK, wantType: "image/jpeg", wantFormat: "jpeg", wantSize: 1024, wantCached: 4},
+		{query: "?width=foo&height=100", status: http.StatusBadRequest, wantCached: 4},
+		{query: "?width=0&height=100", status: http.StatusBadRequest, wantCached: 4},
+	} {
+		t.Run(tt.query+" "+tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			serveImage(src, thumbnails, w, r)
+			resp := w.Result()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d; want %d", resp.StatusCode, tt.status)
+			}
+			if got := countFiles(t, dir); got != tt.wantCached {
+				t.Errorf("got %d cached files; want %d", got, tt.wantCached)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if got := resp.Header.Get("Content-Type"); got != tt.wantType {
+				t.Errorf("got Content-Type %q; want %q", got, tt.wantType)
+			}
+			if got := resp.Header.Get("Vary"); got != "Accept" {
+				t.Errorf("got Vary %q; want %q", got, "Accept")
+			}
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(w.Body.Bytes()))
+			if err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if format != tt.wantFormat || cfg.Width > tt.wantSize || cfg.Height > tt.wantSize || (cfg.Width != tt.wantSize && cfg.Height != tt.wantSize) {
+				t.Errorf("got %s %dx%d; want %s fits in %dx%d", format, cfg.Width, cfg.Height, tt.wantFormat, tt.wantSize, tt.wantSize)
+			}
+		})
+	}
+}
+
+func countFiles(t *testing.T, dir string) int {
+	t.Helper()
+	n := 0
+	if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			n++
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to walk %s: %v", dir, err)
+	}
+	return n
+}
@@ -0,0 +1,161 @@
+package images
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// watchDelay is the delay to notify changes for coalescing file events.
+	watchDelay = 500 * time.Millisecond
+	// pollInterval is the interval to check directories which os can not watch.
+	pollInterval = time.Minute
+)
+
+// sysWatcher represents os file event notification api.
+type sysWatcher interface {
+	add(dir string) error
+	remove(dir string) error
+	close() error
+}
+
+// dirWatcher notifies changed directories. dirWatcher uses os file event
+// notification if available, or polls directories periodically.
+type dirWatcher struct {
+	notify  func(dirs []string)
+	sys     sysWatcher // nil if os file event notification is not available
+	mu      sync.Mutex
+	watched map[string]struct{}
+	polled  map[string]struct{}
+	pending map[string]struct{}
+	timer   *time.Timer
+	closed  bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// newDirWatcher creates dirWatcher. If sys is false, all directories are polled.
+func newDirWatcher(notify func(dirs []string), sys bool, interval time.Duration) *dirWatcher {
+	w := &dirWatcher{
+		notify:  notify,
+		watched: map[string]struct{}{},
+		polled:  map[string]struct{}{},
+		pending: map[string]struct{}{},
+		done:    make(chan struct{}),
+	}
+	if sys {
+		if s, err := newSysWatcher(w.changed); err == nil {
+			w.sys = s
+		}
+	}
+	w.wg.Add(1)
+	go w.poll(interval)
+	return w
+}
+
+// add starts watching dir.
+func (w *dirWatcher) add(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.watched[dir]; ok || w.closed {
+		return
+	}
+	w.watched[dir] = struct{}{}
+	if w.sys == nil || w.sys.add(dir) != nil {
+		w.polled[dir] = struct{}{}
+	}
+}
+
+// remove stops watching dir.
+func (w *dirWatcher) remove(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.watched[dir]; !ok || w.closed {
+		return
+	}
+	delete(w.watched, dir)
+	if _, ok := w.polled[dir]; ok {
+		delete(w.polled, dir)
+		return
+	}
+	w.sys.remove(dir)
+}
+
+// changed records changed dir and notifies it after watchDelay.
+// If removed is true, dir is no longer watched.
+func (w *dirWatcher) changed(dir string, removed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	if removed {
+		delete(w.watched, dir)
+		delete(w.polled, dir)
+	}
+	w.pending[dir] = struct{}{}
+	if w.timer == nil {
+		w.wg.Add(1)
+		w.timer = time.AfterFunc(watchDelay, w.flush)
+	}
+}
+
+func (w *dirWatcher) flush() {
+	defer w.wg.Done()
+	w.mu.Lock()
+	dirs := make([]string, 0, len(w.pending))
+	for dir := range w.pending {
+		dirs = append(dirs, dir)
+	}
+	w.pending = map[string]struct{}{}
+	w.timer = nil
+	w.mu.Unlock()
+	sort.Strings(dirs)
+	w.notify(dirs)
+}
+
+func (w *dirWatcher) poll(interval time.Duration) {
+	defer w.wg.Done()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-t.C:
+			w.mu.Lock()
+			dirs := make([]string, 0, len(w.polled))
+			for dir := range w.polled {
+				dirs = append(dirs, dir)
+			}
+			w.mu.Unlock()
+			if len(dirs) != 0 {
+				sort.Strings(dirs)
+				w.notify(dirs)
+			}
+		}
+	}
+}
+
+// close stops watching and waits running notifications.
+func (w *dirWatcher) close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	if w.timer != nil && w.timer.Stop() {
+		w.timer = nil
+		w.wg.Done()
+	}
+	close(w.done)
+	w.mu.Unlock()
+	var err error
+	if w.sys != nil {
+		err = w.sys.close()
+	}
+	w.wg.Wait()
+	return err
+}
@@ -0,0 +1,102 @@
+package images
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+	"syscall"
+)
+
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotify watches directories by linux inotify api.
+type inotify struct {
+	fd      int
+	f       *os.File
+	changed func(dir string, removed bool)
+	mu      sync.Mutex
+	dirs    map[int32]string
+	wds     map[string]int32
+	done    chan struct{}
+}
+
+func newSysWatcher(changed func(dir string, removed bool)) (sysWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	w := &inotify{
+		fd: fd,
+		// non-blocking fd is registered to runtime poller; Close interrupts Read.
+		f:       os.NewFile(uintptr(fd), "inotify"),
+		changed: changed,
+		dirs:    map[int32]string{},
+		wds:     map[string]int32{},
+		done:    make(chan struct{}),
+	}
+	go w.read()
+	return w, nil
+}
+
+func (w *inotify) add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if err != nil {
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+	w.mu.Lock()
+	w.dirs[int32(wd)] = dir
+	w.wds[dir] = int32(wd)
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *inotify) remove(dir string) error {
+	w.mu.Lock()
+	wd, ok := w.wds[dir]
+	if ok {
+		// ignores IN_IGNORED event for removed watch
+		delete(w.wds, dir)
+		delete(w.dirs, wd)
+	}
+	w.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	if _, err := syscall.InotifyRmWatch(w.fd, uint32(wd)); err != nil {
+		return os.NewSyscallError("inotify_rm_watch", err)
+	}
+	return nil
+}
+
+func (w *inotify) read() {
+	defer close(w.done)
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			return
+		}
+		for i := 0; i+syscall.SizeofInotifyEvent <= n; {
+			wd := int32(binary.NativeEndian.Uint32(buf[i:]))
+			mask := binary.NativeEndian.Uint32(buf[i+4:])
+			i += syscall.SizeofInotifyEvent + int(binary.NativeEndian.Uint32(buf[i+12:]))
+			removed := mask&syscall.IN_IGNORED != 0
+			w.mu.Lock()
+			dir, ok := w.dirs[wd]
+			if removed && ok {
+				delete(w.dirs, wd)
+				delete(w.wds, dir)
+			}
+			w.mu.Unlock()
+			if ok {
+				w.changed(dir, removed)
+			}
+		}
+	}
+}
+
+func (w *inotify) close() error {
+	err := w.f.Close()
+	<-w.done
+	return err
+}
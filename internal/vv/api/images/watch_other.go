@@ -0,0 +1,9 @@
+//go:build !linux
+
+package images
+
+import "errors"
+
+func newSysWatcher(changed func(dir string, removed bool)) (sysWatcher, error) {
+	return nil, errors.New("file event notification is not supported")
+}
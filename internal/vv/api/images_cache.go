@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// errAlreadyCollecting returns if already GC is called
+	errAlreadyCollecting = errors.New("api: gc already started")
+	// errEmptyLibrary returns if GC is called before library songs are loaded
+	errEmptyLibrary = errors.New("api: library songs are not loaded")
+)
+
+// ImageCache represents cover image provider which stores images in local cache.
+type ImageCache interface {
+	// CacheName returns unique provider name for usage report.
+	CacheName() string
+	// CacheUsage returns number of cached files and total bytes.
+	CacheUsage() (int, int64, error)
+	// GC removes cached images for songs not in library and evicts images by cache limits.
+	GC(context.Context, []map[string][]string) error
+}
+
+type httpImagesCacheUsage struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+type httpImagesCache struct {
+	Collecting bool                             `json:"collecting"`
+	Files      int                              `json:"files"`
+	Bytes      int64                            `json:"bytes"`
+	Providers  map[string]*httpImagesCacheUsage `json:"providers"`
+}
+
+// ImagesCacheHandler provides cover image cache usage and garbage collection api.
+type ImagesCacheHandler struct {
+	apis    []ImageCache
+	cache   *cache
+	sem     chan struct{}
+	mu      sync.RWMutex
+	library []map[string][]string
+	logger  Logger
+
+	shutdownMu sync.Mutex
+	shutdownCh chan struct{}
+	shutdownB  bool
+}
+
+// NewImagesCacheHandler initilize cache usage with image cache providers.
+func NewImagesCacheHandler(apis []ImageCache, logger Logger) (*ImagesCacheHandler, error) {
+	c, err := newCache(&httpImagesCache{Providers: map[string]*httpImagesCacheUsage{}})
+	if err != nil {
+		return nil, err
+	}
+	ret := &ImagesCacheHandler{
+		apis:       apis,
+		cache:      c,
+		sem:        make(chan struct{}, 1),
+		logger:     logger,
+		shutdownCh: make(chan struct{}),
+	}
+	ret.sem <- struct{}{}
+	if err := ret.updateUsage(false); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (a *ImagesCacheHandler) updateUsage(collecting bool) error {
+	ret := &httpImagesCache{Collecting: collecting, Providers: make(map[string]*httpImagesCacheUsage, len(a.apis))}
+	for _, api := range a.apis {
+		files, bytes, err := api.CacheUsage()
+		if err != nil {
+			return err
+		}
+		ret.Files += files
+		ret.Bytes += bytes
+		ret.Providers[api.CacheName()] = &httpImagesCacheUsage{Files: files, Bytes: bytes}
+	}
+	_, err := a.cache.SetIfModified(ret)
+	return err
+}
+
+// ServeHTTP responses cache usage as json format.
+// POST {"collecting":true} starts garbage collection.
+func (a *ImagesCacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		a.cache.ServeHTTP(w, r)
+		return
+	}
+	var req httpImagesCache
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !req.Collecting {
+		writeHTTPError(w, http.StatusBadRequest, errors.New("requires collecting=true"))
+		return
+	}
+	if err := a.GC(); err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	now := time.Now().UTC()
+	r.Method = http.MethodGet
+	a.cache.ServeHTTP(w, setUpdateTime(r, now))
+}
+
+// UpdateLibrarySongs set songs to keep images in cache.
+func (a *ImagesCacheHandler) UpdateLibrarySongs(songs []map[string][]string) {
+	a.mu.Lock()
+	a.library = songs
+	a.mu.Unlock()
+}
+
+// GC starts background garbage collection for library songs.
+// GC returns error if library songs are not loaded to avoid dropping all caches.
+func (a *ImagesCacheHandler) GC() error {
+	if len(a.apis) == 0 {
+		return nil
+	}
+	a.mu.RLock()
+	library := a.library
+	a.mu.RUnlock()
+	if len(library) == 0 {
+		return errEmptyLibrary
+	}
+	select {
+	case _, ok := <-a.sem:
+		if !ok {
+			return ErrAlreadyShutdown
+		}
+	default:
+		return errAlreadyCollecting
+	}
+	if err := a.updateUsage(true); err != nil {
+		a.sem <- struct{}{}
+		return err
+	}
+	go func() {
+		defer func() { a.sem <- struct{}{} }()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-ctx.Done():
+			case <-a.shutdownCh:
+				cancel()
+			}
+		}()
+		for _, api := range a.apis {
+			if err := api.GC(ctx, library); err != nil {
+				a.logger.Printf("vv/api: images cache: gc: %s: %v", api.CacheName(), err)
+			}
+		}
+		if err := a.updateUsage(false); err != nil {
+			a.logger.Printf("vv/api: images cache: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Changed returns response body changes event chan.
+func (a *ImagesCacheHandler) Changed() <-chan struct{} {
+	return a.cache.Changed()
+}
+
+// Close closes update event chan.
+func (a *ImagesCacheHandler) Close() {
+	a.cache.Close()
+}
+
+// Shutdown stops background garbage collection.
+func (a *ImagesCacheHandler) Shutdown(ctx context.Context) error {
+	a.shutdownMu.Lock()
+	if !a.shutdownB {
+		close(a.shutdownCh)
+		a.shutdownB = true
+	}
+	a.shutdownMu.Unlock()
+	select {
+	case _, ok := <-a.sem:
+		if ok {
+			close(a.sem)
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
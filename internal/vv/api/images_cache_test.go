@@ -0,0 +1,206 @@
+package api_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/meiraka/vv/internal/log"
+	"github.com/meiraka/vv/internal/vv/api"
+)
+
+func TestImagesCacheHandler(t *testing.T) {
+	songs := []map[string][]string{
+		{"file": {"/foo/bar.mp3"}},
+		{"file": {"/baz/qux.mp3"}},
+	}
+	for label, tt := range map[string][]struct {
+		label string
+		// wait background gc by Shutdown before requests, if true
+		shutdown   bool
+		method     string
+		body       io.Reader
+		songs      []map[string][]string
+		want       string
+		wantStatus int
+		img        *imageCache
+	}{
+		"ok/GET": {{
+			method:     http.MethodGet,
+			want:       `{"collecting":false,"files":3,"bytes":300,"providers":{"foo":{"files":3,"bytes":300}}}`,
+			wantStatus: http.StatusOK,
+			img: &imageCache{
+				name:  "foo",
+				usage: func(t *testing.T) (int, int64, error) { return 3, 300, nil },
+			},
+		}},
+		"ok/collecting/running": {{
+			label:      `POST/{"collecting":true}`,
+			method:     http.MethodPost,
+			body:       strings.NewReader(`{"collecting":true}`),
+			songs:      songs,
+			want:       `{"collecting":true,"files":3,"bytes":300,"providers":{"foo":{"files":3,"bytes":300}}}`,
+			wantStatus: http.StatusAccepted,
+			img: &imageCache{
+				name:  "foo",
+				files: 3,
+				gc: func(ctx context.Context, t *testing.T, library []map[string][]string) (int, error) {
+					<-ctx.Done()
+					return 3, nil
+				},
+			},
+		}, {
+			label:      `POST/{"collecting":true}/already running`,
+			method:     http.MethodPost,
+			body:       strings.NewReader(`{"collecting":true}`),
+			want:       `{"error":"api: gc already started"}`,
+			wantStatus: http.StatusInternalServerError,
+		}, {
+			label:      "GET",
+			method:     http.MethodGet,
+			want:       `{"collecting":true,"files":3,"bytes":300,"providers":{"foo":{"files":3,"bytes":300}}}`,
+			wantStatus: http.StatusOK,
+		}},
+		"ok/collecting/finished": {{
+			label:      `POST/{"collecting":true}`,
+			method:     http.MethodPost,
+			body:       strings.NewReader(`{"collecting":true}`),
+			songs:      songs,
+			want:       `{"collecting":true,"files":3,"bytes":300,"providers":{"foo":{"files":3,"bytes":300}}}`,
+			wantStatus: http.StatusAccepted,
+			img: &imageCache{
+				name:  "foo",
+				files: 3,
+				gc: func(ctx context.Context, t *testing.T, library []map[string][]string) (int, error) {
+					if len(library) != len(songs) {
+						t.Errorf("got gc library %v; want %v", library, songs)
+					}
+					return 1, nil
+				},
+			},
+		}, {
+			label:      "GET",
+			shutdown:   true,
+			method:     http.MethodGet,
+			want:       `{"collecting":false,"files":1,"bytes":100,"providers":{"foo":{"files":1,"bytes":100}}}`,
+			wantStatus: http.StatusOK,
+		}},
+		"error/POST/empty library": {{
+			method:     http.MethodPost,
+			body:       strings.NewReader(`{"collecting":true}`),
+			want:       `{"error":"api: library songs are not loaded"}`,
+			wantStatus: http.StatusInternalServerError,
+			img:        &imageCache{name: "foo"},
+		}},
+		"error/POST/invalid json": {{
+			method:     http.MethodPost,
+			body:       strings.NewReader(`invalid json`),
+			songs:      songs,
+			want:       `{"error":"invalid character 'i' looking for beginning of value"}`,
+			wantStatus: http.StatusBadRequest,
+			img:        &imageCache{name: "foo"},
+		}},
+		`error/POST/{"collecting":false}`: {{
+			method:     http.MethodPost,
+			body:       strings.NewReader(`{"collecting":false}`),
+			songs:      songs,
+			want:       `{"error":"requires collecting=true"}`,
+			wantStatus: http.StatusBadRequest,
+			img:        &imageCache{name: "foo"},
+		}},
+	} {
+		t.Run(label, func(t *testing.T) {
+			if tt[0].img == nil {
+				t.Fatalf("FIXME: tt[0].img must not be nil")
+			}
+			img := tt[0].img
+			img.SetT(t)
+			h, err := api.NewImagesCacheHandler([]api.ImageCache{img}, log.NewTestLogger(t))
+			if err != nil {
+				t.Fatalf("api.NewImagesCacheHandler() = %v", err)
+			}
+			defer h.Close()
+			defer h.Shutdown(context.TODO())
+			for i := range tt {
+				f := func(t *testing.T) {
+					img.SetT(t)
+					if tt[i].shutdown {
+						ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+						if err := h.Shutdown(ctx); err != nil {
+							t.Errorf("Shutdown() = %v", err)
+						}
+						cancel()
+					}
+					if tt[i].songs != nil {
+						h.UpdateLibrarySongs(tt[i].songs)
+					}
+					if tt[i].method != "" {
+						r := httptest.NewRequest(tt[i].method, "/", tt[i].body)
+						w := httptest.NewRecorder()
+						h.ServeHTTP(w, r)
+						if status, got := w.Result().StatusCode, w.Body.String(); status != tt[i].wantStatus || got != tt[i].want {
+							t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", status, got, tt[i].wantStatus, tt[i].want)
+						}
+					}
+				}
+				if len(tt) != 1 {
+					if tt[i].label == "" {
+						t.Fatalf("test definition error: no test label")
+					}
+					t.Run(tt[i].label, f)
+				} else {
+					f(t)
+				}
+			}
+		})
+	}
+}
+
+// imageCache is mock ImageCache which has files of 100 bytes.
+type imageCache struct {
+	t     *testing.T
+	name  string
+	files int
+	usage func(*testing.T) (int, int64, error)
+	gc    func(context.Context, *testing.T, []map[string][]string) (int, error)
+	mu    sync.Mutex
+}
+
+func (i *imageCache) SetT(t *testing.T) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.t = t
+}
+
+func (i *imageCache) CacheName() string { return i.name }
+
+func (i *imageCache) CacheUsage() (int, int64, error) {
+	i.mu.Lock()
+	t, f, files := i.t, i.usage, i.files
+	i.mu.Unlock()
+	t.Helper()
+	if f == nil {
+		return files, int64(files) * 100, nil
+	}
+	return f(t)
+}
+
+func (i *imageCache) GC(ctx context.Context, library []map[string][]string) error {
+	i.mu.Lock()
+	t, f := i.t, i.gc
+	i.mu.Unlock()
+	t.Helper()
+	if f == nil {
+		t.Fatal("no GC mock function")
+	}
+	files, err := f(ctx, t, library)
+	i.mu.Lock()
+	i.files = files
+	i.mu.Unlock()
+	return err
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/meiraka/vv/internal/vv/api/images"
+)
+
+// maxImageOverrideSize is the maximum bytes of uploaded cover image.
+const maxImageOverrideSize = 32 << 20
+
+var errImageOverrideNotSupported = errors.New("api: cover image override is not supported")
+
+// ImageOverride represents image provider which serves images uploaded by users.
+type ImageOverride interface {
+	// SetImage overrides images of song file or directory by image binary.
+	SetImage(string, []byte) error
+	// DeleteImage removes override image of song file or directory.
+	// DeleteImage returns fs.ErrNotExist if image is not overridden.
+	DeleteImage(string) error
+	// Images returns override image urls by song file or directory.
+	Images() map[string]string
+}
+
+// ImagesOverrideHandler provides cover image upload api.
+type ImagesOverrideHandler struct {
+	api   ImageOverride
+	cache *cache
+}
+
+// NewImagesOverrideHandler initilize ImagesOverrideHandler with image provider.
+// api may be nil if no provider supports override.
+func NewImagesOverrideHandler(api ImageOverride) (*ImagesOverrideHandler, error) {
+	c, err := newCache(map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	ret := &ImagesOverrideHandler{
+		api:   api,
+		cache: c,
+	}
+	if err := ret.update(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (a *ImagesOverrideHandler) update() error {
+	if a.api == nil {
+		return nil
+	}
+	_, err := a.cache.SetIfModified(a.api.Images())
+	return err
+}
+
+// ServeHTTP responses override image urls by song file or directory as json format.
+// POST with image binary body overrides images of song file or directory given by file query.
+// DELETE removes override image given by file query.
+func (a *ImagesOverrideHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		a.cache.ServeHTTP(w, r)
+		return
+	}
+	if a.api == nil {
+		writeHTTPError(w, http.StatusNotImplemented, errImageOverrideNotSupported)
+		return
+	}
+	file := r.URL.Query().Get("file")
+	if file == "" {
+		writeHTTPError(w, http.StatusBadRequest, errors.New("requires file query"))
+		return
+	}
+	if file != path.Clean(file) || path.IsAbs(file) || file == "." || file == ".." || strings.HasPrefix(file, "../") {
+		writeHTTPError(w, http.StatusBadRequest, errors.New("file must be relative path in music directory"))
+		return
+	}
+	now := time.Now().UTC()
+	if r.Method == http.MethodDelete {
+		if err := a.api.DeleteImage(file); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, fs.ErrNotExist) {
+				status = http.StatusNotFound
+			}
+			writeHTTPError(w, status, err)
+			return
+		}
+	} else {
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImageOverrideSize))
+		if err != nil {
+			status := http.StatusBadRequest
+			var merr *http.MaxBytesError
+			if errors.As(err, &merr) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			writeHTTPError(w, status, err)
+			return
+		}
+		if _, _, err := images.DecodeConfig(bytes.NewReader(b)); err != nil {
+			if errors.Is(err, images.ErrTooManyPixels) {
+				writeHTTPError(w, http.StatusRequestEntityTooLarge, err)
+				return
+			}
+			writeHTTPError(w, http.StatusBadRequest, errors.New("unsupported image type: "+http.DetectContentType(b)))
+			return
+		}
+		if err := a.api.SetImage(file, b); err != nil {
+			writeHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	if err := a.update(); err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	r.Method = http.MethodGet
+	a.cache.ServeHTTP(w, setUpdateTime(r, now))
+}
+
+// Changed returns response body changes event chan.
+func (a *ImagesOverrideHandler) Changed() <-chan struct{} {
+	return a.cache.Changed()
+}
+
+// Close closes update event chan.
+func (a *ImagesOverrideHandler) Close() {
+	a.cache.Close()
+}
@@ -0,0 +1,186 @@
+package api_test
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/meiraka/vv/internal/vv/api"
+)
+
+func TestImagesOverrideHandler(t *testing.T) {
+	// 1x1 png image
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89"
+	largePNG := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\xc3\x50\x00\x00\xc3\x50\x08\x06\x00\x00\x00\x4b\xaf\x3d\xca" // 50000x50000
+	for _, tt := range []struct {
+		label       string
+		method      string
+		query       string
+		body        io.Reader
+		images      map[string]string
+		setImage    func(*testing.T, string, []byte) error
+		deleteImage func(*testing.T, string) error
+		want        string
+		wantStatus  int
+		wantImages  map[string]string
+	}{{
+		label:      "GET",
+		method:     http.MethodGet,
+		images:     map[string]string{"foo": "/api/music/images/override/1.png"},
+		want:       `{"foo":"/api/music/images/override/1.png"}`,
+		wantStatus: http.StatusOK,
+	}, {
+		label:  "POST",
+		method: http.MethodPost,
+		query:  "?file=foo%2Fbar",
+		body:   strings.NewReader(png),
+		images: map[string]string{},
+		setImage: func(t *testing.T, file string, b []byte) error {
+			if file != "foo/bar" || string(b) != png {
+				t.Errorf("called SetImage(%q, %q); want SetImage(%q, %q)", file, b, "foo/bar", png)
+			}
+			return nil
+		},
+		want:       `{"foo/bar":"/api/music/images/override/1.png"}`,
+		wantStatus: http.StatusOK,
+		wantImages: map[string]string{"foo/bar": "/api/music/images/override/1.png"},
+	}, {
+		label:      "POST/no file",
+		method:     http.MethodPost,
+		body:       strings.NewReader(png),
+		want:       `{"error":"requires file query"}`,
+		wantStatus: http.StatusBadRequest,
+	}, {
+		label:      "POST/invalid file",
+		method:     http.MethodPost,
+		query:      "?file=..%2Ffoo",
+		body:       strings.NewReader(png),
+		want:       `{"error":"file must be relative path in music directory"}`,
+		wantStatus: http.StatusBadRequest,
+	}, {
+		label:      "POST/not image",
+		method:     http.MethodPost,
+		query:      "?file=foo",
+		body:       strings.NewReader("foo"),
+		want:       `{"error":"unsupported image type: text/plain; charset=utf-8"}`,
+		wantStatus: http.StatusBadRequest,
+	}, {
+		label:      "POST/not decodable image",
+		method:     http.MethodPost,
+		query:      "?file=foo",
+		body:       strings.NewReader("\x00\x00\x01\x00\x01\x00"),
+		want:       `{"error":"unsupported image type: image/x-icon"}`,
+		wantStatus: http.StatusBadRequest,
+	}, {
+		label:      "POST/too many pixels",
+		method:     http.MethodPost,
+		query:      "?file=foo",
+		body:       strings.NewReader(largePNG),
+		want:       `{"error":"images: too many pixels: 50000x50000"}`,
+		wantStatus: http.StatusRequestEntityTooLarge,
+	}, {
+		label:      "POST/error",
+		method:     http.MethodPost,
+		query:      "?file=foo",
+		body:       strings.NewReader(png),
+		setImage:   func(*testing.T, string, []byte) error { return errTest },
+		want:       `{"error":"api_test: test error"}`,
+		wantStatus: http.StatusInternalServerError,
+	}, {
+		label:  "DELETE",
+		method: http.MethodDelete,
+		query:  "?file=foo",
+		images: map[string]string{"foo": "/api/music/images/override/1.png"},
+		deleteImage: func(t *testing.T, file string) error {
+			if file != "foo" {
+				t.Errorf("called DeleteImage(%q); want DeleteImage(%q)", file, "foo")
+			}
+			return nil
+		},
+		want:       `{}`,
+		wantStatus: http.StatusOK,
+		wantImages: map[string]string{},
+	}, {
+		label:       "DELETE/not found",
+		method:      http.MethodDelete,
+		query:       "?file=foo",
+		deleteImage: func(*testing.T, string) error { return fs.ErrNotExist },
+		want:        `{"error":"file does not exist"}`,
+		wantStatus:  http.StatusNotFound,
+	}} {
+		t.Run(tt.label, func(t *testing.T) {
+			o := &imageOverride{t: t, images: tt.images, setImage: tt.setImage, deleteImage: tt.deleteImage}
+			h, err := api.NewImagesOverrideHandler(o)
+			if err != nil {
+				t.Fatalf("api.NewImagesOverrideHandler() = %v", err)
+			}
+			defer h.Close()
+			r := httptest.NewRequest(tt.method, "/"+tt.query, tt.body)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if status, got := w.Result().StatusCode, w.Body.String(); status != tt.wantStatus || got != tt.want {
+				t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", status, got, tt.wantStatus, tt.want)
+			}
+			if tt.wantImages != nil && fmt.Sprint(o.images) != fmt.Sprint(tt.wantImages) {
+				t.Errorf("got images %v; want %v", o.images, tt.wantImages)
+			}
+		})
+	}
+	t.Run("not supported", func(t *testing.T) {
+		h, err := api.NewImagesOverrideHandler(nil)
+		if err != nil {
+			t.Fatalf("api.NewImagesOverrideHandler() = %v", err)
+		}
+		defer h.Close()
+		r := httptest.NewRequest(http.MethodPost, "/?file=foo", strings.NewReader(png))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if status, got, want := w.Result().StatusCode, w.Body.String(), `{"error":"api: cover image override is not supported"}`; status != http.StatusNotImplemented || got != want {
+			t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", status, got, http.StatusNotImplemented, want)
+		}
+	})
+}
+
+// imageOverride is mock ImageOverride which names images as "1.png".
+type imageOverride struct {
+	t           *testing.T
+	images      map[string]string
+	setImage    func(*testing.T, string, []byte) error
+	deleteImage func(*testing.T, string) error
+}
+
+func (i *imageOverride) SetImage(file string, b []byte) error {
+	i.t.Helper()
+	if i.setImage == nil {
+		i.t.Fatal("no SetImage mock function")
+	}
+	if err := i.setImage(i.t, file, b); err != nil {
+		return err
+	}
+	i.images[file] = "/api/music/images/override/1.png"
+	return nil
+}
+
+func (i *imageOverride) DeleteImage(file string) error {
+	i.t.Helper()
+	if i.deleteImage == nil {
+		i.t.Fatal("no DeleteImage mock function")
+	}
+	if err := i.deleteImage(i.t, file); err != nil {
+		return err
+	}
+	delete(i.images, file)
+	return nil
+}
+
+func (i *imageOverride) Images() map[string]string {
+	ret := make(map[string]string, len(i.images))
+	for k, v := range i.images {
+		ret[k] = v
+	}
+	return ret
+}
@@ -35,7 +35,7 @@ func TestImagesHandler(t *testing.T) {
 		"ok/GET": {{
 			method:     http.MethodGet,
 			songs:      songs,
-			want:       `{"updating":false}`,
+			want:       `{"updating":false,"total":0,"done":0,"errors":0}`,
 			wantStatus: http.StatusOK,
 			img:        &imageProvider{},
 		}},
@@ -44,7 +44,7 @@ func TestImagesHandler(t *testing.T) {
 			method:     http.MethodPost,
 			body:       strings.NewReader(`{"updating":true}`),
 			songs:      songs,
-			want:       `{"updating":false}`,
+			want:       `{"updating":false,"total":0,"done":0,"errors":0}`,
 			wantStatus: http.StatusAccepted,
 			img: &imageProvider{
 				getURLs: func(t *testing.T, s map[string][]string) ([]string, bool) {
@@ -66,7 +66,7 @@ func TestImagesHandler(t *testing.T) {
 			label:      "GET",
 			changed:    boolptr(true),
 			method:     http.MethodGet,
-			want:       `{"updating":true}`,
+			want:       `{"updating":true,"total":2,"done":0,"errors":0,"current":"/foo"}`,
 			wantStatus: http.StatusOK,
 		}},
 		"ok/updating/stopped": {{
@@ -74,7 +74,7 @@ func TestImagesHandler(t *testing.T) {
 			method:     http.MethodPost,
 			body:       strings.NewReader(`{"updating":true}`),
 			songs:      songs,
-			want:       `{"updating":false}`,
+			want:       `{"updating":false,"total":0,"done":0,"errors":0}`,
 			wantStatus: http.StatusAccepted,
 			img: &imageProvider{
 				getURLs: func(t *testing.T, s map[string][]string) ([]string, bool) {
@@ -92,7 +92,7 @@ func TestImagesHandler(t *testing.T) {
 			label:      "GET",
 			changed:    boolptr(false),
 			method:     http.MethodGet,
-			want:       `{"updating":false}`,
+			want:       `{"updating":false,"total":2,"done":2,"errors":0}`,
 			wantStatus: http.StatusOK,
 		}},
 		"error/POST/invalid json": {{
@@ -118,7 +118,7 @@ func TestImagesHandler(t *testing.T) {
 			}
 			img := tt[0].img
 			img.SetT(t)
-			h, err := api.NewImagesHandler([]api.ImageProvider{img}, log.NewTestLogger(t))
+			h, err := api.NewImagesHandler([]api.ImageProvider{img}, 1, log.NewTestLogger(t))
 			if err != nil {
 				t.Fatalf("api.NewLibraryHandler(mpd) = %v", err)
 			}
@@ -223,7 +223,7 @@ func TestImagesHandlerConvSongs(t *testing.T) {
 			}
 			img := tt[0].img
 			img.SetT(t)
-			h, err := api.NewImagesHandler([]api.ImageProvider{img}, log.NewTestLogger(t))
+			h, err := api.NewImagesHandler([]api.ImageProvider{img}, 1, log.NewTestLogger(t))
 			if err != nil {
 				t.Fatalf("api.NewLibraryHandler(mpd) = %v", err)
 			}
@@ -277,6 +277,38 @@ func TestImagesHandlerConvSong(t *testing.T) {
 				},
 			},
 		},
+		"ok/indexed/palette": {
+			song: map[string][]string{"file": {"/foo/bar.mp3"}},
+			want: map[string][]string{"file": {"/foo/bar.mp3"}, "cover": {"/foo/cover.jpg"}, "cover_palette": {"#102030", "#ffe000", "#ffffff"}},
+			ok:   true,
+			img: &imageProvider{
+				getURLs: func(t *testing.T, s map[string][]string) ([]string, bool) {
+					return []string{"/foo/cover.jpg"}, true
+				},
+				palette: func(t *testing.T, url string) ([]string, bool) {
+					if url != "/foo/cover.jpg" {
+						t.Errorf("called Palette(%q); want Palette(%q)", url, "/foo/cover.jpg")
+					}
+					return []string{"#102030", "#ffe000", "#ffffff"}, true
+				},
+			},
+		},
+		"ok/indexed/blurhash": {
+			song: map[string][]string{"file": {"/foo/bar.mp3"}, "cover_blurhash": {"old"}},
+			want: map[string][]string{"file": {"/foo/bar.mp3"}, "cover": {"/foo/cover.jpg"}, "cover_blurhash": {"L9TSUA~qfQ~q~qoffQoffQfQfQfQ"}},
+			ok:   true,
+			img: &imageProvider{
+				getURLs: func(t *testing.T, s map[string][]string) ([]string, bool) {
+					return []string{"/foo/cover.jpg"}, true
+				},
+				blurhash: func(t *testing.T, url string) (string, bool) {
+					if url != "/foo/cover.jpg" {
+						t.Errorf("called Blurhash(%q); want Blurhash(%q)", url, "/foo/cover.jpg")
+					}
+					return "L9TSUA~qfQ~q~qoffQoffQfQfQfQ", true
+				},
+			},
+		},
 		"ok/indexed": {
 			song: map[string][]string{"file": {"/foo/bar.mp3"}},
 			want: map[string][]string{"file": {"/foo/bar.mp3"}, "cover": {"/foo/cover.jpg"}},
@@ -291,7 +323,7 @@ func TestImagesHandlerConvSong(t *testing.T) {
 	} {
 		t.Run(label, func(t *testing.T) {
 			img := &imageProvider{t: t}
-			h, err := api.NewImagesHandler([]api.ImageProvider{img}, log.NewTestLogger(t))
+			h, err := api.NewImagesHandler([]api.ImageProvider{img}, 1, log.NewTestLogger(t))
 			if err != nil {
 				t.Fatalf("api.NewLibraryHandler(mpd) = %v", err)
 			}
@@ -302,6 +334,8 @@ func TestImagesHandlerConvSong(t *testing.T) {
 				img.rescan = tt.img.rescan
 				img.update = tt.img.update
 				img.getURLs = tt.img.getURLs
+				img.palette = tt.img.palette
+				img.blurhash = tt.img.blurhash
 			}
 			if got, ok := h.ConvSong(tt.song); !reflect.DeepEqual(got, tt.want) || ok != tt.ok {
 				t.Errorf("got\n%v, %v; want\n%v %v", got, ok, tt.want, tt.ok)
@@ -310,12 +344,43 @@ func TestImagesHandlerConvSong(t *testing.T) {
 	}
 }
 
+func TestImagesHandlerWatch(t *testing.T) {
+	img := &imageWatcher{imageProvider: &imageProvider{t: t}, changed: make(chan struct{}, 1)}
+	h, err := api.NewImagesHandler([]api.ImageProvider{img}, 1, log.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("api.NewImagesHandler() = %v", err)
+	}
+	defer h.Close()
+	defer h.Shutdown(context.TODO())
+	defer close(img.changed)
+	img.changed <- struct{}{}
+	select {
+	case <-h.URLChanged():
+	case <-time.After(time.Second):
+		t.Errorf("no url changed event in 1sec")
+	}
+	select {
+	case got := <-h.Changed():
+		t.Errorf("got changed = %v; want no event", got)
+	default:
+	}
+}
+
+type imageWatcher struct {
+	*imageProvider
+	changed chan struct{}
+}
+
+func (i *imageWatcher) Changed() <-chan struct{} { return i.changed }
+
 type imageProvider struct {
-	t       *testing.T
-	update  func(context.Context, *testing.T, map[string][]string) error
-	rescan  func(context.Context, *testing.T, map[string][]string, string) error
-	getURLs func(*testing.T, map[string][]string) ([]string, bool)
-	mu      sync.Mutex
+	t        *testing.T
+	update   func(context.Context, *testing.T, map[string][]string) error
+	rescan   func(context.Context, *testing.T, map[string][]string, string) error
+	getURLs  func(*testing.T, map[string][]string) ([]string, bool)
+	palette  func(*testing.T, string) ([]string, bool)
+	blurhash func(*testing.T, string) (string, bool)
+	mu       sync.Mutex
 }
 
 func (i *imageProvider) SetT(t *testing.T) {
@@ -356,3 +421,25 @@ func (i *imageProvider) GetURLs(a map[string][]string) ([]string, bool) {
 	}
 	return f(t, a)
 }
+
+func (i *imageProvider) Palette(a string) ([]string, bool) {
+	i.mu.Lock()
+	t, f := i.t, i.palette
+	i.mu.Unlock()
+	t.Helper()
+	if f == nil {
+		return nil, false
+	}
+	return f(t, a)
+}
+
+func (i *imageProvider) Blurhash(a string) (string, bool) {
+	i.mu.Lock()
+	t, f := i.t, i.blurhash
+	i.mu.Unlock()
+	t.Helper()
+	if f == nil {
+		return "", false
+	}
+	return f(t, a)
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/meiraka/vv/internal/mpd"
+)
+
+// MPDLibrarySearch represents mpd api for library search API.
+type MPDLibrarySearch interface {
+	Find(context.Context, string) ([]map[string][]string, error)
+	Search(context.Context, string) ([]map[string][]string, error)
+}
+
+// LibrarySearchHandler provides library songs matching mpd filter expression.
+type LibrarySearchHandler struct {
+	mpd       MPDLibrarySearch
+	songsHook func([]map[string][]string) []map[string][]string
+}
+
+// NewLibrarySearchHandler initilize LibrarySearchHandler with mpd connection.
+func NewLibrarySearchHandler(mpd MPDLibrarySearch, songsHook func([]map[string][]string) []map[string][]string) (*LibrarySearchHandler, error) {
+	return &LibrarySearchHandler{
+		mpd:       mpd,
+		songsHook: songsHook,
+	}, nil
+}
+
+// ServeHTTP responses songs matching filter query as json format.
+// mode query selects case sensitive "find" or case insensitive "search"(default).
+func (a *LibrarySearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := q.Get("filter")
+	if filter == "" {
+		writeHTTPError(w, http.StatusBadRequest, errors.New("requires filter query"))
+		return
+	}
+	var l []map[string][]string
+	var err error
+	switch mode := q.Get("mode"); mode {
+	case "", "search":
+		l, err = a.mpd.Search(r.Context(), filter)
+	case "find":
+		l, err = a.mpd.Find(r.Context(), filter)
+	default:
+		writeHTTPError(w, http.StatusBadRequest, fmt.Errorf("unknown mode: %s", mode))
+		return
+	}
+	if err != nil {
+		if errors.Is(err, mpd.ErrArg) {
+			writeHTTPError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeHTTPJSON(w, a.songsHook(l))
+}
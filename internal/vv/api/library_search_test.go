@@ -0,0 +1,109 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/meiraka/vv/internal/mpd"
+	"github.com/meiraka/vv/internal/vv/api"
+)
+
+func TestLibrarySearchHandlerGET(t *testing.T) {
+	songsHook, randValue := testSongsHook()
+	for label, tt := range map[string]struct {
+		query  url.Values
+		find   func(*testing.T, string) ([]map[string][]string, error)
+		search func(*testing.T, string) ([]map[string][]string, error)
+		status int
+		want   string
+	}{
+		"ok/search": {
+			query: url.Values{"filter": {`(Album contains "foo")`}},
+			search: func(t *testing.T, filter string) ([]map[string][]string, error) {
+				t.Helper()
+				if want := `(Album contains "foo")`; filter != want {
+					t.Errorf("called mpd.Search(ctx, %q); want mpd.Search(ctx, %q)", filter, want)
+				}
+				return []map[string][]string{{"file": {"/foo/bar.mp3"}}}, nil
+			},
+			status: http.StatusOK,
+			want:   fmt.Sprintf(`[{"%s":["%s"],"file":["/foo/bar.mp3"]}]`, randValue, randValue),
+		},
+		"ok/find": {
+			query: url.Values{"filter": {`(Album == "foo")`}, "mode": {"find"}},
+			find: func(t *testing.T, filter string) ([]map[string][]string, error) {
+				t.Helper()
+				if want := `(Album == "foo")`; filter != want {
+					t.Errorf("called mpd.Find(ctx, %q); want mpd.Find(ctx, %q)", filter, want)
+				}
+				return []map[string][]string{}, nil
+			},
+			status: http.StatusOK,
+			want:   `[]`,
+		},
+		"error/no filter": {
+			status: http.StatusBadRequest,
+			want:   `{"error":"requires filter query"}`,
+		},
+		"error/unknown mode": {
+			query:  url.Values{"filter": {`(Album == "foo")`}, "mode": {"foo"}},
+			status: http.StatusBadRequest,
+			want:   `{"error":"unknown mode: foo"}`,
+		},
+		"error/invalid filter": {
+			query: url.Values{"filter": {`(Album = "foo")`}},
+			search: func(t *testing.T, filter string) ([]map[string][]string, error) {
+				return nil, &mpd.CommandError{ID: mpd.ErrArg, Index: 0, Command: "search", Message: "Unknown filter operator"}
+			},
+			status: http.StatusBadRequest,
+			want:   `{"error":"mpd: search: Unknown filter operator"}`,
+		},
+		"error/network": {
+			query: url.Values{"filter": {`(Album == "foo")`}},
+			search: func(t *testing.T, filter string) ([]map[string][]string, error) {
+				return nil, errTest
+			},
+			status: http.StatusInternalServerError,
+			want:   `{"error":"api_test: test error"}`,
+		},
+	} {
+		t.Run(label, func(t *testing.T) {
+			h, err := api.NewLibrarySearchHandler(&mpdLibrarySearch{t: t, find: tt.find, search: tt.search}, songsHook)
+			if err != nil {
+				t.Fatalf("api.NewLibrarySearchHandler() = %v, %v", h, err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/?"+tt.query.Encode(), nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if status, got := w.Result().StatusCode, w.Body.String(); status != tt.status || got != tt.want {
+				t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", status, got, tt.status, tt.want)
+			}
+		})
+	}
+}
+
+type mpdLibrarySearch struct {
+	t      *testing.T
+	find   func(*testing.T, string) ([]map[string][]string, error)
+	search func(*testing.T, string) ([]map[string][]string, error)
+}
+
+func (m *mpdLibrarySearch) Find(ctx context.Context, filter string) ([]map[string][]string, error) {
+	m.t.Helper()
+	if m.find == nil {
+		m.t.Fatal("no Find mock function")
+	}
+	return m.find(m.t, filter)
+}
+
+func (m *mpdLibrarySearch) Search(ctx context.Context, filter string) ([]map[string][]string, error) {
+	m.t.Helper()
+	if m.search == nil {
+		m.t.Fatal("no Search mock function")
+	}
+	return m.search(m.t, filter)
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketLibrary      = []byte("library")
+	bucketLibrarySongs = []byte("library_songs")
+	keyLibraryDBUpdate = []byte("db_update")
+	keyLibraryFiles    = []byte("files")
+)
+
+// librarySnapshot stores mpd library songs with mpd db_update stats.
+// Songs are stored per file path with the list of files to keep mpd order,
+// so that small library changes write changed songs only.
+type librarySnapshot struct {
+	db *bolt.DB
+}
+
+func newLibrarySnapshot(cacheDir string) (*librarySnapshot, error) {
+	if err := os.MkdirAll(cacheDir, 0766); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(cacheDir, "library.db"), 0666, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			return nil, fmt.Errorf("obtain library snapshot lock: %w", err)
+		}
+		return nil, err
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketLibrary, bucketLibrarySongs} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("create bucket: %w", err)
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &librarySnapshot{db: db}, nil
+}
+
+// Get returns stored songs and db_update.
+// Get returns nil songs if snapshot is not stored.
+func (s *librarySnapshot) Get() ([]map[string][]string, string, error) {
+	var dbUpdate string
+	var songs []map[string][]string
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketLibrary)
+		raw := b.Get(keyLibraryFiles)
+		if raw == nil {
+			return nil
+		}
+		var files []string
+		if err := json.Unmarshal(raw, &files); err != nil {
+			return err
+		}
+		sb := tx.Bucket(bucketLibrarySongs)
+		l := make([]map[string][]string, len(files))
+		for i, f := range files {
+			v := sb.Get([]byte(f))
+			if v == nil {
+				return fmt.Errorf("song not found: %s", f)
+			}
+			if err := json.Unmarshal(v, &l[i]); err != nil {
+				return err
+			}
+		}
+		songs = l
+		dbUpdate = string(b.Get(keyLibraryDBUpdate))
+		return nil
+	}); err != nil {
+		return nil, "", err
+	}
+	return songs, dbUpdate, nil
+}
+
+// Set stores songs and db_update.
+// Set writes changed songs only and does not write to db if songs and
+// db_update are not changed.
+func (s *librarySnapshot) Set(songs []map[string][]string, dbUpdate string) error {
+	files := make([]string, 0, len(songs))
+	raws := make(map[string][]byte, len(songs))
+	for _, song := range songs {
+		f := song["file"]
+		if len(f) == 0 || len(f[0]) == 0 {
+			continue
+		}
+		raw, err := json.Marshal(song)
+		if err != nil {
+			return err
+		}
+		files = append(files, f[0])
+		raws[f[0]] = raw
+	}
+	rawFiles, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+	put := map[string][]byte{}
+	var removed [][]byte
+	var sameFiles, sameDBUpdate bool
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketLibrary)
+		sameFiles = bytes.Equal(b.Get(keyLibraryFiles), rawFiles)
+		sameDBUpdate = string(b.Get(keyLibraryDBUpdate)) == dbUpdate
+		sb := tx.Bucket(bucketLibrarySongs)
+		for f, raw := range raws {
+			if !bytes.Equal(sb.Get([]byte(f)), raw) {
+				put[f] = raw
+			}
+		}
+		return sb.ForEach(func(k, _ []byte) error {
+			if _, ok := raws[string(k)]; !ok {
+				removed = append(removed, bytes.Clone(k))
+			}
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+	if sameFiles && sameDBUpdate && len(put) == 0 && len(removed) == 0 {
+		return nil
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		sb := tx.Bucket(bucketLibrarySongs)
+		for f, raw := range put {
+			if err := sb.Put([]byte(f), raw); err != nil {
+				return err
+			}
+		}
+		for _, k := range removed {
+			if err := sb.Delete(k); err != nil {
+				return err
+			}
+		}
+		b := tx.Bucket(bucketLibrary)
+		if !sameFiles {
+			if err := b.Put(keyLibraryFiles, rawFiles); err != nil {
+				return err
+			}
+		}
+		return b.Put(keyLibraryDBUpdate, []byte(dbUpdate))
+	})
+}
+
+// Close closes snapshot db.
+func (s *librarySnapshot) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestLibrarySnapshot(t *testing.T) {
+	s, err := newLibrarySnapshot(t.TempDir())
+	if err != nil {
+		t.Fatalf("newLibrarySnapshot() = %v", err)
+	}
+	defer s.Close()
+	txID := func() int {
+		var id int
+		s.db.View(func(tx *bolt.Tx) error {
+			id = tx.ID()
+			return nil
+		})
+		return id
+	}
+	foo := map[string][]string{"file": {"foo"}}
+	bar := map[string][]string{"file": {"bar"}}
+	newBar := map[string][]string{"file": {"bar"}, "Title": {"bar"}}
+	songs := []map[string][]string{foo}
+	for _, tt := range []struct {
+		label    string
+		songs    []map[string][]string
+		dbUpdate string
+		write    bool
+	}{
+		{label: "new", songs: songs, dbUpdate: "1", write: true},
+		{label: "not changed", songs: songs, dbUpdate: "1"},
+		{label: "db_update changed", songs: songs, dbUpdate: "2", write: true},
+		{label: "song added", songs: []map[string][]string{foo, bar}, dbUpdate: "3", write: true},
+		{label: "song changed", songs: []map[string][]string{foo, newBar}, dbUpdate: "4", write: true},
+		{label: "order changed", songs: []map[string][]string{newBar, foo}, dbUpdate: "4", write: true},
+		{label: "song removed", songs: []map[string][]string{foo}, dbUpdate: "5", write: true},
+		{label: "songs changed", songs: []map[string][]string{}, dbUpdate: "5", write: true},
+	} {
+		t.Run(tt.label, func(t *testing.T) {
+			before := txID()
+			if err := s.Set(tt.songs, tt.dbUpdate); err != nil {
+				t.Fatalf("Set() = %v; want <nil>", err)
+			}
+			if write := txID() != before; write != tt.write {
+				t.Errorf("Set() writes db: %v; want %v", write, tt.write)
+			}
+			songs, dbUpdate, err := s.Get()
+			if err != nil || !reflect.DeepEqual(songs, tt.songs) || dbUpdate != tt.dbUpdate {
+				t.Errorf("Get() = %v, %q, %v; want %v, %q, <nil>", songs, dbUpdate, err, tt.songs, tt.dbUpdate)
+			}
+			var stored int
+			s.db.View(func(tx *bolt.Tx) error {
+				stored = tx.Bucket(bucketLibrarySongs).Stats().KeyN
+				return nil
+			})
+			if stored != len(tt.songs) {
+				t.Errorf("stored songs = %d; want %d", stored, len(tt.songs))
+			}
+		})
+	}
+}
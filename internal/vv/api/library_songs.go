@@ -2,26 +2,83 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+
+	"github.com/meiraka/vv/internal/songs"
 )
 
 type MPDLibrarySongs interface {
 	ListAllInfo(context.Context, string) ([]map[string][]string, error)
+	ListAll(context.Context, string) ([]string, error)
+	LsInfo(context.Context, string) ([]map[string][]string, error)
+	Find(context.Context, string) ([]map[string][]string, error)
+	Stats(context.Context) (map[string]string, error)
 }
 
-func NewLibrarySongsHandler(mpd MPDLibrarySongs, songsHook func([]map[string][]string) []map[string][]string) (*LibrarySongsHandler, error) {
+// NewLibrarySongsHandler initilize library songs cache with mpd connection.
+// If cacheDir is not empty, library songs are persisted to cacheDir and
+// loaded from it to serve before first Update.
+func NewLibrarySongsHandler(mpd MPDLibrarySongs, songsHook func([]map[string][]string) []map[string][]string, cacheDir string) (*LibrarySongsHandler, error) {
 	cache, err := newCache([]map[string][]string{})
 	if err != nil {
 		return nil, err
 	}
-	return &LibrarySongsHandler{
+	a := &LibrarySongsHandler{
 		mpd:       mpd,
 		cache:     cache,
 		changed:   make(chan struct{}, cap(cache.Changed())),
 		songsHook: songsHook,
-	}, nil
+	}
+	if len(cacheDir) == 0 {
+		return a, nil
+	}
+	if a.snapshot, err = newLibrarySnapshot(cacheDir); err != nil {
+		return nil, err
+	}
+	// ignore broken snapshot; it will be overwritten by Update
+	if l, dbUpdate, err := a.snapshot.Get(); err == nil && l != nil {
+		if err := a.set(l); err != nil {
+			a.snapshot.Close()
+			return nil, err
+		}
+		a.dbUpdate = dbUpdate
+	}
+	return a, nil
+}
+
+// librarySongsDeltaMax is the maximum number of library deltas kept for delta API.
+const librarySongsDeltaMax = 16
 
+// librarySongsRefreshDirMax is the maximum number of directories listed by
+// incremental library update; Update lists all songs if more directories
+// have new songs.
+const librarySongsRefreshDirMax = 32
+
+// librarySongsViewMax is the maximum number of sorted library views kept for query API.
+const librarySongsViewMax = 8
+
+type httpLibrarySongsDelta struct {
+	Added   []map[string][]string `json:"added"`
+	Changed []map[string][]string `json:"changed"`
+	Removed []string              `json:"removed"`
+}
+
+// librarySongsDelta represents library changes from the version of etag.
+type librarySongsDelta struct {
+	etag    string
+	added   []string
+	changed []string
+	removed []string
 }
 
 type LibrarySongsHandler struct {
@@ -29,23 +86,261 @@ type LibrarySongsHandler struct {
 	cache     *cache
 	changed   chan struct{}
 	songsHook func([]map[string][]string) []map[string][]string
+	raw       []map[string][]string // songs before songsHook
 	data      []map[string][]string
+	typed     []*songs.Song // data as typed songs in the same order
+	index     map[string]map[string][]string
+	etag      string
+	deltas    []*librarySongsDelta
+	views     map[string][]*songs.Song // sorted songs by sort keys
+	snapshot  *librarySnapshot
+	dbUpdate  string
 	mu        sync.RWMutex
 }
 
+// Update updates library songs.
+// Update lists all songs at first, and then lists songs in directories which
+// have new songs and songs modified after the previous result only.
+// Update lists all songs again if mpd database was updated but no changes
+// are found, like a file replaced by older one.
+// Update compares songs with previous result and skips updating cache if no songs changed.
 func (a *LibrarySongsHandler) Update(ctx context.Context) error {
-	l, err := a.mpd.ListAllInfo(ctx, "/")
+	stats, err := a.mpd.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	dbUpdate := stats["db_update"]
+	a.mu.RLock()
+	raw, prev := a.raw, a.dbUpdate
+	a.mu.RUnlock()
+	l, found, err := a.refresh(ctx, raw)
+	if err != nil {
+		return err
+	}
+	if !found && len(prev) != 0 && prev != dbUpdate {
+		if l, err = a.mpd.ListAllInfo(ctx, "/"); err != nil {
+			return err
+		}
+	}
+	if a.snapshot != nil {
+		if err := a.snapshot.Set(l, dbUpdate); err != nil {
+			return err
+		}
+	}
+	a.mu.Lock()
+	a.dbUpdate = dbUpdate
+	a.mu.Unlock()
+	return a.set(l)
+}
+
+// refresh returns all library songs. refresh reuses songs in old and lists
+// songs only in directories which have new files and songs modified after
+// the latest song in old. Directory Last-Modified is not used to find
+// changed directories because it is not updated by changes in sub directories.
+// refresh returns false if it lists changes from old only and finds no added,
+// modified or removed songs.
+func (a *LibrarySongsHandler) refresh(ctx context.Context, old []map[string][]string) ([]map[string][]string, bool, error) {
+	var since string
+	index := make(map[string]map[string][]string, len(old))
+	for _, s := range old {
+		if f := s["file"]; len(f) != 0 {
+			index[f[0]] = s
+		}
+		if m := s["Last-Modified"]; len(m) != 0 && m[0] > since {
+			since = m[0]
+		}
+	}
+	if len(since) == 0 {
+		l, err := a.mpd.ListAllInfo(ctx, "/")
+		return l, true, err
+	}
+	files, err := a.mpd.ListAll(ctx, "/")
+	if err != nil {
+		return nil, false, err
+	}
+	dirs := []string{}
+	found := map[string]struct{}{}
+	for _, f := range files {
+		if _, ok := index[f]; ok {
+			continue
+		}
+		dir := path.Dir(f)
+		if dir == "." {
+			dir = "/"
+		}
+		if _, ok := found[dir]; !ok {
+			found[dir] = struct{}{}
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) > librarySongsRefreshDirMax {
+		l, err := a.mpd.ListAllInfo(ctx, "/")
+		return l, true, err
+	}
+	fresh := map[string]map[string][]string{}
+	for _, dir := range dirs {
+		l, err := a.mpd.LsInfo(ctx, dir)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, s := range l {
+			if f := s["file"]; len(f) != 0 {
+				fresh[f[0]] = s
+			}
+		}
+	}
+	l, err := a.mpd.Find(ctx, fmt.Sprintf("(modified-since '%s')", since))
 	if err != nil {
+		return nil, false, err
+	}
+	for _, s := range l {
+		if f := s["file"]; len(f) != 0 {
+			fresh[f[0]] = s
+		}
+	}
+	ret := make([]map[string][]string, 0, len(files))
+	for _, f := range files {
+		if s, ok := fresh[f]; ok {
+			ret = append(ret, s)
+		} else if s, ok := index[f]; ok {
+			ret = append(ret, s)
+		}
+	}
+	return ret, len(fresh) != 0 || len(ret) != len(old), nil
+}
+
+// Revalidate updates library songs if mpd database was updated after the
+// persisted library songs.
+func (a *LibrarySongsHandler) Revalidate(ctx context.Context) error {
+	if a.snapshot == nil {
+		return a.Update(ctx)
+	}
+	stats, err := a.mpd.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	a.mu.RLock()
+	fresh := a.data != nil && a.dbUpdate == stats["db_update"]
+	a.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+	return a.Update(ctx)
+}
+
+// Rehook applies songsHook to cached library songs again without mpd
+// connection; used to reflect changes of data added by songsHook.
+func (a *LibrarySongsHandler) Rehook() error {
+	a.mu.RLock()
+	raw := a.raw
+	a.mu.RUnlock()
+	if raw == nil {
+		return nil
+	}
+	return a.set(raw)
+}
+
+// RehookSong applies songsHook to cached library song of file again without
+// notifying changes; used to reflect changes of data added by songsHook for
+// the song. Clients get the change by next request.
+func (a *LibrarySongsHandler) RehookSong(file string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	old, ok := a.index[file]
+	if !ok {
+		return nil
+	}
+	s := make(map[string][]string, len(old))
+	for k, v := range old {
+		s[k] = v
+	}
+	s = a.songsHook([]map[string][]string{s})[0]
+	if reflect.DeepEqual(old, s) {
+		return nil
+	}
+	data := make([]map[string][]string, len(a.data))
+	typed := make([]*songs.Song, len(a.typed))
+	for i := range a.data {
+		data[i], typed[i] = a.data[i], a.typed[i]
+		if f := data[i]["file"]; len(f) != 0 && f[0] == file {
+			data[i], typed[i] = s, songs.NewSong(s)
+		}
+	}
+	if err := a.cache.Set(data); err != nil {
 		return err
 	}
-	v := a.songsHook(l)
+	_, _, date := a.cache.get()
+	a.deltas = append(a.deltas, &librarySongsDelta{etag: a.etag, changed: []string{file}})
+	if len(a.deltas) > librarySongsDeltaMax {
+		a.deltas = a.deltas[len(a.deltas)-librarySongsDeltaMax:]
+	}
+	a.data = data
+	a.typed = typed
+	a.index[file] = s
+	a.etag = cacheETag(date)
+	a.views = nil
+	return nil
+}
+
+// DBUpdate returns mpd database update time of library songs.
+func (a *LibrarySongsHandler) DBUpdate() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.dbUpdate
+}
+
+// HasSnapshot returns true if library songs are loaded from persisted data.
+func (a *LibrarySongsHandler) HasSnapshot() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.snapshot != nil && a.data != nil
+}
+
+// set applies songsHook to copy of songs l and updates cache.
+// l is kept as is to apply songsHook again.
+func (a *LibrarySongsHandler) set(l []map[string][]string) error {
+	v := make([]map[string][]string, len(l))
+	for i := range l {
+		v[i] = make(map[string][]string, len(l[i]))
+		for k, s := range l[i] {
+			v[i][k] = s
+		}
+	}
+	v = a.songsHook(v)
+	typed := songs.NewSongs(v)
+	index := make(map[string]map[string][]string, len(v))
+	for i := range v {
+		if f, ok := v[i]["file"]; ok && len(f) != 0 {
+			index[f[0]] = v[i]
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.raw = l
+	first := a.data == nil
+	delta := diffLibrarySongs(a.index, index)
+	if !first && len(delta.added) == 0 && len(delta.changed) == 0 && len(delta.removed) == 0 && sameFileOrder(a.data, v) {
+		return nil
+	}
 	// force update to skip []byte compare
 	if err := a.cache.Set(v); err != nil {
 		return err
 	}
-	a.mu.Lock()
+	_, _, date := a.cache.get()
+	delta.etag = a.etag
 	a.data = v
-	a.mu.Unlock()
+	a.typed = typed
+	a.index = index
+	a.etag = cacheETag(date)
+	a.views = nil
+	if first {
+		a.deltas = nil
+	} else {
+		a.deltas = append(a.deltas, delta)
+		if len(a.deltas) > librarySongsDeltaMax {
+			a.deltas = a.deltas[len(a.deltas)-librarySongsDeltaMax:]
+		}
+	}
 	select {
 	case a.changed <- struct{}{}:
 	default:
@@ -53,15 +348,239 @@ func (a *LibrarySongsHandler) Update(ctx context.Context) error {
 	return nil
 }
 
+func diffLibrarySongs(o, n map[string]map[string][]string) *librarySongsDelta {
+	d := &librarySongsDelta{}
+	for f, ns := range n {
+		os, ok := o[f]
+		if !ok {
+			d.added = append(d.added, f)
+		} else if !reflect.DeepEqual(os, ns) {
+			d.changed = append(d.changed, f)
+		}
+	}
+	for f := range o {
+		if _, ok := n[f]; !ok {
+			d.removed = append(d.removed, f)
+		}
+	}
+	return d
+}
+
+func sameFileOrder(o, n []map[string][]string) bool {
+	if len(o) != len(n) {
+		return false
+	}
+	for i := range o {
+		if !reflect.DeepEqual(o[i]["file"], n[i]["file"]) {
+			return false
+		}
+	}
+	return true
+}
+
 func (a *LibrarySongsHandler) Cache() []map[string][]string {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 	return a.data
 }
 
+// Songs returns library songs as typed songs in the same order as Cache.
+// Returned songs must not be modified.
+func (a *LibrarySongsHandler) Songs() []*songs.Song {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.typed
+}
+
 // ServeHTTP responses library song list as json format.
+// ServeHTTP responses added, changed and removed songs from given etag if since query is set.
+// since query can not be used with offset, limit, fields and sort queries.
 func (a *LibrarySongsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	a.cache.ServeHTTP(w, r)
+	q := r.URL.Query()
+	if q.Has("offset") || q.Has("limit") || q.Has("fields") || q.Has("sort") {
+		if q.Has("since") {
+			writeHTTPError(w, http.StatusBadRequest, errors.New("since can not be used with offset, limit, fields or sort"))
+			return
+		}
+		a.serveQuery(w, q)
+		return
+	}
+	since := q.Get("since")
+	if len(since) == 0 {
+		a.cache.ServeHTTP(w, r)
+		return
+	}
+	since = strings.Trim(since, `"`)
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	start := -1
+	if since == strings.Trim(a.etag, `"`) {
+		start = len(a.deltas)
+	} else {
+		for i := range a.deltas {
+			if strings.Trim(a.deltas[i].etag, `"`) == since {
+				start = i
+				break
+			}
+		}
+	}
+	if start < 0 {
+		writeHTTPError(w, http.StatusGone, fmt.Errorf("unknown or expired etag: %s", since))
+		return
+	}
+	const (
+		added = iota + 1
+		changed
+		removed
+	)
+	state := map[string]int{}
+	for _, d := range a.deltas[start:] {
+		for _, f := range d.added {
+			if state[f] == removed {
+				state[f] = changed
+			} else {
+				state[f] = added
+			}
+		}
+		for _, f := range d.changed {
+			if state[f] != added {
+				state[f] = changed
+			}
+		}
+		for _, f := range d.removed {
+			if state[f] == added {
+				delete(state, f)
+			} else {
+				state[f] = removed
+			}
+		}
+	}
+	ret := &httpLibrarySongsDelta{
+		Added:   []map[string][]string{},
+		Changed: []map[string][]string{},
+		Removed: []string{},
+	}
+	if len(state) != 0 {
+		for _, song := range a.data {
+			f, ok := song["file"]
+			if !ok || len(f) == 0 {
+				continue
+			}
+			switch state[f[0]] {
+			case added:
+				ret.Added = append(ret.Added, song)
+			case changed:
+				ret.Changed = append(ret.Changed, song)
+			}
+		}
+		for f, s := range state {
+			if s == removed {
+				ret.Removed = append(ret.Removed, f)
+			}
+		}
+		sort.Strings(ret.Removed)
+	}
+	w.Header().Add("ETag", a.etag)
+	writeHTTPJSON(w, ret)
+}
+
+// serveQuery responses sorted, paginated and projected library song list.
+func (a *LibrarySongsHandler) serveQuery(w http.ResponseWriter, q url.Values) {
+	offset, err := queryInt(q, "offset", 0)
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit, err := queryInt(q, "limit", -1)
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	var fields, keys []string
+	if f := q.Get("fields"); len(f) != 0 {
+		fields = strings.Split(f, ",")
+	}
+	if k := q.Get("sort"); len(k) != 0 {
+		keys = strings.Split(k, ",")
+	}
+	var l []*songs.Song
+	if len(keys) != 0 {
+		l = a.view(keys)
+	} else {
+		a.mu.RLock()
+		l = a.typed
+		a.mu.RUnlock()
+	}
+	total := len(l)
+	if offset > len(l) {
+		offset = len(l)
+	}
+	l = l[offset:]
+	if limit >= 0 && limit < len(l) {
+		l = l[:limit]
+	}
+	w.Header().Add("X-Total-Count", strconv.Itoa(total))
+	if fields != nil {
+		n := make([]map[string][]string, len(l))
+		for i := range l {
+			n[i] = make(map[string][]string, len(fields))
+			for _, f := range fields {
+				if v := l[i].Get(f); v != nil {
+					n[i][f] = v
+				}
+			}
+		}
+		writeHTTPJSON(w, n)
+		return
+	}
+	if l == nil {
+		l = []*songs.Song{}
+	}
+	writeHTTPJSON(w, l)
+}
+
+// view returns library songs sorted by keys. Songs which have multiple values
+// of sort keys are listed once at the first position.
+func (a *LibrarySongsHandler) view(keys []string) []*songs.Song {
+	k := strings.Join(keys, ",")
+	a.mu.RLock()
+	data, etag := a.typed, a.etag
+	v, ok := a.views[k]
+	a.mu.RUnlock()
+	if ok {
+		return v
+	}
+	sorted, _, _ := songs.WeakFilterSortSongs(data, keys, nil, 0, math.MaxInt, -1)
+	v = make([]*songs.Song, 0, len(data))
+	seen := make(map[*songs.Song]struct{}, len(data))
+	for _, s := range sorted {
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		v = append(v, s)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.etag == etag {
+		if a.views == nil || len(a.views) >= librarySongsViewMax {
+			a.views = map[string][]*songs.Song{}
+		}
+		a.views[k] = v
+	}
+	return v
+}
+
+func queryInt(q url.Values, key string, e int) (int, error) {
+	v := q.Get(key)
+	if len(v) == 0 {
+		return e, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid %s: %s", key, v)
+	}
+	return i, nil
 }
 
 // Changed returns library song list update event chan.
@@ -72,4 +591,7 @@ func (a *LibrarySongsHandler) Changed() <-chan struct{} {
 // Close closes update event chan.
 func (a *LibrarySongsHandler) Close() {
 	a.cache.Close()
+	if a.snapshot != nil {
+		a.snapshot.Close()
+	}
 }
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/meiraka/vv/internal/mpd"
+)
+
+// MPDLibrarySongsComments represents mpd api for song comments API.
+type MPDLibrarySongsComments interface {
+	ReadComments(context.Context, string) (map[string][]string, error)
+}
+
+// LibrarySongsCommentsHandler provides raw song file tags including tags not
+// indexed by mpd.
+type LibrarySongsCommentsHandler struct {
+	mpd MPDLibrarySongsComments
+}
+
+// NewLibrarySongsCommentsHandler initilize LibrarySongsCommentsHandler with mpd connection.
+func NewLibrarySongsCommentsHandler(mpd MPDLibrarySongsComments) (*LibrarySongsCommentsHandler, error) {
+	return &LibrarySongsCommentsHandler{
+		mpd: mpd,
+	}, nil
+}
+
+// ServeHTTP responses song comments given by file query as json format.
+func (a *LibrarySongsCommentsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	file := r.URL.Query().Get("file")
+	if file == "" {
+		writeHTTPError(w, http.StatusBadRequest, errors.New("requires file query"))
+		return
+	}
+	m, err := a.mpd.ReadComments(r.Context(), file)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, mpd.ErrNoExist) {
+			status = http.StatusNotFound
+		} else if errors.Is(err, mpd.ErrArg) {
+			status = http.StatusBadRequest
+		}
+		writeHTTPError(w, status, err)
+		return
+	}
+	if m == nil {
+		m = map[string][]string{}
+	}
+	writeHTTPJSON(w, m)
+}
@@ -0,0 +1,83 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/meiraka/vv/internal/mpd"
+	"github.com/meiraka/vv/internal/vv/api"
+)
+
+func TestLibrarySongsCommentsHandlerGET(t *testing.T) {
+	for _, tt := range []struct {
+		label        string
+		query        string
+		readComments func(*testing.T, string) (map[string][]string, error)
+		status       int
+		want         string
+	}{{
+		label: "ok",
+		query: "?file=foo%2Fbar.flac",
+		readComments: func(t *testing.T, file string) (map[string][]string, error) {
+			if file != "foo/bar.flac" {
+				t.Errorf("called mpd.ReadComments(ctx, %q); want mpd.ReadComments(ctx, %q)", file, "foo/bar.flac")
+			}
+			return map[string][]string{"REPLAYGAIN_TRACK_GAIN": {"-1.00 dB"}, "MUSICBRAINZ_ARTISTID": {"foo", "bar"}}, nil
+		},
+		status: http.StatusOK,
+		want:   `{"MUSICBRAINZ_ARTISTID":["foo","bar"],"REPLAYGAIN_TRACK_GAIN":["-1.00 dB"]}`,
+	}, {
+		label:        "empty",
+		query:        "?file=foo",
+		readComments: func(*testing.T, string) (map[string][]string, error) { return nil, nil },
+		status:       http.StatusOK,
+		want:         `{}`,
+	}, {
+		label:  "no file",
+		status: http.StatusBadRequest,
+		want:   `{"error":"requires file query"}`,
+	}, {
+		label: "not found",
+		query: "?file=foo",
+		readComments: func(*testing.T, string) (map[string][]string, error) {
+			return nil, &mpd.CommandError{ID: 50, Index: 0, Command: "readcomments", Message: "No such file or directory"}
+		},
+		status: http.StatusNotFound,
+		want:   `{"error":"mpd: readcomments: No such file or directory"}`,
+	}, {
+		label:        "error",
+		query:        "?file=foo",
+		readComments: func(*testing.T, string) (map[string][]string, error) { return nil, errTest },
+		status:       http.StatusInternalServerError,
+		want:         `{"error":"api_test: test error"}`,
+	}} {
+		t.Run(tt.label, func(t *testing.T) {
+			m := &mpdLibrarySongsComments{t: t, readComments: tt.readComments}
+			h, err := api.NewLibrarySongsCommentsHandler(m)
+			if err != nil {
+				t.Fatalf("failed to init LibrarySongsCommentsHandler: %v", err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if status, got := w.Result().StatusCode, w.Body.String(); status != tt.status || got != tt.want {
+				t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", status, got, tt.status, tt.want)
+			}
+		})
+	}
+}
+
+type mpdLibrarySongsComments struct {
+	t            *testing.T
+	readComments func(*testing.T, string) (map[string][]string, error)
+}
+
+func (m *mpdLibrarySongsComments) ReadComments(ctx context.Context, file string) (map[string][]string, error) {
+	m.t.Helper()
+	if m.readComments == nil {
+		m.t.Fatal("no ReadComments mock function")
+	}
+	return m.readComments(m.t, file)
+}
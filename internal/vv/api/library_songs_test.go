@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"testing"
 
@@ -46,6 +47,13 @@ func TestLibrarySongsHandlerGet(t *testing.T) {
 			want:    fmt.Sprintf(`[{"%s":["%s"],"file":["/foo/bar.mp3"]}]`, randValue, randValue),
 			cache:   []map[string][]string{{"file": {"/foo/bar.mp3"}, randValue: {randValue}}},
 			changed: true,
+		}, {
+			label: "not changed",
+			listAllInfo: func(t *testing.T, path string) ([]map[string][]string, error) {
+				return []map[string][]string{{"file": {"/foo/bar.mp3"}}}, nil
+			},
+			want:  fmt.Sprintf(`[{"%s":["%s"],"file":["/foo/bar.mp3"]}]`, randValue, randValue),
+			cache: []map[string][]string{{"file": {"/foo/bar.mp3"}, randValue: {randValue}}},
 		}, {
 			label: "remove",
 			listAllInfo: func(t *testing.T, path string) ([]map[string][]string, error) {
@@ -87,7 +95,7 @@ func TestLibrarySongsHandlerGet(t *testing.T) {
 	} {
 		t.Run(label, func(t *testing.T) {
 			mpd := &mpdLibrarySongs{t: t}
-			h, err := api.NewLibrarySongsHandler(mpd, songsHook)
+			h, err := api.NewLibrarySongsHandler(mpd, songsHook, "")
 			if err != nil {
 				t.Fatalf("api.NewLibrarySongs() = %v, %v", h, err)
 			}
@@ -117,6 +125,423 @@ func TestLibrarySongsHandlerGet(t *testing.T) {
 
 }
 
+func TestLibrarySongsHandlerUpdateIncremental(t *testing.T) {
+	songsHook, randValue := testSongsHook()
+	mpd := &mpdLibrarySongs{t: t, listAllInfo: func(*testing.T, string) ([]map[string][]string, error) {
+		return []map[string][]string{
+			{"file": {"a/1"}, "Last-Modified": {"2024-01-01T00:00:00Z"}},
+			{"file": {"a/2"}, "Last-Modified": {"2024-01-02T00:00:00Z"}},
+			{"file": {"b/1"}, "Last-Modified": {"2024-01-01T00:00:00Z"}},
+		}, nil
+	}}
+	h, err := api.NewLibrarySongsHandler(mpd, songsHook, "")
+	if err != nil {
+		t.Fatalf("api.NewLibrarySongs() = %v, %v", h, err)
+	}
+	defer h.Close()
+	if err := h.Update(context.TODO()); err != nil {
+		t.Fatalf("handler.Update(context.TODO()) = %v", err)
+	}
+	recieveMsg(h.Changed())
+	mpd.listAllInfo = nil
+	mpd.listAll = func(t *testing.T, path string) ([]string, error) {
+		t.Helper()
+		if path != "/" {
+			t.Errorf("called mpd.ListAll(ctx, %q); want mpd.ListAll(ctx, %q)", path, "/")
+		}
+		return []string{"a/1", "a/2", "c/1", "1"}, nil
+	}
+	lsInfo := []string{}
+	mpd.lsInfo = func(t *testing.T, path string) ([]map[string][]string, error) {
+		lsInfo = append(lsInfo, path)
+		switch path {
+		case "c":
+			return []map[string][]string{{"file": {"c/1"}, "Last-Modified": {"2024-01-03T00:00:00Z"}}}, nil
+		case "/":
+			return []map[string][]string{{"file": {"1"}, "Last-Modified": {"2024-01-03T00:00:00Z"}}}, nil
+		}
+		return nil, errTest
+	}
+	mpd.find = func(t *testing.T, filter string) ([]map[string][]string, error) {
+		t.Helper()
+		if want := "(modified-since '2024-01-02T00:00:00Z')"; filter != want {
+			t.Errorf("called mpd.Find(ctx, %q); want mpd.Find(ctx, %q)", filter, want)
+		}
+		return []map[string][]string{{"file": {"a/2"}, "Last-Modified": {"2024-01-03T00:00:00Z"}, "Title": {"foo"}}}, nil
+	}
+	if err := h.Update(context.TODO()); err != nil {
+		t.Fatalf("handler.Update(context.TODO()) = %v", err)
+	}
+	if want := []string{"c", "/"}; !reflect.DeepEqual(lsInfo, want) {
+		t.Errorf("called mpd.LsInfo for %v; want %v", lsInfo, want)
+	}
+	want := []map[string][]string{
+		{"file": {"a/1"}, "Last-Modified": {"2024-01-01T00:00:00Z"}, randValue: {randValue}},
+		{"file": {"a/2"}, "Last-Modified": {"2024-01-03T00:00:00Z"}, "Title": {"foo"}, randValue: {randValue}},
+		{"file": {"c/1"}, "Last-Modified": {"2024-01-03T00:00:00Z"}, randValue: {randValue}},
+		{"file": {"1"}, "Last-Modified": {"2024-01-03T00:00:00Z"}, randValue: {randValue}},
+	}
+	if cache := h.Cache(); !reflect.DeepEqual(cache, want) {
+		t.Errorf("got cache\n%v; want\n%v", cache, want)
+	}
+	if changed := recieveMsg(h.Changed()); !changed {
+		t.Errorf("changed = %v; want true", changed)
+	}
+}
+
+func TestLibrarySongsHandlerUpdateReplacedByOlder(t *testing.T) {
+	songsHook, randValue := testSongsHook()
+	songs := []map[string][]string{
+		{"file": {"a/1"}, "Last-Modified": {"2024-01-01T00:00:00Z"}},
+		{"file": {"a/2"}, "Last-Modified": {"2024-01-02T00:00:00Z"}},
+	}
+	mpd := &mpdLibrarySongs{
+		t:           t,
+		stats:       func(*testing.T) (map[string]string, error) { return map[string]string{"db_update": "1"}, nil },
+		listAllInfo: func(*testing.T, string) ([]map[string][]string, error) { return songs, nil },
+		listAll:     func(*testing.T, string) ([]string, error) { return []string{"a/1", "a/2"}, nil },
+		find:        func(*testing.T, string) ([]map[string][]string, error) { return nil, nil },
+	}
+	h, err := api.NewLibrarySongsHandler(mpd, songsHook, "")
+	if err != nil {
+		t.Fatalf("api.NewLibrarySongs() = %v, %v", h, err)
+	}
+	defer h.Close()
+	if err := h.Update(context.TODO()); err != nil {
+		t.Fatalf("handler.Update(context.TODO()) = %v", err)
+	}
+	recieveMsg(h.Changed())
+	// a/1 is replaced by a file which has older modified time
+	songs = []map[string][]string{
+		{"file": {"a/1"}, "Last-Modified": {"2023-01-01T00:00:00Z"}, "Title": {"foo"}},
+		{"file": {"a/2"}, "Last-Modified": {"2024-01-02T00:00:00Z"}},
+	}
+	// no database update
+	if err := h.Update(context.TODO()); err != nil {
+		t.Fatalf("handler.Update(context.TODO()) = %v", err)
+	}
+	if changed := recieveMsg(h.Changed()); changed {
+		t.Errorf("changed = %v; want false", changed)
+	}
+	mpd.stats = func(*testing.T) (map[string]string, error) { return map[string]string{"db_update": "2"}, nil }
+	if err := h.Update(context.TODO()); err != nil {
+		t.Fatalf("handler.Update(context.TODO()) = %v", err)
+	}
+	want := []map[string][]string{
+		{"file": {"a/1"}, "Last-Modified": {"2023-01-01T00:00:00Z"}, "Title": {"foo"}, randValue: {randValue}},
+		{"file": {"a/2"}, "Last-Modified": {"2024-01-02T00:00:00Z"}, randValue: {randValue}},
+	}
+	if cache := h.Cache(); !reflect.DeepEqual(cache, want) {
+		t.Errorf("got cache\n%v; want\n%v", cache, want)
+	}
+	if changed := recieveMsg(h.Changed()); !changed {
+		t.Errorf("changed = %v; want true", changed)
+	}
+}
+
+func TestLibrarySongsHandlerDelta(t *testing.T) {
+	songsHook, randValue := testSongsHook()
+	mpd := &mpdLibrarySongs{t: t}
+	h, err := api.NewLibrarySongsHandler(mpd, songsHook, "")
+	if err != nil {
+		t.Fatalf("api.NewLibrarySongs() = %v, %v", h, err)
+	}
+	etag := func() string {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Result().Header.Get("ETag")
+	}
+	update := func(songs ...map[string][]string) {
+		mpd.listAllInfo = func(*testing.T, string) ([]map[string][]string, error) { return songs, nil }
+		if err := h.Update(context.TODO()); err != nil {
+			t.Fatalf("handler.Update(context.TODO()) = %v; want <nil>", err)
+		}
+	}
+	update(map[string][]string{"file": {"a"}}, map[string][]string{"file": {"b"}})
+	v1 := etag()
+	update(map[string][]string{"file": {"a"}, "Title": {"A"}}, map[string][]string{"file": {"c"}})
+	v2 := etag()
+	update(map[string][]string{"file": {"a"}, "Title": {"A"}}, map[string][]string{"file": {"d"}})
+	for label, tt := range map[string]struct {
+		since  string
+		status int
+		want   string
+	}{
+		"v1": {
+			since:  v1,
+			status: http.StatusOK,
+			want:   fmt.Sprintf(`{"added":[{"%s":["%s"],"file":["d"]}],"changed":[{"%s":["%s"],"Title":["A"],"file":["a"]}],"removed":["b"]}`, randValue, randValue, randValue, randValue),
+		},
+		"v2": {
+			since:  v2,
+			status: http.StatusOK,
+			want:   fmt.Sprintf(`{"added":[{"%s":["%s"],"file":["d"]}],"changed":[],"removed":["c"]}`, randValue, randValue),
+		},
+		"latest": {
+			since:  etag(),
+			status: http.StatusOK,
+			want:   `{"added":[],"changed":[],"removed":[]}`,
+		},
+		"unknown": {
+			since:  `"0.0"`,
+			status: http.StatusGone,
+			want:   `{"error":"unknown or expired etag: 0.0"}`,
+		},
+	} {
+		t.Run(label, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/?since="+url.QueryEscape(tt.since), nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if status, got := w.Result().StatusCode, w.Body.String(); status != tt.status || got != tt.want {
+				t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", status, got, tt.status, tt.want)
+			}
+		})
+	}
+}
+
+func TestLibrarySongsHandlerRehook(t *testing.T) {
+	rating := "1"
+	songsHook := func(s []map[string][]string) []map[string][]string {
+		for i := range s {
+			s[i]["Rating"] = []string{rating}
+		}
+		return s
+	}
+	mpd := &mpdLibrarySongs{t: t}
+	h, err := api.NewLibrarySongsHandler(mpd, songsHook, "")
+	if err != nil {
+		t.Fatalf("api.NewLibrarySongs() = %v, %v", h, err)
+	}
+	defer h.Close()
+	if err := h.Rehook(); err != nil {
+		t.Errorf("handler.Rehook() before Update = %v; want <nil>", err)
+	}
+	if changed := recieveMsg(h.Changed()); changed {
+		t.Errorf("changed = %v; want false", changed)
+	}
+	mpd.listAllInfo = func(*testing.T, string) ([]map[string][]string, error) {
+		return []map[string][]string{{"file": {"a"}}}, nil
+	}
+	if err := h.Update(context.TODO()); err != nil {
+		t.Fatalf("handler.Update(context.TODO()) = %v; want <nil>", err)
+	}
+	recieveMsg(h.Changed())
+	old := h.Cache()
+	rating = "2"
+	if err := h.Rehook(); err != nil {
+		t.Errorf("handler.Rehook() = %v; want <nil>", err)
+	}
+	if changed := recieveMsg(h.Changed()); !changed {
+		t.Errorf("changed = %v; want true", changed)
+	}
+	if got, want := h.Cache(), []map[string][]string{{"file": {"a"}, "Rating": {"2"}}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got cache %v; want %v", got, want)
+	}
+	if got, want := old, []map[string][]string{{"file": {"a"}, "Rating": {"1"}}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Rehook modifies old cache %v; want %v", got, want)
+	}
+}
+
+func TestLibrarySongsHandlerRehookSong(t *testing.T) {
+	rating := "1"
+	songsHook := func(s []map[string][]string) []map[string][]string {
+		for i := range s {
+			s[i]["Rating"] = []string{rating}
+		}
+		return s
+	}
+	mpd := &mpdLibrarySongs{t: t, listAllInfo: func(*testing.T, string) ([]map[string][]string, error) {
+		return []map[string][]string{{"file": {"a"}}, {"file": {"b"}}}, nil
+	}}
+	h, err := api.NewLibrarySongsHandler(mpd, songsHook, "")
+	if err != nil {
+		t.Fatalf("api.NewLibrarySongs() = %v, %v", h, err)
+	}
+	defer h.Close()
+	if err := h.Update(context.TODO()); err != nil {
+		t.Fatalf("handler.Update(context.TODO()) = %v; want <nil>", err)
+	}
+	recieveMsg(h.Changed())
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	etag := w.Result().Header.Get("ETag")
+	rating = "2"
+	if err := h.RehookSong("b"); err != nil {
+		t.Errorf("handler.RehookSong(b) = %v; want <nil>", err)
+	}
+	if changed := recieveMsg(h.Changed()); changed {
+		t.Errorf("changed = %v; want false", changed)
+	}
+	if got, want := h.Cache(), []map[string][]string{{"file": {"a"}, "Rating": {"1"}}, {"file": {"b"}, "Rating": {"2"}}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got cache %v; want %v", got, want)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/?since="+url.QueryEscape(etag), nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got, want := w.Body.String(), `{"added":[],"changed":[{"Rating":["2"],"file":["b"]}],"removed":[]}`; got != want {
+		t.Errorf("ServeHTTP got %s; want %s", got, want)
+	}
+}
+
+func TestLibrarySongsHandlerQuery(t *testing.T) {
+	mpd := &mpdLibrarySongs{t: t, listAllInfo: func(*testing.T, string) ([]map[string][]string, error) {
+		return []map[string][]string{
+			{"file": {"c"}, "Album": {"baz"}, "Track": {"2"}, "Artist": {"foo"}},
+			{"file": {"a"}, "Album": {"qux"}, "Track": {"1"}, "Artist": {"bar", "baz"}},
+			{"file": {"b"}, "Album": {"baz"}, "Track": {"1"}, "Artist": {"bar"}},
+		}, nil
+	}}
+	h, err := api.NewLibrarySongsHandler(mpd, func(s []map[string][]string) []map[string][]string { return s }, "")
+	if err != nil {
+		t.Fatalf("api.NewLibrarySongs() = %v, %v", h, err)
+	}
+	if err := h.Update(context.TODO()); err != nil {
+		t.Fatalf("handler.Update(context.TODO()) = %v; want <nil>", err)
+	}
+	for label, tt := range map[string]struct {
+		query  url.Values
+		status int
+		total  string
+		want   string
+	}{
+		"fields": {
+			query:  url.Values{"fields": {"file"}},
+			status: http.StatusOK,
+			total:  "3",
+			want:   `[{"file":["c"]},{"file":["a"]},{"file":["b"]}]`,
+		},
+		"sort": {
+			query:  url.Values{"fields": {"file,Track"}, "sort": {"Album,Track"}},
+			status: http.StatusOK,
+			total:  "3",
+			want:   `[{"Track":["1"],"file":["b"]},{"Track":["2"],"file":["c"]},{"Track":["1"],"file":["a"]}]`,
+		},
+		"offset and limit": {
+			query:  url.Values{"fields": {"file"}, "sort": {"file"}, "offset": {"1"}, "limit": {"1"}},
+			status: http.StatusOK,
+			total:  "3",
+			want:   `[{"file":["b"]}]`,
+		},
+		"sort/all fields": {
+			query:  url.Values{"sort": {"file"}, "limit": {"1"}},
+			status: http.StatusOK,
+			total:  "3",
+			want:   `[{"Album":["qux"],"Artist":["bar","baz"],"DiscNumber":["0001"],"Length":["00:00"],"Track":["1"],"TrackNumber":["0001"],"file":["a"]}]`,
+		},
+		"sort/multiple values": {
+			query:  url.Values{"fields": {"file"}, "sort": {"Artist,file"}},
+			status: http.StatusOK,
+			total:  "3",
+			want:   `[{"file":["a"]},{"file":["b"]},{"file":["c"]}]`,
+		},
+		"offset overflow": {
+			query:  url.Values{"offset": {"4"}},
+			status: http.StatusOK,
+			total:  "3",
+			want:   `[]`,
+		},
+		"error/offset": {
+			query:  url.Values{"offset": {"-1"}},
+			status: http.StatusBadRequest,
+			want:   `{"error":"invalid offset: -1"}`,
+		},
+		"error/limit": {
+			query:  url.Values{"limit": {"foo"}},
+			status: http.StatusBadRequest,
+			want:   `{"error":"invalid limit: foo"}`,
+		},
+		"error/since": {
+			query:  url.Values{"limit": {"1"}, "since": {"foo"}},
+			status: http.StatusBadRequest,
+			want:   `{"error":"since can not be used with offset, limit, fields or sort"}`,
+		},
+	} {
+		t.Run(label, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/?"+tt.query.Encode(), nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if status, got := w.Result().StatusCode, w.Body.String(); status != tt.status || got != tt.want {
+				t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", status, got, tt.status, tt.want)
+			}
+			if total := w.Result().Header.Get("X-Total-Count"); total != tt.total {
+				t.Errorf("got X-Total-Count %q; want %q", total, tt.total)
+			}
+		})
+	}
+	t.Run("sort after update", func(t *testing.T) {
+		mpd.listAllInfo = func(*testing.T, string) ([]map[string][]string, error) {
+			return []map[string][]string{{"file": {"d"}, "Album": {"baz"}, "Track": {"3"}}}, nil
+		}
+		if err := h.Update(context.TODO()); err != nil {
+			t.Fatalf("handler.Update(context.TODO()) = %v; want <nil>", err)
+		}
+		r := httptest.NewRequest(http.MethodGet, "/?fields=file&sort=Album,Track", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got, want := w.Body.String(), `[{"file":["d"]}]`; got != want {
+			t.Errorf("ServeHTTP got %s; want %s", got, want)
+		}
+	})
+}
+
+func TestLibrarySongsHandlerSnapshot(t *testing.T) {
+	songsHook, randValue := testSongsHook()
+	dir := t.TempDir()
+	stats := func(dbUpdate string) func(*testing.T) (map[string]string, error) {
+		return func(*testing.T) (map[string]string, error) { return map[string]string{"db_update": dbUpdate}, nil }
+	}
+	get := func(h *api.LibrarySongsHandler) string {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+	mpd := &mpdLibrarySongs{t: t, stats: stats("1"), listAllInfo: func(*testing.T, string) ([]map[string][]string, error) {
+		return []map[string][]string{{"file": {"/foo/bar.mp3"}}}, nil
+	}}
+	h, err := api.NewLibrarySongsHandler(mpd, songsHook, dir)
+	if err != nil {
+		t.Fatalf("api.NewLibrarySongs() = %v, %v", h, err)
+	}
+	if h.HasSnapshot() {
+		t.Errorf("HasSnapshot() = true; want false")
+	}
+	if err := h.Update(context.TODO()); err != nil {
+		t.Fatalf("handler.Update(context.TODO()) = %v; want <nil>", err)
+	}
+	h.Close()
+
+	want := fmt.Sprintf(`[{"%s":["%s"],"file":["/foo/bar.mp3"]}]`, randValue, randValue)
+	mpd = &mpdLibrarySongs{t: t, stats: stats("1")}
+	h, err = api.NewLibrarySongsHandler(mpd, songsHook, dir)
+	if err != nil {
+		t.Fatalf("api.NewLibrarySongs() = %v, %v", h, err)
+	}
+	defer h.Close()
+	if !h.HasSnapshot() {
+		t.Errorf("HasSnapshot() = false; want true")
+	}
+	if got := get(h); got != want {
+		t.Errorf("ServeHTTP got %s; want %s", got, want)
+	}
+	// db_update is not changed; does not call ListAllInfo
+	if err := h.Revalidate(context.TODO()); err != nil {
+		t.Errorf("handler.Revalidate(context.TODO()) = %v; want <nil>", err)
+	}
+	mpd.stats = stats("2")
+	mpd.listAllInfo = func(*testing.T, string) ([]map[string][]string, error) {
+		return []map[string][]string{}, nil
+	}
+	if err := h.Revalidate(context.TODO()); err != nil {
+		t.Errorf("handler.Revalidate(context.TODO()) = %v; want <nil>", err)
+	}
+	if got := get(h); got != "[]" {
+		t.Errorf("ServeHTTP got %s; want %s", got, "[]")
+	}
+}
+
 func testSongsHook() (func(s []map[string][]string) []map[string][]string, string) {
 	f, key := testSongHook()
 	return func(s []map[string][]string) []map[string][]string {
@@ -130,6 +555,18 @@ func testSongsHook() (func(s []map[string][]string) []map[string][]string, strin
 type mpdLibrarySongs struct {
 	t           *testing.T
 	listAllInfo func(*testing.T, string) ([]map[string][]string, error)
+	listAll     func(*testing.T, string) ([]string, error)
+	lsInfo      func(*testing.T, string) ([]map[string][]string, error)
+	find        func(*testing.T, string) ([]map[string][]string, error)
+	stats       func(*testing.T) (map[string]string, error)
+}
+
+func (m *mpdLibrarySongs) Stats(ctx context.Context) (map[string]string, error) {
+	m.t.Helper()
+	if m.stats == nil {
+		return map[string]string{}, nil
+	}
+	return m.stats(m.t)
 }
 
 func (m *mpdLibrarySongs) ListAllInfo(ctx context.Context, s string) ([]map[string][]string, error) {
@@ -139,3 +576,27 @@ func (m *mpdLibrarySongs) ListAllInfo(ctx context.Context, s string) ([]map[stri
 	}
 	return m.listAllInfo(m.t, s)
 }
+
+func (m *mpdLibrarySongs) ListAll(ctx context.Context, s string) ([]string, error) {
+	m.t.Helper()
+	if m.listAll == nil {
+		m.t.Fatal("no ListAll mock function")
+	}
+	return m.listAll(m.t, s)
+}
+
+func (m *mpdLibrarySongs) LsInfo(ctx context.Context, s string) ([]map[string][]string, error) {
+	m.t.Helper()
+	if m.lsInfo == nil {
+		m.t.Fatal("no LsInfo mock function")
+	}
+	return m.lsInfo(m.t, s)
+}
+
+func (m *mpdLibrarySongs) Find(ctx context.Context, s string) ([]map[string][]string, error) {
+	m.t.Helper()
+	if m.find == nil {
+		m.t.Fatal("no Find mock function")
+	}
+	return m.find(m.t, s)
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// LyricsProvider represents song lyrics api.
+// Lyrics returns raw lyrics text in plain or LRC format.
+type LyricsProvider interface {
+	Lyrics(context.Context, map[string][]string) (string, bool, error)
+}
+
+type httpLyrics struct {
+	File   string            `json:"file,omitempty"`
+	Synced bool              `json:"synced"`
+	Lines  []*httpLyricsLine `json:"lines"`
+}
+
+type httpLyricsLine struct {
+	Time *float64 `json:"time,omitempty"`
+	Text string   `json:"text"`
+}
+
+var (
+	lrcTimeTag = regexp.MustCompile(`^\[(\d+):(\d{1,2}(?:[.:]\d{1,3})?)\]`)
+	lrcIDTag   = regexp.MustCompile(`^\[([a-zA-Z#]+):([^\]]*)\]`)
+	lrcWordTag = regexp.MustCompile(`<\d+:\d{1,2}(?:[.:]\d{1,3})?>`)
+)
+
+// LyricsHandler provides current song lyrics.
+type LyricsHandler struct {
+	providers []LyricsProvider
+	cache     *cache
+	file      *string // song file of cached lyrics; nil if not cached
+	mu        sync.Mutex
+}
+
+// NewLyricsHandler initilize lyrics cache with lyrics providers.
+func NewLyricsHandler(providers []LyricsProvider) (*LyricsHandler, error) {
+	c, err := newCache(&httpLyrics{Lines: []*httpLyricsLine{}})
+	if err != nil {
+		return nil, err
+	}
+	return &LyricsHandler{
+		providers: providers,
+		cache:     c,
+	}, nil
+}
+
+// Update updates lyrics if song file is changed.
+// Update uses the first lyrics found in providers order.
+func (a *LyricsHandler) Update(ctx context.Context, song map[string][]string) error {
+	var file string
+	if f := song["file"]; len(f) != 0 {
+		file = f[0]
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file != nil && *a.file == file {
+		return nil
+	}
+	ret := &httpLyrics{File: file, Lines: []*httpLyricsLine{}}
+	var errs []error
+	if len(file) != 0 {
+		for _, p := range a.providers {
+			text, ok, err := p.Lyrics(ctx, song)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if ok {
+				ret.Synced, ret.Lines = parseLyrics(text)
+				break
+			}
+		}
+	}
+	if _, err := a.cache.SetIfModified(ret); err != nil {
+		return err
+	}
+	if len(errs) != 0 {
+		// retry on next update
+		a.file = nil
+		return errors.Join(errs...)
+	}
+	a.file = &file
+	return nil
+}
+
+// parseLyrics parses plain text or LRC format lyrics.
+// Synced lines are sorted by time; lines without time tag are dropped if
+// lyrics has any time tag.
+func parseLyrics(text string) (bool, []*httpLyricsLine) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	plain := []*httpLyricsLine{}
+	synced := []*httpLyricsLine{}
+	var offset float64
+	for _, line := range strings.Split(text, "\n") {
+		var times []float64
+		meta := false
+		for {
+			if m := lrcTimeTag.FindStringSubmatch(line); m != nil {
+				min, _ := strconv.ParseFloat(m[1], 64)
+				sec, _ := strconv.ParseFloat(strings.Replace(m[2], ":", ".", 1), 64)
+				times = append(times, min*60+sec)
+				line = line[len(m[0]):]
+				continue
+			}
+			if m := lrcIDTag.FindStringSubmatch(line); m != nil && len(times) == 0 {
+				if strings.EqualFold(m[1], "offset") {
+					if ms, err := strconv.ParseFloat(strings.TrimSpace(m[2]), 64); err == nil {
+						offset = ms / 1000
+					}
+				}
+				meta = true
+				line = line[len(m[0]):]
+				continue
+			}
+			break
+		}
+		line = strings.TrimSpace(lrcWordTag.ReplaceAllString(line, ""))
+		if len(times) == 0 {
+			if !meta {
+				plain = append(plain, &httpLyricsLine{Text: line})
+			}
+			continue
+		}
+		for i := range times {
+			synced = append(synced, &httpLyricsLine{Time: &times[i], Text: line})
+		}
+	}
+	if len(synced) == 0 {
+		// trim blank lines at both ends
+		for len(plain) != 0 && plain[0].Text == "" {
+			plain = plain[1:]
+		}
+		for len(plain) != 0 && plain[len(plain)-1].Text == "" {
+			plain = plain[:len(plain)-1]
+		}
+		return false, plain
+	}
+	for i := range synced {
+		// positive offset shows lyrics sooner
+		t := *synced[i].Time - offset
+		if t < 0 {
+			t = 0
+		}
+		synced[i].Time = &t
+	}
+	sort.SliceStable(synced, func(i, j int) bool { return *synced[i].Time < *synced[j].Time })
+	return true, synced
+}
+
+// ServeHTTP responses current song lyrics as json format.
+func (a *LyricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.cache.ServeHTTP(w, r)
+}
+
+// Changed returns lyrics update event chan.
+func (a *LyricsHandler) Changed() <-chan struct{} {
+	return a.cache.Changed()
+}
+
+// Close closes update event chan.
+func (a *LyricsHandler) Close() {
+	a.cache.Close()
+}
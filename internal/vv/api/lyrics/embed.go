@@ -0,0 +1,51 @@
+package lyrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/meiraka/vv/internal/mpd"
+)
+
+// embedKeys are song comment keys for lyrics in priority order.
+var embedKeys = []string{"SYNCEDLYRICS", "LYRICS", "UNSYNCEDLYRICS", "USLT"}
+
+// Embed provides song lyrics from embedded tags via mpd readcomments api.
+type Embed struct {
+	client *mpd.Client
+}
+
+// NewEmbed creates Embed.
+func NewEmbed(client *mpd.Client) *Embed {
+	return &Embed{client: client}
+}
+
+// Lyrics returns lyrics text from song comments.
+func (e *Embed) Lyrics(ctx context.Context, song map[string][]string) (string, bool, error) {
+	file, ok := song["file"]
+	if !ok || len(file) != 1 {
+		return "", false, nil
+	}
+	comments, err := e.client.ReadComments(ctx, file[0])
+	if err != nil {
+		// song is not a local file or mpd does not support readcomments
+		if errors.Is(err, mpd.ErrNoExist) || errors.Is(err, mpd.ErrUnknown) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	upper := make(map[string][]string, len(comments))
+	for k, v := range comments {
+		k = strings.ToUpper(k)
+		upper[k] = append(upper[k], v...)
+	}
+	for _, k := range embedKeys {
+		for _, v := range upper[k] {
+			if len(v) != 0 {
+				return v, true, nil
+			}
+		}
+	}
+	return "", false, nil
+}
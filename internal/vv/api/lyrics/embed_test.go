@@ -0,0 +1,65 @@
+package lyrics
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/meiraka/vv/internal/mpd"
+	"github.com/meiraka/vv/internal/mpd/mpdtest"
+)
+
+const testTimeout = time.Second
+
+func TestEmbed(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ts := mpdtest.NewServer("OK MPD 0.21")
+	defer ts.Close()
+	c, err := mpd.Dial("tcp", ts.URL, &mpd.ClientOptions{Timeout: testTimeout})
+	if err != nil {
+		t.Fatalf("dial got err: %v", err)
+	}
+	defer c.Close(ctx)
+	e := NewEmbed(c)
+	for _, tt := range []struct {
+		song    map[string][]string
+		wr      *mpdtest.WR
+		want    string
+		wantOK  bool
+		wantErr bool
+	}{{
+		song:   map[string][]string{"file": {"foo.flac"}},
+		wr:     &mpdtest.WR{Read: "readcomments \"foo.flac\"\n", Write: "lyrics: foo\nOK\n"},
+		want:   "foo",
+		wantOK: true,
+	}, {
+		song:   map[string][]string{"file": {"foo.flac"}},
+		wr:     &mpdtest.WR{Read: "readcomments \"foo.flac\"\n", Write: "UNSYNCEDLYRICS: foo\nSYNCEDLYRICS: [00:01.00]bar\nOK\n"},
+		want:   "[00:01.00]bar",
+		wantOK: true,
+	}, {
+		song: map[string][]string{"file": {"foo.flac"}},
+		wr:   &mpdtest.WR{Read: "readcomments \"foo.flac\"\n", Write: "TITLE: foo\nOK\n"},
+	}, {
+		song: map[string][]string{"file": {"http://example.com/stream"}},
+		wr:   &mpdtest.WR{Read: "readcomments \"http://example.com/stream\"\n", Write: "ACK [50@0] {readcomments} No such file or directory\n"},
+	}, {
+		song:    map[string][]string{"file": {"foo.flac"}},
+		wr:      &mpdtest.WR{Read: "readcomments \"foo.flac\"\n", Write: "ACK [4@0] {readcomments} you don't have permission for \"readcomments\"\n"},
+		wantErr: true,
+	}, {
+		song: map[string][]string{},
+	}} {
+		t.Run(fmt.Sprint(tt.song, tt.wr), func(t *testing.T) {
+			if tt.wr != nil {
+				go ts.Expect(ctx, tt.wr)
+			}
+			got, ok, err := e.Lyrics(ctx, tt.song)
+			if got != tt.want || ok != tt.wantOK || (err != nil) != tt.wantErr {
+				t.Errorf("Lyrics(%v) = %q, %v, %v; want %q, %v, error %v", tt.song, got, ok, err, tt.want, tt.wantOK, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package lyrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// utf8BOM is the byte order mark written by some lyrics editors.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Local provides song lyrics from sidecar files in music directory.
+type Local struct {
+	musicDirectory string
+	exts           []string
+}
+
+// NewLocal creates Local. exts are sidecar file extensions in priority order
+// like ".lrc", ".txt".
+func NewLocal(dir string, exts []string) (*Local, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Local{
+		musicDirectory: dir,
+		exts:           exts,
+	}, nil
+}
+
+// Lyrics returns lyrics text from the sidecar file which has the same name as
+// the song file except for extension.
+func (l *Local) Lyrics(ctx context.Context, song map[string][]string) (string, bool, error) {
+	file, ok := song["file"]
+	if !ok || len(file) != 1 {
+		return "", false, nil
+	}
+	songPath := filepath.Join(l.musicDirectory, filepath.FromSlash(file[0]))
+	base := strings.TrimSuffix(songPath, filepath.Ext(songPath))
+	for _, ext := range l.exts {
+		b, err := os.ReadFile(base + ext)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return "", false, err
+		}
+		return string(bytes.TrimPrefix(b, utf8BOM)), true, nil
+	}
+	return "", false, nil
+}
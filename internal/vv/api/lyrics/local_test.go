@@ -0,0 +1,34 @@
+package lyrics
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	for _, tt := range []struct {
+		exts   []string
+		song   map[string][]string
+		want   string
+		wantOK bool
+	}{
+		{exts: []string{".lrc", ".txt"}, song: map[string][]string{"file": {"testdata/test.flac"}}, want: "[00:01.00]foo\n", wantOK: true},
+		{exts: []string{".txt", ".lrc"}, song: map[string][]string{"file": {"testdata/test.flac"}}, want: "bar\n", wantOK: true},
+		{exts: []string{".lrc", ".txt"}, song: map[string][]string{"file": {"testdata/other.mp3"}}, want: "baz\n", wantOK: true},
+		{exts: []string{".lrc", ".txt"}, song: map[string][]string{"file": {"testdata/bom/test.flac"}}, want: "qux\n", wantOK: true},
+		{exts: []string{".lrc", ".txt"}, song: map[string][]string{"file": {"notfound/test.flac"}}},
+		{exts: []string{".lrc", ".txt"}, song: map[string][]string{}},
+	} {
+		t.Run(fmt.Sprint(tt.exts, tt.song), func(t *testing.T) {
+			l, err := NewLocal(".", tt.exts)
+			if err != nil {
+				t.Fatalf("failed to initialize lyrics.Local: %v", err)
+			}
+			got, ok, err := l.Lyrics(context.TODO(), tt.song)
+			if got != tt.want || ok != tt.wantOK || err != nil {
+				t.Errorf("Lyrics(%v) = %q, %v, %v; want %q, %v, nil", tt.song, got, ok, err, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/meiraka/vv/internal/vv/api"
+)
+
+type mpdLyricsProvider struct {
+	t      *testing.T
+	lyrics func(map[string][]string) (string, bool, error)
+}
+
+func (m *mpdLyricsProvider) Lyrics(ctx context.Context, song map[string][]string) (string, bool, error) {
+	m.t.Helper()
+	if m.lyrics == nil {
+		m.t.Fatal("no Lyrics mock function")
+	}
+	return m.lyrics(song)
+}
+
+func TestLyricsHandler(t *testing.T) {
+	song := map[string][]string{"file": {"foo.flac"}}
+	for _, tt := range []struct {
+		label     string
+		providers []func(map[string][]string) (string, bool, error)
+		song      map[string][]string
+		err       bool
+		want      string
+	}{{
+		label: "no song",
+		song:  map[string][]string{},
+		want:  `{"synced":false,"lines":[]}`,
+	}, {
+		label:     "not found",
+		providers: []func(map[string][]string) (string, bool, error){func(map[string][]string) (string, bool, error) { return "", false, nil }},
+		song:      song,
+		want:      `{"file":"foo.flac","synced":false,"lines":[]}`,
+	}, {
+		label:     "plain",
+		providers: []func(map[string][]string) (string, bool, error){func(map[string][]string) (string, bool, error) { return "\r\nfoo\r\n\r\nbar\r\n", true, nil }},
+		song:      song,
+		want:      `{"file":"foo.flac","synced":false,"lines":[{"text":"foo"},{"text":""},{"text":"bar"}]}`,
+	}, {
+		label: "synced",
+		providers: []func(map[string][]string) (string, bool, error){func(map[string][]string) (string, bool, error) {
+			return "[ti:title]\n[ar:artist]\n[00:01.50]foo\n[00:10.00][01:02.345]<00:10.00>bar <00:11.00>baz\n[00:05:25]qux\nignored\n", true, nil
+		}},
+		song: song,
+		want: `{"file":"foo.flac","synced":true,"lines":[{"time":1.5,"text":"foo"},{"time":5.25,"text":"qux"},{"time":10,"text":"bar baz"},{"time":62.345,"text":"bar baz"}]}`,
+	}, {
+		label: "offset",
+		providers: []func(map[string][]string) (string, bool, error){func(map[string][]string) (string, bool, error) {
+			return "[offset:+500]\n[00:00.20]foo\n[00:01.50]bar\n", true, nil
+		}},
+		song: song,
+		want: `{"file":"foo.flac","synced":true,"lines":[{"time":0,"text":"foo"},{"time":1,"text":"bar"}]}`,
+	}, {
+		label: "fallback",
+		providers: []func(map[string][]string) (string, bool, error){
+			func(map[string][]string) (string, bool, error) { return "", false, nil },
+			func(map[string][]string) (string, bool, error) { return "foo", true, nil },
+			func(map[string][]string) (string, bool, error) { return "bar", true, nil },
+		},
+		song: song,
+		want: `{"file":"foo.flac","synced":false,"lines":[{"text":"foo"}]}`,
+	}, {
+		label: "error",
+		providers: []func(map[string][]string) (string, bool, error){
+			func(map[string][]string) (string, bool, error) { return "", false, errors.New("foo") },
+			func(map[string][]string) (string, bool, error) { return "bar", true, nil },
+		},
+		song: song,
+		err:  true,
+		want: `{"file":"foo.flac","synced":false,"lines":[{"text":"bar"}]}`,
+	}} {
+		t.Run(tt.label, func(t *testing.T) {
+			providers := make([]api.LyricsProvider, len(tt.providers))
+			for i := range tt.providers {
+				providers[i] = &mpdLyricsProvider{t: t, lyrics: tt.providers[i]}
+			}
+			h, err := api.NewLyricsHandler(providers)
+			if err != nil {
+				t.Fatalf("NewLyricsHandler() = %v", err)
+			}
+			defer h.Close()
+			if err := h.Update(context.TODO(), tt.song); (err != nil) != tt.err {
+				t.Errorf("Update() = %v; want error %v", err, tt.err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if got, _ := io.ReadAll(w.Result().Body); string(got) != tt.want || w.Result().StatusCode != http.StatusOK {
+				t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", w.Result().StatusCode, got, http.StatusOK, tt.want)
+			}
+		})
+	}
+	t.Run("same file", func(t *testing.T) {
+		calls := 0
+		h, err := api.NewLyricsHandler([]api.LyricsProvider{&mpdLyricsProvider{t: t, lyrics: func(map[string][]string) (string, bool, error) {
+			calls++
+			return "foo", true, nil
+		}}})
+		if err != nil {
+			t.Fatalf("NewLyricsHandler() = %v", err)
+		}
+		defer h.Close()
+		for i := 0; i < 2; i++ {
+			if err := h.Update(context.TODO(), song); err != nil {
+				t.Errorf("Update() = %v", err)
+			}
+		}
+		if calls != 1 {
+			t.Errorf("got %d Lyrics calls; want 1", calls)
+		}
+	})
+}
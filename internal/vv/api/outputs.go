@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -16,6 +17,7 @@ type httpOutput struct {
 	Name       string               `json:"name"`
 	Plugin     string               `json:"plugin,omitempty"`
 	Enabled    *bool                `json:"enabled"`
+	Partition  string               `json:"partition,omitempty"`
 	Attributes *httpOutputAttrbutes `json:"attributes,omitempty"`
 	Stream     string               `json:"stream,omitempty"`
 }
@@ -30,23 +32,33 @@ type MPDOutputs interface {
 	DisableOutput(context.Context, string) error
 	OutputSet(context.Context, string, string, string) error
 	Outputs(context.Context) ([]*mpd.Output, error)
+	MoveOutput(context.Context, string) error
+	ListPartitions(context.Context) ([]string, error)
+	PartitionOutputs(context.Context, string) ([]*mpd.Output, error)
 }
 
 type OutputsHandler struct {
-	mpd   MPDOutputs
-	cache *cache
-	proxy map[string]string
+	mpd       MPDOutputs
+	cache     *cache
+	proxy     map[string]string
+	partition string
 }
 
-func NewOutputsHandler(mpd MPDOutputs, proxy map[string]string) (*OutputsHandler, error) {
+// NewOutputsHandler initilize outputs cache with mpd connection.
+// partition is the mpd partition name of mpd connection; "default" if empty.
+func NewOutputsHandler(mpd MPDOutputs, proxy map[string]string, partition string) (*OutputsHandler, error) {
 	c, err := newCache(map[string]*httpOutput{})
 	if err != nil {
 		return nil, err
 	}
+	if len(partition) == 0 {
+		partition = defaultPartition
+	}
 	return &OutputsHandler{
-		mpd:   mpd,
-		cache: c,
-		proxy: proxy,
+		mpd:       mpd,
+		cache:     c,
+		proxy:     proxy,
+		partition: partition,
 	}, nil
 }
 
@@ -64,6 +76,26 @@ func (a *OutputsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	now := time.Now().UTC()
 	changed := false
 	for k, v := range req {
+		if len(v.Partition) != 0 {
+			if v.Partition != a.partition {
+				writeHTTPError(w, http.StatusBadRequest, fmt.Errorf("api: outputs can only be moved to current partition %q: got %q", a.partition, v.Partition))
+				return
+			}
+			name, err := a.outputName(ctx, k)
+			if err != nil {
+				writeHTTPError(w, http.StatusInternalServerError, err)
+				return
+			}
+			if len(name) == 0 {
+				writeHTTPError(w, http.StatusNotFound, fmt.Errorf("api: output not found: %s", k))
+				return
+			}
+			changed = true
+			if err := a.mpd.MoveOutput(ctx, name); err != nil {
+				writeHTTPError(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
 		if v.Enabled != nil {
 			var err error
 			changed = true
@@ -109,11 +141,29 @@ func (a *OutputsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// outputName returns output name for mpd moveoutput command.
+func (a *OutputsHandler) outputName(ctx context.Context, id string) (string, error) {
+	l, err := a.mpd.Outputs(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range l {
+		if v.ID == id {
+			return v.Name, nil
+		}
+	}
+	return "", nil
+}
+
 func (a *OutputsHandler) Update(ctx context.Context) error {
 	l, err := a.mpd.Outputs(ctx)
 	if err != nil {
 		return err
 	}
+	partitions, err := a.partitions(ctx, l)
+	if err != nil {
+		return err
+	}
 	data := make(map[string]*httpOutput, len(l))
 	for _, v := range l {
 		var stream string
@@ -126,6 +176,11 @@ func (a *OutputsHandler) Update(ctx context.Context) error {
 			Enabled: &v.Enabled,
 			Stream:  stream,
 		}
+		if p, ok := partitions[v.Name]; ok {
+			output.Partition = p
+		} else {
+			output.Partition = a.partition
+		}
 		if v.Attributes != nil {
 			output.Attributes = &httpOutputAttrbutes{}
 			if dop, ok := v.Attributes["dop"]; ok {
@@ -145,6 +200,47 @@ func (a *OutputsHandler) Update(ctx context.Context) error {
 	return err
 }
 
+// partitions returns partition names by output name for outputs moved to
+// other partitions.
+func (a *OutputsHandler) partitions(ctx context.Context, l []*mpd.Output) (map[string]string, error) {
+	ret := map[string]string{}
+	// mpd shows outputs moved to other partitions as "dummy" plugin
+	dummy := false
+	for _, v := range l {
+		if v.Plugin == "dummy" {
+			dummy = true
+			break
+		}
+	}
+	if !dummy {
+		return ret, nil
+	}
+	partitions, err := a.mpd.ListPartitions(ctx)
+	if err != nil {
+		// skip command error to support mpd-0.21 or earlier
+		var perr *mpd.CommandError
+		if errors.As(err, &perr) {
+			return ret, nil
+		}
+		return nil, err
+	}
+	for _, p := range partitions {
+		if p == a.partition {
+			continue
+		}
+		outputs, err := a.mpd.PartitionOutputs(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range outputs {
+			if v.Plugin != "dummy" {
+				ret[v.Name] = p
+			}
+		}
+	}
+	return ret, nil
+}
+
 // Changed returns outputs update event chan.
 func (a *OutputsHandler) Changed() <-chan struct{} {
 	return a.cache.Changed()
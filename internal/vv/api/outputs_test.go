@@ -15,11 +15,13 @@ import (
 func TestOutputsHandlerGET(t *testing.T) {
 	proxy := map[string]string{"Ogg Stream": "localhost:8080/"}
 	for label, tt := range map[string][]struct {
-		label   string
-		outputs func() ([]*mpd.Output, error)
-		err     error
-		want    string
-		changed bool
+		label            string
+		outputs          func() ([]*mpd.Output, error)
+		listPartitions   func() ([]string, error)
+		partitionOutputs func(*testing.T, string) ([]*mpd.Output, error)
+		err              error
+		want             string
+		changed          bool
 	}{
 		"ok": {{
 			label:   "empty",
@@ -35,7 +37,7 @@ func TestOutputsHandlerGET(t *testing.T) {
 					Enabled: true,
 				}}, nil
 			},
-			want:    `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":true}}`,
+			want:    `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":true,"partition":"default"}}`,
 			changed: true,
 		}, {
 			label:   "remove",
@@ -53,7 +55,7 @@ func TestOutputsHandlerGET(t *testing.T) {
 					Attributes: map[string]string{"dop": "0"},
 				}}, nil
 			},
-			want:    `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":true,"attributes":{"dop":false}}}`,
+			want:    `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":true,"partition":"default","attributes":{"dop":false}}}`,
 			changed: true,
 		}},
 		"ok/allowed formats": {{
@@ -66,7 +68,7 @@ func TestOutputsHandlerGET(t *testing.T) {
 					Attributes: map[string]string{"allowed_formats": "96000:16:* 192000:24:* dsd64:=dop *:dsd:"},
 				}}, nil
 			},
-			want:    `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":true,"attributes":{"allowed_formats":["96000:16:*","192000:24:*","dsd64:=dop","*:dsd:"]}}}`,
+			want:    `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":true,"partition":"default","attributes":{"allowed_formats":["96000:16:*","192000:24:*","dsd64:=dop","*:dsd:"]}}}`,
 			changed: true,
 		}},
 		"ok/stream": {{
@@ -84,7 +86,50 @@ func TestOutputsHandlerGET(t *testing.T) {
 					Enabled: true,
 				}}, nil
 			},
-			want:    `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":true,"attributes":{"dop":false}},"1":{"name":"Ogg Stream","plugin":"http","enabled":true,"stream":"/api/music/outputs/stream?name=Ogg+Stream"}}`,
+			want:    `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":true,"partition":"default","attributes":{"dop":false}},"1":{"name":"Ogg Stream","plugin":"http","enabled":true,"partition":"default","stream":"/api/music/outputs/stream?name=Ogg+Stream"}}`,
+			changed: true,
+		}},
+		"ok/partition": {{
+			outputs: func() ([]*mpd.Output, error) {
+				return []*mpd.Output{{
+					ID:      "0",
+					Name:    "My ALSA Device",
+					Plugin:  "alsa",
+					Enabled: true,
+				}, {
+					ID:      "1",
+					Name:    "My Pulse Device",
+					Plugin:  "dummy",
+					Enabled: false,
+				}, {
+					ID:      "2",
+					Name:    "My Null Device",
+					Plugin:  "dummy",
+					Enabled: false,
+				}}, nil
+			},
+			listPartitions: func() ([]string, error) { return []string{"default", "zone1"}, nil },
+			partitionOutputs: func(t *testing.T, p string) ([]*mpd.Output, error) {
+				if p != "zone1" {
+					t.Errorf("called mpd.PartitionOutputs(ctx, %q); want mpd.PartitionOutputs(ctx, %q)", p, "zone1")
+				}
+				return []*mpd.Output{
+					{ID: "0", Name: "My ALSA Device", Plugin: "dummy"},
+					{ID: "1", Name: "My Pulse Device", Plugin: "pulse", Enabled: true},
+					{ID: "2", Name: "My Null Device", Plugin: "dummy"},
+				}, nil
+			},
+			want:    `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":true,"partition":"default"},"1":{"name":"My Pulse Device","plugin":"dummy","enabled":false,"partition":"zone1"},"2":{"name":"My Null Device","plugin":"dummy","enabled":false,"partition":"default"}}`,
+			changed: true,
+		}},
+		"ok/partition(mpd-0.21)": {{
+			outputs: func() ([]*mpd.Output, error) {
+				return []*mpd.Output{{ID: "0", Name: "My Null Device", Plugin: "dummy"}}, nil
+			},
+			listPartitions: func() ([]string, error) {
+				return nil, &mpd.CommandError{ID: 5, Index: 0, Command: "listpartitions", Message: "unknown command \"listpartitions\""}
+			},
+			want:    `{"0":{"name":"My Null Device","plugin":"dummy","enabled":false,"partition":"default"}}`,
 			changed: true,
 		}},
 		"error": {{
@@ -98,18 +143,18 @@ func TestOutputsHandlerGET(t *testing.T) {
 					Attributes: map[string]string{"dop": "0"},
 				}}, nil
 			},
-			want:    `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":true,"attributes":{"dop":false}}}`,
+			want:    `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":true,"partition":"default","attributes":{"dop":false}}}`,
 			changed: true,
 		}, {
 			label:   "error",
 			outputs: func() ([]*mpd.Output, error) { return nil, errTest },
 			err:     errTest,
-			want:    `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":true,"attributes":{"dop":false}}}`,
+			want:    `{"0":{"name":"My ALSA Device","plugin":"alsa","enabled":true,"partition":"default","attributes":{"dop":false}}}`,
 		}},
 	} {
 		t.Run(label, func(t *testing.T) {
 			mpd := &mpdOutputs{t: t}
-			h, err := api.NewOutputsHandler(mpd, proxy)
+			h, err := api.NewOutputsHandler(mpd, proxy, "")
 			if err != nil {
 				t.Fatalf("api.NewOutputsHandler(mpd) = %v", err)
 			}
@@ -118,6 +163,8 @@ func TestOutputsHandlerGET(t *testing.T) {
 				f := func(t *testing.T) {
 					mpd.t = t
 					mpd.outputs = tt[i].outputs
+					mpd.listPartitions = tt[i].listPartitions
+					mpd.partitionOutputs = tt[i].partitionOutputs
 					if err := h.Update(context.TODO()); !errors.Is(err, tt[i].err) {
 						t.Errorf("h.Update(context.TODO()) = %v; want %v", err, tt[i].err)
 					}
@@ -149,6 +196,8 @@ func TestOutputsHandlerPOST(t *testing.T) {
 		enableOutput  func(*testing.T, string) error
 		disableOutput func(*testing.T, string) error
 		outputSet     func(*testing.T, string, string, string) error
+		outputs       func() ([]*mpd.Output, error)
+		moveOutput    func(*testing.T, string) error
 	}{
 		`error/invalid json`: {
 			body:       `invalid json`,
@@ -244,10 +293,41 @@ func TestOutputsHandlerPOST(t *testing.T) {
 				return nil
 			},
 		},
+		`ok/{"partition":"default"}`: {
+			body:       `{"1":{"partition":"default"}}`,
+			wantStatus: http.StatusAccepted,
+			want:       `{}`,
+			outputs: func() ([]*mpd.Output, error) {
+				return []*mpd.Output{{ID: "0", Name: "My ALSA Device", Plugin: "alsa"}, {ID: "1", Name: "My HTTP Stream", Plugin: "dummy"}}, nil
+			},
+			moveOutput: mockStringFunc("mpd.MoveOutput(ctx, %q)", "My HTTP Stream", nil),
+		},
+		`error/{"partition":"zone1"}`: {
+			body:       `{"1":{"partition":"zone1"}}`,
+			wantStatus: http.StatusBadRequest,
+			want:       `{"error":"api: outputs can only be moved to current partition \"default\": got \"zone1\""}`,
+		},
+		`error/{"partition":"default"}(not found)`: {
+			body:       `{"2":{"partition":"default"}}`,
+			wantStatus: http.StatusNotFound,
+			want:       `{"error":"api: output not found: 2"}`,
+			outputs: func() ([]*mpd.Output, error) {
+				return []*mpd.Output{{ID: "0", Name: "My ALSA Device", Plugin: "alsa"}}, nil
+			},
+		},
+		`error/{"partition":"default"}`: {
+			body:       `{"1":{"partition":"default"}}`,
+			wantStatus: http.StatusInternalServerError,
+			want:       `{"error":"api_test: test error"}`,
+			outputs: func() ([]*mpd.Output, error) {
+				return []*mpd.Output{{ID: "1", Name: "My HTTP Stream", Plugin: "dummy"}}, nil
+			},
+			moveOutput: mockStringFunc("mpd.MoveOutput(ctx, %q)", "My HTTP Stream", errTest),
+		},
 	} {
 		t.Run(label, func(t *testing.T) {
-			mpd := &mpdOutputs{t: t, enableOutput: tt.enableOutput, disableOutput: tt.disableOutput, outputSet: tt.outputSet}
-			h, err := api.NewOutputsHandler(mpd, map[string]string{})
+			mpd := &mpdOutputs{t: t, enableOutput: tt.enableOutput, disableOutput: tt.disableOutput, outputSet: tt.outputSet, outputs: tt.outputs, moveOutput: tt.moveOutput}
+			h, err := api.NewOutputsHandler(mpd, map[string]string{}, "")
 			if err != nil {
 				t.Fatalf("api.NewOutputsHandler(mpd) = %v, %v", h, err)
 			}
@@ -264,11 +344,14 @@ func TestOutputsHandlerPOST(t *testing.T) {
 }
 
 type mpdOutputs struct {
-	t             *testing.T
-	enableOutput  func(*testing.T, string) error
-	disableOutput func(*testing.T, string) error
-	outputSet     func(*testing.T, string, string, string) error
-	outputs       func() ([]*mpd.Output, error)
+	t                *testing.T
+	enableOutput     func(*testing.T, string) error
+	disableOutput    func(*testing.T, string) error
+	outputSet        func(*testing.T, string, string, string) error
+	outputs          func() ([]*mpd.Output, error)
+	moveOutput       func(*testing.T, string) error
+	listPartitions   func() ([]string, error)
+	partitionOutputs func(*testing.T, string) ([]*mpd.Output, error)
 }
 
 func (m *mpdOutputs) EnableOutput(ctx context.Context, a string) error {
@@ -299,3 +382,24 @@ func (m *mpdOutputs) Outputs(context.Context) ([]*mpd.Output, error) {
 	}
 	return m.outputs()
 }
+func (m *mpdOutputs) MoveOutput(ctx context.Context, a string) error {
+	m.t.Helper()
+	if m.moveOutput == nil {
+		m.t.Fatal("no MoveOutput mock function")
+	}
+	return m.moveOutput(m.t, a)
+}
+func (m *mpdOutputs) ListPartitions(context.Context) ([]string, error) {
+	m.t.Helper()
+	if m.listPartitions == nil {
+		m.t.Fatal("no ListPartitions mock function")
+	}
+	return m.listPartitions()
+}
+func (m *mpdOutputs) PartitionOutputs(ctx context.Context, a string) ([]*mpd.Output, error) {
+	m.t.Helper()
+	if m.partitionOutputs == nil {
+		m.t.Fatal("no PartitionOutputs mock function")
+	}
+	return m.partitionOutputs(m.t, a)
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/meiraka/vv/internal/mpd"
+)
+
+// MPDPartitionRouter represents mpd api for PartitionRouter.
+type MPDPartitionRouter interface {
+	ListPartitions(context.Context) ([]string, error)
+}
+
+// PartitionRouter serves api handler of mpd partition given by partition
+// query, so that each client session can control its own partition.
+// Handlers of other partitions are created by newHandler at first request.
+// Handlers which implement interface{ Close() } are closed on removal.
+type PartitionRouter struct {
+	mpd        MPDPartitionRouter
+	partition  string
+	handler    http.Handler
+	newHandler func(string) (http.Handler, error)
+	handlers   map[string]*partitionRoute
+	closed     bool
+	mu         sync.Mutex
+}
+
+// partitionRoute is the handler of partition; done is closed after creation.
+type partitionRoute struct {
+	done    chan struct{}
+	handler http.Handler
+	status  int
+	err     error
+}
+
+// NewPartitionRouter initilize PartitionRouter with handler of partition.
+// partition is the mpd partition name of handler; "default" if empty.
+func NewPartitionRouter(mpd MPDPartitionRouter, partition string, handler http.Handler, newHandler func(string) (http.Handler, error)) *PartitionRouter {
+	if len(partition) == 0 {
+		partition = defaultPartition
+	}
+	return &PartitionRouter{
+		mpd:        mpd,
+		partition:  partition,
+		handler:    handler,
+		newHandler: newHandler,
+		handlers:   map[string]*partitionRoute{},
+	}
+}
+
+// ServeHTTP serves api of the partition given by partition query.
+// ServeHTTP serves api of default handler if partition query is empty.
+func (p *PartitionRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("partition")
+	if len(name) == 0 || name == p.partition {
+		p.handler.ServeHTTP(w, r)
+		return
+	}
+	h, status, err := p.get(r.Context(), name)
+	if err != nil {
+		writeHTTPError(w, status, err)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+// get returns handler of partition name and creates it if not exists.
+// Concurrent requests for the same partition wait for a single creation
+// without blocking requests for other partitions.
+func (p *PartitionRouter) get(ctx context.Context, name string) (http.Handler, int, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, http.StatusServiceUnavailable, errors.New("api: server is shutting down")
+	}
+	e, ok := p.handlers[name]
+	if !ok {
+		e = &partitionRoute{done: make(chan struct{})}
+		p.handlers[name] = e
+		p.mu.Unlock()
+		e.handler, e.status, e.err = p.create(ctx, name)
+		p.mu.Lock()
+		if e.err != nil && p.handlers[name] == e {
+			// retry on next request
+			delete(p.handlers, name)
+		}
+		close(e.done)
+	}
+	p.mu.Unlock()
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return nil, http.StatusServiceUnavailable, ctx.Err()
+	}
+	return e.handler, e.status, e.err
+}
+
+// create creates handler of partition name if the partition exists.
+func (p *PartitionRouter) create(ctx context.Context, name string) (http.Handler, int, error) {
+	l, err := p.mpd.ListPartitions(ctx)
+	if err != nil {
+		var perr *mpd.CommandError
+		if errors.As(err, &perr) {
+			return nil, http.StatusNotFound, fmt.Errorf("api: partition not found: %s", name)
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+	if !slices.Contains(l, name) {
+		return nil, http.StatusNotFound, fmt.Errorf("api: partition not found: %s", name)
+	}
+	h, err := p.newHandler(name)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return h, http.StatusOK, nil
+}
+
+// Update removes handlers of partitions which no longer exist.
+func (p *PartitionRouter) Update(ctx context.Context) error {
+	l, err := p.mpd.ListPartitions(ctx)
+	if err != nil {
+		var perr *mpd.CommandError
+		if errors.As(err, &perr) {
+			return nil
+		}
+		return err
+	}
+	p.mu.Lock()
+	var removed []*partitionRoute
+	for name, e := range p.handlers {
+		if !slices.Contains(l, name) {
+			removed = append(removed, e)
+			delete(p.handlers, name)
+		}
+	}
+	p.mu.Unlock()
+	closeRoutes(removed)
+	return nil
+}
+
+// Remove removes handler of partition name.
+func (p *PartitionRouter) Remove(name string) {
+	p.mu.Lock()
+	e, ok := p.handlers[name]
+	delete(p.handlers, name)
+	p.mu.Unlock()
+	if ok {
+		closeRoutes([]*partitionRoute{e})
+	}
+}
+
+// Close stops creating handlers of partitions and closes created handlers.
+func (p *PartitionRouter) Close() {
+	p.mu.Lock()
+	p.closed = true
+	removed := make([]*partitionRoute, 0, len(p.handlers))
+	for name, e := range p.handlers {
+		removed = append(removed, e)
+		delete(p.handlers, name)
+	}
+	p.mu.Unlock()
+	closeRoutes(removed)
+}
+
+func closeRoutes(routes []*partitionRoute) {
+	for _, e := range routes {
+		<-e.done
+		if c, ok := e.handler.(interface{ Close() }); ok {
+			c.Close()
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package api_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/meiraka/vv/internal/mpd"
+	"github.com/meiraka/vv/internal/vv/api"
+)
+
+func TestPartitionRouter(t *testing.T) {
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, name) })
+	}
+	m := &mpdPartitions{t: t}
+	created := []string{}
+	p := api.NewPartitionRouter(m, "", handler("default"), func(name string) (http.Handler, error) {
+		created = append(created, name)
+		if name == "broken" {
+			return nil, errTest
+		}
+		return handler(name), nil
+	})
+	for _, tt := range []struct {
+		query          string
+		listPartitions func(*testing.T) ([]string, error)
+		wantStatus     int
+		want           string
+	}{
+		{query: "", wantStatus: http.StatusOK, want: "default"},
+		{query: "?partition=default", wantStatus: http.StatusOK, want: "default"},
+		{
+			query:          "?partition=zone1",
+			listPartitions: func(*testing.T) ([]string, error) { return []string{"default", "zone1", "broken"}, nil },
+			wantStatus:     http.StatusOK, want: "zone1",
+		},
+		{query: "?partition=zone1", wantStatus: http.StatusOK, want: "zone1"},
+		{
+			query:          "?partition=zone2",
+			listPartitions: func(*testing.T) ([]string, error) { return []string{"default", "zone1", "broken"}, nil },
+			wantStatus:     http.StatusNotFound, want: `{"error":"api: partition not found: zone2"}`,
+		},
+		{
+			query: "?partition=zone2",
+			listPartitions: func(*testing.T) ([]string, error) {
+				return nil, &mpd.CommandError{ID: 5, Index: 0, Command: "", Message: `unknown command "listpartitions"`}
+			},
+			wantStatus: http.StatusNotFound, want: `{"error":"api: partition not found: zone2"}`,
+		},
+		{
+			query:          "?partition=zone2",
+			listPartitions: func(*testing.T) ([]string, error) { return nil, errTest },
+			wantStatus:     http.StatusInternalServerError, want: `{"error":"api_test: test error"}`,
+		},
+		{
+			query:          "?partition=broken",
+			listPartitions: func(*testing.T) ([]string, error) { return []string{"default", "zone1", "broken"}, nil },
+			wantStatus:     http.StatusInternalServerError, want: `{"error":"api_test: test error"}`,
+		},
+	} {
+		t.Run(tt.query, func(t *testing.T) {
+			m.t = t
+			m.listPartitions = tt.listPartitions
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/music"+tt.query, nil))
+			if status, got := w.Result().StatusCode, w.Body.String(); status != tt.wantStatus || got != tt.want {
+				t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", status, got, tt.wantStatus, tt.want)
+			}
+		})
+	}
+	if want := []string{"zone1", "broken"}; !reflect.DeepEqual(created, want) {
+		t.Errorf("created handlers for %v; want %v", created, want)
+	}
+	p.Close()
+	m.t = t
+	m.listPartitions = func(*testing.T) ([]string, error) { return []string{"default", "zone1", "zone3"}, nil }
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/music?partition=zone3", nil))
+	if status := w.Result().StatusCode; status != http.StatusServiceUnavailable {
+		t.Errorf("ServeHTTP after Close got %d; want %d", status, http.StatusServiceUnavailable)
+	}
+}
+
+type closableHandler struct {
+	http.Handler
+	closed bool
+}
+
+func (h *closableHandler) Close() { h.closed = true }
+
+func TestPartitionRouterRemove(t *testing.T) {
+	m := &mpdPartitions{t: t, listPartitions: func(*testing.T) ([]string, error) { return []string{"default", "zone1", "zone2"}, nil }}
+	handlers := map[string]*closableHandler{}
+	p := api.NewPartitionRouter(m, "", http.NotFoundHandler(), func(name string) (http.Handler, error) {
+		h := &closableHandler{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, name) })}
+		handlers[name] = h
+		return h, nil
+	})
+	for _, name := range []string{"zone1", "zone2"} {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/music?partition="+name, nil))
+		if got := w.Body.String(); got != name {
+			t.Fatalf("ServeHTTP got %s; want %s", got, name)
+		}
+	}
+	m.listPartitions = func(*testing.T) ([]string, error) { return []string{"default", "zone2"}, nil }
+	if err := p.Update(context.Background()); err != nil {
+		t.Errorf("Update() = %v; want <nil>", err)
+	}
+	if !handlers["zone1"].closed || handlers["zone2"].closed {
+		t.Errorf("after Update got closed zone1: %v, zone2: %v; want true, false", handlers["zone1"].closed, handlers["zone2"].closed)
+	}
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/music?partition=zone1", nil))
+	if status := w.Result().StatusCode; status != http.StatusNotFound {
+		t.Errorf("ServeHTTP removed partition got %d; want %d", status, http.StatusNotFound)
+	}
+	p.Remove("zone2")
+	if !handlers["zone2"].closed {
+		t.Errorf("after Remove got closed zone2: false; want true")
+	}
+	m.listPartitions = func(*testing.T) ([]string, error) { return []string{"default", "zone1"}, nil }
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/music?partition=zone1", nil))
+	if got := w.Body.String(); got != "zone1" || handlers["zone1"].closed {
+		t.Errorf("ServeHTTP re-created partition got %s, closed: %v; want zone1, false", got, handlers["zone1"].closed)
+	}
+	p.Close()
+	if !handlers["zone1"].closed {
+		t.Errorf("after Close got closed zone1: false; want true")
+	}
+}
+
+func TestPartitionRouterConcurrent(t *testing.T) {
+	m := &mpdPartitions{t: t, listPartitions: func(*testing.T) ([]string, error) { return []string{"default", "zone1", "zone2"}, nil }}
+	var mu sync.Mutex
+	created := map[string]int{}
+	release := make(chan struct{})
+	p := api.NewPartitionRouter(m, "", http.NotFoundHandler(), func(name string) (http.Handler, error) {
+		mu.Lock()
+		created[name]++
+		mu.Unlock()
+		if name == "zone1" {
+			<-release
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, name) }), nil
+	})
+	defer p.Close()
+	var wg sync.WaitGroup
+	got := make([]string, 4)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/music?partition=zone1", nil))
+			got[i] = w.Body.String()
+		}(i)
+	}
+	// zone2 is not blocked by creating zone1
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/music?partition=zone2", nil))
+	if got := w.Body.String(); got != "zone2" {
+		t.Errorf("ServeHTTP got %s; want zone2", got)
+	}
+	close(release)
+	wg.Wait()
+	if want := []string{"zone1", "zone1", "zone1", "zone1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ServeHTTP got %v; want %v", got, want)
+	}
+	if want := map[string]int{"zone1": 1, "zone2": 1}; !reflect.DeepEqual(created, want) {
+		t.Errorf("created handlers %v; want %v", created, want)
+	}
+}
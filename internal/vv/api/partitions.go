@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/meiraka/vv/internal/mpd"
+)
+
+// defaultPartition is the mpd partition name used if no partition is selected.
+const defaultPartition = "default"
+
+type httpPartition struct {
+	Current bool `json:"current"`
+}
+
+type httpPartitionRequest struct {
+	Name   string `json:"name"`
+	Create bool   `json:"create,omitempty"`
+	Remove bool   `json:"remove,omitempty"`
+}
+
+// MPDPartitions represents mpd api for partitions API.
+type MPDPartitions interface {
+	ListPartitions(context.Context) ([]string, error)
+	NewPartition(context.Context, string) error
+	DelPartition(context.Context, string) error
+}
+
+// PartitionsHandler provides mpd partition list and management api.
+type PartitionsHandler struct {
+	mpd       MPDPartitions
+	cache     *cache
+	partition string
+	logger    Logger
+}
+
+// NewPartitionsHandler initilize partition list cache with mpd connection.
+// partition is the mpd partition name of mpd connection; "default" if empty.
+func NewPartitionsHandler(mpd MPDPartitions, partition string, logger Logger) (*PartitionsHandler, error) {
+	c, err := newCache(map[string]*httpPartition{})
+	if err != nil {
+		return nil, err
+	}
+	if len(partition) == 0 {
+		partition = defaultPartition
+	}
+	return &PartitionsHandler{
+		mpd:       mpd,
+		cache:     c,
+		partition: partition,
+		logger:    logger,
+	}, nil
+}
+
+// Update updates partition list.
+func (a *PartitionsHandler) Update(ctx context.Context) error {
+	ret := map[string]*httpPartition{}
+	l, err := a.mpd.ListPartitions(ctx)
+	if err != nil {
+		// skip command error to support mpd-0.21 or earlier
+		var perr *mpd.CommandError
+		if errors.As(err, &perr) {
+			a.cache.SetIfModified(ret)
+			a.logger.Debugf("vv/api: partitions: %v", err)
+			return nil
+		}
+		return err
+	}
+	for _, name := range l {
+		ret[name] = &httpPartition{Current: name == a.partition}
+	}
+	_, err = a.cache.SetIfModified(ret)
+	return err
+}
+
+// ServeHTTP responses partition list as json format.
+func (a *PartitionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		a.cache.ServeHTTP(w, r)
+		return
+	}
+	var req httpPartitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Name == "" {
+		writeHTTPError(w, http.StatusBadRequest, errors.New("partition name is empty"))
+		return
+	}
+	if req.Remove && req.Name == a.partition {
+		writeHTTPError(w, http.StatusBadRequest, errors.New("current partition can not be removed"))
+		return
+	}
+	ctx := r.Context()
+	now := time.Now().UTC()
+	changed := false
+	if req.Create {
+		if err := a.mpd.NewPartition(ctx, req.Name); err != nil {
+			writeHTTPError(w, partitionErrorStatus(err), err)
+			return
+		}
+		changed = true
+	}
+	if req.Remove {
+		if err := a.mpd.DelPartition(ctx, req.Name); err != nil {
+			writeHTTPError(w, partitionErrorStatus(err), err)
+			return
+		}
+		changed = true
+	}
+	if changed {
+		r = setUpdateTime(r, now)
+	}
+	r.Method = http.MethodGet
+	a.cache.ServeHTTP(w, r)
+}
+
+func partitionErrorStatus(err error) int {
+	if errors.Is(err, mpd.ErrArg) || errors.Is(err, mpd.ErrExist) || errors.Is(err, mpd.ErrNoExist) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// Changed returns partition list update event chan.
+func (a *PartitionsHandler) Changed() <-chan struct{} {
+	return a.cache.Changed()
+}
+
+// Close closes update event chan.
+func (a *PartitionsHandler) Close() {
+	a.cache.Close()
+}
@@ -0,0 +1,202 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/meiraka/vv/internal/log"
+	"github.com/meiraka/vv/internal/mpd"
+	"github.com/meiraka/vv/internal/vv/api"
+)
+
+func TestPartitionsHandlerGET(t *testing.T) {
+	for label, tt := range map[string][]struct {
+		label          string
+		partition      string
+		listPartitions func(*testing.T) ([]string, error)
+		err            error
+		want           string
+		changed        bool
+	}{
+		"ok": {{
+			label:          "default",
+			listPartitions: func(*testing.T) ([]string, error) { return []string{"default"}, nil },
+			want:           `{"default":{"current":true}}`,
+			changed:        true,
+		}, {
+			label:          "new partition",
+			listPartitions: func(*testing.T) ([]string, error) { return []string{"default", "zone1"}, nil },
+			want:           `{"default":{"current":true},"zone1":{"current":false}}`,
+			changed:        true,
+		}},
+		"ok/partition": {{
+			partition:      "zone1",
+			listPartitions: func(*testing.T) ([]string, error) { return []string{"default", "zone1"}, nil },
+			want:           `{"default":{"current":false},"zone1":{"current":true}}`,
+			changed:        true,
+		}},
+		"error/network": {{
+			label:          "prepare data",
+			listPartitions: func(*testing.T) ([]string, error) { return []string{"default"}, nil },
+			want:           `{"default":{"current":true}}`,
+			changed:        true,
+		}, {
+			label:          "error",
+			listPartitions: func(*testing.T) ([]string, error) { return nil, errTest },
+			err:            errTest,
+			want:           `{"default":{"current":true}}`,
+		}},
+		"error/mpd": {{
+			label: "unknown command",
+			listPartitions: func(*testing.T) ([]string, error) {
+				return nil, &mpd.CommandError{ID: 5, Index: 0, Command: "", Message: `unknown command "listpartitions"`}
+			},
+			want: "{}",
+		}},
+	} {
+		t.Run(label, func(t *testing.T) {
+			mpd := &mpdPartitions{t: t}
+			h, err := api.NewPartitionsHandler(mpd, tt[0].partition, log.NewTestLogger(t))
+			if err != nil {
+				t.Fatalf("failed to init Partitions: %v", err)
+			}
+			defer h.Close()
+			for i := range tt {
+				f := func(t *testing.T) {
+					mpd.t = t
+					mpd.listPartitions = tt[i].listPartitions
+					if err := h.Update(context.TODO()); !errors.Is(err, tt[i].err) {
+						t.Errorf("Update(ctx) = %v; want %v", err, tt[i].err)
+					}
+					r := httptest.NewRequest(http.MethodGet, "/", nil)
+					w := httptest.NewRecorder()
+					h.ServeHTTP(w, r)
+					if status, got := w.Result().StatusCode, w.Body.String(); status != http.StatusOK || got != tt[i].want {
+						t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", status, got, http.StatusOK, tt[i].want)
+					}
+					if changed := recieveMsg(h.Changed()); changed != tt[i].changed {
+						t.Errorf("changed = %v; want %v", changed, tt[i].changed)
+					}
+				}
+				if len(tt) != 1 {
+					t.Run(tt[i].label, f)
+				} else {
+					f(t)
+				}
+			}
+		})
+	}
+}
+
+func TestPartitionsHandlerPOST(t *testing.T) {
+	for label, tt := range map[string]struct {
+		body      string
+		want      string
+		status    int
+		wantCalls []string
+		err       error
+	}{
+		"error/invalid json": {
+			body:   `invalid json`,
+			want:   `{"error":"invalid character 'i' looking for beginning of value"}`,
+			status: http.StatusBadRequest,
+		},
+		"error/no name": {
+			body:   `{"create":true}`,
+			want:   `{"error":"partition name is empty"}`,
+			status: http.StatusBadRequest,
+		},
+		"error/remove current": {
+			body:   `{"name":"default","remove":true}`,
+			want:   `{"error":"current partition can not be removed"}`,
+			status: http.StatusBadRequest,
+		},
+		"ok/create": {
+			body:      `{"name":"zone1","create":true}`,
+			want:      `{}`,
+			status:    http.StatusAccepted,
+			wantCalls: []string{`NewPartition("zone1")`},
+		},
+		"ok/remove": {
+			body:      `{"name":"zone1","remove":true}`,
+			want:      `{}`,
+			status:    http.StatusAccepted,
+			wantCalls: []string{`DelPartition("zone1")`},
+		},
+		"ok/nop": {
+			body:   `{"name":"zone1"}`,
+			want:   `{}`,
+			status: http.StatusOK,
+		},
+		"error/exist": {
+			body:      `{"name":"zone1","create":true}`,
+			want:      `{"error":"mpd: newpartition: partition already exists"}`,
+			status:    http.StatusBadRequest,
+			wantCalls: []string{`NewPartition("zone1")`},
+			err:       &mpd.CommandError{ID: mpd.ErrExist, Index: 0, Command: "newpartition", Message: "partition already exists"},
+		},
+		"error/network": {
+			body:      `{"name":"zone1","create":true}`,
+			want:      `{"error":"api_test: test error"}`,
+			status:    http.StatusInternalServerError,
+			wantCalls: []string{`NewPartition("zone1")`},
+			err:       errTest,
+		},
+	} {
+		t.Run(label, func(t *testing.T) {
+			mpd := &mpdPartitions{t: t, err: tt.err}
+			h, err := api.NewPartitionsHandler(mpd, "", log.NewTestLogger(t))
+			if err != nil {
+				t.Fatalf("failed to init Partitions: %v", err)
+			}
+			defer h.Close()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if got := w.Body.String(); got != tt.want || w.Result().StatusCode != tt.status {
+				t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", w.Result().StatusCode, got, tt.status, tt.want)
+			}
+			if !reflect.DeepEqual(mpd.calls, tt.wantCalls) {
+				t.Errorf("got calls %v; want %v", mpd.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+type mpdPartitions struct {
+	t              *testing.T
+	listPartitions func(*testing.T) ([]string, error)
+	calls          []string
+	err            error
+}
+
+func (m *mpdPartitions) ListPartitions(context.Context) ([]string, error) {
+	m.t.Helper()
+	if m.listPartitions == nil {
+		m.t.Fatal("no ListPartitions mock function")
+	}
+	return m.listPartitions(m.t)
+}
+
+func (m *mpdPartitions) call(f string, args ...interface{}) error {
+	s := make([]string, len(args))
+	for i := range args {
+		s[i] = fmt.Sprintf("%#v", args[i])
+	}
+	m.calls = append(m.calls, f+"("+strings.Join(s, ", ")+")")
+	return m.err
+}
+
+func (m *mpdPartitions) NewPartition(ctx context.Context, name string) error {
+	return m.call("NewPartition", name)
+}
+
+func (m *mpdPartitions) DelPartition(ctx context.Context, name string) error {
+	return m.call("DelPartition", name)
+}
@@ -24,8 +24,8 @@ type httpPlaylistInfo struct {
 // PlaylistHandler provides current playlist sort function.
 type PlaylistHandler struct {
 	mpd         MPDPlaylist
-	library     []map[string][]string
-	librarySort []map[string][]string
+	library     []*songs.Song
+	librarySort []*songs.Song
 	playlist    []map[string][]string
 	cache       *cache
 	data        *httpPlaylistInfo
@@ -80,14 +80,14 @@ func (a *PlaylistHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	a.mu.Lock()
-	librarySort, filters, newpos := songs.WeakFilterSort(a.library, req.Sort, req.Filters, req.Must, 9999, *req.Current)
+	librarySort, filters, newpos := songs.WeakFilterSortSongs(a.library, req.Sort, req.Filters, req.Must, 9999, *req.Current)
 	a.librarySort = librarySort
-	update := !songs.SortEqual(a.playlist, a.librarySort)
+	update := !songs.SortEqualSongs(a.playlist, a.librarySort)
 	a.mu.Unlock()
 	cl := &mpd.CommandList{}
 	cl.Clear()
 	for i := range a.librarySort {
-		cl.Add(a.librarySort[i]["file"][0])
+		cl.Add(a.librarySort[i].File())
 	}
 	cl.Play(newpos)
 	if !update {
@@ -151,7 +151,7 @@ func (a *PlaylistHandler) updateSort(sort []string, filters [][2]*string, must i
 func (a *PlaylistHandler) UpdatePlaylistSongs(i []map[string][]string) {
 	a.mu.Lock()
 	a.playlist = i
-	unsort := a.data.Sort != nil && !songs.SortEqual(a.playlist, a.librarySort)
+	unsort := a.data.Sort != nil && !songs.SortEqualSongs(a.playlist, a.librarySort)
 	a.mu.Unlock()
 	if unsort {
 		a.updateSort(nil, nil, 0)
@@ -161,9 +161,10 @@ func (a *PlaylistHandler) UpdatePlaylistSongs(i []map[string][]string) {
 	}
 }
 
-func (a *PlaylistHandler) UpdateLibrarySongs(i []map[string][]string) {
+// UpdateLibrarySongs sets library songs to sort. l must not be modified.
+func (a *PlaylistHandler) UpdateLibrarySongs(l []*songs.Song) {
 	a.mu.Lock()
-	a.library = songs.Copy(i)
+	a.library = l
 	a.librarySort = nil
 	a.mu.Unlock()
 }
@@ -2,12 +2,77 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"sync"
+	"time"
+
+	"github.com/meiraka/vv/internal/mpd"
 )
 
+type httpPlaylistSongsRequest struct {
+	Op   string  `json:"op"`
+	File *string `json:"file,omitempty"`
+	Pos  *int    `json:"pos,omitempty"`
+	ID   *int    `json:"id,omitempty"`
+	IDs  []int   `json:"ids,omitempty"`
+	To   *int    `json:"to,omitempty"`
+	With *int    `json:"with,omitempty"`
+	Prio *int    `json:"prio,omitempty"`
+}
+
+// build appends mpd command to cl.
+func (r *httpPlaylistSongsRequest) build(cl *mpd.CommandList) error {
+	switch r.Op {
+	case "add":
+		if r.File == nil {
+			return errors.New("add: requires file")
+		}
+		pos := -1
+		if r.Pos != nil {
+			pos = *r.Pos
+		}
+		cl.AddID(*r.File, pos)
+	case "delete":
+		if r.ID == nil {
+			return errors.New("delete: requires id")
+		}
+		cl.DeleteID(*r.ID)
+	case "move":
+		if r.ID == nil || r.To == nil {
+			return errors.New("move: requires id and to")
+		}
+		cl.MoveID(*r.ID, *r.To)
+	case "swap":
+		if r.ID == nil || r.With == nil {
+			return errors.New("swap: requires id and with")
+		}
+		cl.SwapID(*r.ID, *r.With)
+	case "shuffle":
+		cl.Shuffle()
+	case "prio":
+		if r.Prio == nil || (r.ID == nil && len(r.IDs) == 0) {
+			return errors.New("prio: requires prio and id or ids")
+		}
+		if *r.Prio < 0 || *r.Prio > 255 {
+			return fmt.Errorf("prio: out of range: %d", *r.Prio)
+		}
+		ids := r.IDs
+		if r.ID != nil {
+			ids = append([]int{*r.ID}, ids...)
+		}
+		cl.PrioID(*r.Prio, ids...)
+	default:
+		return fmt.Errorf("unknown op: %q", r.Op)
+	}
+	return nil
+}
+
 type MPDPlaylistSongs interface {
 	PlaylistInfo(context.Context) ([]map[string][]string, error)
+	ExecCommandList(context.Context, *mpd.CommandList) error
 }
 
 type PlaylistSongsHandler struct {
@@ -61,8 +126,42 @@ func (a *PlaylistSongsHandler) Cache() []map[string][]string {
 	return a.data
 }
 
-// ServeHTTP responses neighbors list as json format.
+// ServeHTTP responses playlist songs as json format.
+// POST request applies list of queue edit operations atomically.
 func (a *PlaylistSongsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		a.cache.ServeHTTP(w, r)
+		return
+	}
+	var req []*httpPlaylistSongsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	now := time.Now().UTC()
+	if len(req) != 0 {
+		cl := &mpd.CommandList{}
+		for i := range req {
+			if req[i] == nil {
+				writeHTTPError(w, http.StatusBadRequest, fmt.Errorf("%d: operation is null", i))
+				return
+			}
+			if err := req[i].build(cl); err != nil {
+				writeHTTPError(w, http.StatusBadRequest, fmt.Errorf("%d: %w", i, err))
+				return
+			}
+		}
+		if err := a.mpd.ExecCommandList(r.Context(), cl); err != nil {
+			if errors.Is(err, mpd.ErrArg) || errors.Is(err, mpd.ErrNoExist) {
+				writeHTTPError(w, http.StatusBadRequest, err)
+				return
+			}
+			writeHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+		r = setUpdateTime(r, now)
+	}
+	r.Method = http.MethodGet
 	a.cache.ServeHTTP(w, r)
 }
 
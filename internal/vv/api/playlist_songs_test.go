@@ -7,8 +7,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/meiraka/vv/internal/mpd"
 	"github.com/meiraka/vv/internal/vv/api"
 )
 
@@ -94,9 +96,103 @@ func TestPlaylistSongsHandlerGET(t *testing.T) {
 
 }
 
+func TestPlaylistSongsHandlerPOST(t *testing.T) {
+	songsHook, _ := testSongsHook()
+	for label, tt := range map[string]struct {
+		body            string
+		execCommandList func(*testing.T, *mpd.CommandList) error
+		status          int
+		want            string
+	}{
+		"ok": {
+			body: `[{"op":"add","file":"foo.flac","pos":0},{"op":"add","file":"bar.flac"},{"op":"delete","id":1},{"op":"move","id":2,"to":0},{"op":"swap","id":2,"with":3},{"op":"prio","prio":255,"ids":[2,3]},{"op":"shuffle"}]`,
+			execCommandList: func(t *testing.T, got *mpd.CommandList) error {
+				t.Helper()
+				want := &mpd.CommandList{}
+				want.AddID("foo.flac", 0)
+				want.AddID("bar.flac", -1)
+				want.DeleteID(1)
+				want.MoveID(2, 0)
+				want.SwapID(2, 3)
+				want.PrioID(255, 2, 3)
+				want.Shuffle()
+				if !mpd.CommandListEqual(got, want) {
+					t.Errorf("call mpd.ExecCommandList(ctx,\n%v); want mpd.ExecCommandList(ctx,\n%v)", got, want)
+				}
+				return nil
+			},
+			status: http.StatusAccepted,
+			want:   `[]`,
+		},
+		"ok/empty": {
+			body:   `[]`,
+			status: http.StatusOK,
+			want:   `[]`,
+		},
+		"error/invalid json": {
+			body:   `{}`,
+			status: http.StatusBadRequest,
+			want:   `{"error":"json: cannot unmarshal object into Go value of type []*api.httpPlaylistSongsRequest"}`,
+		},
+		"error/unknown op": {
+			body:   `[{"op":"foo"}]`,
+			status: http.StatusBadRequest,
+			want:   `{"error":"0: unknown op: \"foo\""}`,
+		},
+		"error/missing arg": {
+			body:   `[{"op":"shuffle"},{"op":"move","id":1}]`,
+			status: http.StatusBadRequest,
+			want:   `{"error":"1: move: requires id and to"}`,
+		},
+		"error/prio range": {
+			body:   `[{"op":"prio","prio":256,"id":1}]`,
+			status: http.StatusBadRequest,
+			want:   `{"error":"0: prio: out of range: 256"}`,
+		},
+		"error/no exist": {
+			body: `[{"op":"delete","id":1}]`,
+			execCommandList: func(t *testing.T, got *mpd.CommandList) error {
+				return &mpd.CommandError{ID: mpd.ErrNoExist, Index: 0, Command: "deleteid", Message: "No such song"}
+			},
+			status: http.StatusBadRequest,
+			want:   `{"error":"mpd: deleteid: No such song"}`,
+		},
+		"error/network": {
+			body: `[{"op":"shuffle"}]`,
+			execCommandList: func(t *testing.T, got *mpd.CommandList) error {
+				return errTest
+			},
+			status: http.StatusInternalServerError,
+			want:   `{"error":"api_test: test error"}`,
+		},
+	} {
+		t.Run(label, func(t *testing.T) {
+			h, err := api.NewPlaylistSongsHandler(&mpdPlaylistSongs{t: t, execCommandList: tt.execCommandList}, songsHook)
+			if err != nil {
+				t.Fatalf("api.NewPlaylistSongs() = %v, %v", h, err)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if status, got := w.Result().StatusCode, w.Body.String(); status != tt.status || got != tt.want {
+				t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", status, got, tt.status, tt.want)
+			}
+		})
+	}
+}
+
 type mpdPlaylistSongs struct {
-	t            *testing.T
-	playlistInfo func(*testing.T) ([]map[string][]string, error)
+	t               *testing.T
+	playlistInfo    func(*testing.T) ([]map[string][]string, error)
+	execCommandList func(*testing.T, *mpd.CommandList) error
+}
+
+func (m *mpdPlaylistSongs) ExecCommandList(ctx context.Context, cl *mpd.CommandList) error {
+	m.t.Helper()
+	if m.execCommandList == nil {
+		m.t.Fatal("no ExecCommandList mock function")
+	}
+	return m.execCommandList(m.t, cl)
 }
 
 func (m *mpdPlaylistSongs) PlaylistInfo(ctx context.Context) ([]map[string][]string, error) {
@@ -155,7 +155,7 @@ func TestPlaylistHandler(t *testing.T) {
 						mpd.execCommandList = tt[i].mpd.execCommandList
 					}
 					if tt[i].library != nil {
-						h.UpdateLibrarySongs(tt[i].library)
+						h.UpdateLibrarySongs(songs.NewSongs(tt[i].library))
 					}
 					if tt[i].playlist != nil {
 						h.UpdatePlaylistSongs(tt[i].playlist)
@@ -0,0 +1,161 @@
+package scrobble
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultListenBrainzURL is the ListenBrainz submit listens api url.
+const DefaultListenBrainzURL = "https://api.listenbrainz.org/1/submit-listens"
+
+// Listen represents a listened song.
+type Listen struct {
+	ListenedAt time.Time           `json:"listened_at"`
+	Song       map[string][]string `json:"song"`
+}
+
+// HTTPError represents non 2xx http response from scrobbler api.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("scrobble: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+type lbSubmission struct {
+	ListenType string      `json:"listen_type"`
+	Payload    []*lbListen `json:"payload"`
+}
+
+type lbListen struct {
+	ListenedAt    int64            `json:"listened_at"`
+	TrackMetadata *lbTrackMetadata `json:"track_metadata"`
+}
+
+type lbTrackMetadata struct {
+	ArtistName     string                 `json:"artist_name"`
+	TrackName      string                 `json:"track_name"`
+	ReleaseName    string                 `json:"release_name,omitempty"`
+	AdditionalInfo map[string]interface{} `json:"additional_info"`
+}
+
+// ListenBrainz submits listens to ListenBrainz compatible api.
+type ListenBrainz struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewListenBrainz initializes ListenBrainz with api url and user token.
+// If url is empty, DefaultListenBrainzURL is used.
+func NewListenBrainz(url, token string) *ListenBrainz {
+	if len(url) == 0 {
+		url = DefaultListenBrainzURL
+	}
+	return &ListenBrainz{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Submit submits listens as ListenBrainz json format.
+// Submit skips songs without artist or title which are required by ListenBrainz.
+func (l *ListenBrainz) Submit(ctx context.Context, listens []*Listen) error {
+	payload := make([]*lbListen, 0, len(listens))
+	for _, v := range listens {
+		if m := listenBrainzTrack(v.Song); m != nil {
+			payload = append(payload, &lbListen{ListenedAt: v.ListenedAt.Unix(), TrackMetadata: m})
+		}
+	}
+	if len(payload) == 0 {
+		return nil
+	}
+	typ := "single"
+	if len(payload) > 1 {
+		typ = "import"
+	}
+	b, err := json.Marshal(&lbSubmission{ListenType: typ, Payload: payload})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(l.token) != 0 {
+		req.Header.Set("Authorization", "Token "+l.token)
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &HTTPError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// listenBrainzTrack converts mpd song tags to ListenBrainz track metadata.
+func listenBrainzTrack(song map[string][]string) *lbTrackMetadata {
+	artist := strings.Join(song["Artist"], ", ")
+	title := strings.Join(song["Title"], ", ")
+	if len(artist) == 0 || len(title) == 0 {
+		return nil
+	}
+	info := map[string]interface{}{
+		"media_player":      "vv",
+		"submission_client": "vv",
+	}
+	if v, ok := first(song, "Track"); ok {
+		if i, err := strconv.Atoi(strings.SplitN(v, "/", 2)[0]); err == nil {
+			info["tracknumber"] = i
+		}
+	}
+	for _, k := range []string{"duration", "Time"} {
+		if v, ok := first(song, k); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				info["duration_ms"] = int64(f * 1000)
+				break
+			}
+		}
+	}
+	if v, ok := first(song, "MUSICBRAINZ_TRACKID"); ok {
+		info["recording_mbid"] = v
+	}
+	if v, ok := first(song, "MUSICBRAINZ_RELEASETRACKID"); ok {
+		info["track_mbid"] = v
+	}
+	if v, ok := first(song, "MUSICBRAINZ_ALBUMID"); ok {
+		info["release_mbid"] = v
+	}
+	if v := song["MUSICBRAINZ_ARTISTID"]; len(v) != 0 {
+		info["artist_mbids"] = v
+	}
+	return &lbTrackMetadata{
+		ArtistName:     artist,
+		TrackName:      title,
+		ReleaseName:    strings.Join(song["Album"], ", "),
+		AdditionalInfo: info,
+	}
+}
+
+func first(song map[string][]string, key string) (string, bool) {
+	if v := song[key]; len(v) != 0 && len(v[0]) != 0 {
+		return v[0], true
+	}
+	return "", false
+}
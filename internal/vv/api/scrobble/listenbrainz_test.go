@@ -0,0 +1,102 @@
+package scrobble
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListenBrainzSubmit(t *testing.T) {
+	listenedAt := time.Date(2021, 1, 17, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		label   string
+		listens []*Listen
+		status  int
+		want    string
+		wantErr error
+	}{{
+		label: "single",
+		listens: []*Listen{{ListenedAt: listenedAt, Song: map[string][]string{
+			"file": {"foo"}, "Artist": {"foo", "bar"}, "Title": {"baz"}, "Album": {"qux"}, "Track": {"3/12"}, "duration": {"123.456"},
+			"MUSICBRAINZ_TRACKID": {"recording"}, "MUSICBRAINZ_ARTISTID": {"artist1", "artist2"},
+		}}},
+		status: http.StatusOK,
+		want:   `{"listen_type":"single","payload":[{"listened_at":1610841600,"track_metadata":{"artist_name":"foo, bar","track_name":"baz","release_name":"qux","additional_info":{"artist_mbids":["artist1","artist2"],"duration_ms":123456,"media_player":"vv","recording_mbid":"recording","submission_client":"vv","tracknumber":3}}}]}`,
+	}, {
+		label: "import",
+		listens: []*Listen{
+			{ListenedAt: listenedAt, Song: map[string][]string{"Artist": {"foo"}, "Title": {"bar"}}},
+			{ListenedAt: listenedAt.Add(time.Minute), Song: map[string][]string{"file": {"no tags"}}},
+			{ListenedAt: listenedAt.Add(2 * time.Minute), Song: map[string][]string{"Artist": {"baz"}, "Title": {"qux"}, "Time": {"60"}}},
+		},
+		status: http.StatusOK,
+		want:   `{"listen_type":"import","payload":[{"listened_at":1610841600,"track_metadata":{"artist_name":"foo","track_name":"bar","additional_info":{"media_player":"vv","submission_client":"vv"}}},{"listened_at":1610841720,"track_metadata":{"artist_name":"baz","track_name":"qux","additional_info":{"duration_ms":60000,"media_player":"vv","submission_client":"vv"}}}]}`,
+	}, {
+		label:   "error",
+		listens: []*Listen{{ListenedAt: listenedAt, Song: map[string][]string{"Artist": {"foo"}, "Title": {"bar"}}}},
+		status:  http.StatusUnauthorized,
+		want:    `{"listen_type":"single","payload":[{"listened_at":1610841600,"track_metadata":{"artist_name":"foo","track_name":"bar","additional_info":{"media_player":"vv","submission_client":"vv"}}}]}`,
+		wantErr: &HTTPError{StatusCode: http.StatusUnauthorized, Body: `{"code": 401, "error": "Invalid authorization token."}`},
+	}} {
+		t.Run(tt.label, func(t *testing.T) {
+			var got string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if auth := r.Header.Get("Authorization"); auth != "Token secret" {
+					t.Errorf("got Authorization header %q; want %q", auth, "Token secret")
+				}
+				b, _ := io.ReadAll(r.Body)
+				got = string(b)
+				w.WriteHeader(tt.status)
+				if tt.status != http.StatusOK {
+					io.WriteString(w, `{"code": 401, "error": "Invalid authorization token."}`)
+				}
+			}))
+			defer ts.Close()
+			l := NewListenBrainz(ts.URL, "secret")
+			err := l.Submit(context.TODO(), tt.listens)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && (err == nil || err.Error() != tt.wantErr.Error()) {
+				t.Errorf("Submit() = %v; want %v", err, tt.wantErr)
+			}
+			if !jsonEqual(t, got, tt.want) {
+				t.Errorf("got request\n%s; want\n%s", got, tt.want)
+			}
+		})
+	}
+	t.Run("no valid listens", func(t *testing.T) {
+		l := NewListenBrainz("http://127.0.0.1:0/", "")
+		if err := l.Submit(context.TODO(), []*Listen{{ListenedAt: listenedAt, Song: map[string][]string{"file": {"foo"}}}}); err != nil {
+			t.Errorf("Submit() = %v; want nil", err)
+		}
+	})
+	t.Run("network error", func(t *testing.T) {
+		ts := httptest.NewServer(http.NotFoundHandler())
+		ts.Close()
+		l := NewListenBrainz(ts.URL, "")
+		err := l.Submit(context.TODO(), []*Listen{{ListenedAt: listenedAt, Song: map[string][]string{"Artist": {"foo"}, "Title": {"bar"}}}})
+		var herr *HTTPError
+		if err == nil || errors.As(err, &herr) {
+			t.Errorf("Submit() = %v; want network error", err)
+		}
+	})
+}
+
+func jsonEqual(t *testing.T, a, b string) bool {
+	t.Helper()
+	var av, bv interface{}
+	if err := json.Unmarshal([]byte(a), &av); err != nil {
+		t.Errorf("failed to decode %q: %v", a, err)
+		return false
+	}
+	if err := json.Unmarshal([]byte(b), &bv); err != nil {
+		t.Errorf("failed to decode %q: %v", b, err)
+		return false
+	}
+	ab, _ := json.Marshal(av)
+	bb, _ := json.Marshal(bv)
+	return string(ab) == string(bb)
+}
@@ -0,0 +1,201 @@
+package scrobble
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var bucketQueue = []byte("queue")
+
+// queueBatchSize is the maximum number of listens in a submission.
+const queueBatchSize = 100
+
+// Submitter represents scrobbler api.
+type Submitter interface {
+	Submit(context.Context, []*Listen) error
+}
+
+// Logger represents logger for background submission errors.
+type Logger interface {
+	Printf(string, ...interface{})
+	Debugf(string, ...interface{})
+}
+
+// Queue stores listens to durable on-disk queue and submits them via
+// Submitter in background. Failed submissions are retried every interval.
+type Queue struct {
+	submitter Submitter
+	db        *bolt.DB
+	logger    Logger
+	notify    chan struct{}
+	cancel    context.CancelFunc
+	done      chan struct{}
+	mu        sync.Mutex
+}
+
+// NewQueue initializes Queue with queue db in cacheDir and starts background
+// submission.
+func NewQueue(submitter Submitter, cacheDir string, interval time.Duration, logger Logger) (*Queue, error) {
+	if err := os.MkdirAll(cacheDir, 0766); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(cacheDir, "queue.db"), 0666, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			return nil, fmt.Errorf("obtain scrobble queue lock: %w", err)
+		}
+		return nil, err
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(bucketQueue); err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		submitter: submitter,
+		db:        db,
+		logger:    logger,
+		notify:    make(chan struct{}, 1),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	go q.run(ctx, interval)
+	return q, nil
+}
+
+func (q *Queue) run(ctx context.Context, interval time.Duration) {
+	defer close(q.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// submits listens queued before restart
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.notify:
+		case <-ticker.C:
+		}
+		if err := q.Flush(ctx); err != nil && ctx.Err() == nil {
+			q.logger.Printf("vv/api/scrobble: %v", err)
+		}
+	}
+}
+
+// Scrobble enqueues listened song and submits it in background.
+func (q *Queue) Scrobble(ctx context.Context, listenedAt time.Time, song map[string][]string) error {
+	v, err := json.Marshal(&Listen{ListenedAt: listenedAt, Song: song})
+	if err != nil {
+		return err
+	}
+	if err := q.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketQueue)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		k := make([]byte, 8)
+		binary.BigEndian.PutUint64(k, id)
+		return b.Put(k, v)
+	}); err != nil {
+		return err
+	}
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Flush submits queued listens in order.
+// Listens rejected as bad request are dropped since retry never succeeds.
+func (q *Queue) Flush(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		keys, listens, err := q.peek()
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		if err := q.submitter.Submit(ctx, listens); err != nil {
+			var herr *HTTPError
+			if !errors.As(err, &herr) || herr.StatusCode != http.StatusBadRequest {
+				return err
+			}
+			q.logger.Printf("vv/api/scrobble: drop %d listens: %v", len(keys), err)
+		}
+		if err := q.remove(keys); err != nil {
+			return err
+		}
+	}
+}
+
+// Len returns number of queued listens.
+func (q *Queue) Len() (int, error) {
+	var n int
+	err := q.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(bucketQueue).Stats().KeyN
+		return nil
+	})
+	return n, err
+}
+
+func (q *Queue) peek() ([][]byte, []*Listen, error) {
+	var keys [][]byte
+	var listens []*Listen
+	err := q.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketQueue).Cursor()
+		for k, v := c.First(); k != nil && len(keys) < queueBatchSize; k, v = c.Next() {
+			keys = append(keys, append([]byte{}, k...))
+			var l Listen
+			if err := json.Unmarshal(v, &l); err != nil {
+				q.logger.Printf("vv/api/scrobble: drop broken listen: %v", err)
+				continue
+			}
+			listens = append(listens, &l)
+		}
+		return nil
+	})
+	return keys, listens, err
+}
+
+func (q *Queue) remove(keys [][]byte) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketQueue)
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close stops background submission and closes queue db.
+// Unsubmitted listens are kept in queue db for next run.
+func (q *Queue) Close() error {
+	q.cancel()
+	<-q.done
+	return q.db.Close()
+}
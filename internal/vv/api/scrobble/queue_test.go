@@ -0,0 +1,158 @@
+package scrobble
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/meiraka/vv/internal/log"
+)
+
+// testServer is a ListenBrainz stand-in server.
+type testServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	received []string
+	called   int
+}
+
+func newTestServer(status int) *testServer {
+	s := &testServer{status: status}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req lbSubmission
+		json.NewDecoder(r.Body).Decode(&req)
+		s.mu.Lock()
+		status := s.status
+		if status == http.StatusOK {
+			for _, l := range req.Payload {
+				s.received = append(s.received, l.TrackMetadata.TrackName)
+			}
+		}
+		s.called++
+		s.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	return s
+}
+
+func (s *testServer) setStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+func (s *testServer) Called() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.called
+}
+
+func (s *testServer) Received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.received...)
+}
+
+// waitFor waits until f returns true.
+func waitFor(t *testing.T, f func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !f(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+	}
+}
+
+func (q *Queue) testLen(n int) func() bool {
+	return func() bool {
+		l, err := q.Len()
+		return err == nil && l == n
+	}
+}
+
+func testSong(title string) map[string][]string {
+	return map[string][]string{"Artist": {"foo"}, "Title": {title}}
+}
+
+func TestQueue(t *testing.T) {
+	listenedAt := time.Date(2021, 1, 17, 0, 0, 0, 0, time.UTC)
+	t.Run("submit", func(t *testing.T) {
+		ts := newTestServer(http.StatusOK)
+		defer ts.Close()
+		q, err := NewQueue(NewListenBrainz(ts.URL, ""), t.TempDir(), time.Hour, log.NewTestLogger(t))
+		if err != nil {
+			t.Fatalf("NewQueue() = %v", err)
+		}
+		defer q.Close()
+		if err := q.Scrobble(context.TODO(), listenedAt, testSong("bar")); err != nil {
+			t.Fatalf("Scrobble() = %v", err)
+		}
+		waitFor(t, q.testLen(0))
+		if got := ts.Received(); len(got) != 1 || got[0] != "bar" {
+			t.Errorf("got received %v; want [bar]", got)
+		}
+		if n, err := q.Len(); n != 0 || err != nil {
+			t.Errorf("Len() = %d, %v; want 0, nil", n, err)
+		}
+	})
+	t.Run("retry", func(t *testing.T) {
+		ts := newTestServer(http.StatusServiceUnavailable)
+		defer ts.Close()
+		dir := t.TempDir()
+		q, err := NewQueue(NewListenBrainz(ts.URL, ""), dir, time.Hour, log.NewTestLogger(t))
+		if err != nil {
+			t.Fatalf("NewQueue() = %v", err)
+		}
+		for _, title := range []string{"bar", "baz"} {
+			if err := q.Scrobble(context.TODO(), listenedAt, testSong(title)); err != nil {
+				t.Fatalf("Scrobble() = %v", err)
+			}
+		}
+		waitFor(t, func() bool { return ts.Called() != 0 })
+		if err := q.Flush(context.TODO()); err == nil {
+			t.Errorf("Flush() = nil; want error")
+		}
+		if n, err := q.Len(); n != 2 || err != nil {
+			t.Errorf("Len() = %d, %v; want 2, nil", n, err)
+		}
+		if err := q.Close(); err != nil {
+			t.Fatalf("Close() = %v", err)
+		}
+
+		// queued listens are kept after restart
+		ts.setStatus(http.StatusOK)
+		q, err = NewQueue(NewListenBrainz(ts.URL, ""), dir, time.Hour, log.NewTestLogger(t))
+		if err != nil {
+			t.Fatalf("NewQueue() = %v", err)
+		}
+		defer q.Close()
+		waitFor(t, q.testLen(0))
+		if got := ts.Received(); len(got) != 2 || got[0] != "bar" || got[1] != "baz" {
+			t.Errorf("got received %v; want [bar baz]", got)
+		}
+		if n, err := q.Len(); n != 0 || err != nil {
+			t.Errorf("Len() = %d, %v; want 0, nil", n, err)
+		}
+	})
+	t.Run("drop bad request", func(t *testing.T) {
+		ts := newTestServer(http.StatusBadRequest)
+		defer ts.Close()
+		q, err := NewQueue(NewListenBrainz(ts.URL, ""), t.TempDir(), time.Hour, log.NewTestLogger(t))
+		if err != nil {
+			t.Fatalf("NewQueue() = %v", err)
+		}
+		defer q.Close()
+		if err := q.Scrobble(context.TODO(), listenedAt, testSong("bar")); err != nil {
+			t.Fatalf("Scrobble() = %v", err)
+		}
+		waitFor(t, q.testLen(0))
+		if got := ts.Called(); got != 1 {
+			t.Errorf("got called %d; want 1", got)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+)
+
+type httpServer struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// ServersHandler provides mpd server list api.
+type ServersHandler struct {
+	cache *cache
+}
+
+// NewServersHandler initilize mpd server list.
+// defaultName is a name for the mpd server served under /api/.
+func NewServersHandler(defaultName string, names []string) (*ServersHandler, error) {
+	servers := make([]*httpServer, 0, len(names)+1)
+	servers = append(servers, &httpServer{Name: defaultName, Path: "/api/"})
+	for _, name := range names {
+		servers = append(servers, &httpServer{Name: name, Path: ServerPath(name)})
+	}
+	c, err := newCache(servers)
+	if err != nil {
+		return nil, err
+	}
+	return &ServersHandler{cache: c}, nil
+}
+
+// ServeHTTP responses mpd server list as json format.
+func (a *ServersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.cache.ServeHTTP(w, r)
+}
+
+// Close closes update event chan.
+func (a *ServersHandler) Close() {
+	a.cache.Close()
+}
@@ -0,0 +1,38 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/meiraka/vv/internal/vv/api"
+)
+
+func TestServersHandlerGET(t *testing.T) {
+	for label, tt := range map[string]struct {
+		names []string
+		want  string
+	}{
+		"default only": {
+			want: `[{"name":"default","path":"/api/"}]`,
+		},
+		"servers": {
+			names: []string{"kitchen", "living"},
+			want:  `[{"name":"default","path":"/api/"},{"name":"kitchen","path":"/api/servers/kitchen/"},{"name":"living","path":"/api/servers/living/"}]`,
+		},
+	} {
+		t.Run(label, func(t *testing.T) {
+			h, err := api.NewServersHandler("default", tt.names)
+			if err != nil {
+				t.Fatalf("api.NewServersHandler() = %v, %v", h, err)
+			}
+			defer h.Close()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if status, got := w.Result().StatusCode, w.Body.String(); status != http.StatusOK || got != tt.want {
+				t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", status, got, http.StatusOK, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/meiraka/vv/internal/mpd"
+)
+
+// mpd sticker names for song.
+const (
+	stickerRating     = "rating"
+	stickerPlayCount  = "playcount"
+	stickerLastPlayed = "lastplayed"
+)
+
+// maxRating is the maximum value of rating sticker.
+const maxRating = 5
+
+// stickersSongChangedMax is the buffer size of changed song event chan.
+const stickersSongChangedMax = 16
+
+type httpSticker struct {
+	Rating     *int   `json:"rating,omitempty"`
+	PlayCount  *int   `json:"play_count,omitempty"`
+	LastPlayed string `json:"last_played,omitempty"`
+}
+
+type httpStickerRequest struct {
+	File   string `json:"file"`
+	Rating *int   `json:"rating"`
+}
+
+// MPDStickers represents mpd api for stickers API.
+type MPDStickers interface {
+	StickerGet(context.Context, string, string, string) (string, error)
+	StickerSet(context.Context, string, string, string, string) error
+	StickerDelete(context.Context, string, string, string) error
+	StickerFind(context.Context, string, string, string) (map[string]string, error)
+	StickerList(context.Context, string, string) (map[string]string, error)
+}
+
+// StickersHandler provides song rating, play count and last played time
+// stored in mpd sticker database.
+// StickersHandler implements Scrobbler to count listened songs.
+type StickersHandler struct {
+	mpd         MPDStickers
+	cache       *cache
+	changed     chan struct{}
+	songChanged chan string
+	logger      Logger
+	data        map[string]*httpSticker
+	disabled    bool
+	pending     map[string]int // number of sticker writes by StickersHandler per song since last sticker event
+	closed      bool
+	mu          sync.RWMutex
+}
+
+// NewStickersHandler initilize stickers cache with mpd connection.
+func NewStickersHandler(mpd MPDStickers, logger Logger) (*StickersHandler, error) {
+	c, err := newCache(map[string]*httpSticker{})
+	if err != nil {
+		return nil, err
+	}
+	return &StickersHandler{
+		mpd:         mpd,
+		cache:       c,
+		changed:     make(chan struct{}, cap(c.Changed())),
+		songChanged: make(chan string, stickersSongChangedMax),
+		logger:      logger,
+		pending:     map[string]int{},
+	}, nil
+}
+
+// Update updates song stickers.
+func (a *StickersHandler) Update(ctx context.Context) error {
+	ret := map[string]*httpSticker{}
+	for _, name := range []string{stickerRating, stickerPlayCount, stickerLastPlayed} {
+		m, err := a.mpd.StickerFind(ctx, "song", "", name)
+		if err != nil {
+			// skip command error to support mpd without sticker_file
+			var perr *mpd.CommandError
+			if errors.As(err, &perr) {
+				a.logger.Debugf("vv/api: stickers: %v", err)
+				a.mu.Lock()
+				a.data = map[string]*httpSticker{}
+				a.disabled = true
+				a.pending = map[string]int{}
+				a.mu.Unlock()
+				return a.set()
+			}
+			return err
+		}
+		for file, v := range m {
+			s, ok := ret[file]
+			if !ok {
+				s = &httpSticker{}
+				ret[file] = s
+			}
+			setSticker(s, name, v)
+		}
+	}
+	a.mu.Lock()
+	a.data = ret
+	a.disabled = false
+	a.pending = map[string]int{}
+	a.mu.Unlock()
+	return a.set()
+}
+
+// setSticker sets mpd sticker value of name to s.
+func setSticker(s *httpSticker, name, v string) {
+	switch name {
+	case stickerRating:
+		if i, err := strconv.Atoi(v); err == nil {
+			s.Rating = &i
+		}
+	case stickerPlayCount:
+		if i, err := strconv.Atoi(v); err == nil {
+			s.PlayCount = &i
+		}
+	case stickerLastPlayed:
+		s.LastPlayed = v
+	}
+}
+
+// UpdateExternal updates song stickers on mpd sticker event.
+// If songs are written by StickersHandler after the last event, the event is
+// treated as caused by the writes and UpdateExternal reloads stickers of the
+// written songs only. Otherwise UpdateExternal updates all songs.
+func (a *StickersHandler) UpdateExternal(ctx context.Context) error {
+	a.mu.Lock()
+	files := make([]string, 0, len(a.pending))
+	for f := range a.pending {
+		files = append(files, f)
+	}
+	a.pending = map[string]int{}
+	a.mu.Unlock()
+	if len(files) == 0 {
+		return a.Update(ctx)
+	}
+	for _, file := range files {
+		m, err := a.mpd.StickerList(ctx, "song", file)
+		if err != nil && !errors.Is(err, mpd.ErrNoExist) {
+			return err
+		}
+		s := &httpSticker{}
+		for k, v := range m {
+			setSticker(s, k, v)
+		}
+		if err := a.setSong(file, func(v *httpSticker) { *v = *s }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write runs f to write stickers of the song to mpd. The sticker event caused
+// by the write is handled by UpdateExternal; written is false if f does not
+// write stickers like deleting not existing sticker.
+func (a *StickersHandler) write(file string, f func() (written bool, err error)) error {
+	a.mu.Lock()
+	a.pending[file]++
+	a.mu.Unlock()
+	written, err := f()
+	if err != nil || !written {
+		a.mu.Lock()
+		if a.pending[file]--; a.pending[file] <= 0 {
+			delete(a.pending, file)
+		}
+		a.mu.Unlock()
+	}
+	return err
+}
+
+// set updates cache by song stickers.
+func (a *StickersHandler) set() error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	changed, err := a.cache.SetIfModified(a.data)
+	if err != nil || !changed || a.closed {
+		return err
+	}
+	select {
+	case a.changed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// setSong updates sticker cache of the song.
+func (a *StickersHandler) setSong(file string, f func(*httpSticker)) error {
+	a.mu.Lock()
+	s := &httpSticker{}
+	old, ok := a.data[file]
+	if ok {
+		*s = *old
+	}
+	f(s)
+	empty := s.Rating == nil && s.PlayCount == nil && len(s.LastPlayed) == 0
+	if (!ok && empty) || (ok && reflect.DeepEqual(old, s)) {
+		a.mu.Unlock()
+		return nil
+	}
+	if a.data == nil {
+		a.data = map[string]*httpSticker{}
+	}
+	if empty {
+		delete(a.data, file)
+	} else {
+		a.data[file] = s
+	}
+	a.mu.Unlock()
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if _, err := a.cache.SetIfModified(a.data); err != nil {
+		return err
+	}
+	if a.closed {
+		return nil
+	}
+	select {
+	case a.songChanged <- file:
+	default:
+		// too many songs; updates all songs
+		select {
+		case a.changed <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// ConvSong adds sticker values to song as tags.
+func (a *StickersHandler) ConvSong(s map[string][]string) map[string][]string {
+	delete(s, "Rating")
+	delete(s, "PlayCount")
+	delete(s, "PlayCountNumber")
+	delete(s, "LastPlayed")
+	delete(s, "LastPlayedDate")
+	f, ok := s["file"]
+	if !ok || len(f) == 0 {
+		return s
+	}
+	a.mu.RLock()
+	v, ok := a.data[f[0]]
+	a.mu.RUnlock()
+	if !ok {
+		return s
+	}
+	if v.Rating != nil {
+		s["Rating"] = []string{strconv.Itoa(*v.Rating)}
+	}
+	if v.PlayCount != nil {
+		s["PlayCount"] = []string{strconv.Itoa(*v.PlayCount)}
+		s["PlayCountNumber"] = []string{fmt.Sprintf("%04d", *v.PlayCount)}
+	}
+	if len(v.LastPlayed) != 0 {
+		s["LastPlayed"] = []string{v.LastPlayed}
+		if t, err := time.Parse(time.RFC3339, v.LastPlayed); err == nil {
+			s["LastPlayedDate"] = []string{t.Format("2006.01.02")}
+		}
+	}
+	return s
+}
+
+// ConvSongs adds sticker values to songs as tags.
+func (a *StickersHandler) ConvSongs(s []map[string][]string) []map[string][]string {
+	for i := range s {
+		s[i] = a.ConvSong(s[i])
+	}
+	return s
+}
+
+// Scrobble increments play count and updates last played time of the
+// listened song.
+func (a *StickersHandler) Scrobble(ctx context.Context, listenedAt time.Time, song map[string][]string) error {
+	f := song["file"]
+	a.mu.RLock()
+	disabled := a.disabled
+	a.mu.RUnlock()
+	if len(f) == 0 || len(f[0]) == 0 || disabled {
+		return nil
+	}
+	file := f[0]
+	count := 0
+	v, err := a.mpd.StickerGet(ctx, "song", file, stickerPlayCount)
+	if err != nil && !errors.Is(err, mpd.ErrNoExist) {
+		return err
+	}
+	if i, err := strconv.Atoi(v); err == nil {
+		count = i
+	}
+	count++
+	lastPlayed := listenedAt.UTC().Format(time.RFC3339)
+	if err := a.write(file, func() (bool, error) {
+		if err := a.mpd.StickerSet(ctx, "song", file, stickerPlayCount, strconv.Itoa(count)); err != nil {
+			return false, err
+		}
+		// play count is written
+		return true, a.mpd.StickerSet(ctx, "song", file, stickerLastPlayed, lastPlayed)
+	}); err != nil {
+		return err
+	}
+	return a.setSong(file, func(s *httpSticker) {
+		s.PlayCount = &count
+		s.LastPlayed = lastPlayed
+	})
+}
+
+// ServeHTTP responses song stickers as json format.
+// POST request updates song rating; rating 0 removes rating.
+func (a *StickersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		a.cache.ServeHTTP(w, r)
+		return
+	}
+	var req httpStickerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.File == "" {
+		writeHTTPError(w, http.StatusBadRequest, errors.New("file is empty"))
+		return
+	}
+	if req.Rating == nil {
+		writeHTTPError(w, http.StatusBadRequest, errors.New("rating is empty"))
+		return
+	}
+	if *req.Rating < 0 || *req.Rating > maxRating {
+		writeHTTPError(w, http.StatusBadRequest, fmt.Errorf("rating must be between 0 and %d: got %d", maxRating, *req.Rating))
+		return
+	}
+	ctx := r.Context()
+	now := time.Now().UTC()
+	err := a.write(req.File, func() (bool, error) {
+		if *req.Rating != 0 {
+			return true, a.mpd.StickerSet(ctx, "song", req.File, stickerRating, strconv.Itoa(*req.Rating))
+		}
+		err := a.mpd.StickerDelete(ctx, "song", req.File, stickerRating)
+		if errors.Is(err, mpd.ErrNoExist) {
+			// no rating
+			return false, nil
+		}
+		return true, err
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, mpd.ErrArg) || errors.Is(err, mpd.ErrNoExist) {
+			status = http.StatusBadRequest
+		}
+		writeHTTPError(w, status, err)
+		return
+	}
+	if err := a.setSong(req.File, func(s *httpSticker) {
+		s.Rating = nil
+		if *req.Rating != 0 {
+			s.Rating = req.Rating
+		}
+	}); err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	r = setUpdateTime(r, now)
+	r.Method = http.MethodGet
+	a.cache.ServeHTTP(w, r)
+}
+
+// Changed returns stickers update event chan.
+func (a *StickersHandler) Changed() <-chan struct{} {
+	return a.changed
+}
+
+// SongChanged returns stickers update event chan of a song written by
+// StickersHandler.
+func (a *StickersHandler) SongChanged() <-chan string {
+	return a.songChanged
+}
+
+// Close closes update event chan.
+func (a *StickersHandler) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	a.closed = true
+	a.cache.Close()
+	close(a.changed)
+	close(a.songChanged)
+}
@@ -0,0 +1,376 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meiraka/vv/internal/log"
+	"github.com/meiraka/vv/internal/mpd"
+	"github.com/meiraka/vv/internal/vv/api"
+)
+
+func TestStickersHandlerGET(t *testing.T) {
+	for label, tt := range map[string][]struct {
+		label       string
+		stickerFind func(*testing.T, string) (map[string]string, error)
+		err         error
+		want        string
+		wantSong    map[string][]string
+		changed     bool
+	}{
+		"ok": {{
+			label:       "empty",
+			stickerFind: func(*testing.T, string) (map[string]string, error) { return map[string]string{}, nil },
+			want:        "{}",
+			wantSong:    map[string][]string{"file": {"foo"}},
+		}, {
+			label: "some data",
+			stickerFind: func(t *testing.T, name string) (map[string]string, error) {
+				return map[string]map[string]string{
+					"rating":     {"foo": "3", "bar": "invalid"},
+					"playcount":  {"foo": "12"},
+					"lastplayed": {"foo": "2021-01-17T01:06:27Z"},
+				}[name], nil
+			},
+			want:     `{"bar":{},"foo":{"rating":3,"play_count":12,"last_played":"2021-01-17T01:06:27Z"}}`,
+			wantSong: map[string][]string{"file": {"foo"}, "Rating": {"3"}, "PlayCount": {"12"}, "PlayCountNumber": {"0012"}, "LastPlayed": {"2021-01-17T01:06:27Z"}, "LastPlayedDate": {"2021.01.17"}},
+			changed:  true,
+		}, {
+			label:       "remove",
+			stickerFind: func(*testing.T, string) (map[string]string, error) { return map[string]string{}, nil },
+			want:        "{}",
+			wantSong:    map[string][]string{"file": {"foo"}},
+			changed:     true,
+		}},
+		"error/network": {{
+			label:       "prepare data",
+			stickerFind: func(*testing.T, string) (map[string]string, error) { return map[string]string{"foo": "3"}, nil },
+			want:        `{"foo":{"rating":3,"play_count":3,"last_played":"3"}}`,
+			wantSong:    map[string][]string{"file": {"foo"}, "Rating": {"3"}, "PlayCount": {"3"}, "PlayCountNumber": {"0003"}, "LastPlayed": {"3"}},
+			changed:     true,
+		}, {
+			label:       "error",
+			stickerFind: func(*testing.T, string) (map[string]string, error) { return nil, errTest },
+			err:         errTest,
+			want:        `{"foo":{"rating":3,"play_count":3,"last_played":"3"}}`,
+			wantSong:    map[string][]string{"file": {"foo"}, "Rating": {"3"}, "PlayCount": {"3"}, "PlayCountNumber": {"0003"}, "LastPlayed": {"3"}},
+		}},
+		"error/mpd": {{
+			label:       "prepare data",
+			stickerFind: func(*testing.T, string) (map[string]string, error) { return map[string]string{"foo": "3"}, nil },
+			want:        `{"foo":{"rating":3,"play_count":3,"last_played":"3"}}`,
+			wantSong:    map[string][]string{"file": {"foo"}, "Rating": {"3"}, "PlayCount": {"3"}, "PlayCountNumber": {"0003"}, "LastPlayed": {"3"}},
+			changed:     true,
+		}, {
+			label: "sticker database is disabled",
+			stickerFind: func(*testing.T, string) (map[string]string, error) {
+				return nil, &mpd.CommandError{ID: 5, Index: 0, Command: "sticker", Message: "sticker database is disabled"}
+			},
+			want:     "{}",
+			wantSong: map[string][]string{"file": {"foo"}},
+			changed:  true,
+		}},
+	} {
+		t.Run(label, func(t *testing.T) {
+			mpd := &mpdStickers{t: t}
+			h, err := api.NewStickersHandler(mpd, log.NewTestLogger(t))
+			if err != nil {
+				t.Fatalf("failed to init Stickers: %v", err)
+			}
+			defer h.Close()
+			for i := range tt {
+				t.Run(tt[i].label, func(t *testing.T) {
+					mpd.t = t
+					mpd.stickerFind = tt[i].stickerFind
+					if err := h.Update(context.TODO()); !errors.Is(err, tt[i].err) {
+						t.Errorf("Update(ctx) = %v; want %v", err, tt[i].err)
+					}
+					r := httptest.NewRequest(http.MethodGet, "/", nil)
+					w := httptest.NewRecorder()
+					h.ServeHTTP(w, r)
+					if status, got := w.Result().StatusCode, w.Body.String(); status != http.StatusOK || got != tt[i].want {
+						t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", status, got, http.StatusOK, tt[i].want)
+					}
+					if changed := recieveMsg(h.Changed()); changed != tt[i].changed {
+						t.Errorf("changed = %v; want %v", changed, tt[i].changed)
+					}
+					song := map[string][]string{"file": {"foo"}, "Rating": {"5"}}
+					if got := h.ConvSong(song); !reflect.DeepEqual(got, tt[i].wantSong) {
+						t.Errorf("ConvSong(song) = %v; want %v", got, tt[i].wantSong)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestStickersHandlerPOST(t *testing.T) {
+	for label, tt := range map[string]struct {
+		body      string
+		want      string
+		status    int
+		wantCalls []string
+		err       error
+	}{
+		"error/invalid json": {
+			body:   `invalid json`,
+			want:   `{"error":"invalid character 'i' looking for beginning of value"}`,
+			status: http.StatusBadRequest,
+		},
+		"error/no file": {
+			body:   `{"rating":3}`,
+			want:   `{"error":"file is empty"}`,
+			status: http.StatusBadRequest,
+		},
+		"error/no rating": {
+			body:   `{"file":"foo"}`,
+			want:   `{"error":"rating is empty"}`,
+			status: http.StatusBadRequest,
+		},
+		"error/invalid rating": {
+			body:   `{"file":"foo","rating":6}`,
+			want:   `{"error":"rating must be between 0 and 5: got 6"}`,
+			status: http.StatusBadRequest,
+		},
+		"ok/rating": {
+			body:      `{"file":"foo","rating":3}`,
+			want:      `{"foo":{"rating":3}}`,
+			status:    http.StatusOK,
+			wantCalls: []string{`StickerSet("song", "foo", "rating", "3")`},
+		},
+		"ok/remove rating": {
+			body:      `{"file":"foo","rating":0}`,
+			want:      `{}`,
+			status:    http.StatusAccepted,
+			wantCalls: []string{`StickerDelete("song", "foo", "rating")`},
+		},
+		"ok/remove no rating": {
+			body:      `{"file":"foo","rating":0}`,
+			want:      `{}`,
+			status:    http.StatusAccepted,
+			wantCalls: []string{`StickerDelete("song", "foo", "rating")`},
+			err:       &mpd.CommandError{ID: mpd.ErrNoExist, Index: 0, Command: "sticker", Message: "no such sticker"},
+		},
+		"error/no such song": {
+			body:      `{"file":"foo","rating":3}`,
+			want:      `{"error":"mpd: sticker: no such song"}`,
+			status:    http.StatusBadRequest,
+			wantCalls: []string{`StickerSet("song", "foo", "rating", "3")`},
+			err:       &mpd.CommandError{ID: mpd.ErrNoExist, Index: 0, Command: "sticker", Message: "no such song"},
+		},
+		"error/network": {
+			body:      `{"file":"foo","rating":3}`,
+			want:      `{"error":"api_test: test error"}`,
+			status:    http.StatusInternalServerError,
+			wantCalls: []string{`StickerSet("song", "foo", "rating", "3")`},
+			err:       errTest,
+		},
+	} {
+		t.Run(label, func(t *testing.T) {
+			mpd := &mpdStickers{t: t, err: tt.err}
+			h, err := api.NewStickersHandler(mpd, log.NewTestLogger(t))
+			if err != nil {
+				t.Fatalf("failed to init Stickers: %v", err)
+			}
+			defer h.Close()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if got := w.Body.String(); got != tt.want || w.Result().StatusCode != tt.status {
+				t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", w.Result().StatusCode, got, tt.status, tt.want)
+			}
+			if !reflect.DeepEqual(mpd.calls, tt.wantCalls) {
+				t.Errorf("got calls %v; want %v", mpd.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestStickersHandlerScrobble(t *testing.T) {
+	m := &mpdStickers{t: t, stickerFind: func(*testing.T, string) (map[string]string, error) { return map[string]string{}, nil }}
+	h, err := api.NewStickersHandler(m, log.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("failed to init Stickers: %v", err)
+	}
+	defer h.Close()
+	if err := h.Update(context.TODO()); err != nil {
+		t.Fatalf("Update(ctx) = %v; want <nil>", err)
+	}
+	recieveMsg(h.Changed())
+	listenedAt := time.Date(2021, 1, 17, 1, 6, 27, 0, time.UTC)
+	for _, tt := range []struct {
+		file       string
+		stickerGet func(*testing.T, string, string) (string, error)
+		wantCalls  []string
+		want       string
+	}{
+		{
+			file:       "bar",
+			stickerGet: func(*testing.T, string, string) (string, error) { return "2", nil },
+			wantCalls:  []string{`StickerSet("song", "bar", "playcount", "3")`, `StickerSet("song", "bar", "lastplayed", "2021-01-17T01:06:27Z")`},
+			want:       `{"bar":{"play_count":3,"last_played":"2021-01-17T01:06:27Z"}}`,
+		},
+		{
+			file: "foo",
+			stickerGet: func(*testing.T, string, string) (string, error) {
+				return "", &mpd.CommandError{ID: mpd.ErrNoExist, Index: 0, Command: "sticker", Message: "no such sticker"}
+			},
+			wantCalls: []string{`StickerSet("song", "foo", "playcount", "1")`, `StickerSet("song", "foo", "lastplayed", "2021-01-17T01:06:27Z")`},
+			want:      `{"bar":{"play_count":3,"last_played":"2021-01-17T01:06:27Z"},"foo":{"play_count":1,"last_played":"2021-01-17T01:06:27Z"}}`,
+		},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			m.t = t
+			m.calls = nil
+			m.stickerGet = tt.stickerGet
+			if err := h.Scrobble(context.TODO(), listenedAt, map[string][]string{"file": {tt.file}}); err != nil {
+				t.Errorf("Scrobble(ctx, _, %q) = %v; want <nil>", tt.file, err)
+			}
+			if !reflect.DeepEqual(m.calls, tt.wantCalls) {
+				t.Errorf("Scrobble(ctx, _, %q) calls %v; want %v", tt.file, m.calls, tt.wantCalls)
+			}
+			select {
+			case file := <-h.SongChanged():
+				if file != tt.file {
+					t.Errorf("got SongChanged() = %q; want %q", file, tt.file)
+				}
+			default:
+				t.Errorf("SongChanged() is not notified")
+			}
+			if recieveMsg(h.Changed()) {
+				t.Errorf("Changed() is notified; want not notified")
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("ServeHTTP got %s; want %s", got, tt.want)
+			}
+		})
+	}
+	// sticker event caused by Scrobble reloads scrobbled songs only
+	m.stickerFind = nil
+	m.stickerList = func(t *testing.T, file string) (map[string]string, error) {
+		if file == "bar" {
+			// rated by other client
+			return map[string]string{"rating": "4", "playcount": "3", "lastplayed": "2021-01-17T01:06:27Z"}, nil
+		}
+		return map[string]string{"playcount": "1", "lastplayed": "2021-01-17T01:06:27Z"}, nil
+	}
+	if err := h.UpdateExternal(context.TODO()); err != nil {
+		t.Errorf("UpdateExternal(ctx) = %v; want <nil>", err)
+	}
+	select {
+	case file := <-h.SongChanged():
+		if file != "bar" {
+			t.Errorf("got SongChanged() = %q; want %q", file, "bar")
+		}
+	default:
+		t.Errorf("SongChanged() is not notified")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got, want := w.Body.String(), `{"bar":{"rating":4,"play_count":3,"last_played":"2021-01-17T01:06:27Z"},"foo":{"play_count":1,"last_played":"2021-01-17T01:06:27Z"}}`; got != want {
+		t.Errorf("ServeHTTP got %s; want %s", got, want)
+	}
+	m.stickerList = nil
+	m.stickerFind = func(*testing.T, string) (map[string]string, error) { return map[string]string{}, nil }
+	if err := h.UpdateExternal(context.TODO()); err != nil {
+		t.Errorf("UpdateExternal(ctx) = %v; want <nil>", err)
+	}
+	if !recieveMsg(h.Changed()) {
+		t.Errorf("Changed() is not notified by external sticker changes")
+	}
+}
+
+func TestStickersHandlerUpdateExternal(t *testing.T) {
+	m := &mpdStickers{t: t, stickerFind: func(*testing.T, string) (map[string]string, error) { return map[string]string{}, nil }}
+	h, err := api.NewStickersHandler(m, log.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("failed to init Stickers: %v", err)
+	}
+	defer h.Close()
+	if err := h.Update(context.TODO()); err != nil {
+		t.Fatalf("Update(ctx) = %v; want <nil>", err)
+	}
+	recieveMsg(h.Changed())
+	// removing no rating does not cause sticker event
+	m.err = &mpd.CommandError{ID: mpd.ErrNoExist, Index: 0, Command: "sticker", Message: "no such sticker"}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"file":"foo","rating":0}`)))
+	// failed write does not cause sticker event
+	m.err = errTest
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"file":"foo","rating":3}`)))
+	m.stickerFind = func(_ *testing.T, name string) (map[string]string, error) {
+		if name == "rating" {
+			return map[string]string{"foo": "5"}, nil
+		}
+		return map[string]string{}, nil
+	}
+	if err := h.UpdateExternal(context.TODO()); err != nil {
+		t.Errorf("UpdateExternal(ctx) = %v; want <nil>", err)
+	}
+	if !recieveMsg(h.Changed()) {
+		t.Errorf("Changed() is not notified by external sticker changes")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got, want := w.Body.String(), `{"foo":{"rating":5}}`; got != want {
+		t.Errorf("ServeHTTP got %s; want %s", got, want)
+	}
+}
+
+type mpdStickers struct {
+	t           *testing.T
+	stickerFind func(*testing.T, string) (map[string]string, error)
+	stickerGet  func(*testing.T, string, string) (string, error)
+	stickerList func(*testing.T, string) (map[string]string, error)
+	calls       []string
+	err         error
+}
+
+func (m *mpdStickers) StickerFind(ctx context.Context, typ, uri, name string) (map[string]string, error) {
+	m.t.Helper()
+	if m.stickerFind == nil {
+		m.t.Fatal("no StickerFind mock function")
+	}
+	return m.stickerFind(m.t, name)
+}
+
+func (m *mpdStickers) StickerGet(ctx context.Context, typ, uri, name string) (string, error) {
+	m.t.Helper()
+	if m.stickerGet == nil {
+		m.t.Fatal("no StickerGet mock function")
+	}
+	return m.stickerGet(m.t, uri, name)
+}
+
+func (m *mpdStickers) StickerList(ctx context.Context, typ, uri string) (map[string]string, error) {
+	m.t.Helper()
+	if m.stickerList == nil {
+		m.t.Fatal("no StickerList mock function")
+	}
+	return m.stickerList(m.t, uri)
+}
+
+func (m *mpdStickers) call(f string, args ...interface{}) error {
+	s := make([]string, len(args))
+	for i := range args {
+		s[i] = fmt.Sprintf("%#v", args[i])
+	}
+	m.calls = append(m.calls, f+"("+strings.Join(s, ", ")+")")
+	return m.err
+}
+
+func (m *mpdStickers) StickerSet(ctx context.Context, typ, uri, name, value string) error {
+	return m.call("StickerSet", typ, uri, name, value)
+}
+
+func (m *mpdStickers) StickerDelete(ctx context.Context, typ, uri, name string) error {
+	return m.call("StickerDelete", typ, uri, name)
+}
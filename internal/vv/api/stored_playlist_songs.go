@@ -0,0 +1,41 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// MPDStoredPlaylistSongs represents mpd api for stored playlist songs API.
+type MPDStoredPlaylistSongs interface {
+	ListPlaylistInfo(context.Context, string) ([]map[string][]string, error)
+}
+
+// StoredPlaylistSongsHandler provides song list in the stored playlist.
+type StoredPlaylistSongsHandler struct {
+	mpd       MPDStoredPlaylistSongs
+	songsHook func([]map[string][]string) []map[string][]string
+}
+
+// NewStoredPlaylistSongsHandler initilize StoredPlaylistSongsHandler with mpd connection.
+func NewStoredPlaylistSongsHandler(mpd MPDStoredPlaylistSongs, songsHook func([]map[string][]string) []map[string][]string) (*StoredPlaylistSongsHandler, error) {
+	return &StoredPlaylistSongsHandler{
+		mpd:       mpd,
+		songsHook: songsHook,
+	}, nil
+}
+
+// ServeHTTP responses song list in the stored playlist given by name query as json format.
+func (a *StoredPlaylistSongsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		writeHTTPError(w, http.StatusBadRequest, errors.New("requires name query"))
+		return
+	}
+	l, err := a.mpd.ListPlaylistInfo(r.Context(), name)
+	if err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeHTTPJSON(w, a.songsHook(l))
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/meiraka/vv/internal/mpd"
+)
+
+type httpStoredPlaylist struct {
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+type httpStoredPlaylistRequest struct {
+	Name   string   `json:"name"`
+	Save   bool     `json:"save,omitempty"`
+	Add    []string `json:"add,omitempty"`
+	Delete *int     `json:"delete,omitempty"`
+	Move   *[2]int  `json:"move,omitempty"`
+	Load   bool     `json:"load,omitempty"`
+	Rename *string  `json:"rename,omitempty"`
+	Remove bool     `json:"remove,omitempty"`
+}
+
+// MPDStoredPlaylists represents mpd api for stored playlists API.
+type MPDStoredPlaylists interface {
+	ListPlaylists(context.Context) ([]map[string]string, error)
+	Save(context.Context, string) error
+	Load(context.Context, string) error
+	PlaylistAdd(context.Context, string, string) error
+	PlaylistDelete(context.Context, string, int) error
+	PlaylistMove(context.Context, string, int, int) error
+	Rename(context.Context, string, string) error
+	Rm(context.Context, string) error
+}
+
+// StoredPlaylistsHandler provides stored playlist list and management api.
+type StoredPlaylistsHandler struct {
+	mpd    MPDStoredPlaylists
+	cache  *cache
+	logger Logger
+}
+
+// NewStoredPlaylistsHandler initilize stored playlist cache with mpd connection.
+func NewStoredPlaylistsHandler(mpd MPDStoredPlaylists, logger Logger) (*StoredPlaylistsHandler, error) {
+	c, err := newCache(map[string]*httpStoredPlaylist{})
+	if err != nil {
+		return nil, err
+	}
+	return &StoredPlaylistsHandler{
+		mpd:    mpd,
+		cache:  c,
+		logger: logger,
+	}, nil
+}
+
+// Update updates stored playlist list.
+func (a *StoredPlaylistsHandler) Update(ctx context.Context) error {
+	ret := map[string]*httpStoredPlaylist{}
+	ms, err := a.mpd.ListPlaylists(ctx)
+	if err != nil {
+		// skip command error to support mpd without playlist_directory
+		var perr *mpd.CommandError
+		if errors.As(err, &perr) {
+			a.cache.SetIfModified(ret)
+			a.logger.Debugf("vv/api: stored playlists: %v", err)
+			return nil
+		}
+		return err
+	}
+	for _, m := range ms {
+		ret[m["playlist"]] = &httpStoredPlaylist{
+			LastModified: m["Last-Modified"],
+		}
+	}
+	_, err = a.cache.SetIfModified(ret)
+	return err
+}
+
+// ServeHTTP responses stored playlist list as json format.
+func (a *StoredPlaylistsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		a.cache.ServeHTTP(w, r)
+		return
+	}
+	var req httpStoredPlaylistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Name == "" {
+		writeHTTPError(w, http.StatusBadRequest, errors.New("playlist name is empty"))
+		return
+	}
+	if req.Rename != nil && *req.Rename == "" {
+		writeHTTPError(w, http.StatusBadRequest, errors.New("new playlist name is empty"))
+		return
+	}
+	ctx := r.Context()
+	now := time.Now().UTC()
+	changed := false
+	if req.Save {
+		if err := a.mpd.Save(ctx, req.Name); err != nil {
+			writeHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+		changed = true
+	}
+	for _, uri := range req.Add {
+		if err := a.mpd.PlaylistAdd(ctx, req.Name, uri); err != nil {
+			writeHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+		changed = true
+	}
+	if req.Delete != nil {
+		if err := a.mpd.PlaylistDelete(ctx, req.Name, *req.Delete); err != nil {
+			writeHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+		changed = true
+	}
+	if req.Move != nil {
+		if err := a.mpd.PlaylistMove(ctx, req.Name, req.Move[0], req.Move[1]); err != nil {
+			writeHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+		changed = true
+	}
+	if req.Load {
+		if err := a.mpd.Load(ctx, req.Name); err != nil {
+			writeHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+		changed = true
+	}
+	if req.Rename != nil {
+		if err := a.mpd.Rename(ctx, req.Name, *req.Rename); err != nil {
+			writeHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+		changed = true
+	}
+	if req.Remove {
+		if err := a.mpd.Rm(ctx, req.Name); err != nil {
+			writeHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+		changed = true
+	}
+	if changed {
+		r = setUpdateTime(r, now)
+	}
+	r.Method = http.MethodGet
+	a.cache.ServeHTTP(w, r)
+}
+
+// Changed returns stored playlist list update event chan.
+func (a *StoredPlaylistsHandler) Changed() <-chan struct{} {
+	return a.cache.Changed()
+}
+
+// Close closes update event chan.
+func (a *StoredPlaylistsHandler) Close() {
+	a.cache.Close()
+}
@@ -0,0 +1,244 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/meiraka/vv/internal/log"
+	"github.com/meiraka/vv/internal/mpd"
+	"github.com/meiraka/vv/internal/vv/api"
+)
+
+func TestStoredPlaylistsHandlerGET(t *testing.T) {
+	for label, tt := range map[string][]struct {
+		label         string
+		listPlaylists func(*testing.T) ([]map[string]string, error)
+		err           error
+		want          string
+		changed       bool
+	}{
+		"ok": {{
+			label:         "empty",
+			listPlaylists: func(*testing.T) ([]map[string]string, error) { return []map[string]string{}, nil },
+			want:          "{}",
+		}, {
+			label: "some data",
+			listPlaylists: func(*testing.T) ([]map[string]string, error) {
+				return []map[string]string{
+					{"playlist": "foo", "Last-Modified": "2021-01-17T01:06:27Z"},
+					{"playlist": "bar", "Last-Modified": "2021-01-18T01:06:27Z"},
+				}, nil
+			},
+			want:    `{"bar":{"last_modified":"2021-01-18T01:06:27Z"},"foo":{"last_modified":"2021-01-17T01:06:27Z"}}`,
+			changed: true,
+		}, {
+			label:         "remove",
+			listPlaylists: func(*testing.T) ([]map[string]string, error) { return []map[string]string{}, nil },
+			want:          "{}",
+			changed:       true,
+		}},
+		"error/network": {{
+			label: "prepare data",
+			listPlaylists: func(*testing.T) ([]map[string]string, error) {
+				return []map[string]string{{"playlist": "foo", "Last-Modified": "2021-01-17T01:06:27Z"}}, nil
+			},
+			want:    `{"foo":{"last_modified":"2021-01-17T01:06:27Z"}}`,
+			changed: true,
+		}, {
+			label:         "error",
+			listPlaylists: func(*testing.T) ([]map[string]string, error) { return nil, errTest },
+			err:           errTest,
+			want:          `{"foo":{"last_modified":"2021-01-17T01:06:27Z"}}`,
+		}},
+		"error/mpd": {{
+			label: "prepare data",
+			listPlaylists: func(*testing.T) ([]map[string]string, error) {
+				return []map[string]string{{"playlist": "foo", "Last-Modified": "2021-01-17T01:06:27Z"}}, nil
+			},
+			want:    `{"foo":{"last_modified":"2021-01-17T01:06:27Z"}}`,
+			changed: true,
+		}, {
+			label: "no playlist directory",
+			listPlaylists: func(*testing.T) ([]map[string]string, error) {
+				return nil, &mpd.CommandError{ID: 52, Index: 0, Command: "listplaylists", Message: "No playlist directory"}
+			},
+			want:    "{}",
+			changed: true,
+		}},
+	} {
+		t.Run(label, func(t *testing.T) {
+			mpd := &mpdStoredPlaylists{t: t}
+			h, err := api.NewStoredPlaylistsHandler(mpd, log.NewTestLogger(t))
+			if err != nil {
+				t.Fatalf("failed to init StoredPlaylists: %v", err)
+			}
+			for i := range tt {
+				t.Run(tt[i].label, func(t *testing.T) {
+					mpd.t = t
+					mpd.listPlaylists = tt[i].listPlaylists
+					if err := h.Update(context.TODO()); !errors.Is(err, tt[i].err) {
+						t.Errorf("Update(ctx) = %v; want %v", err, tt[i].err)
+					}
+					r := httptest.NewRequest(http.MethodGet, "/", nil)
+					w := httptest.NewRecorder()
+					h.ServeHTTP(w, r)
+					if status, got := w.Result().StatusCode, w.Body.String(); status != http.StatusOK || got != tt[i].want {
+						t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", status, got, http.StatusOK, tt[i].want)
+					}
+					if changed := recieveMsg(h.Changed()); changed != tt[i].changed {
+						t.Errorf("changed = %v; want %v", changed, tt[i].changed)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestStoredPlaylistsHandlerPOST(t *testing.T) {
+	for label, tt := range map[string]struct {
+		body      string
+		want      string
+		status    int
+		wantCalls []string
+		err       error
+	}{
+		"error/invalid json": {
+			body:   `invalid json`,
+			want:   `{"error":"invalid character 'i' looking for beginning of value"}`,
+			status: http.StatusBadRequest,
+		},
+		"error/no name": {
+			body:   `{"save":true}`,
+			want:   `{"error":"playlist name is empty"}`,
+			status: http.StatusBadRequest,
+		},
+		"error/empty rename": {
+			body:   `{"name":"foo","rename":""}`,
+			want:   `{"error":"new playlist name is empty"}`,
+			status: http.StatusBadRequest,
+		},
+		"ok/save": {
+			body:      `{"name":"foo","save":true}`,
+			want:      `{}`,
+			status:    http.StatusAccepted,
+			wantCalls: []string{`Save("foo")`},
+		},
+		"ok/add": {
+			body:      `{"name":"foo","add":["bar.flac","baz.flac"]}`,
+			want:      `{}`,
+			status:    http.StatusAccepted,
+			wantCalls: []string{`PlaylistAdd("foo", "bar.flac")`, `PlaylistAdd("foo", "baz.flac")`},
+		},
+		"ok/delete": {
+			body:      `{"name":"foo","delete":1}`,
+			want:      `{}`,
+			status:    http.StatusAccepted,
+			wantCalls: []string{`PlaylistDelete("foo", 1)`},
+		},
+		"ok/move": {
+			body:      `{"name":"foo","move":[1,2]}`,
+			want:      `{}`,
+			status:    http.StatusAccepted,
+			wantCalls: []string{`PlaylistMove("foo", 1, 2)`},
+		},
+		"ok/load": {
+			body:      `{"name":"foo","load":true}`,
+			want:      `{}`,
+			status:    http.StatusAccepted,
+			wantCalls: []string{`Load("foo")`},
+		},
+		"ok/rename": {
+			body:      `{"name":"foo","rename":"bar"}`,
+			want:      `{}`,
+			status:    http.StatusAccepted,
+			wantCalls: []string{`Rename("foo", "bar")`},
+		},
+		"ok/remove": {
+			body:      `{"name":"foo","remove":true}`,
+			want:      `{}`,
+			status:    http.StatusAccepted,
+			wantCalls: []string{`Rm("foo")`},
+		},
+		"ok/nop": {
+			body:   `{"name":"foo"}`,
+			want:   `{}`,
+			status: http.StatusOK,
+		},
+		"error/save": {
+			body:      `{"name":"foo","save":true,"load":true}`,
+			want:      `{"error":"api_test: test error"}`,
+			status:    http.StatusInternalServerError,
+			wantCalls: []string{`Save("foo")`},
+			err:       errTest,
+		},
+	} {
+		t.Run(label, func(t *testing.T) {
+			mpd := &mpdStoredPlaylists{t: t, err: tt.err}
+			h, err := api.NewStoredPlaylistsHandler(mpd, log.NewTestLogger(t))
+			if err != nil {
+				t.Fatalf("failed to init StoredPlaylists: %v", err)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if got := w.Body.String(); got != tt.want || w.Result().StatusCode != tt.status {
+				t.Errorf("ServeHTTP got\n%d %s; want\n%d %s", w.Result().StatusCode, got, tt.status, tt.want)
+			}
+			if !reflect.DeepEqual(mpd.calls, tt.wantCalls) {
+				t.Errorf("got calls %v; want %v", mpd.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+type mpdStoredPlaylists struct {
+	t             *testing.T
+	listPlaylists func(*testing.T) ([]map[string]string, error)
+	calls         []string
+	err           error
+}
+
+func (m *mpdStoredPlaylists) ListPlaylists(context.Context) ([]map[string]string, error) {
+	m.t.Helper()
+	if m.listPlaylists == nil {
+		m.t.Fatal("no ListPlaylists mock function")
+	}
+	return m.listPlaylists(m.t)
+}
+
+func (m *mpdStoredPlaylists) call(f string, args ...interface{}) error {
+	s := make([]string, len(args))
+	for i := range args {
+		s[i] = fmt.Sprintf("%#v", args[i])
+	}
+	m.calls = append(m.calls, f+"("+strings.Join(s, ", ")+")")
+	return m.err
+}
+
+func (m *mpdStoredPlaylists) Save(ctx context.Context, name string) error {
+	return m.call("Save", name)
+}
+func (m *mpdStoredPlaylists) Load(ctx context.Context, name string) error {
+	return m.call("Load", name)
+}
+func (m *mpdStoredPlaylists) PlaylistAdd(ctx context.Context, name, uri string) error {
+	return m.call("PlaylistAdd", name, uri)
+}
+func (m *mpdStoredPlaylists) PlaylistDelete(ctx context.Context, name string, pos int) error {
+	return m.call("PlaylistDelete", name, pos)
+}
+func (m *mpdStoredPlaylists) PlaylistMove(ctx context.Context, name string, from, to int) error {
+	return m.call("PlaylistMove", name, from, to)
+}
+func (m *mpdStoredPlaylists) Rename(ctx context.Context, name, newName string) error {
+	return m.call("Rename", name, newName)
+}
+func (m *mpdStoredPlaylists) Rm(ctx context.Context, name string) error {
+	return m.call("Rm", name)
+}
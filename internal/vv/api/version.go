@@ -1,9 +1,12 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"runtime"
+
+	"github.com/meiraka/vv/internal/mpd"
 )
 
 var goVersion = fmt.Sprintf("%s %s %s", runtime.Version(), runtime.GOOS, runtime.GOARCH)
@@ -12,6 +15,8 @@ type httpVersion struct {
 	App string `json:"app"`
 	Go  string `json:"go"`
 	MPD string `json:"mpd"`
+	// MPDError describes mpd authentication error.
+	MPDError string `json:"mpd_error,omitempty"`
 }
 
 type VersionHandler struct {
@@ -46,6 +51,20 @@ func (a *VersionHandler) Update() error {
 	return err
 }
 
+// UpdateError sets mpd authentication error to version info.
+// UpdateError ignores errors except mpd.ErrPassword and mpd.ErrPermission.
+func (a *VersionHandler) UpdateError(err error) error {
+	if !errors.Is(err, mpd.ErrPassword) && !errors.Is(err, mpd.ErrPermission) {
+		return nil
+	}
+	mpdVersion := a.mpd.Version()
+	if len(mpdVersion) == 0 {
+		mpdVersion = "unknown"
+	}
+	_, err = a.cache.SetIfModified(&httpVersion{App: a.version, Go: goVersion, MPD: mpdVersion, MPDError: err.Error()})
+	return err
+}
+
 func (a *VersionHandler) UpdateNoMPD() error {
 	_, err := a.cache.SetIfModified(&httpVersion{App: a.version, Go: goVersion})
 	return err
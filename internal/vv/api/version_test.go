@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"testing"
 
+	"github.com/meiraka/vv/internal/mpd"
 	"github.com/meiraka/vv/internal/vv/api"
 )
 
@@ -20,6 +21,7 @@ func TestVersionHandlerGET(t *testing.T) {
 		want    string
 		changed bool
 		update  string
+		mpdErr  error
 	}{
 		"update": {
 			version: func() string { return "foobar" },
@@ -33,6 +35,19 @@ func TestVersionHandlerGET(t *testing.T) {
 			changed: true,
 			update:  "Update",
 		},
+		"update error/permission": {
+			version: func() string { return "foobar" },
+			mpdErr:  &mpd.CommandError{ID: mpd.ErrPermission, Index: 0, Command: "status", Message: "you don't have permission for \"status\""},
+			want:    fmt.Sprintf(`{"app":"%s","go":"%s","mpd":"foobar","mpd_error":"mpd: status: you don't have permission for \"status\""}`, appVersion, goVersion),
+			changed: true,
+			update:  "UpdateError",
+		},
+		"update error/other": {
+			mpdErr:  errTest,
+			want:    `{}`,
+			changed: false,
+			update:  "UpdateError",
+		},
 		"update no mpd": {
 			want:    fmt.Sprintf(`{"app":"%s","go":"%s","mpd":""}`, appVersion, goVersion),
 			changed: true,
@@ -53,6 +68,10 @@ func TestVersionHandlerGET(t *testing.T) {
 				if err := h.Update(); !errors.Is(err, tt.err) {
 					t.Errorf("handler.Update() = %v; want %v", err, tt.err)
 				}
+			case "UpdateError":
+				if err := h.UpdateError(tt.mpdErr); !errors.Is(err, tt.err) {
+					t.Errorf("handler.UpdateError(%v) = %v; want %v", tt.mpdErr, err, tt.err)
+				}
 			case "UpdateNoMPD":
 				if err := h.UpdateNoMPD(); !errors.Is(err, tt.err) {
 					t.Errorf("handler.UpdateNoMPD() = %v; want %v", err, tt.err)
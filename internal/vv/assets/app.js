@@ -395,8 +395,28 @@ class Songs {
     }
 }
 
+let _partition = "";  // mpd partition of this session; empty for default
+try {
+    _partition = sessionStorage.getItem("partition") || "";
+} catch (_) { /* private browsing */ }
 const _requests = {};
 class HTTP {
+    static url(path) {
+        if (_partition === "") {
+            return path;
+        }
+        return `${path}${path.includes("?") ? "&" : "?"}partition=${encodeURIComponent(_partition)}`;
+    }
+    static setPartition(name) {
+        try {
+            sessionStorage.setItem("partition", name);
+            // cached current song and playlist are partition specific
+            for (const key of ["current", "current_last_modified", "playlist", "playlist_last_modified"]) {
+                localStorage.removeItem(key);
+            }
+        } catch (_) { /* private browsing */ }
+        location.reload();
+    }
     static abortAll(options) {
         const opts = options || {};
         for (const key in _requests) {
@@ -432,6 +452,10 @@ class HTTP {
                 return;
             }
             // error handling
+            if (xhr.status === 404 && _partition !== "" && xhr.response && xhr.response.error === `api: partition not found: ${_partition}`) {
+                HTTP.setPartition("");  // removed by other client
+                return;
+            }
             if (xhr.status !== 0) {
                 UINotification.show("network", xhr.statusText);
             }
@@ -451,7 +475,7 @@ class HTTP {
                 UINotification.show("network", "timeout");
             }
         };
-        xhr.open("GET", path, true);
+        xhr.open("GET", HTTP.url(path), true);
         if (etag !== "") {
             xhr.setRequestHeader("If-None-Match", etag);
         } else {
@@ -493,7 +517,7 @@ class HTTP {
             }
             UINotification.show("network", "error");
         };
-        xhr.open("POST", path, true);
+        xhr.open("POST", HTTP.url(path), true);
         xhr.setRequestHeader("Content-Type", "application/json");
         xhr.send(JSON.stringify(obj));
     }
@@ -516,7 +540,7 @@ class MPDWatcher extends PubSub {
             lastConnection = (new Date()).getTime();
             connected = false;
             const wsp = document.location.protocol === "https:" ? "wss:" : "ws:";
-            const uri = `${wsp}//${location.host}/api/music`;
+            const uri = `${wsp}//${location.host}${HTTP.url("/api/music")}`;
             if (ws !== null) {
                 ws.onclose = () => { };
                 ws.close();
@@ -667,6 +691,7 @@ class MPDClient extends PubSub {
         this.outputs = [];
         this.storage = {};
         this.neighbors = {};
+        this.partitions = {};
         this.stats = {};
         this.last_modified = {};
         this.etag = {};
@@ -703,6 +728,7 @@ class MPDClient extends PubSub {
         mpdWatcher.addEventListener("/api/music/images", () => { this._fetch("/api/music/images", "images"); });
         mpdWatcher.addEventListener("/api/music/storage", () => { this._fetch("/api/music/storage", "storage"); });
         mpdWatcher.addEventListener("/api/music/storage/neighbors", () => { this._fetch("/api/music/storage/neighbors", "neighbors"); });
+        mpdWatcher.addEventListener("/api/music/partitions", () => { this._fetch("/api/music/partitions", "partitions"); });
         mpdWatcher.addEventListener("/api/version", () => { this._fetch("/api/version", "version"); });
     }
     rescanLibrary() {
@@ -843,6 +869,7 @@ class MPDClient extends PubSub {
         this._fetch("/api/music/images", "images");
         this._fetch("/api/music/storage", "storage");
         this._fetch("/api/music/storage/neighbors", "neighbors");
+        this._fetch("/api/music/partitions", "partitions");
     }
     _fetch(target, store) {
         HTTP.get(
@@ -1490,14 +1517,14 @@ class UIBackground {
         document.body.classList.remove("unload");
         const img = new ImageFader(this.preferences, document.getElementById("background-image"), document.getElementById("background-image2"));
         if (this.mpd.current !== null && this.mpd.current.cover && this.mpd.current.cover[0]) {
-            this.update_color(this.mpd.current.cover[0]);
+            this.update_color(this.mpd.current);
             img.show(this.mpd.current.cover[0]);
         } else {
             img.show(this.preferences.nocover());
         }
         this.mpd.addEventListener("current", () => {
             if (this.mpd.current !== null && this.mpd.current.cover && this.mpd.current.cover[0]) {
-                this.update_color(this.mpd.current.cover[0]);
+                this.update_color(this.mpd.current);
                 img.show(this.mpd.current.cover[0]);
             } else {
                 img.show(this.preferences.nocover());
@@ -1568,7 +1595,18 @@ class UIBackground {
         e1.style.filter = `blur(${this.preferences.appearance.background_image_blur})`;
         e2.style.filter = `blur(${this.preferences.appearance.background_image_blur})`;
     }
-    update_color(path) {
+    update_color(song) {
+        if (song.cover_palette && song.cover_palette.length !== 0) {
+            // uses server side dominant color in the same scale as image sampling
+            const c = song.cover_palette[0];
+            const r = parseInt(c.slice(1, 3), 16);
+            const g = parseInt(c.slice(3, 5), 16);
+            const b = parseInt(c.slice(5, 7), 16);
+            this.rgbg = { r: parseInt(r * 3 / 4, 10), g: parseInt(g * 3 / 4, 10), b: parseInt(b * 3 / 4, 10), gray: (r + g + b) / 4 };
+            this.update_theme();
+            return;
+        }
+        const path = song.cover[0];
         const img = new Image();
         img.onload = () => {
             const canvas = document.createElement("canvas");
@@ -1936,6 +1974,21 @@ class UIListView extends PubSub {
             }
             smallCover.alt = `Cover art: ${Song.get(song, "Album")} ` + `by ${Song.get(song, "AlbumArtist")}`;
             mediumCover.alt = smallCover.alt;
+        } else if (style === "plain") {
+            const img = c.querySelector(".plain-artist-image");
+            if (key === "AlbumArtist" && song.artist_image && song.artist_image.length !== 0) {
+                const imgsize = parseInt(40 * window.devicePixelRatio, 10);
+                const s = (song.artist_image[0].lastIndexOf("?") == -1) ? "?" : "&";
+                img.src = `${song.artist_image[0]}${s}width=${imgsize}&height=${imgsize}`;
+                img.width = imgsize;
+                img.height = imgsize;
+                img.alt = `Artist image: ${v}`;
+                img.classList.remove("hide");
+            } else {
+                img.removeAttribute("src");
+                img.alt = "";
+                img.classList.add("hide");
+            }
         }
         return document.importNode(c, true);
     }
@@ -2174,6 +2227,7 @@ class UISystemWindow {
         this.mpd.addEventListener("images", (e) => { this.onImages(e); });
         this.mpd.addEventListener("stats", (e) => { this.onStats(e); });
         this.mpd.addEventListener("outputs", (e) => { this.onOutputs(e); });
+        this.mpd.addEventListener("partitions", (e) => { this.onPartitions(e); });
         this.mpd.addEventListener("storage", (e) => { this.onStorage(e); });
         this.preferences = preferences;
         this.preferences.addEventListener("appearance", () => { this.onPreferencesAppearance(); });
@@ -2283,6 +2337,26 @@ class UISystemWindow {
         }
         ul.appendChild(newul);
     }
+    onPartitions() {
+        const names = Object.keys(this.mpd.partitions).sort();
+        const select = document.getElementById("outputs-partition");
+        while (select.lastChild) {
+            select.removeChild(select.lastChild);
+        }
+        for (const name of names) {
+            const o = document.createElement("option");
+            o.value = name;
+            o.textContent = name;
+            o.selected = this.mpd.partitions[name].current;
+            select.appendChild(o);
+        }
+        if (names.length < 2) {
+            document.getElementById("outputs-partition-box").classList.add("hide");
+        } else {
+            document.getElementById("outputs-partition-box").classList.remove("hide");
+        }
+        this.onOutputs();
+    }
     onOutputs() {
         const ul = document.getElementById("devices");
         while (ul.lastChild) {
@@ -2300,8 +2374,12 @@ class UISystemWindow {
                 const c = document.querySelector("#device-template").content;
                 const e = c.querySelector("li");
                 e.querySelector(".name").textContent = o.name;
-                if (o.plugin) {
-                    e.querySelector(".plugin").textContent = o.plugin;
+                let plugin = o.plugin || "";
+                if (o.partition && this.mpd.partitions[o.partition] && !this.mpd.partitions[o.partition].current) {
+                    plugin = plugin ? `${plugin} (${o.partition})` : o.partition;
+                }
+                if (plugin) {
+                    e.querySelector(".plugin").textContent = plugin;
                     e.querySelector(".plugin").classList.remove("hide");
                 } else {
                     e.querySelector(".plugin").classList.add("hide");
@@ -2459,6 +2537,9 @@ class UISystemWindow {
             this.mpd.raiseEvent("images");
         });
 
+        document.getElementById("outputs-partition").addEventListener("change", (e) => {
+            HTTP.setPartition(e.currentTarget.value);
+        });
         document.getElementById("outputs-replay-gain").addEventListener("change", (e) => {
             HTTP.post("/api/music", { replay_gain: e.currentTarget.value });
         });
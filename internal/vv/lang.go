@@ -85,6 +85,7 @@ var langData = map[language.Tag]map[string]string{
 		"StoragePath":                         "ストレージ名",
 		"StorageURI":                          "URI",
 		"Options":                             "オプション",
+		"Partition":                           "パーティション",
 		"ReplayGain":                          "リプレイゲイン",
 		"ReplayGainOff":                       "オフ",
 		"ReplayGainTrack":                     "トラック",
@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
@@ -15,11 +18,14 @@ import (
 	"github.com/meiraka/vv/internal/vv"
 	"github.com/meiraka/vv/internal/vv/api"
 	"github.com/meiraka/vv/internal/vv/api/images"
+	"github.com/meiraka/vv/internal/vv/api/lyrics"
+	"github.com/meiraka/vv/internal/vv/api/scrobble"
 	"github.com/meiraka/vv/internal/vv/assets"
 )
 
 const (
-	defaultConfigDir = "/etc/xdg/vv"
+	defaultConfigDir  = "/etc/xdg/vv"
+	defaultServerName = "default"
 )
 
 var version = "v0.12.0+"
@@ -36,6 +42,17 @@ func configDirs() []string {
 	return []string{filepath.Join(dir, "vv"), defaultConfigDir}
 }
 
+// dialError adds config hint to mpd authentication error.
+func dialError(err error) error {
+	switch {
+	case errors.Is(err, mpd.ErrPassword):
+		return fmt.Errorf("%w (check mpd.password or mpd.password_file)", err)
+	case errors.Is(err, mpd.ErrPermission):
+		return fmt.Errorf("%w (set mpd.password or mpd.password_file)", err)
+	}
+	return err
+}
+
 func v2() {
 	ctx := context.TODO()
 	logger := log.New(os.Stderr)
@@ -45,52 +62,207 @@ func v2() {
 	if err != nil {
 		logger.Fatalf("failed to load config: %v", err)
 	}
+	if err := config.Validate(); err != nil {
+		logger.Fatalf("invalid config: %v", err)
+	}
 	if lastModified.Before(configDate) {
 		lastModified = configDate
 	}
 	if config.debug {
 		logger = log.NewDebugLogger(os.Stderr)
 	}
-	client, err := mpd.Dial(config.MPD.Network, config.MPD.Addr, &mpd.ClientOptions{
-		BinaryLimit:          int(config.MPD.BinaryLimit),
+	var scrobblers []api.Scrobbler
+	if lb := config.Scrobble.ListenBrainz; len(lb.URL) != 0 || len(lb.Token) != 0 {
+		q, err := scrobble.NewQueue(scrobble.NewListenBrainz(lb.URL, lb.Token), filepath.Join(config.Server.CacheDirectory, "scrobble", "listenbrainz"), time.Minute, logger)
+		if err != nil {
+			logger.Fatalf("failed to initialize scrobbler: %v", err)
+		}
+		scrobblers = append(scrobblers, q)
+		defer q.Close()
+	}
+	m := http.NewServeMux()
+	mainServer := &ConfigServer{
+		Network:        config.MPD.Network,
+		Addr:           config.MPD.Addr,
+		MusicDirectory: config.MPD.MusicDirectory,
+		BinaryLimit:    config.MPD.BinaryLimit,
+		Password:       config.MPD.Password,
+		Partition:      config.MPD.Partition,
+		PoolSize:       config.MPD.PoolSize,
+	}
+	mpdConf, _ := mpd.ParseConfig(config.MPD.Conf)
+	defaultServer, err := newServer(ctx, "", mainServer, mpdConf, scrobblers, config, m, logger)
+	if err != nil {
+		logger.Fatalf("failed to initialize server: %v", err)
+	}
+	root, err := vv.New(&vv.Config{
+		Tree:         toTree(config.Playlist.Tree),
+		TreeOrder:    config.Playlist.TreeOrder,
+		Local:        config.debug,
+		LastModified: lastModified,
+		Logger:       logger,
+	})
+	if err != nil {
+		logger.Fatalf("failed to initialize root handler: %v", err)
+	}
+	assets, err := assets.NewHandler(&assets.Config{
+		Local:        config.debug,
+		LastModified: lastModified,
+		Logger:       logger,
+	})
+	if err != nil {
+		logger.Fatalf("failed to initialize assets handler: %v", err)
+	}
+	names := make([]string, 0, len(config.Servers))
+	for name := range config.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	servers := []*server{defaultServer}
+	for _, name := range names {
+		srv, err := newServer(ctx, name, config.Servers[name], nil, scrobblers, config, m, logger)
+		if err != nil {
+			logger.Fatalf("failed to initialize server %s: %v", name, err)
+		}
+		servers = append(servers, srv)
+	}
+	serversHandler, err := api.NewServersHandler(defaultServerName, names)
+	if err != nil {
+		logger.Fatalf("failed to initialize servers handler: %v", err)
+	}
+	defer serversHandler.Close()
+	m.Handle("/api/servers", serversHandler)
+	m.Handle("/", root)
+	m.Handle("/assets/", assets)
+
+	s := http.Server{
+		Handler: m,
+		Addr:    config.Server.Addr,
+	}
+	for _, srv := range servers {
+		s.RegisterOnShutdown(srv.api.Stop)
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.ListenAndServe()
+	}()
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case <-sc:
+	case err := <-errs:
+		if err != http.ErrServerClosed {
+			logger.Fatalf("server stopped with error: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		logger.Printf("failed to stop http server: %v", err)
+	}
+	for _, srv := range servers {
+		srv.Close(ctx, logger)
+	}
+}
+
+// server is mpd connection and api handler for mpd server.
+type server struct {
+	name    string
+	client  *mpd.Client
+	watcher *mpd.Watcher
+	api     *api.Handler
+	closers []interface{ Close() error }
+}
+
+// dialServer connects to mpd server for api handler.
+// covers enables connections to download cover art from mpd.
+func dialServer(ctx context.Context, sc *ConfigServer, config *Config, covers bool) (*mpd.Client, *mpd.Watcher, error) {
+	covers = covers && config.Server.Cover.Remote
+	binaryPoolSize := 0
+	if covers {
+		// keep playback commands responsive while downloading cover art
+		binaryPoolSize = max(1, config.Server.Cover.Workers)
+	}
+	client, err := mpd.Dial(sc.Network, sc.Addr, &mpd.ClientOptions{
+		BinaryLimit:          int(sc.BinaryLimit),
 		Timeout:              10 * time.Second,
 		HealthCheckInterval:  time.Second,
 		ReconnectionInterval: 5 * time.Second,
-		CacheCommandsResult:  config.Server.Cover.Remote,
+		CacheCommandsResult:  covers,
+		Password:             sc.Password,
+		Partition:            sc.Partition,
+		PoolSize:             sc.PoolSize,
+		BinaryPoolSize:       binaryPoolSize,
 	})
 	if err != nil {
-		logger.Fatalf("failed to dial mpd: %v", err)
+		return nil, nil, dialError(err)
 	}
-	watcher, err := mpd.NewWatcher(config.MPD.Network, config.MPD.Addr, &mpd.WatcherOptions{
+	watcher, err := mpd.NewWatcher(sc.Network, sc.Addr, &mpd.WatcherOptions{
 		Timeout:              10 * time.Second,
 		ReconnectionInterval: 5 * time.Second,
+		Password:             sc.Password,
+		Partition:            sc.Partition,
 	})
 	if err != nil {
-		logger.Fatalf("failed to dial mpd: %v", err)
+		client.Close(ctx)
+		return nil, nil, dialError(err)
+	}
+	return client, watcher, nil
+}
+
+// dialPartition returns function which connects to other partition of mpd server.
+func dialPartition(sc ConfigServer, config *Config) func(context.Context, string) (*mpd.Client, *mpd.Watcher, error) {
+	return func(ctx context.Context, partition string) (*mpd.Client, *mpd.Watcher, error) {
+		psc := sc
+		psc.Partition = partition
+		// cover art is served by api handler of sc
+		return dialServer(ctx, &psc, config, false)
+	}
+}
+
+// newServer connects to mpd server and initializes api handler for server
+// name; empty name means the main mpd server. The api handler and images
+// are served on m. mpdConf is the local mpd config of the server; nil if not
+// available.
+func newServer(ctx context.Context, name string, sc *ConfigServer, mpdConf *mpd.Config, scrobblers []api.Scrobbler, config *Config, m *http.ServeMux, logger *log.Logger) (_ *server, err error) {
+	prefix := "/api/"
+	cacheDir := config.Server.CacheDirectory
+	key := "mpd"
+	if len(name) != 0 {
+		prefix = api.ServerPath(name)
+		cacheDir = filepath.Join(config.Server.CacheDirectory, "servers", name)
+		key = "servers." + name
+	}
+	client, watcher, err := dialServer(ctx, sc, config, true)
+	if err != nil {
+		return nil, fmt.Errorf("dial mpd: %w", err)
 	}
+	srv := &server{name: name, client: client, watcher: watcher}
+	defer func() {
+		if err != nil {
+			srv.Close(ctx, logger)
+		}
+	}()
 	// get music dir from local mpd connection
-	if config.MPD.Network == "unix" && config.MPD.MusicDirectory == "" {
+	if sc.Network == "unix" && sc.MusicDirectory == "" {
 		if c, err := client.Config(ctx); err == nil {
 			if dir, ok := c["music_directory"]; ok && filepath.IsAbs(dir) {
-				config.MPD.MusicDirectory = dir
-				logger.Printf("apply mpd.music_directory from mpd connection: %s", dir)
+				sc.MusicDirectory = dir
+				logger.Printf("apply %s.music_directory from mpd connection: %s", key, dir)
 			}
 		}
 	}
-
 	// get music dir from local mpd config
-	mpdConf, _ := mpd.ParseConfig(config.MPD.Conf)
-	if config.MPD.MusicDirectory == "" {
-		if mpdConf != nil && filepath.IsAbs(config.MPD.Conf) {
-			config.MPD.MusicDirectory = mpdConf.MusicDirectory
-			logger.Printf("apply mpd.music_directory from %s: %s", config.MPD.Conf, mpdConf.MusicDirectory)
-		}
+	if sc.MusicDirectory == "" && mpdConf != nil && filepath.IsAbs(config.MPD.Conf) {
+		sc.MusicDirectory = mpdConf.MusicDirectory
+		logger.Printf("apply %s.music_directory from %s: %s", key, config.MPD.Conf, mpdConf.MusicDirectory)
 	}
 	proxy := map[string]string{}
 	if mpdConf != nil {
 		host := "localhost"
-		if config.MPD.Network == "tcp" {
-			h := strings.Split(config.MPD.Addr, ":")[0]
+		if sc.Network == "tcp" {
+			h := strings.Split(sc.Addr, ":")[0]
 			if len(h) != 0 {
 				host = h
 			}
@@ -101,99 +273,128 @@ func v2() {
 			}
 		}
 	}
-	m := http.NewServeMux()
-	covers := make([]api.ImageProvider, 0, 2)
+	coverCache := newCoverCacheOptions(config)
+	covers := make([]api.ImageProvider, 0, 4)
+	// user uploaded images take precedence over other providers
+	o, err := images.NewOverride(prefix+"music/images/override/", filepath.Join(cacheDir, "override"), coverCache)
+	if err != nil {
+		return nil, fmt.Errorf("initialize coverart: %w", err)
+	}
+	m.Handle(prefix+"music/images/override/", o)
+	covers = append(covers, o)
+	srv.closers = append(srv.closers, o)
 	if config.Server.Cover.Local {
-		if len(config.MPD.MusicDirectory) == 0 {
-			logger.Println("config.server.cover.local is disabled: mpd.music_directory is empty")
+		if len(sc.MusicDirectory) == 0 {
+			logger.Printf("config.server.cover.local is disabled: %s.music_directory is empty", key)
 		} else {
-			c, err := images.NewLocal("/api/music/images/local/", config.MPD.MusicDirectory, []string{"cover.jpg", "cover.jpeg", "cover.webp", "cover.png", "cover.gif", "cover.bmp"})
+			c, err := images.NewLocal(prefix+"music/images/local/", sc.MusicDirectory, config.Server.Cover.LocalFiles, config.Server.Cover.LocalFallback, filepath.Join(cacheDir, "local"), coverCache)
 			if err != nil {
-				logger.Fatalf("failed to initialize coverart: %v", err)
+				return nil, fmt.Errorf("initialize coverart: %w", err)
 			}
-			m.Handle("/api/music/images/local/", c)
+			c.Watch()
+			m.Handle(prefix+"music/images/local/", c)
 			covers = append(covers, c)
-
+			srv.closers = append(srv.closers, c)
 		}
 	}
 	if config.Server.Cover.Remote {
-		a, err := images.NewRemote("/api/music/images/albumart/", client, filepath.Join(config.Server.CacheDirectory, "albumart"))
+		a, err := images.NewRemote(prefix+"music/images/albumart/", client, filepath.Join(cacheDir, "albumart"), coverCache)
 		if err != nil {
-			logger.Fatalf("failed to initialize coverart: %v", err)
+			return nil, fmt.Errorf("initialize coverart: %w", err)
 		}
-		m.Handle("/api/music/images/albumart/", a)
+		m.Handle(prefix+"music/images/albumart/", a)
 		covers = append(covers, a)
-		defer a.Close()
-		e, err := images.NewEmbed("/api/music/images/embed/", client, filepath.Join(config.Server.CacheDirectory, "embed"))
+		srv.closers = append(srv.closers, a)
+		e, err := images.NewEmbed(prefix+"music/images/embed/", client, filepath.Join(cacheDir, "embed"), coverCache)
 		if err != nil {
-			logger.Fatalf("failed to initialize coverart: %v", err)
+			return nil, fmt.Errorf("initialize coverart: %w", err)
 		}
-		m.Handle("/api/music/images/embed/", e)
+		m.Handle(prefix+"music/images/embed/", e)
 		covers = append(covers, e)
-		defer e.Close()
+		srv.closers = append(srv.closers, e)
 	}
-	root, err := vv.New(&vv.Config{
-		Tree:         toTree(config.Playlist.Tree),
-		TreeOrder:    config.Playlist.TreeOrder,
-		Local:        config.debug,
-		LastModified: lastModified,
-		Logger:       logger,
-	})
-	if err != nil {
-		logger.Fatalf("failed to initialize root handler: %v", err)
+	var artistImages []api.ArtistImageProvider
+	if a, err := newArtistImages(prefix+"music/images/artist/", config, sc.MusicDirectory, filepath.Join(cacheDir, "artist")); err != nil {
+		return nil, fmt.Errorf("initialize artist images: %w", err)
+	} else if a != nil {
+		m.Handle(prefix+"music/images/artist/", a)
+		artistImages = append(artistImages, a)
 	}
-	assets, err := assets.NewHandler(&assets.Config{
-		Local:        config.debug,
-		LastModified: lastModified,
-		Logger:       logger,
-	})
-	if err != nil {
-		logger.Fatalf("failed to initialize assets handler: %v", err)
+	if srv.api, err = api.NewHandler(ctx, client, watcher, &api.Config{
+		AppVersion:      version,
+		AudioProxy:      proxy,
+		ImageProviders:  covers,
+		ImageWorkers:    config.Server.Cover.Workers,
+		ArtistImages:    artistImages,
+		LyricsProviders: newLyricsProviders(config, sc.MusicDirectory, client, logger),
+		Scrobblers:      scrobblers,
+		Logger:          logger,
+		CacheDirectory:  cacheDir,
+		ServerName:      name,
+		Partition:       sc.Partition,
+		DialPartition:   dialPartition(*sc, config),
+	}); err != nil {
+		return nil, fmt.Errorf("initialize api handler: %w", err)
 	}
-	api, err := api.NewHandler(ctx, client, watcher, &api.Config{
-		AppVersion:     version,
-		AudioProxy:     proxy,
-		ImageProviders: covers,
-		Logger:         logger,
-	})
-	if err != nil {
-		logger.Fatalf("failed to initialize api handler: %v", err)
+	m.Handle(prefix, srv.api)
+	return srv, nil
+}
+
+// newCoverCacheOptions returns cover image cache limits for each cover source.
+func newCoverCacheOptions(config *Config) *images.CacheOptions {
+	return &images.CacheOptions{
+		MaxSize: int64(config.Server.Cover.Cache.MaxSize),
+		MaxAge:  config.Server.Cover.Cache.MaxAge,
 	}
-	m.Handle("/", root)
-	m.Handle("/assets/", assets)
-	m.Handle("/api/", api)
+}
 
-	s := http.Server{
-		Handler: m,
-		Addr:    config.Server.Addr,
+// newArtistImages returns artist image provider; nil if disabled.
+func newArtistImages(httpPrefix string, config *Config, musicDirectory, cacheDir string) (*images.Artist, error) {
+	if !config.Server.Cover.Local {
+		musicDirectory = ""
 	}
-	s.RegisterOnShutdown(api.Stop)
-	errs := make(chan error, 1)
-	go func() {
-		errs <- s.ListenAndServe()
-	}()
-	sc := make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGTERM, syscall.SIGINT)
-	select {
-	case <-sc:
-	case err := <-errs:
-		if err != http.ErrServerClosed {
-			logger.Fatalf("server stopped with error: %v", err)
+	if len(musicDirectory) == 0 && len(config.Server.Cover.ArtistImages) == 0 {
+		return nil, nil
+	}
+	return images.NewArtist(httpPrefix, musicDirectory, config.Server.Cover.ArtistFiles, config.Server.Cover.ArtistImages, cacheDir, newCoverCacheOptions(config))
+}
+
+// newLyricsProviders returns lyrics providers for mpd server.
+func newLyricsProviders(config *Config, musicDirectory string, client *mpd.Client, logger *log.Logger) []api.LyricsProvider {
+	providers := make([]api.LyricsProvider, 0, 2)
+	if config.Server.Lyrics.Local {
+		if len(musicDirectory) == 0 {
+			logger.Println("config.server.lyrics.local is disabled: mpd.music_directory is empty")
+		} else if l, err := lyrics.NewLocal(musicDirectory, []string{".lrc", ".txt"}); err != nil {
+			logger.Printf("config.server.lyrics.local is disabled: %v", err)
+		} else {
+			providers = append(providers, l)
 		}
 	}
+	if config.Server.Lyrics.Remote {
+		providers = append(providers, lyrics.NewEmbed(client))
+	}
+	return providers
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := s.Shutdown(ctx); err != nil {
-		logger.Printf("failed to stop http server: %v", err)
+// Close closes mpd connections and background tasks.
+func (s *server) Close(ctx context.Context, logger *log.Logger) {
+	name := s.name
+	if len(name) == 0 {
+		name = "main"
 	}
-	if err := client.Close(ctx); err != nil {
-		logger.Printf("failed to close mpd connection(main): %v", err)
+	if err := s.client.Close(ctx); err != nil {
+		logger.Printf("failed to close mpd connection(%s): %v", name, err)
 	}
-	if err := watcher.Close(ctx); err != nil {
-		logger.Printf("failed to close mpd connection(event): %v", err)
+	if err := s.watcher.Close(ctx); err != nil {
+		logger.Printf("failed to close mpd connection(%s event): %v", name, err)
+	}
+	if s.api != nil {
+		if err := s.api.Shutdown(ctx); err != nil {
+			logger.Printf("failed to stop api background task(%s): %v", name, err)
+		}
 	}
-	if err := api.Shutdown(ctx); err != nil {
-		logger.Printf("failed to stop api background task: %v", err)
+	for i := range s.closers {
+		s.closers[i].Close()
 	}
 }